@@ -0,0 +1,298 @@
+// !!! Do not change this code !!!
+// The code is generated automatically by apigen tool
+// Inputs hash: 669958a91d20cc48fe9b3483fe2364f029f6178a34f760cab14a24327eb1b857
+package client
+
+import (
+	"bytes"
+	"context"
+	"encoding"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+type apiClient struct {
+	// BaseURL is prepended to the method URLs, e.g. http://localhost:8080
+	BaseURL string
+	// HTTPClient is used to send requests, http.DefaultClient if nil
+	HTTPClient *http.Client
+	// Auth sets credentials to requests to the methods marked with "auth": true
+	Auth func(r *http.Request)
+}
+
+// ApiError is the error response of the service with its HTTP status.
+type ApiError struct {
+	HTTPStatus int
+	Err        error
+}
+
+func (ae ApiError) Error() string {
+	return ae.Err.Error()
+}
+
+func (ae ApiError) Unwrap() error {
+	return ae.Err
+}
+
+// ParamError describes the invalid request param, it is ApiError.Err of 400 status.
+type ParamError struct {
+	Code   string            `json:"code"`             // stable error code, e.g. param_max
+	Field  string            `json:"field"`            // api name of the param, e.g. settings.region
+	Rule   string            `json:"rule"`             // violated rule, e.g. max
+	Params map[string]string `json:"params,omitempty"` // rule params, e.g. {"max": "128"}
+	Msg    string            `json:"message"`
+}
+
+func (e *ParamError) Error() string {
+	return e.Msg
+}
+
+// ParamErrors are all invalid params of the request to the methods marked with "allErrors": true.
+type ParamErrors []*ParamError
+
+func (e ParamErrors) Error() string {
+	msgs := make([]string, len(e))
+	for i, pe := range e {
+		msgs[i] = pe.Msg
+	}
+	return strings.Join(msgs, "; ")
+}
+
+func (e ParamErrors) Unwrap() []error {
+	errs := make([]error, len(e))
+	for i, pe := range e {
+		errs[i] = pe
+	}
+	return errs
+}
+
+// AccessError describes the missing role or scope of the caller, it is ApiError.Err of 403 status.
+type AccessError struct {
+	Code   string            // missing_role or missing_scope
+	Params map[string]string // required roles or scopes, e.g. {"roles": "admin|moderator"}
+	Msg    string
+}
+
+func (e *AccessError) Error() string {
+	return e.Msg
+}
+
+// problem is RFC 9457 problem details of the error response.
+type problem struct {
+	Detail string            `json:"detail"`
+	Code   string            `json:"code"`
+	Field  string            `json:"field"`
+	Rule   string            `json:"rule"`
+	Params map[string]string `json:"params"`
+	Errors ParamErrors       `json:"errors"`
+}
+
+// apiResponse is the response body of the service method.
+type apiResponse struct {
+	Response any    `json:"response"`
+	Error    string `json:"error"`
+}
+
+// apiErrorResponse is the response body of the failed request.
+type apiErrorResponse struct {
+	Error  string      `json:"error"`
+	Errors ParamErrors `json:"errors"`
+}
+
+// queryValue formats the query or path param value, by MarshalText if implemented.
+func queryValue(v any) string {
+	switch v := v.(type) {
+	case encoding.TextMarshaler:
+		if b, err := v.MarshalText(); err == nil {
+			return string(b)
+		}
+	case json.RawMessage:
+		return string(v)
+	}
+	return fmt.Sprint(v)
+}
+
+// do sends the request and decodes the response body of the success status to res if not nil.
+func (c *apiClient) do(ctx context.Context, method, path string, query url.Values, body any, header http.Header, auth bool, status int, res any) error {
+	u := strings.TrimSuffix(c.BaseURL, "/") + path
+	if len(query) > 0 {
+		u += "?" + query.Encode()
+	}
+	var (
+		reqBody     io.Reader
+		contentType string
+	)
+	switch body := body.(type) {
+	case nil:
+	default:
+		b, err := json.Marshal(body)
+		if err != nil {
+			return err
+		}
+		reqBody, contentType = bytes.NewReader(b), "application/json"
+	}
+	req, err := http.NewRequestWithContext(ctx, method, u, reqBody)
+	if err != nil {
+		return err
+	}
+	if contentType != "" {
+		req.Header.Set("content-type", contentType)
+	}
+	for k, vs := range header {
+		req.Header[k] = vs
+	}
+	req.Header.Set("accept", "application/json, application/problem+json")
+	if auth && c.Auth != nil {
+		c.Auth(req)
+	}
+	client := c.HTTPClient
+	if client == nil {
+		client = http.DefaultClient
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != status && resp.Header.Get("content-type") == "application/problem+json" {
+		var prob problem
+		if err := json.NewDecoder(resp.Body).Decode(&prob); err != nil {
+			return ApiError{HTTPStatus: resp.StatusCode, Err: errors.New(http.StatusText(resp.StatusCode))}
+		}
+		if len(prob.Errors) > 0 {
+			return ApiError{HTTPStatus: resp.StatusCode, Err: prob.Errors}
+		}
+		if prob.Code == "missing_role" || prob.Code == "missing_scope" {
+			return ApiError{HTTPStatus: resp.StatusCode, Err: &AccessError{Code: prob.Code, Params: prob.Params, Msg: prob.Detail}}
+		}
+		if prob.Field != "" {
+			pe := &ParamError{Code: prob.Code, Field: prob.Field, Rule: prob.Rule, Params: prob.Params, Msg: prob.Detail}
+			return ApiError{HTTPStatus: resp.StatusCode, Err: pe}
+		}
+		return ApiError{HTTPStatus: resp.StatusCode, Err: errors.New(prob.Detail)}
+	}
+	if resp.StatusCode != status {
+		var env apiErrorResponse
+		if err := json.NewDecoder(resp.Body).Decode(&env); err != nil {
+			return ApiError{HTTPStatus: resp.StatusCode, Err: errors.New(http.StatusText(resp.StatusCode))}
+		}
+		if len(env.Errors) > 0 {
+			return ApiError{HTTPStatus: resp.StatusCode, Err: env.Errors}
+		}
+		return ApiError{HTTPStatus: resp.StatusCode, Err: errors.New(env.Error)}
+	}
+	if res == nil {
+		return nil
+	}
+	if err := json.NewDecoder(resp.Body).Decode(res); err != nil {
+		return fmt.Errorf("can't decode response: %w", err)
+	}
+	return nil
+}
+
+// CreateUser mirrors service.CreateUser.
+type CreateUser struct {
+	Name    string  `json:"name" apivalidator:"required"`
+	Skill   float64 `json:"skill" apivalidator:"default=0,>=0"`
+	Latency float64 `json:"latency" apivalidator:"default=1,>0"`
+}
+
+// DeleteUser mirrors service.DeleteUser.
+type DeleteUser struct {
+	ID int `json:"id" apivalidator:"path=id,required,>0"`
+}
+
+// GetUser mirrors service.GetUser.
+type GetUser struct {
+	ID int `json:"id" apivalidator:"path=id,required,>0"`
+}
+
+// NewUser mirrors service.NewUser.
+type NewUser struct {
+	ID int `json:"id"`
+}
+
+// None mirrors service.None.
+type None struct{}
+
+// UpdateUser mirrors service.UpdateUser.
+type UpdateUser struct {
+	ID      int     `json:"id" apivalidator:"path=id,required,>0"`
+	Name    string  `json:"name" apivalidator:"required"`
+	Skill   float64 `json:"skill" apivalidator:"required,>=0"`
+	Latency float64 `json:"latency" apivalidator:"required,>0"`
+}
+
+// User mirrors service.User.
+type User struct {
+	ID      int     `json:"id,omitempty"`
+	Name    string  `json:"name,omitempty"`
+	Skill   float64 `json:"skill,omitempty"`
+	Latency float64 `json:"latency,omitempty"`
+}
+
+// ServiceClient is the HTTP client of Service API.
+type ServiceClient struct {
+	apiClient
+}
+
+func NewServiceClient(baseURL string, httpClient *http.Client, auth func(r *http.Request)) *ServiceClient {
+	return &ServiceClient{apiClient{BaseURL: baseURL, HTTPClient: httpClient, Auth: auth}}
+}
+
+// CreateUser calls POST /users
+func (c *ServiceClient) CreateUser(ctx context.Context, in CreateUser) (NewUser, error) {
+	var res NewUser
+	env := apiResponse{Response: &res}
+	path := strings.Join([]string{"", "users"}, "/")
+	var body struct {
+		Name    string  `json:"name"`
+		Skill   float64 `json:"skill,omitempty"`
+		Latency float64 `json:"latency,omitempty"`
+	}
+	body.Name = in.Name
+	body.Skill = in.Skill
+	body.Latency = in.Latency
+	err := c.do(ctx, "POST", path, nil, &body, nil, false, http.StatusCreated, &env)
+	return res, err
+}
+
+// GetUser calls GET /users/{id}
+func (c *ServiceClient) GetUser(ctx context.Context, in GetUser) (User, error) {
+	var res User
+	env := apiResponse{Response: &res}
+	path := strings.Join([]string{"", "users", url.PathEscape(queryValue(in.ID))}, "/")
+	query := url.Values{}
+	err := c.do(ctx, "GET", path, query, nil, nil, true, http.StatusOK, &env)
+	return res, err
+}
+
+// UpdateUser calls PUT /users/{id}
+func (c *ServiceClient) UpdateUser(ctx context.Context, in UpdateUser) (None, error) {
+	var res None
+	path := strings.Join([]string{"", "users", url.PathEscape(queryValue(in.ID))}, "/")
+	var body struct {
+		Name    string  `json:"name"`
+		Skill   float64 `json:"skill"`
+		Latency float64 `json:"latency"`
+	}
+	body.Name = in.Name
+	body.Skill = in.Skill
+	body.Latency = in.Latency
+	err := c.do(ctx, "PUT", path, nil, &body, nil, true, http.StatusNoContent, nil)
+	return res, err
+}
+
+// DeleteUser calls DELETE /users/{id}
+func (c *ServiceClient) DeleteUser(ctx context.Context, in DeleteUser) (None, error) {
+	var res None
+	path := strings.Join([]string{"", "users", url.PathEscape(queryValue(in.ID))}, "/")
+	query := url.Values{}
+	err := c.do(ctx, "DELETE", path, query, nil, nil, true, http.StatusNoContent, nil)
+	return res, err
+}
@@ -19,9 +19,14 @@ const (
 )
 
 func main() {
+	authToken := os.Getenv("AUTH_TOKEN")
+	if authToken == "" {
+		log.Printf("AUTH_TOKEN is not set, all methods that require auth will be unavailable")
+	}
+
 	srv := http.Server{
 		Addr:         serverAddr,
-		Handler:      &service.Service{},
+		Handler:      service.New(authToken),
 		ReadTimeout:  readTimeout,
 		WriteTimeout: writeTimeout,
 	}
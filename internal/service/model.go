@@ -18,18 +18,18 @@ type CreateUser struct {
 }
 
 type UpdateUser struct {
-	ID      int     `json:"id" apivalidator:"required,>0"`
+	ID      int     `json:"id" apivalidator:"path=id,required,>0"`
 	Name    string  `json:"name" apivalidator:"required"`
 	Skill   float64 `json:"skill" apivalidator:"required,>=0"`
 	Latency float64 `json:"latency" apivalidator:"required,>0"`
 }
 
 type DeleteUser struct {
-	ID int `json:"id" apivalidator:"required,>0"`
+	ID int `json:"id" apivalidator:"path=id,required,>0"`
 }
 
 type GetUser struct {
-	ID int `json:"id" apivalidator:"required,>0"`
+	ID int `json:"id" apivalidator:"path=id,required,>0"`
 }
 
 type NewUser struct {
@@ -1,8 +1,25 @@
 package service
 
+import (
+	"crypto/subtle"
+	"errors"
+	"net/http"
+	"strings"
+)
+
 type Service struct {
+	authToken string
+}
+
+func New(authToken string) *Service {
+	return &Service{authToken: authToken}
 }
 
-func New() *Service {
-	return &Service{}
+// Authenticate checks the bearer token of requests to methods marked with "auth": true.
+func (api *Service) Authenticate(r *http.Request, m ApiMethod) (any, error) {
+	token, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+	if !ok || api.authToken == "" || subtle.ConstantTimeCompare([]byte(token), []byte(api.authToken)) != 1 {
+		return nil, ApiError{HTTPStatus: http.StatusUnauthorized, Err: errors.New("unauthorized")}
+	}
+	return token, nil
 }
@@ -1,69 +1,560 @@
 // !!! Do not change this code !!!
 // The code is generated automatically by apigen tool
+// Inputs hash: d3f0b10d87dd50fb33b696cb74b809c2f312e51f54fc167053f4d5d9e71a9eb2
 package service
 
 import (
+	"context"
 	"encoding/json"
 	"errors"
-	"fmt"
 	"io"
 	"log"
+	"mime"
+	"mime/multipart"
 	"net/http"
+	"sort"
 	"strconv"
 	"strings"
 )
 
-func writeApiError(w http.ResponseWriter, ae ApiError) {
+// Encoder encodes the response body to the media type.
+type Encoder interface {
+	ContentType() string
+	Encode(w io.Writer, v any) error
+}
+
+type jsonEncoder struct{}
+
+func (jsonEncoder) ContentType() string { return "application/json" }
+
+func (jsonEncoder) Encode(w io.Writer, v any) error {
+	return json.NewEncoder(w).Encode(v)
+}
+
+// encoders of the responses, the first one is used if the request accepts any type.
+var encoders = []Encoder{jsonEncoder{}}
+
+// RegisterEncoder adds the encoder of the response media type or replaces
+// the registered one. It must be called before serving requests.
+func RegisterEncoder(e Encoder) {
+	for i, v := range encoders {
+		if v.ContentType() == e.ContentType() {
+			encoders[i] = e
+			return
+		}
+	}
+	encoders = append(encoders, e)
+}
+
+// negotiate returns the encoder of the most preferred media type accepted by
+// the request, the first encoder if the Accept header is absent, or nil.
+func negotiate(r *http.Request) Encoder {
+	accept := strings.Join(r.Header.Values("accept"), ",")
+	if accept == "" {
+		return encoders[0]
+	}
+	type mediaRange struct {
+		typ string
+		q   float64
+	}
+	var ranges []mediaRange
+	for _, s := range strings.Split(accept, ",") {
+		typ, params, _ := strings.Cut(s, ";")
+		mr := mediaRange{typ: strings.ToLower(strings.TrimSpace(typ)), q: 1}
+		for _, param := range strings.Split(params, ";") {
+			if k, v, ok := strings.Cut(strings.TrimSpace(param), "="); ok && k == "q" {
+				mr.q, _ = strconv.ParseFloat(v, 64)
+			}
+		}
+		if mr.q > 0 {
+			ranges = append(ranges, mr)
+		}
+	}
+	sort.SliceStable(ranges, func(i, j int) bool { return ranges[i].q > ranges[j].q })
+	for _, mr := range ranges {
+		for _, e := range encoders {
+			ct := e.ContentType()
+			if mr.typ == "*/*" || mr.typ == ct || strings.HasSuffix(mr.typ, "/*") && strings.HasPrefix(ct, mr.typ[:len(mr.typ)-1]) {
+				return e
+			}
+		}
+	}
+	return nil
+}
+
+// apiResponse is the response body of the service method.
+type apiResponse struct {
+	Response any    `json:"response" xml:"response"`
+	Error    string `json:"error" xml:"error"`
+}
+
+// apiErrorResponse is the response body of the failed request.
+type apiErrorResponse struct {
+	Error  string      `json:"error" xml:"error"`
+	Errors ParamErrors `json:"errors,omitempty" xml:"errors,omitempty"`
+}
+
+const problemContentType = "application/problem+json"
+
+// Problem is RFC 9457 problem details, written instead of {"error": "..."}
+// if the request accepts application/problem+json.
+type Problem struct {
+	Type   string            `json:"type"`
+	Title  string            `json:"title"`
+	Status int               `json:"status"`
+	Detail string            `json:"detail"`
+	Code   string            `json:"code"`
+	Field  string            `json:"field,omitempty"`
+	Rule   string            `json:"rule,omitempty"`
+	Params map[string]string `json:"params,omitempty"`
+	Errors ParamErrors       `json:"errors,omitempty"`
+}
+
+// errorCode returns the stable code of the error with the HTTP status, e.g. not_found.
+func errorCode(status int) string {
+	return strings.ToLower(strings.ReplaceAll(http.StatusText(status), " ", "_"))
+}
+
+func writeApiError(w http.ResponseWriter, r *http.Request, ae ApiError) {
 	const op = "writeApiError"
-	w.Header().Add("content-type", "application/json")
+	if strings.Contains(r.Header.Get("accept"), problemContentType) {
+		prob := Problem{
+			Type:   "about:blank",
+			Title:  http.StatusText(ae.HTTPStatus),
+			Status: ae.HTTPStatus,
+			Detail: ae.Err.Error(),
+			Code:   errorCode(ae.HTTPStatus),
+		}
+		var (
+			pes ParamErrors
+			pe  *ParamError
+			ace *AccessError
+		)
+		switch {
+		case errors.As(ae.Err, &pes):
+			prob.Code, prob.Errors = "param_errors", pes
+		case errors.As(ae.Err, &pe):
+			prob.Code, prob.Field, prob.Rule, prob.Params = pe.Code, pe.Field, pe.Rule, pe.Params
+		case errors.As(ae.Err, &ace):
+			prob.Code, prob.Params = ace.Code, ace.Params
+		}
+		w.Header().Add("content-type", problemContentType)
+		w.WriteHeader(ae.HTTPStatus)
+		if err := json.NewEncoder(w).Encode(&prob); err != nil {
+			log.Printf("%s: can't write response body: %v", op, err)
+		}
+		return
+	}
+	// errors are written even if the request accepts no encoder
+	enc := negotiate(r)
+	if enc == nil {
+		enc = encoders[0]
+	}
+	resp := apiErrorResponse{Error: ae.Err.Error()}
+	errors.As(ae.Err, &resp.Errors)
+	w.Header().Add("content-type", enc.ContentType())
 	w.WriteHeader(ae.HTTPStatus)
-	if _, err := fmt.Fprintf(w, "{\"error\":%q}", ae.Err.Error()); err != nil {
+	if err := enc.Encode(w, &resp); err != nil {
 		log.Printf("%s: can't write response body: %v", op, err)
 	}
 }
 
+// ParamError describes the invalid request param. The generated code returns
+// it as ApiError.Err with 400 status.
+type ParamError struct {
+	Code   string            `json:"code"`                     // stable error code, e.g. param_max
+	Field  string            `json:"field"`                    // api name of the param, e.g. settings.region
+	Rule   string            `json:"rule"`                     // violated rule, e.g. max
+	Params map[string]string `json:"params,omitempty" xml:"-"` // rule params, e.g. {"max": "128"}
+	Msg    string            `json:"message"`
+	Err    error             `json:"-"` // error of UnmarshalText or Validate method if any
+}
+
+func (e *ParamError) Error() string {
+	return e.Msg
+}
+
+func (e *ParamError) Unwrap() error {
+	return e.Err
+}
+
+// newParamError returns ParamError of the rule, params are key-value pairs.
+func newParamError(field, rule, msg string, params ...string) error {
+	pe := &ParamError{Code: "param_" + rule, Field: field, Rule: rule, Msg: msg}
+	for i := 0; i+1 < len(params); i += 2 {
+		if pe.Params == nil {
+			pe.Params = map[string]string{}
+		}
+		pe.Params[params[i]] = params[i+1]
+	}
+	return pe
+}
+
+// wrapParamError returns ParamError of the rule caused by err.
+func wrapParamError(field, rule, msg string, err error) error {
+	return &ParamError{Code: "param_" + rule, Field: field, Rule: rule, Msg: msg + err.Error(), Err: err}
+}
+
+// ParamErrors are all invalid params of the request, returned by the methods
+// marked with "allErrors": true instead of the first ParamError.
+type ParamErrors []*ParamError
+
+func (e ParamErrors) Error() string {
+	msgs := make([]string, len(e))
+	for i, pe := range e {
+		msgs[i] = pe.Msg
+	}
+	return strings.Join(msgs, "; ")
+}
+
+func (e ParamErrors) Unwrap() []error {
+	errs := make([]error, len(e))
+	for i, pe := range e {
+		errs[i] = pe
+	}
+	return errs
+}
+
+// add returns the err back if the errors are not accumulated (e is nil),
+// otherwise it keeps the first ParamError of every param and returns nil.
+func (e *ParamErrors) add(err error) error {
+	pe, ok := err.(*ParamError)
+	if e == nil || !ok {
+		return err
+	}
+	for _, v := range *e {
+		if v.Field == pe.Field {
+			return nil
+		}
+	}
+	*e = append(*e, pe)
+	return nil
+}
+
+// ApiMethod describes the service method called by the request.
+type ApiMethod struct {
+	Service    string
+	Name       string
+	URL        string
+	HTTPMethod string
+	Auth       bool
+	Roles      []string // the caller must have one of the roles
+	Scopes     []string // the caller must have all the scopes
+}
+
+// Middleware wraps the handler of the service method described by m. The
+// chain is set by "middleware" of apigen:api marks of the service type and
+// the method, the first middleware is outermost. It's called before Authenticator.
+type Middleware func(next http.Handler, m ApiMethod) http.Handler
+
+// Authenticator authenticates requests to the methods marked with "auth": true.
+// It returns the caller identity, which the service method can get by
+// IdentityFromContext, or an error. Use ApiError to set HTTP status (401 by default).
+type Authenticator interface {
+	Authenticate(r *http.Request, m ApiMethod) (any, error)
+}
+
+// AuthenticatorFunc is an adapter to use the ordinary func as Authenticator.
+type AuthenticatorFunc func(r *http.Request, m ApiMethod) (any, error)
+
+func (f AuthenticatorFunc) Authenticate(r *http.Request, m ApiMethod) (any, error) {
+	return f(r, m)
+}
+
+type identityKey struct{}
+
+// IdentityFromContext returns the caller identity returned by Authenticator.
+func IdentityFromContext(ctx context.Context) (any, bool) {
+	id := ctx.Value(identityKey{})
+	return id, id != nil
+}
+
+func authenticate(w http.ResponseWriter, r *http.Request, a Authenticator, m ApiMethod) (*http.Request, bool) {
+	id, err := a.Authenticate(r, m)
+	if err != nil {
+		switch err := err.(type) {
+		case *ApiError:
+			writeApiError(w, r, *err)
+		case ApiError:
+			writeApiError(w, r, err)
+		default:
+			writeApiError(w, r, ApiError{HTTPStatus: http.StatusUnauthorized, Err: err})
+		}
+		return r, false
+	}
+	return r.WithContext(context.WithValue(r.Context(), identityKey{}, id)), true
+}
+
+// RoleHolder is implemented by the identity returned by Authenticator to
+// call the methods marked with "roles".
+type RoleHolder interface {
+	HasRole(role string) bool
+}
+
+// ScopeHolder is implemented by the identity returned by Authenticator to
+// call the methods marked with "scopes".
+type ScopeHolder interface {
+	HasScope(scope string) bool
+}
+
+// AccessError describes the missing role or scope of the authenticated caller.
+// The generated code returns it as ApiError.Err with 403 status.
+type AccessError struct {
+	Code   string            // missing_role or missing_scope
+	Params map[string]string // required roles or scopes, e.g. {"roles": "admin|moderator"}
+	Msg    string
+}
+
+func (e *AccessError) Error() string {
+	return e.Msg
+}
+
+// authorize checks that the caller identity has one of the method roles and
+// all its scopes. It responds 401 if the caller is anonymous, 403 otherwise.
+func authorize(w http.ResponseWriter, r *http.Request, m ApiMethod) bool {
+	id, ok := IdentityFromContext(r.Context())
+	if !ok {
+		writeApiError(w, r, ApiError{HTTPStatus: http.StatusUnauthorized, Err: errors.New("authentication required")})
+		return false
+	}
+	if len(m.Roles) > 0 {
+		rh, ok := id.(RoleHolder)
+		allowed := false
+		for _, role := range m.Roles {
+			allowed = allowed || ok && rh.HasRole(role)
+		}
+		if !allowed {
+			err := &AccessError{
+				Code:   "missing_role",
+				Params: map[string]string{"roles": strings.Join(m.Roles, "|")},
+				Msg:    "one of roles required: " + strings.Join(m.Roles, ", "),
+			}
+			writeApiError(w, r, ApiError{HTTPStatus: http.StatusForbidden, Err: err})
+			return false
+		}
+	}
+	if len(m.Scopes) > 0 {
+		sh, ok := id.(ScopeHolder)
+		for _, scope := range m.Scopes {
+			if !ok || !sh.HasScope(scope) {
+				err := &AccessError{
+					Code:   "missing_scope",
+					Params: map[string]string{"scopes": strings.Join(m.Scopes, "|")},
+					Msg:    "scopes required: " + strings.Join(m.Scopes, ", "),
+				}
+				writeApiError(w, r, ApiError{HTTPStatus: http.StatusForbidden, Err: err})
+				return false
+			}
+		}
+	}
+	return true
+}
+
+type pathValuesKey struct{}
+
+// matchPath matches the path with the URL template like /users/{id}
+// and returns values of the template params.
+func matchPath(path, tmpl string) (map[string]string, bool) {
+	ps := strings.Split(strings.Trim(path, "/"), "/")
+	ts := strings.Split(strings.Trim(tmpl, "/"), "/")
+	if len(ps) != len(ts) {
+		return nil, false
+	}
+	vals := map[string]string{}
+	for i, t := range ts {
+		if strings.HasPrefix(t, "{") && strings.HasSuffix(t, "}") {
+			if ps[i] == "" {
+				return nil, false
+			}
+			vals[t[1:len(t)-1]] = ps[i]
+		} else if ps[i] != t {
+			return nil, false
+		}
+	}
+	return vals, true
+}
+
+func withPathValues(r *http.Request, vals map[string]string) *http.Request {
+	return r.WithContext(context.WithValue(r.Context(), pathValuesKey{}, vals))
+}
+
+func pathValue(r *http.Request, name string) string {
+	vals, _ := r.Context().Value(pathValuesKey{}).(map[string]string)
+	return vals[name]
+}
+
+// mediaType returns the media type of the request body without parameters.
+func mediaType(r *http.Request) string {
+	ct := r.Header.Get("content-type")
+	if ct == "" {
+		return ""
+	}
+	mt, _, err := mime.ParseMediaType(ct)
+	if err != nil {
+		return ct
+	}
+	return mt
+}
+
+// parseRequest limits the request body by maxBytes if not 0, checks its content type
+// and parses the form. The json body is decoded by getFromRequest of the params.
+func parseRequest(w http.ResponseWriter, r *http.Request, maxBytes, maxMemory int64) error {
+	if maxBytes > 0 {
+		r.Body = http.MaxBytesReader(w, r.Body, maxBytes)
+	}
+	switch mt := mediaType(r); mt {
+	case "application/json":
+		return nil
+	case "", "application/x-www-form-urlencoded":
+		return r.ParseForm()
+	case "multipart/form-data":
+		return r.ParseMultipartForm(maxMemory)
+	default:
+		return ApiError{HTTPStatus: http.StatusUnsupportedMediaType, Err: errors.New("unsupported content type " + mt)}
+	}
+}
+
+// requestError returns ApiError of reading the request: 413 if the body is too large,
+// the own status of ApiError or 400.
+func requestError(err error) ApiError {
+	var mbe *http.MaxBytesError
+	if errors.As(err, &mbe) {
+		msg := "request body must be <= " + strconv.FormatInt(mbe.Limit, 10) + " bytes"
+		return ApiError{HTTPStatus: http.StatusRequestEntityTooLarge, Err: errors.New(msg)}
+	}
+	switch ae := err.(type) {
+	case ApiError:
+		return ae
+	case *ApiError:
+		return *ae
+	}
+	return ApiError{HTTPStatus: http.StatusBadRequest, Err: err}
+}
+
+// formValue returns the first non-empty value of the form or query keys,
+// e.g. dotted settings.region and bracket settings[region] keys of the nested param.
+func formValue(r *http.Request, keys ...string) string {
+	for _, key := range keys {
+		if v := r.FormValue(key); v != "" {
+			return v
+		}
+	}
+	return ""
+}
+
+// formFiles returns the files uploaded in multipart form by the first of the keys having them.
+func formFiles(r *http.Request, keys ...string) []*multipart.FileHeader {
+	if r.MultipartForm == nil {
+		return nil
+	}
+	for _, key := range keys {
+		if fhs := r.MultipartForm.File[key]; len(fhs) > 0 {
+			return fhs
+		}
+	}
+	return nil
+}
+
+// cookieValue returns the value of the named cookie or empty string if there is no cookie.
+func cookieValue(r *http.Request, name string) string {
+	c, err := r.Cookie(name)
+	if err != nil {
+		return ""
+	}
+	return c.Value
+}
+
+// formValues returns all non-empty values of the repeated form or query keys.
+// If split is true, the values are also split by comma.
+func formValues(r *http.Request, split bool, keys ...string) []string {
+	var vals []string
+	for _, key := range keys {
+		_ = r.FormValue(key) // parses the form
+		for _, v := range r.Form[key] {
+			ss := []string{v}
+			if split {
+				ss = strings.Split(v, ",")
+			}
+			for _, s := range ss {
+				if s != "" {
+					vals = append(vals, s)
+				}
+			}
+		}
+	}
+	return vals
+}
+
+var (
+	apiMethodServiceCreateUser = ApiMethod{Service: "Service", Name: "CreateUser", URL: "/users", HTTPMethod: "POST", Auth: false}
+	apiMethodServiceGetUser    = ApiMethod{Service: "Service", Name: "GetUser", URL: "/users/{id}", HTTPMethod: "GET", Auth: true}
+	apiMethodServiceUpdateUser = ApiMethod{Service: "Service", Name: "UpdateUser", URL: "/users/{id}", HTTPMethod: "PUT", Auth: true}
+	apiMethodServiceDeleteUser = ApiMethod{Service: "Service", Name: "DeleteUser", URL: "/users/{id}", HTTPMethod: "DELETE", Auth: true}
+)
+
 func (h *Service) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 	switch r.URL.Path {
 	case "/users":
 		switch /*r.Method*/ {
-		case strings.EqualFold(r.Method, "DELETE"):
-			if key := r.Header.Get("X-Auth"); key != "100500" { // XXX
-				writeApiError(w, ApiError{HTTPStatus: http.StatusForbidden, Err: errors.New("unauthorized")})
-				return
-			}
-			h.wrapperDeleteUser(w, r)
-		case strings.EqualFold(r.Method, "GET"):
-			if key := r.Header.Get("X-Auth"); key != "100500" { // XXX
-				writeApiError(w, ApiError{HTTPStatus: http.StatusForbidden, Err: errors.New("unauthorized")})
-				return
-			}
-			h.wrapperGetUser(w, r)
 		case strings.EqualFold(r.Method, "POST"):
 			h.wrapperCreateUser(w, r)
-		case strings.EqualFold(r.Method, "PUT"):
-			if key := r.Header.Get("X-Auth"); key != "100500" { // XXX
-				writeApiError(w, ApiError{HTTPStatus: http.StatusForbidden, Err: errors.New("unauthorized")})
-				return
-			}
-			h.wrapperUpdateUser(w, r)
 		default:
-			writeApiError(w, ApiError{HTTPStatus: http.StatusNotAcceptable, Err: errors.New("bad method")})
+			writeApiError(w, r, ApiError{HTTPStatus: http.StatusNotAcceptable, Err: errors.New("bad method")})
 			return
 		}
 	default:
-		writeApiError(w, ApiError{HTTPStatus: http.StatusNotFound, Err: errors.New("unknown method")})
+		if vals, ok := matchPath(r.URL.Path, "/users/{id}"); ok {
+			r = withPathValues(r, vals)
+			switch /*r.Method*/ {
+			case strings.EqualFold(r.Method, "DELETE"):
+				r, ok := authenticate(w, r, h, apiMethodServiceDeleteUser)
+				if !ok {
+					return
+				}
+				h.wrapperDeleteUser(w, r)
+			case strings.EqualFold(r.Method, "GET"):
+				r, ok := authenticate(w, r, h, apiMethodServiceGetUser)
+				if !ok {
+					return
+				}
+				h.wrapperGetUser(w, r)
+			case strings.EqualFold(r.Method, "PUT"):
+				r, ok := authenticate(w, r, h, apiMethodServiceUpdateUser)
+				if !ok {
+					return
+				}
+				h.wrapperUpdateUser(w, r)
+			default:
+				writeApiError(w, r, ApiError{HTTPStatus: http.StatusNotAcceptable, Err: errors.New("bad method")})
+				return
+			}
+			return
+		}
+		writeApiError(w, r, ApiError{HTTPStatus: http.StatusNotFound, Err: errors.New("unknown method")})
 	}
 }
 
 func (h *Service) wrapperCreateUser(w http.ResponseWriter, r *http.Request) {
 	const op = "Service.wrapperCreateUser"
 	var params CreateUser
-	if err := params.getFromRequest(r); err != nil {
-		writeApiError(w, ApiError{HTTPStatus: http.StatusBadRequest, Err: err})
+	if err := parseRequest(w, r, 0, 33554432); err != nil {
+		writeApiError(w, r, requestError(err))
 		return
 	}
-	if err := params.validate(); err != nil {
-		writeApiError(w, ApiError{HTTPStatus: http.StatusBadRequest, Err: err})
+	if r.MultipartForm != nil {
+		defer r.MultipartForm.RemoveAll()
+	}
+	if err := params.getFromRequest(r, nil, false); err != nil {
+		writeApiError(w, r, requestError(err))
+		return
+	}
+	if err := params.validate(nil); err != nil {
+		writeApiError(w, r, ApiError{HTTPStatus: http.StatusBadRequest, Err: err})
+		return
+	}
+	enc := negotiate(r)
+	if enc == nil {
+		writeApiError(w, r, ApiError{HTTPStatus: http.StatusNotAcceptable, Err: errors.New("no acceptable response content type")})
 		return
 	}
 	ctx := r.Context()
@@ -71,23 +562,17 @@ func (h *Service) wrapperCreateUser(w http.ResponseWriter, r *http.Request) {
 	if err != nil {
 		switch err := err.(type) {
 		case *ApiError:
-			writeApiError(w, *err)
+			writeApiError(w, r, *err)
 		case ApiError:
-			writeApiError(w, err)
+			writeApiError(w, r, err)
 		default:
-			writeApiError(w, ApiError{HTTPStatus: http.StatusInternalServerError, Err: err})
+			writeApiError(w, r, ApiError{HTTPStatus: http.StatusInternalServerError, Err: err})
 		}
 		return
 	}
-	resp := struct {
-		Response NewUser `json:"response"`
-		Error    string  `json:"error"`
-	}{
-		Response: res,
-	}
-	w.Header().Add("content-type", "application/json")
-	w.WriteHeader(http.StatusOK)
-	if err := json.NewEncoder(w).Encode(&resp); err != nil {
+	w.Header().Add("content-type", enc.ContentType())
+	w.WriteHeader(http.StatusCreated)
+	if err := enc.Encode(w, &apiResponse{Response: res}); err != nil {
 		log.Printf("%s: can't write response body: %v", op, err)
 	}
 }
@@ -95,12 +580,24 @@ func (h *Service) wrapperCreateUser(w http.ResponseWriter, r *http.Request) {
 func (h *Service) wrapperGetUser(w http.ResponseWriter, r *http.Request) {
 	const op = "Service.wrapperGetUser"
 	var params GetUser
-	if err := params.getFromRequest(r); err != nil {
-		writeApiError(w, ApiError{HTTPStatus: http.StatusBadRequest, Err: err})
+	if err := parseRequest(w, r, 0, 33554432); err != nil {
+		writeApiError(w, r, requestError(err))
 		return
 	}
-	if err := params.validate(); err != nil {
-		writeApiError(w, ApiError{HTTPStatus: http.StatusBadRequest, Err: err})
+	if r.MultipartForm != nil {
+		defer r.MultipartForm.RemoveAll()
+	}
+	if err := params.getFromRequest(r, nil, false); err != nil {
+		writeApiError(w, r, requestError(err))
+		return
+	}
+	if err := params.validate(nil); err != nil {
+		writeApiError(w, r, ApiError{HTTPStatus: http.StatusBadRequest, Err: err})
+		return
+	}
+	enc := negotiate(r)
+	if enc == nil {
+		writeApiError(w, r, ApiError{HTTPStatus: http.StatusNotAcceptable, Err: errors.New("no acceptable response content type")})
 		return
 	}
 	ctx := r.Context()
@@ -108,23 +605,17 @@ func (h *Service) wrapperGetUser(w http.ResponseWriter, r *http.Request) {
 	if err != nil {
 		switch err := err.(type) {
 		case *ApiError:
-			writeApiError(w, *err)
+			writeApiError(w, r, *err)
 		case ApiError:
-			writeApiError(w, err)
+			writeApiError(w, r, err)
 		default:
-			writeApiError(w, ApiError{HTTPStatus: http.StatusInternalServerError, Err: err})
+			writeApiError(w, r, ApiError{HTTPStatus: http.StatusInternalServerError, Err: err})
 		}
 		return
 	}
-	resp := struct {
-		Response User   `json:"response"`
-		Error    string `json:"error"`
-	}{
-		Response: res,
-	}
-	w.Header().Add("content-type", "application/json")
+	w.Header().Add("content-type", enc.ContentType())
 	w.WriteHeader(http.StatusOK)
-	if err := json.NewEncoder(w).Encode(&resp); err != nil {
+	if err := enc.Encode(w, &apiResponse{Response: res}); err != nil {
 		log.Printf("%s: can't write response body: %v", op, err)
 	}
 }
@@ -132,104 +623,120 @@ func (h *Service) wrapperGetUser(w http.ResponseWriter, r *http.Request) {
 func (h *Service) wrapperUpdateUser(w http.ResponseWriter, r *http.Request) {
 	const op = "Service.wrapperUpdateUser"
 	var params UpdateUser
-	if err := params.getFromRequest(r); err != nil {
-		writeApiError(w, ApiError{HTTPStatus: http.StatusBadRequest, Err: err})
+	if err := parseRequest(w, r, 0, 33554432); err != nil {
+		writeApiError(w, r, requestError(err))
 		return
 	}
-	if err := params.validate(); err != nil {
-		writeApiError(w, ApiError{HTTPStatus: http.StatusBadRequest, Err: err})
+	if r.MultipartForm != nil {
+		defer r.MultipartForm.RemoveAll()
+	}
+	if err := params.getFromRequest(r, nil, false); err != nil {
+		writeApiError(w, r, requestError(err))
+		return
+	}
+	if err := params.validate(nil); err != nil {
+		writeApiError(w, r, ApiError{HTTPStatus: http.StatusBadRequest, Err: err})
 		return
 	}
 	ctx := r.Context()
-	res, err := h.UpdateUser(ctx, params)
+	_, err := h.UpdateUser(ctx, params)
 	if err != nil {
 		switch err := err.(type) {
 		case *ApiError:
-			writeApiError(w, *err)
+			writeApiError(w, r, *err)
 		case ApiError:
-			writeApiError(w, err)
+			writeApiError(w, r, err)
 		default:
-			writeApiError(w, ApiError{HTTPStatus: http.StatusInternalServerError, Err: err})
+			writeApiError(w, r, ApiError{HTTPStatus: http.StatusInternalServerError, Err: err})
 		}
 		return
 	}
-	resp := struct {
-		Response None   `json:"response"`
-		Error    string `json:"error"`
-	}{
-		Response: res,
-	}
-	w.Header().Add("content-type", "application/json")
-	w.WriteHeader(http.StatusOK)
-	if err := json.NewEncoder(w).Encode(&resp); err != nil {
-		log.Printf("%s: can't write response body: %v", op, err)
-	}
+	w.WriteHeader(http.StatusNoContent)
 }
 
 func (h *Service) wrapperDeleteUser(w http.ResponseWriter, r *http.Request) {
 	const op = "Service.wrapperDeleteUser"
 	var params DeleteUser
-	if err := params.getFromRequest(r); err != nil {
-		writeApiError(w, ApiError{HTTPStatus: http.StatusBadRequest, Err: err})
+	if err := parseRequest(w, r, 0, 33554432); err != nil {
+		writeApiError(w, r, requestError(err))
+		return
+	}
+	if r.MultipartForm != nil {
+		defer r.MultipartForm.RemoveAll()
+	}
+	if err := params.getFromRequest(r, nil, false); err != nil {
+		writeApiError(w, r, requestError(err))
 		return
 	}
-	if err := params.validate(); err != nil {
-		writeApiError(w, ApiError{HTTPStatus: http.StatusBadRequest, Err: err})
+	if err := params.validate(nil); err != nil {
+		writeApiError(w, r, ApiError{HTTPStatus: http.StatusBadRequest, Err: err})
 		return
 	}
 	ctx := r.Context()
-	res, err := h.DeleteUser(ctx, params)
+	_, err := h.DeleteUser(ctx, params)
 	if err != nil {
 		switch err := err.(type) {
 		case *ApiError:
-			writeApiError(w, *err)
+			writeApiError(w, r, *err)
 		case ApiError:
-			writeApiError(w, err)
+			writeApiError(w, r, err)
 		default:
-			writeApiError(w, ApiError{HTTPStatus: http.StatusInternalServerError, Err: err})
+			writeApiError(w, r, ApiError{HTTPStatus: http.StatusInternalServerError, Err: err})
 		}
 		return
 	}
-	resp := struct {
-		Response None   `json:"response"`
-		Error    string `json:"error"`
-	}{
-		Response: res,
-	}
-	w.Header().Add("content-type", "application/json")
-	w.WriteHeader(http.StatusOK)
-	if err := json.NewEncoder(w).Encode(&resp); err != nil {
-		log.Printf("%s: can't write response body: %v", op, err)
-	}
+	w.WriteHeader(http.StatusNoContent)
 }
 
-func (p *CreateUser) getFromRequest(r *http.Request) error {
-	if r.Header.Get("content-type") == "application/json" {
+func (p *CreateUser) getFromRequest(r *http.Request, errs *ParamErrors, strict bool) error {
+	if mediaType(r) == "application/json" {
 		// get from json body
 		defer io.Copy(io.Discard, r.Body)
-		req := struct {
-			Name    *string `json:"name"`
-			Skill   float64 `json:"skill"`
-			Latency float64 `json:"latency"`
-		}{
-			Skill:   0,
-			Latency: 1,
-		}
-		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		var req struct {
+			Name    *string  `json:"name"`
+			Skill   *float64 `json:"skill"`
+			Latency *float64 `json:"latency"`
+		}
+		dec := json.NewDecoder(r.Body)
+		if strict {
+			dec.DisallowUnknownFields()
+		}
+		if err := dec.Decode(&req); err != nil {
 			return /*bad json*/ err
 		}
-		if req.Name == nil {
-			return errors.New("name must be not empty")
+		if strict {
+			if _, err := dec.Token(); err != io.EOF {
+				if err == nil {
+					err = errors.New("unexpected data after json body")
+				}
+				return err
+			}
+		}
+		if req.Name != nil {
+			p.Name = *req.Name
+		} else {
+			if err := errs.add(newParamError("name", "required", "name must be not empty")); err != nil {
+				return err
+			}
+		}
+		if req.Skill != nil {
+			p.Skill = *req.Skill
+		} else {
+			p.Skill = 0
+		}
+		if req.Latency != nil {
+			p.Latency = *req.Latency
+		} else {
+			p.Latency = 1
 		}
-		p.Name = *req.Name
-		p.Skill = req.Skill
-		p.Latency = req.Latency
 	} else {
 		// get from form or query
 		{
 			s := r.FormValue("name")
 			if s == "" {
-				return errors.New("name must be not empty")
+				if err := errs.add(newParamError("name", "required", "name must be not empty")); err != nil {
+					return err
+				}
 			}
 			p.Name = s
 		}
@@ -239,8 +746,15 @@ func (p *CreateUser) getFromRequest(r *http.Request) error {
 				p.Skill = 0
 			} else {
 				v, err := strconv.ParseFloat(s, 64)
+				if errors.Is(err, strconv.ErrRange) {
+					if err := errs.add(newParamError("skill", "type", "skill is out of float64 range", "type", "float64")); err != nil {
+						return err
+					}
+				}
 				if err != nil {
-					return errors.New("skill must be float64")
+					if err := errs.add(newParamError("skill", "type", "skill must be float64", "type", "float64")); err != nil {
+						return err
+					}
 				}
 				p.Skill = v
 			}
@@ -251,8 +765,15 @@ func (p *CreateUser) getFromRequest(r *http.Request) error {
 				p.Latency = 1
 			} else {
 				v, err := strconv.ParseFloat(s, 64)
+				if errors.Is(err, strconv.ErrRange) {
+					if err := errs.add(newParamError("latency", "type", "latency is out of float64 range", "type", "float64")); err != nil {
+						return err
+					}
+				}
 				if err != nil {
-					return errors.New("latency must be float64")
+					if err := errs.add(newParamError("latency", "type", "latency must be float64", "type", "float64")); err != nil {
+						return err
+					}
 				}
 				p.Latency = v
 			}
@@ -261,160 +782,248 @@ func (p *CreateUser) getFromRequest(r *http.Request) error {
 	return nil
 }
 
-func (p *CreateUser) validate() error {
+func (p *CreateUser) validate(errs *ParamErrors) error {
 	if !(p.Skill >= 0) {
-		return errors.New("skill must be >= 0")
+		if err := errs.add(newParamError("skill", "min", "skill must be >= 0", "min", "0")); err != nil {
+			return err
+		}
 	}
 	if !(p.Latency > 0) {
-		return errors.New("latency must be > 0")
+		if err := errs.add(newParamError("latency", "greater", "latency must be > 0", "greater", "0")); err != nil {
+			return err
+		}
 	}
 	return nil
 }
 
-func (p *DeleteUser) getFromRequest(r *http.Request) error {
-	if r.Header.Get("content-type") == "application/json" {
+func (p *DeleteUser) getFromRequest(r *http.Request, errs *ParamErrors, strict bool) error {
+	// get from path
+	{
+		s := pathValue(r, "id")
+		if s == "" {
+			if err := errs.add(newParamError("id", "required", "id must be not empty")); err != nil {
+				return err
+			}
+		}
+		v, err := strconv.Atoi(s)
+		if errors.Is(err, strconv.ErrRange) {
+			if err := errs.add(newParamError("id", "type", "id is out of int range", "type", "int")); err != nil {
+				return err
+			}
+		}
+		if err != nil {
+			if err := errs.add(newParamError("id", "type", "id must be int", "type", "int")); err != nil {
+				return err
+			}
+		}
+		p.ID = v
+	}
+	if mediaType(r) == "application/json" {
 		// get from json body
 		defer io.Copy(io.Discard, r.Body)
-		req := struct {
-			ID *int `json:"id"`
-		}{}
-		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		var req struct {
+		}
+		dec := json.NewDecoder(r.Body)
+		if strict {
+			dec.DisallowUnknownFields()
+		}
+		if err := dec.Decode(&req); err != nil {
 			return /*bad json*/ err
 		}
-		if req.ID == nil {
-			return errors.New("id must be not empty")
+		if strict {
+			if _, err := dec.Token(); err != io.EOF {
+				if err == nil {
+					err = errors.New("unexpected data after json body")
+				}
+				return err
+			}
 		}
-		p.ID = *req.ID
 	} else {
 		// get from form or query
-		{
-			s := r.FormValue("id")
-			if s == "" {
-				return errors.New("id must be not empty")
-			}
-			v, err := strconv.Atoi(s)
-			if err != nil {
-				return errors.New("id must be int")
-			}
-			p.ID = v
-		}
 	}
 	return nil
 }
 
-func (p *DeleteUser) validate() error {
+func (p *DeleteUser) validate(errs *ParamErrors) error {
 	if !(p.ID > 0) {
-		return errors.New("id must be > 0")
+		if err := errs.add(newParamError("id", "greater", "id must be > 0", "greater", "0")); err != nil {
+			return err
+		}
 	}
 	return nil
 }
 
-func (p *GetUser) getFromRequest(r *http.Request) error {
-	if r.Header.Get("content-type") == "application/json" {
+func (p *GetUser) getFromRequest(r *http.Request, errs *ParamErrors, strict bool) error {
+	// get from path
+	{
+		s := pathValue(r, "id")
+		if s == "" {
+			if err := errs.add(newParamError("id", "required", "id must be not empty")); err != nil {
+				return err
+			}
+		}
+		v, err := strconv.Atoi(s)
+		if errors.Is(err, strconv.ErrRange) {
+			if err := errs.add(newParamError("id", "type", "id is out of int range", "type", "int")); err != nil {
+				return err
+			}
+		}
+		if err != nil {
+			if err := errs.add(newParamError("id", "type", "id must be int", "type", "int")); err != nil {
+				return err
+			}
+		}
+		p.ID = v
+	}
+	if mediaType(r) == "application/json" {
 		// get from json body
 		defer io.Copy(io.Discard, r.Body)
-		req := struct {
-			ID *int `json:"id"`
-		}{}
-		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		var req struct {
+		}
+		dec := json.NewDecoder(r.Body)
+		if strict {
+			dec.DisallowUnknownFields()
+		}
+		if err := dec.Decode(&req); err != nil {
 			return /*bad json*/ err
 		}
-		if req.ID == nil {
-			return errors.New("id must be not empty")
+		if strict {
+			if _, err := dec.Token(); err != io.EOF {
+				if err == nil {
+					err = errors.New("unexpected data after json body")
+				}
+				return err
+			}
 		}
-		p.ID = *req.ID
 	} else {
 		// get from form or query
-		{
-			s := r.FormValue("id")
-			if s == "" {
-				return errors.New("id must be not empty")
-			}
-			v, err := strconv.Atoi(s)
-			if err != nil {
-				return errors.New("id must be int")
-			}
-			p.ID = v
-		}
 	}
 	return nil
 }
 
-func (p *GetUser) validate() error {
+func (p *GetUser) validate(errs *ParamErrors) error {
 	if !(p.ID > 0) {
-		return errors.New("id must be > 0")
+		if err := errs.add(newParamError("id", "greater", "id must be > 0", "greater", "0")); err != nil {
+			return err
+		}
 	}
 	return nil
 }
 
-func (p *UpdateUser) getFromRequest(r *http.Request) error {
-	if r.Header.Get("content-type") == "application/json" {
+func (p *UpdateUser) getFromRequest(r *http.Request, errs *ParamErrors, strict bool) error {
+	// get from path
+	{
+		s := pathValue(r, "id")
+		if s == "" {
+			if err := errs.add(newParamError("id", "required", "id must be not empty")); err != nil {
+				return err
+			}
+		}
+		v, err := strconv.Atoi(s)
+		if errors.Is(err, strconv.ErrRange) {
+			if err := errs.add(newParamError("id", "type", "id is out of int range", "type", "int")); err != nil {
+				return err
+			}
+		}
+		if err != nil {
+			if err := errs.add(newParamError("id", "type", "id must be int", "type", "int")); err != nil {
+				return err
+			}
+		}
+		p.ID = v
+	}
+	if mediaType(r) == "application/json" {
 		// get from json body
 		defer io.Copy(io.Discard, r.Body)
-		req := struct {
-			ID      *int     `json:"id"`
+		var req struct {
 			Name    *string  `json:"name"`
 			Skill   *float64 `json:"skill"`
 			Latency *float64 `json:"latency"`
-		}{}
-		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-			return /*bad json*/ err
 		}
-		if req.ID == nil {
-			return errors.New("id must be not empty")
+		dec := json.NewDecoder(r.Body)
+		if strict {
+			dec.DisallowUnknownFields()
 		}
-		if req.Name == nil {
-			return errors.New("name must be not empty")
+		if err := dec.Decode(&req); err != nil {
+			return /*bad json*/ err
 		}
-		if req.Skill == nil {
-			return errors.New("skill must be not empty")
+		if strict {
+			if _, err := dec.Token(); err != io.EOF {
+				if err == nil {
+					err = errors.New("unexpected data after json body")
+				}
+				return err
+			}
 		}
-		if req.Latency == nil {
-			return errors.New("latency must be not empty")
+		if req.Name != nil {
+			p.Name = *req.Name
+		} else {
+			if err := errs.add(newParamError("name", "required", "name must be not empty")); err != nil {
+				return err
+			}
 		}
-		p.ID = *req.ID
-		p.Name = *req.Name
-		p.Skill = *req.Skill
-		p.Latency = *req.Latency
-	} else {
-		// get from form or query
-		{
-			s := r.FormValue("id")
-			if s == "" {
-				return errors.New("id must be not empty")
+		if req.Skill != nil {
+			p.Skill = *req.Skill
+		} else {
+			if err := errs.add(newParamError("skill", "required", "skill must be not empty")); err != nil {
+				return err
 			}
-			v, err := strconv.Atoi(s)
-			if err != nil {
-				return errors.New("id must be int")
+		}
+		if req.Latency != nil {
+			p.Latency = *req.Latency
+		} else {
+			if err := errs.add(newParamError("latency", "required", "latency must be not empty")); err != nil {
+				return err
 			}
-			p.ID = v
 		}
+	} else {
+		// get from form or query
 		{
 			s := r.FormValue("name")
 			if s == "" {
-				return errors.New("name must be not empty")
+				if err := errs.add(newParamError("name", "required", "name must be not empty")); err != nil {
+					return err
+				}
 			}
 			p.Name = s
 		}
 		{
 			s := r.FormValue("skill")
 			if s == "" {
-				return errors.New("skill must be not empty")
+				if err := errs.add(newParamError("skill", "required", "skill must be not empty")); err != nil {
+					return err
+				}
 			}
 			v, err := strconv.ParseFloat(s, 64)
+			if errors.Is(err, strconv.ErrRange) {
+				if err := errs.add(newParamError("skill", "type", "skill is out of float64 range", "type", "float64")); err != nil {
+					return err
+				}
+			}
 			if err != nil {
-				return errors.New("skill must be float64")
+				if err := errs.add(newParamError("skill", "type", "skill must be float64", "type", "float64")); err != nil {
+					return err
+				}
 			}
 			p.Skill = v
 		}
 		{
 			s := r.FormValue("latency")
 			if s == "" {
-				return errors.New("latency must be not empty")
+				if err := errs.add(newParamError("latency", "required", "latency must be not empty")); err != nil {
+					return err
+				}
 			}
 			v, err := strconv.ParseFloat(s, 64)
+			if errors.Is(err, strconv.ErrRange) {
+				if err := errs.add(newParamError("latency", "type", "latency is out of float64 range", "type", "float64")); err != nil {
+					return err
+				}
+			}
 			if err != nil {
-				return errors.New("latency must be float64")
+				if err := errs.add(newParamError("latency", "type", "latency must be float64", "type", "float64")); err != nil {
+					return err
+				}
 			}
 			p.Latency = v
 		}
@@ -422,15 +1031,21 @@ func (p *UpdateUser) getFromRequest(r *http.Request) error {
 	return nil
 }
 
-func (p *UpdateUser) validate() error {
+func (p *UpdateUser) validate(errs *ParamErrors) error {
 	if !(p.ID > 0) {
-		return errors.New("id must be > 0")
+		if err := errs.add(newParamError("id", "greater", "id must be > 0", "greater", "0")); err != nil {
+			return err
+		}
 	}
 	if !(p.Skill >= 0) {
-		return errors.New("skill must be >= 0")
+		if err := errs.add(newParamError("skill", "min", "skill must be >= 0", "min", "0")); err != nil {
+			return err
+		}
 	}
 	if !(p.Latency > 0) {
-		return errors.New("latency must be > 0")
+		if err := errs.add(newParamError("latency", "greater", "latency must be > 0", "greater", "0")); err != nil {
+			return err
+		}
 	}
 	return nil
 }
@@ -5,7 +5,7 @@ import (
 	"log"
 )
 
-// apigen:api {"url": "/users", "method": "POST"}
+// apigen:api {"url": "/users", "method": "POST", "status": 201}
 func (api *Service) CreateUser(ctx context.Context, params CreateUser) (NewUser, error) {
 	const op = "CreateUser"
 	// TODO
@@ -13,7 +13,7 @@ func (api *Service) CreateUser(ctx context.Context, params CreateUser) (NewUser,
 	return NewUser{ID: 1}, nil
 }
 
-// apigen:api {"url": "/users", "method": "GET", "auth": true}
+// apigen:api {"url": "/users/{id}", "method": "GET", "auth": true}
 func (api *Service) GetUser(ctx context.Context, params GetUser) (User, error) {
 	const op = "GetUser"
 	// TODO
@@ -21,7 +21,7 @@ func (api *Service) GetUser(ctx context.Context, params GetUser) (User, error) {
 	return User{ID: 1, Name: "Vasya", Skill: 100500, Latency: 10}, nil
 }
 
-// apigen:api {"url": "/users", "method": "PUT", "auth": true}
+// apigen:api {"url": "/users/{id}", "method": "PUT", "auth": true, "status": 204}
 func (api *Service) UpdateUser(ctx context.Context, params UpdateUser) (None, error) {
 	const op = "UpdateUser"
 	// TODO
@@ -29,7 +29,7 @@ func (api *Service) UpdateUser(ctx context.Context, params UpdateUser) (None, er
 	return None{}, nil
 }
 
-// apigen:api {"url": "/users", "method": "DELETE", "auth": true}
+// apigen:api {"url": "/users/{id}", "method": "DELETE", "auth": true, "status": 204}
 func (api *Service) DeleteUser(ctx context.Context, params DeleteUser) (None, error) {
 	const op = "DeleteUser"
 	// TODO
@@ -2,12 +2,10 @@ package main
 
 import (
 	"bytes"
+	"errors"
 	"flag"
 	"fmt"
-	"go/ast"
-	"go/parser"
-	"go/token"
-	"io/fs"
+	"io"
 	"log"
 	"os"
 	"path/filepath"
@@ -16,21 +14,48 @@ import (
 	"apigen/internal/apigen"
 )
 
+// options of the generation of every package
+type options struct {
+	pkgName   string
+	outFile   string
+	openAPI   string
+	servs     string
+	encoders  string
+	templates string
+	client    bool
+	check     bool
+}
+
+// result of the generation for the package
+type result int
+
+const (
+	written  result = iota
+	upToDate        // checked file is up to date
+	stale           // checked file differs from the generated code
+	skipped         // package has no apigen:api marks or other name
+)
+
 func main() {
 	log.SetFlags(0)
 	log.SetPrefix("apigen: ")
 
 	flag.Usage = func() {
-		fmt.Fprintf(flag.CommandLine.Output(), "Usage: %s [opts] {src_dir | <src_file>...}\n", filepath.Base(os.Args[0]))
+		fmt.Fprintf(flag.CommandLine.Output(), "Usage: %s [opts] {src_dir | <src_file>... | <pkg_pattern>...}\n", filepath.Base(os.Args[0]))
+		fmt.Fprintf(flag.CommandLine.Output(), "The package patterns like ./... generate the code of every package having apigen:api marks.\n")
+		fmt.Fprintf(flag.CommandLine.Output(), "The defaults of the flags are set by apigen.yaml or apigen.json in the module root.\n")
 		flag.PrintDefaults()
 	}
 
-	var (
-		pkgName string
-		outFile string
-	)
-	flag.StringVar(&pkgName, "p", "", "package name")
-	flag.StringVar(&outFile, "o", "", "output file name, by default output to <pkg_name>_apigen.go, if '-' output to stdout")
+	var opts options
+	flag.StringVar(&opts.pkgName, "p", "", "package name")
+	flag.StringVar(&opts.outFile, "o", "", "output file name, by default output to <pkg_name>_apigen.go, if '-' output to stdout")
+	flag.StringVar(&opts.openAPI, "openapi", "", "output OpenAPI 3.1 document in json or yaml format instead of code,\nby default output to <pkg_name>_openapi.{json|yaml}")
+	flag.BoolVar(&opts.client, "client", false, "output HTTP client code instead of server code to the client package named by its dir,\nby default output to <pkg_name>client/client_apigen.go")
+	flag.StringVar(&opts.servs, "s", "", "comma separated list of services to describe in OpenAPI document, by default all")
+	flag.StringVar(&opts.encoders, "encoders", "", "comma separated list of response encodings besides json: xml, msgpack, cbor")
+	flag.StringVar(&opts.templates, "templates", "", "directory of *.tmpl files redefining the default templates of server code by name")
+	flag.BoolVar(&opts.check, "check", false, "check the output file is up to date instead of writing it,\nif it is not, print the diff and exit with status 1")
 	flag.Parse()
 
 	args := flag.Args()
@@ -39,69 +64,282 @@ func main() {
 		os.Exit(1)
 	}
 
+	cfgs := configs{}
+	if isPatterns(args) {
+		os.Exit(generateAll(args, opts, cfgs))
+	}
+
 	dir, files, err := parseArgs(args)
 	if err != nil {
 		log.Fatal(err)
 	}
-
-	fset := token.NewFileSet()
-	pkgs, err := parser.ParseDir(fset, dir, newFileFilter(files), parser.ParseComments)
+	conf, err := cfgs.get(dir)
+	if err != nil {
+		log.Fatal(err)
+	}
+	res, err := generate(dir, files, conf, opts, false)
 	if err != nil {
-		log.Fatalf("can't parser.ParseDir: %v", err)
+		log.Fatal(err)
+	}
+	if res == stale {
+		os.Exit(1)
 	}
+}
 
-	if len(pkgs) == 0 {
-		log.Fatalf("not any package was detected")
+// reports whether the args are package patterns like ./... instead of the
+// directory or files.
+func isPatterns(args []string) bool {
+	for _, arg := range args {
+		if strings.Contains(arg, "...") {
+			return true
+		}
 	}
+	return false
+}
 
-	pkgNames := make([]string, 0, len(pkgs))
-	for k := range pkgs {
-		pkgNames = append(pkgNames, k)
+// generates the code of the packages matched by the patterns, skipping the
+// ones without the marked methods, and returns the exit status.
+func generateAll(patterns []string, opts options, cfgs configs) int {
+	if opts.outFile != "" {
+		log.Fatal("-o can't be used with package patterns")
 	}
 
-	var pkg *ast.Package
-	if pkgName != "" {
-		v, ok := pkgs[pkgName]
-		if !ok {
-			log.Fatalf("%v package not found. available: %v", pkgName, strings.Join(pkgNames, ", "))
+	dirs, err := apigen.ListPackages(patterns...)
+	if err != nil {
+		log.Fatal(err)
+	}
+	// the configs are validated before the generation of any package
+	confs := make([]apigen.Config, len(dirs))
+	for i, dir := range dirs {
+		if confs[i], err = cfgs.get(dir); err != nil {
+			log.Fatal(err)
 		}
-		pkg = v
-	} else {
-		if len(pkgNames) > 1 {
-			log.Fatalf("detected more one packages: %v. please select one of them by -p flag", strings.Join(pkgNames, ", "))
+	}
+
+	counts := map[result]int{}
+	failed := 0
+	for i, dir := range dirs {
+		res, err := generate(dir, nil, confs[i], opts, true)
+		if err != nil {
+			log.Printf("%s: FAIL %v", dir, err)
+			failed++
+			continue
 		}
-		pkg = pkgs[pkgNames[0]]
+		counts[res]++
 	}
 
-	genCfg, err := apigen.ParseFiles(pkg.Files)
+	summary := []string{fmt.Sprintf("%d packages", len(dirs))}
+	for _, c := range []struct {
+		n    int
+		what string
+	}{
+		{counts[written], "generated"},
+		{counts[upToDate], "up to date"},
+		{counts[stale], "stale"},
+		{counts[skipped], "skipped"},
+		{failed, "failed"},
+	} {
+		if c.n > 0 {
+			summary = append(summary, fmt.Sprintf("%d %s", c.n, c.what))
+		}
+	}
+	log.Printf("summary: %s", strings.Join(summary, ", "))
+
+	if failed > 0 || counts[stale] > 0 {
+		return 1
+	}
+	return 0
+}
+
+// generates the outputs of the package in the dir, the flags take precedence
+// over the config. If skipEmpty is true, the package without apigen:api marks
+// is skipped.
+func generate(dir string, files []string, conf apigen.Config, opts options, skipEmpty bool) (result, error) {
+	pkg, err := apigen.LoadPackage(dir, files)
 	if err != nil {
-		if e, ok := err.(*apigen.ParseError); ok {
-			log.Fatalf("%v: %v", fset.Position(e.Pos), e.Error())
+		return 0, err
+	}
+	if opts.pkgName != "" && opts.pkgName != pkg.Name {
+		if skipEmpty {
+			return skipped, nil
 		}
-		log.Fatal(err)
+		return 0, fmt.Errorf("%v package not found. available: %v", opts.pkgName, pkg.Name)
 	}
 
-	var buf bytes.Buffer
-	if err := apigen.GenCode(&buf, genCfg); err != nil {
-		if e, ok := err.(*apigen.ParseError); ok {
-			log.Fatalf("%v: %v", fset.Position(e.Pos), e.Error())
+	genCfg, err := apigen.Parse(pkg, conf.ParseOptions())
+	if err != nil {
+		return 0, positionError(pkg, err)
+	}
+	if skipEmpty && len(genCfg.Services()) == 0 {
+		log.Printf("%s: SKIP package %s has no apigen:api marks", dir, pkg.Name)
+		return skipped, nil
+	}
+
+	if opts.encoders != "" {
+		conf.Encoders = strings.Split(opts.encoders, ",")
+	}
+	if opts.templates != "" {
+		conf.Templates = opts.templates
+	}
+	if opts.openAPI != "" {
+		conf.OpenAPI = opts.openAPI
+	}
+	if conf.OpenAPI == "" {
+		conf.OpenAPI = "json"
+	}
+
+	kinds := conf.Generate
+	switch {
+	case opts.client:
+		kinds = []string{apigen.ClientOutput}
+	case opts.openAPI != "":
+		kinds = []string{apigen.OpenAPIOutput}
+	case len(kinds) == 0:
+		kinds = []string{apigen.ServerOutput}
+	}
+	if opts.outFile != "" && len(kinds) > 1 {
+		return 0, fmt.Errorf("-o can't be used with several outputs: %s", strings.Join(kinds, ", "))
+	}
+
+	// the outputs are all written or all checked, so the result of the
+	// package is the greatest one: written, up to date or stale
+	res := written
+	for _, kind := range kinds {
+		r, err := output(dir, pkg, genCfg, kind, conf, opts)
+		if err != nil {
+			return 0, err
 		}
-		log.Fatal(err)
+		res = max(res, r)
 	}
+	return res, nil
+}
 
+// generates the output of the kind and writes or checks its file.
+func output(dir string, pkg *apigen.Package, genCfg apigen.GenConfig, kind string, conf apigen.Config, opts options) (result, error) {
+	outFile := opts.outFile
 	if outFile == "" {
-		outFile = dir + "/" + pkg.Name + "_apigen.go"
+		outFile = filepath.Join(dir, filepath.FromSlash(conf.OutputFile(kind, pkg.Name)))
+	}
+
+	var (
+		gen  func(w io.Writer) error
+		hash string // inputs hash written to the header of the code, OpenAPI has none
+		err  error
+	)
+	switch kind {
+	case apigen.ClientOutput:
+		var o apigen.ClientOptions
+		if o.Package, err = clientPackage(dir, outFile); err != nil {
+			return 0, err
+		}
+		hash, err = apigen.ClientHash(genCfg, o)
+		gen = func(w io.Writer) error { return apigen.GenClient(w, genCfg, o) }
+	case apigen.OpenAPIOutput:
+		o := apigen.OpenAPIOptions{Format: conf.OpenAPI, ContentTypes: conf.ContentTypes}
+		if opts.servs != "" {
+			o.Services = strings.Split(opts.servs, ",")
+		}
+		gen = func(w io.Writer) error { return apigen.GenOpenAPI(w, genCfg, o) }
+	default:
+		o := apigen.CodeOptions{
+			Encoders:     conf.Encoders,
+			Templates:    conf.Templates,
+			ContentTypes: conf.ContentTypes,
+			Names:        conf.Names,
+		}
+		hash, err = apigen.CodeHash(genCfg, o)
+		gen = func(w io.Writer) error { return apigen.GenCode(w, genCfg, o) }
+	}
+	if err != nil {
+		return 0, err
+	}
+
+	if opts.check {
+		if outFile == "-" {
+			return 0, errors.New("-check needs the output file")
+		}
+		// the code of the same inputs is not generated again
+		if old, err := os.ReadFile(outFile); err == nil && hash != "" && apigen.GeneratedHash(old) == hash {
+			log.Printf("%s: up to date", outFile)
+			return upToDate, nil
+		}
+	}
+
+	var buf bytes.Buffer
+	if err := gen(&buf); err != nil {
+		return 0, positionError(pkg, err)
+	}
+
+	if opts.check {
+		return checkFile(outFile, buf.Bytes())
 	}
 
 	if outFile == "-" {
 		if _, err := buf.WriteTo(os.Stdout); err != nil {
-			log.Fatal(err)
+			return 0, err
 		}
 	} else {
+		if err := os.MkdirAll(filepath.Dir(outFile), 0777); err != nil {
+			return 0, err
+		}
 		if err := os.WriteFile(outFile, buf.Bytes(), 0666); err != nil {
-			log.Fatal(err)
+			return 0, err
+		}
+	}
+	return written, nil
+}
+
+// returns the name of the client package by the dir of its file, empty for
+// stdout. The client declares the types of the package in the dir, so it
+// can't be in the same dir.
+func clientPackage(dir, outFile string) (string, error) {
+	if outFile == "-" {
+		return "", nil
+	}
+	pkgDir, err := filepath.Abs(dir)
+	if err != nil {
+		return "", err
+	}
+	clientDir, err := filepath.Abs(filepath.Dir(outFile))
+	if err != nil {
+		return "", err
+	}
+	if clientDir == pkgDir {
+		return "", fmt.Errorf("%s: client must be in the dir of its own package", outFile)
+	}
+	return filepath.Base(clientDir), nil
+}
+
+// configs of the modules by the path of the config file
+type configs map[string]*apigen.Config
+
+// returns the config of the package in the dir. The config of its module is
+// loaded and validated once.
+func (cs configs) get(dir string) (apigen.Config, error) {
+	file, err := apigen.FindConfig(dir)
+	if err != nil {
+		return apigen.Config{}, err
+	}
+	c, ok := cs[file]
+	if !ok {
+		c = &apigen.Config{}
+		if file != "" {
+			if c, err = apigen.LoadConfig(file); err != nil {
+				return apigen.Config{}, err
+			}
+			log.Printf("config %s", file)
 		}
+		cs[file] = c
+	}
+	return c.Package(dir)
+}
+
+// returns the error with the position in the package if it is ParseError.
+func positionError(pkg *apigen.Package, err error) error {
+	if e, ok := err.(*apigen.ParseError); ok {
+		return fmt.Errorf("%v: %v", pkg.Fset.Position(e.Pos), e.Error())
 	}
+	return err
 }
 
 func parseArgs(args []string) (dir string, files []string, _ error) {
@@ -133,19 +371,23 @@ func parseArgs(args []string) (dir string, files []string, _ error) {
 	return dir, files, nil
 }
 
-func newFileFilter(files []string) func(fs.FileInfo) bool {
-	return func(fileInfo fs.FileInfo) bool {
-		if strings.HasSuffix(fileInfo.Name(), "_apigen.go") {
-			return false
-		}
-		if len(files) == 0 {
-			return true
-		}
-		for _, fn := range files {
-			if fn == fileInfo.Name() {
-				return true
-			}
+// compares the file with the generated code and prints the diff if they differ.
+func checkFile(fp string, code []byte) (result, error) {
+	old, err := os.ReadFile(fp)
+	if err != nil {
+		if os.IsNotExist(err) {
+			log.Printf("%s: not generated", fp)
+			return stale, nil
 		}
-		return false
+		return 0, err
+	}
+
+	diff := apigen.UnifiedDiff(fp, fp+" (generated)", old, code)
+	if diff == "" {
+		log.Printf("%s: up to date", fp)
+		return upToDate, nil
 	}
+	fmt.Print(diff)
+	log.Printf("%s: stale, regenerate it", fp)
+	return stale, nil
 }
@@ -0,0 +1,852 @@
+package apigen
+
+import (
+	"bytes"
+	"fmt"
+	"go/token"
+	"go/types"
+	"io"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+var clientImports = []string{"bytes", "context", "encoding", "encoding/json", "errors", "fmt", "io", "mime", "mime/multipart", "net/http", "net/textproto", "net/url", "strings"}
+
+// ClientOptions are the options of the client code.
+type ClientOptions struct {
+	Package string // name of the client package, <pkg>client by default
+}
+
+// GenClient writes HTTP client code for every service to its own package, so
+// other services use it without the server package. The client encodes params
+// the same way the generated getFromRequest decodes them. The types of the
+// server package used by the methods are declared in the client package
+// without their methods, see clientTypes.
+func GenClient(w io.Writer, cfg GenConfig, opts ClientOptions) error {
+	const op = "GenClient"
+
+	pkgName, err := clientPackageName(cfg, opts)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	var body bytes.Buffer
+	p := newPrinter(&body)
+	p.pkg = cfg.pkg
+
+	order := sortedKeys(cfg.servs.items)
+	log.Printf("%s: generate clients for services: %v", op, strings.Join(order, ", "))
+
+	ct := newClientTypes(p, order)
+	for _, servName := range order {
+		for _, m := range cfg.servs.items[servName] {
+			if err := ct.addMethod(m); err != nil {
+				return err
+			}
+		}
+	}
+	if err := ct.declare(); err != nil {
+		return err
+	}
+
+	if err := genApiClient(p, ct.files); err != nil {
+		return err
+	}
+	ct.print()
+
+	for _, servName := range order {
+		if err := genServiceClient(p, servName); err != nil {
+			return err
+		}
+		for _, m := range cfg.servs.items[servName] {
+			if err := genClientMethod(p, ct, m, cfg.params.items[m.params.name]); err != nil {
+				return err
+			}
+		}
+	}
+
+	return newPrinter(w).printFile(pkgName, inputsHash(cfg, "client", pkgName), clientImports, p, &body)
+}
+
+// ClientHash returns the inputs hash GenClient writes to the header of the code,
+// so the code is checked by GeneratedHash without generating it.
+func ClientHash(cfg GenConfig, opts ClientOptions) (string, error) {
+	const op = "ClientHash"
+
+	pkgName, err := clientPackageName(cfg, opts)
+	if err != nil {
+		return "", fmt.Errorf("%s: %w", op, err)
+	}
+	return inputsHash(cfg, "client", pkgName), nil
+}
+
+// returns name of the client package, the server package name with client
+// suffix by default.
+func clientPackageName(cfg GenConfig, opts ClientOptions) (string, error) {
+	pkgName := opts.Package
+	if pkgName == "" {
+		pkgName = cfg.packageName + "client"
+	}
+	if !token.IsIdentifier(pkgName) {
+		return "", fmt.Errorf("%q is not the package name", pkgName)
+	}
+	return pkgName, nil
+}
+
+// names declared by the client code besides the service clients
+var clientNames = []string{
+	"apiClient", "queryValue", "ApiError", "ParamError", "ParamErrors", "AccessError",
+	"problem", "apiResponse", "apiErrorResponse", "File", "multipartForm", "newMultipartForm",
+}
+
+func genApiClient(p *printer, files bool) error {
+	p.printf(``)
+	p.printf(`type apiClient struct {`)
+	p.printf(`	// BaseURL is prepended to the method URLs, e.g. http://localhost:8080`)
+	p.printf(`	BaseURL string`)
+	p.printf(`	// HTTPClient is used to send requests, http.DefaultClient if nil`)
+	p.printf(`	HTTPClient *http.Client`)
+	p.printf(`	// Auth sets credentials to requests to the methods marked with "auth": true`)
+	p.printf(`	Auth func(r *http.Request)`)
+	p.printf(`}`)
+
+	p.printf(``)
+	p.printf(`// ApiError is the error response of the service with its HTTP status.`)
+	p.printf(`type ApiError struct {`)
+	p.printf(`	HTTPStatus int`)
+	p.printf(`	Err        error`)
+	p.printf(`}`)
+	p.printf(``)
+	p.printf(`func (ae ApiError) Error() string {`)
+	p.printf(`	return ae.Err.Error()`)
+	p.printf(`}`)
+	p.printf(``)
+	p.printf(`func (ae ApiError) Unwrap() error {`)
+	p.printf(`	return ae.Err`)
+	p.printf(`}`)
+
+	p.printf(``)
+	p.printf(`// ParamError describes the invalid request param, it is ApiError.Err of 400 status.`)
+	p.printf(`type ParamError struct {`)
+	p.printf(`	Code   string            ` + q + `json:"code"` + q + `             // stable error code, e.g. param_max`)
+	p.printf(`	Field  string            ` + q + `json:"field"` + q + `            // api name of the param, e.g. settings.region`)
+	p.printf(`	Rule   string            ` + q + `json:"rule"` + q + `             // violated rule, e.g. max`)
+	p.printf(`	Params map[string]string ` + q + `json:"params,omitempty"` + q + ` // rule params, e.g. {"max": "128"}`)
+	p.printf(`	Msg    string            ` + q + `json:"message"` + q)
+	p.printf(`}`)
+	p.printf(``)
+	p.printf(`func (e *ParamError) Error() string {`)
+	p.printf(`	return e.Msg`)
+	p.printf(`}`)
+
+	p.printf(``)
+	p.printf(`// ParamErrors are all invalid params of the request to the methods marked with "allErrors": true.`)
+	p.printf(`type ParamErrors []*ParamError`)
+	p.printf(``)
+	p.printf(`func (e ParamErrors) Error() string {`)
+	p.printf(`	msgs := make([]string, len(e))`)
+	p.printf(`	for i, pe := range e {`)
+	p.printf(`		msgs[i] = pe.Msg`)
+	p.printf(`	}`)
+	p.printf(`	return strings.Join(msgs, "; ")`)
+	p.printf(`}`)
+	p.printf(``)
+	p.printf(`func (e ParamErrors) Unwrap() []error {`)
+	p.printf(`	errs := make([]error, len(e))`)
+	p.printf(`	for i, pe := range e {`)
+	p.printf(`		errs[i] = pe`)
+	p.printf(`	}`)
+	p.printf(`	return errs`)
+	p.printf(`}`)
+
+	p.printf(``)
+	p.printf(`// AccessError describes the missing role or scope of the caller, it is ApiError.Err of 403 status.`)
+	p.printf(`type AccessError struct {`)
+	p.printf(`	Code   string            // missing_role or missing_scope`)
+	p.printf(`	Params map[string]string // required roles or scopes, e.g. {"roles": "admin|moderator"}`)
+	p.printf(`	Msg    string`)
+	p.printf(`}`)
+	p.printf(``)
+	p.printf(`func (e *AccessError) Error() string {`)
+	p.printf(`	return e.Msg`)
+	p.printf(`}`)
+
+	p.printf(``)
+	p.printf(`// problem is RFC 9457 problem details of the error response.`)
+	p.printf(`type problem struct {`)
+	p.printf(`	Detail string            ` + q + `json:"detail"` + q)
+	p.printf(`	Code   string            ` + q + `json:"code"` + q)
+	p.printf(`	Field  string            ` + q + `json:"field"` + q)
+	p.printf(`	Rule   string            ` + q + `json:"rule"` + q)
+	p.printf(`	Params map[string]string ` + q + `json:"params"` + q)
+	p.printf(`	Errors ParamErrors       ` + q + `json:"errors"` + q)
+	p.printf(`}`)
+
+	p.printf(``)
+	p.printf(`// apiResponse is the response body of the service method.`)
+	p.printf(`type apiResponse struct {`)
+	p.printf(`	Response any    ` + q + `json:"response"` + q)
+	p.printf(`	Error    string ` + q + `json:"error"` + q)
+	p.printf(`}`)
+	p.printf(``)
+	p.printf(`// apiErrorResponse is the response body of the failed request.`)
+	p.printf(`type apiErrorResponse struct {`)
+	p.printf(`	Error  string      ` + q + `json:"error"` + q)
+	p.printf(`	Errors ParamErrors ` + q + `json:"errors"` + q)
+	p.printf(`}`)
+
+	if files {
+		genMultipartForm(p)
+	}
+
+	p.printf(``)
+	p.printf(`// queryValue formats the query or path param value, by MarshalText if implemented.`)
+	p.printf(`func queryValue(v any) string {`)
+	p.printf(`switch v := v.(type) {`)
+	p.printf(`case encoding.TextMarshaler:`)
+	p.printf(`	if b, err := v.MarshalText(); err == nil {`)
+	p.printf(`		return string(b)`)
+	p.printf(`	}`)
+	p.printf(`case json.RawMessage:`)
+	p.printf(`	return string(v)`)
+	p.printf(`}`)
+	p.printf(`return fmt.Sprint(v)`)
+	p.printf(`}`)
+
+	p.printf(``)
+	p.printf(`// do sends the request and decodes the response body of the success status to res if not nil.`)
+	p.printf(`func (c *apiClient) do(ctx context.Context, method, path string, query url.Values, body any, header http.Header, auth bool, status int, res any) error {`)
+	p.printf(`u := strings.TrimSuffix(c.BaseURL, "/") + path`)
+	p.printf(`if len(query) > 0 {`)
+	p.printf(`	u += "?" + query.Encode()`)
+	p.printf(`}`)
+
+	p.printf(`var (`)
+	p.printf(`	reqBody     io.Reader`)
+	p.printf(`	contentType string`)
+	p.printf(`)`)
+	p.printf(`switch body := body.(type) {`)
+	p.printf(`case nil:`)
+	if files {
+		p.printf(`case *multipartForm:`)
+		p.printf(`	if body.err == nil {`)
+		p.printf(`		body.err = body.w.Close()`)
+		p.printf(`	}`)
+		p.printf(`	if body.err != nil {`)
+		p.printf(`		return body.err`)
+		p.printf(`	}`)
+		p.printf(`	reqBody, contentType = &body.buf, body.w.FormDataContentType()`)
+	}
+	p.printf(`default:`)
+	p.printf(`	b, err := json.Marshal(body)`)
+	p.printf(`	if err != nil {`)
+	p.printf(`		return err`)
+	p.printf(`	}`)
+	p.printf(`	reqBody, contentType = bytes.NewReader(b), "application/json"`)
+	p.printf(`}`)
+
+	p.printf(`req, err := http.NewRequestWithContext(ctx, method, u, reqBody)`)
+	p.printf(`if err != nil {`)
+	p.printf(`	return err`)
+	p.printf(`}`)
+	p.printf(`if contentType != "" {`)
+	p.printf(`	req.Header.Set("content-type", contentType)`)
+	p.printf(`}`)
+	p.printf(`for k, vs := range header {`)
+	p.printf(`	req.Header[k] = vs`)
+	p.printf(`}`)
+	p.printf(`req.Header.Set("accept", "application/json, application/problem+json")`)
+	p.printf(`if auth && c.Auth != nil {`)
+	p.printf(`	c.Auth(req)`)
+	p.printf(`}`)
+
+	p.printf(`client := c.HTTPClient`)
+	p.printf(`if client == nil {`)
+	p.printf(`	client = http.DefaultClient`)
+	p.printf(`}`)
+	p.printf(`resp, err := client.Do(req)`)
+	p.printf(`if err != nil {`)
+	p.printf(`	return err`)
+	p.printf(`}`)
+	p.printf(`defer resp.Body.Close()`)
+
+	p.printf(`if resp.StatusCode != status && resp.Header.Get("content-type") == "application/problem+json" {`)
+	p.printf(`	var prob problem`)
+	p.printf(`	if err := json.NewDecoder(resp.Body).Decode(&prob); err != nil {`)
+	p.printf(`		return ApiError{HTTPStatus: resp.StatusCode, Err: errors.New(http.StatusText(resp.StatusCode))}`)
+	p.printf(`	}`)
+	p.printf(`	if len(prob.Errors) > 0 {`)
+	p.printf(`		return ApiError{HTTPStatus: resp.StatusCode, Err: prob.Errors}`)
+	p.printf(`	}`)
+	p.printf(`	if prob.Code == "missing_role" || prob.Code == "missing_scope" {`)
+	p.printf(`		return ApiError{HTTPStatus: resp.StatusCode, Err: &AccessError{Code: prob.Code, Params: prob.Params, Msg: prob.Detail}}`)
+	p.printf(`	}`)
+	p.printf(`	if prob.Field != "" {`)
+	p.printf(`		pe := &ParamError{Code: prob.Code, Field: prob.Field, Rule: prob.Rule, Params: prob.Params, Msg: prob.Detail}`)
+	p.printf(`		return ApiError{HTTPStatus: resp.StatusCode, Err: pe}`)
+	p.printf(`	}`)
+	p.printf(`	return ApiError{HTTPStatus: resp.StatusCode, Err: errors.New(prob.Detail)}`)
+	p.printf(`}`)
+
+	p.printf(`if resp.StatusCode != status {`)
+	p.printf(`	var env apiErrorResponse`)
+	p.printf(`	if err := json.NewDecoder(resp.Body).Decode(&env); err != nil {`)
+	p.printf(`		return ApiError{HTTPStatus: resp.StatusCode, Err: errors.New(http.StatusText(resp.StatusCode))}`)
+	p.printf(`	}`)
+	p.printf(`	if len(env.Errors) > 0 {`)
+	p.printf(`		return ApiError{HTTPStatus: resp.StatusCode, Err: env.Errors}`)
+	p.printf(`	}`)
+	p.printf(`	return ApiError{HTTPStatus: resp.StatusCode, Err: errors.New(env.Error)}`)
+	p.printf(`}`)
+	p.printf(`if res == nil {`)
+	p.printf(`	return nil`)
+	p.printf(`}`)
+	p.printf(`if err := json.NewDecoder(resp.Body).Decode(res); err != nil {`)
+	p.printf(`	return fmt.Errorf("can't decode response: %%w", err)`)
+	p.printf(`}`)
+	p.printf(`return nil`)
+	p.printf(`}`)
+
+	return p.err
+}
+
+// generates File and the multipart form encoder of the methods uploading files.
+func genMultipartForm(p *printer) {
+	p.printf(``)
+	p.printf(`// File is the file uploaded in the multipart form.`)
+	p.printf(`type File struct {`)
+	p.printf(`	Name        string    // file name`)
+	p.printf(`	ContentType string    // media type, application/octet-stream if empty`)
+	p.printf(`	Content     io.Reader // read when the request is sent`)
+	p.printf(`}`)
+
+	p.printf(``)
+	p.printf(`// multipartForm is the request body of the methods uploading files.`)
+	p.printf(`// The first error of writing is kept and returned by do.`)
+	p.printf(`type multipartForm struct {`)
+	p.printf(`	buf bytes.Buffer`)
+	p.printf(`	w   *multipart.Writer`)
+	p.printf(`	err error`)
+	p.printf(`}`)
+	p.printf(``)
+	p.printf(`func newMultipartForm() *multipartForm {`)
+	p.printf(`	f := &multipartForm{}`)
+	p.printf(`	f.w = multipart.NewWriter(&f.buf)`)
+	p.printf(`	return f`)
+	p.printf(`}`)
+	p.printf(``)
+	p.printf(`func (f *multipartForm) field(name, value string) {`)
+	p.printf(`	if f.err == nil {`)
+	p.printf(`		f.err = f.w.WriteField(name, value)`)
+	p.printf(`	}`)
+	p.printf(`}`)
+	p.printf(``)
+	p.printf(`func (f *multipartForm) file(name string, file *File) {`)
+	p.printf(`	if f.err != nil || file == nil {`)
+	p.printf(`		return`)
+	p.printf(`	}`)
+	p.printf(`	ct := file.ContentType`)
+	p.printf(`	if ct == "" {`)
+	p.printf(`		ct = "application/octet-stream"`)
+	p.printf(`	}`)
+	p.printf(`	h := textproto.MIMEHeader{}`)
+	p.printf(`	h.Set("Content-Disposition", mime.FormatMediaType("form-data", map[string]string{"name": name, "filename": file.Name}))`)
+	p.printf(`	h.Set("Content-Type", ct)`)
+	p.printf(`	part, err := f.w.CreatePart(h)`)
+	p.printf(`	if err == nil && file.Content != nil {`)
+	p.printf(`		_, err = io.Copy(part, file.Content)`)
+	p.printf(`	}`)
+	p.printf(`	f.err = err`)
+	p.printf(`}`)
+}
+
+func genServiceClient(p *printer, servName string) error {
+	p.printf(``)
+	p.printf(`// %sClient is the HTTP client of %s API.`, servName, servName)
+	p.printf(`type %sClient struct {`, servName)
+	p.printf(`	apiClient`)
+	p.printf(`}`)
+
+	p.printf(``)
+	p.printf(`func New%sClient(baseURL string, httpClient *http.Client, auth func(r *http.Request)) *%sClient {`, servName, servName)
+	p.printf(`	return &%sClient{apiClient{BaseURL: baseURL, HTTPClient: httpClient, Auth: auth}}`, servName)
+	p.printf(`}`)
+
+	return p.err
+}
+
+func genClientMethod(p *printer, ct *clientTypes, m *serviceMethod, fields []*paramStructField) error {
+	const op = "genClientMethod"
+
+	httpMethod := m.HTTPMethod
+	switch {
+	case httpMethod == anyHTTPMethod && m.hasFiles:
+		httpMethod = http.MethodPost
+	case httpMethod == anyHTTPMethod:
+		httpMethod = http.MethodGet
+	case m.hasFiles && !hasRequestBody(httpMethod):
+		return &ParseError{
+			Err: fmt.Errorf("%s: %s.%s: files are uploaded in the request body, but %s has no body", op, m.recv.name, m.name, httpMethod),
+			Pos: m.pos,
+		}
+	}
+
+	var (
+		paramsType = p.typeName(m.params.typ)
+		resultType = p.typeName(m.result.typ)
+		in         = "in"
+	)
+	if m.params.isPointer {
+		paramsType = "*" + paramsType
+	}
+	if m.result.isPointer {
+		resultType = "*" + resultType
+	}
+
+	// the server decodes the text of the field by UnmarshalText, so the client
+	// encodes it by MarshalText. The types of the server package are the text.
+	for _, field := range flatten(fields) {
+		if field.unmarshaler == textUnmarshaler && !ct.isText(field.typ) && !hasMethod(field.typ, "MarshalText", "() []byte, error") {
+			return &ParseError{
+				Err: fmt.Errorf("%s: %s.%s: %s type implements encoding.TextUnmarshaler, but not encoding.TextMarshaler to encode it in the client",
+					op, m.params.name, field.name, p.typeName(field.typ)),
+				Pos: field.pos,
+			}
+		}
+	}
+
+	p.printf(``)
+	if m.doc != "" {
+		for _, line := range strings.Split(m.doc, "\n") {
+			p.printf(`// %s`, line)
+		}
+	} else {
+		p.printf(`// %s calls %s %s`, m.name, httpMethod, m.URL)
+	}
+	p.printf(`func (c *%sClient) %s(ctx context.Context, %s %s) (%s, error) {`, m.recv.name, m.name, in, paramsType, resultType)
+	p.printf(`var res %s`, resultType)
+
+	// the response body decoded by do, the typed envelope field is assigned to the result after
+	var (
+		out      = "&res"
+		envField string
+	)
+	switch {
+	case m.Status == http.StatusNoContent:
+		out = "nil"
+	case m.envelope != nil:
+		out = "&env"
+		if types.IsInterface(m.envelope.field.Type()) {
+			p.printf(`env := %s{%s: &res}`, p.typeName(m.envelope.typ), m.envelope.field.Name())
+		} else {
+			p.printf(`var env %s`, p.typeName(m.envelope.typ))
+			envField = m.envelope.field.Name()
+		}
+	case m.Envelope == defaultEnvelope:
+		out = "&env"
+		p.printf(`env := apiResponse{Response: &res}`)
+	}
+
+	// path
+	var path []string
+	byName := map[string]*paramStructField{}
+	for _, field := range fields {
+		if field.source == pathSource {
+			byName[field.apiParamName()] = field
+		}
+	}
+	for _, seg := range strings.Split(m.URL, "/") {
+		if !strings.HasPrefix(seg, "{") || !strings.HasSuffix(seg, "}") {
+			path = append(path, fmt.Sprintf("%q", seg))
+			continue
+		}
+		field, ok := byName[seg[1:len(seg)-1]]
+		if !ok {
+			return &ParseError{
+				Err: fmt.Errorf("%s: %s.%s: %s param not found in %s", op, m.recv.name, m.name, seg, m.params.name),
+				Pos: m.pos,
+			}
+		}
+		path = append(path, fmt.Sprintf(`url.PathEscape(queryValue(%s.%s))`, in, field.name))
+	}
+	p.printf(`path := strings.Join([]string{%s}, "/")`, strings.Join(path, ", "))
+
+	// headers and cookies
+	var headerFields, bodyFields []*paramStructField
+	for _, field := range fields {
+		switch field.source {
+		case pathSource: // in the path above
+		case headerSource, cookieSource:
+			headerFields = append(headerFields, field)
+		default:
+			bodyFields = append(bodyFields, field)
+		}
+	}
+	header := "nil"
+	if len(headerFields) > 0 {
+		header = "header"
+		p.printf(`header := http.Header{}`)
+	}
+	for _, field := range headerFields {
+		value := fmt.Sprintf(`queryValue(%s.%s)`, in, field.name)
+		set := fmt.Sprintf(`header.Set(%q, %s)`, field.apiParamName(), value)
+		if field.source == cookieSource {
+			set = fmt.Sprintf(`header.Add("Cookie", (&http.Cookie{Name: %q, Value: %s}).String())`, field.apiParamName(), value)
+		}
+		if field.rules&defaultRule != 0 {
+			p.printf(`if %s {`, notZero(p, field, in+"."+field.name))
+			p.printf(`	%s`, set)
+			p.printf(`}`)
+		} else {
+			p.printf(`%s`, set)
+		}
+	}
+
+	// query, json body or multipart form
+
+	switch {
+	case m.hasFiles:
+		p.printf(`form := newMultipartForm()`)
+		for _, field := range flatten(bodyFields) {
+			src := in + "." + field.name
+			switch {
+			case field.kind == File && field.isSlice:
+				p.printf(`for _, f := range %s {`, src)
+				p.printf(`	form.file(%q, f)`, field.apiParamName())
+				p.printf(`}`)
+			case field.kind == File:
+				p.printf(`form.file(%q, %s)`, field.apiParamName(), src)
+			case field.isSlice:
+				p.printf(`for _, v := range %s {`, src)
+				p.printf(`	form.field(%q, queryValue(v))`, field.apiParamName())
+				p.printf(`}`)
+			case field.rules&defaultRule != 0:
+				p.printf(`if %s {`, notZero(p, field, src))
+				p.printf(`	form.field(%q, queryValue(%s))`, field.apiParamName(), src)
+				p.printf(`}`)
+			default:
+				p.printf(`form.field(%q, queryValue(%s))`, field.apiParamName(), src)
+			}
+		}
+		p.printf(`err := c.do(ctx, %q, path, nil, form, %s, %v, %s, %s)`, httpMethod, header, m.Auth, statusExpr(m.Status), out)
+	case hasRequestBody(httpMethod):
+		// zero values of fields with defaults are omitted to get the defaults
+		p.printf(`var body struct {`)
+		genClientBodyFields(p, bodyFields)
+		p.printf(`}`)
+		genClientBodyAssign(p, ct, bodyFields, "body", in)
+		p.printf(`err := c.do(ctx, %q, path, nil, &body, %s, %v, %s, %s)`, httpMethod, header, m.Auth, statusExpr(m.Status), out)
+	default:
+		p.printf(`query := url.Values{}`)
+		for _, field := range flatten(bodyFields) {
+			if field.isSlice {
+				p.printf(`for _, v := range %s.%s {`, in, field.name)
+				p.printf(`	query.Add(%q, queryValue(v))`, field.apiParamName())
+				p.printf(`}`)
+			} else if field.rules&defaultRule != 0 {
+				p.printf(`if %s {`, notZero(p, field, in+"."+field.name))
+				p.printf(`	query.Set(%q, queryValue(%s.%s))`, field.apiParamName(), in, field.name)
+				p.printf(`}`)
+			} else {
+				p.printf(`query.Set(%q, queryValue(%s.%s))`, field.apiParamName(), in, field.name)
+			}
+		}
+		p.printf(`err := c.do(ctx, %q, path, query, nil, %s, %v, %s, %s)`, httpMethod, header, m.Auth, statusExpr(m.Status), out)
+	}
+	if envField != "" {
+		p.printf(`res = env.%s`, envField)
+	}
+	p.printf(`return res, err`)
+	p.printf(`}`)
+
+	return p.err
+}
+
+// generates fields of the json body struct mirroring the param struct tree
+func genClientBodyFields(p *printer, fields []*paramStructField) {
+	for _, field := range fields {
+		switch {
+		case field.isStruct():
+			p.printf(`%s struct {`, field.varName())
+			genClientBodyFields(p, field.fields)
+			p.printf(`} `+q+`json:"%s"`+q, field.apiParamName())
+		case field.jsonText() && field.isSlice:
+			p.printf(`%s []string `+q+`json:"%s"`+q, field.varName(), field.apiParamName())
+		case field.jsonText() && field.rules&defaultRule != 0:
+			p.printf(`%s string `+q+`json:"%s,omitempty"`+q, field.varName(), field.apiParamName())
+		case field.jsonText():
+			p.printf(`%s string `+q+`json:"%s"`+q, field.varName(), field.apiParamName())
+		case field.rules&defaultRule != 0 && field.kind == Custom:
+			// omitempty doesn't omit zero structs, so set only not zero values
+			p.printf(`%s any `+q+`json:"%s,omitempty"`+q, field.varName(), field.apiParamName())
+		case field.rules&defaultRule != 0:
+			p.printf(`%s %s `+q+`json:"%s,omitempty"`+q, field.varName(), p.fieldType(field), field.apiParamName())
+		default:
+			p.printf(`%s %s `+q+`json:"%s"`+q, field.varName(), p.fieldType(field), field.apiParamName())
+		}
+	}
+}
+
+func genClientBodyAssign(p *printer, ct *clientTypes, fields []*paramStructField, dst, src string) {
+	for _, field := range fields {
+		dst := dst + "." + field.varName()
+		src := src + "." + field.name
+		if field.isStruct() {
+			genClientBodyAssign(p, ct, field.fields, dst, src)
+			continue
+		}
+		if field.jsonText() {
+			genClientTextAssign(p, field, dst, src, ct.isText(field.typ))
+			continue
+		}
+		if field.rules&defaultRule != 0 && field.kind == Custom {
+			p.printf(`if %s { %s = %s }`, notZero(p, field, src), dst, src)
+			continue
+		}
+		p.printf(`%s = %s`, dst, src)
+	}
+}
+
+// generates encoding of the encoding.TextMarshaler or time.Duration field to
+// the json string, the zero value of the field with the default is omitted.
+// The field of the text type declared by the client is converted.
+func genClientTextAssign(p *printer, field *paramStructField, dst, src string, text bool) {
+	switch {
+	case isDuration(field.typ) && field.isSlice:
+		p.printf(`for _, v := range %s {`, src)
+		p.printf(`	%s = append(%s, v.String())`, dst, dst)
+		p.printf(`}`)
+		return
+	case isDuration(field.typ) && field.rules&defaultRule != 0:
+		p.printf(`if %s { %s = %s.String() }`, notZero(p, field, src), dst, src)
+		return
+	case isDuration(field.typ):
+		p.printf(`%s = %s.String()`, dst, src)
+		return
+	case text && field.isSlice:
+		p.printf(`for _, v := range %s {`, src)
+		p.printf(`	%s = append(%s, string(v))`, dst, dst)
+		p.printf(`}`)
+		return
+	case text:
+		p.printf(`%s = string(%s)`, dst, src)
+		return
+	}
+	if field.isSlice {
+		p.printf(`for _, v := range %s {`, src)
+		p.printf(`	b, err := v.MarshalText()`)
+		p.printf(`	if err != nil {`)
+		p.printf(`		return res, err`)
+		p.printf(`	}`)
+		p.printf(`	%s = append(%s, string(b))`, dst, dst)
+		p.printf(`}`)
+		return
+	}
+	if field.rules&defaultRule != 0 {
+		p.printf(`if %s {`, notZero(p, field, src))
+	} else {
+		p.printf(`{`)
+	}
+	p.printf(`	b, err := %s.MarshalText()`, src)
+	p.printf(`	if err != nil {`)
+	p.printf(`		return res, err`)
+	p.printf(`	}`)
+	p.printf(`	%s = string(b)`, dst)
+	p.printf(`}`)
+}
+
+// returns expression checking the field value is not zero.
+func notZero(p *printer, field *paramStructField, value string) string {
+	if field.kind == Custom {
+		return fmt.Sprintf(`!%s.ValueOf(%s).IsZero()`, p.use("reflect"), value)
+	}
+	return fmt.Sprintf(`%s != %s`, value, zeroValue(field.kind))
+}
+
+// clientTypes are the types of the server package used by the client methods:
+// params, results, envelopes and the types of their fields. They are declared
+// in the client package without their methods, so the types encoded by their
+// MarshalText or UnmarshalText are the text, and by MarshalJSON or
+// UnmarshalJSON are json.RawMessage. *multipart.FileHeader is *File.
+type clientTypes struct {
+	p     *printer
+	names map[string]bool       // names of the client code
+	decls map[string]string     // declarations by type name, empty if queued
+	queue []*types.Named        // types to declare
+	text  map[*types.Named]bool // types declared as the text
+	files bool                  // File is used
+}
+
+func newClientTypes(p *printer, services []string) *clientTypes {
+	ct := &clientTypes{
+		p:     p,
+		names: map[string]bool{},
+		decls: map[string]string{},
+		text:  map[*types.Named]bool{},
+	}
+	for _, name := range clientNames {
+		ct.names[name] = true
+	}
+	for _, servName := range services {
+		ct.names[servName+"Client"] = true
+		ct.names["New"+servName+"Client"] = true
+	}
+	return ct
+}
+
+// queues the types of the method params and result to declare.
+func (ct *clientTypes) addMethod(m *serviceMethod) error {
+	const op = "clientTypes.addMethod"
+
+	if m.hasFiles && m.params.isForeign(ct.p.pkg) {
+		return &ParseError{
+			Err: fmt.Errorf("%s: %s.%s: files of %s params of other package can't be uploaded by the client, declare them in %s package",
+				op, m.recv.name, m.name, m.params.name, ct.p.pkg.Name()),
+			Pos: m.pos,
+		}
+	}
+	for _, t := range []types.Type{m.params.typ, m.result.typ} {
+		if _, err := ct.typeExpr(t); err != nil {
+			return err
+		}
+	}
+	if m.envelope != nil {
+		if _, err := ct.typeExpr(m.envelope.typ); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// reports whether the type is declared by the client as the text.
+func (ct *clientTypes) isText(t types.Type) bool {
+	named, ok := types.Unalias(t).(*types.Named)
+	return ok && ct.text[named]
+}
+
+// returns Go type of the client code, the named types of the server package
+// are queued to declare.
+func (ct *clientTypes) typeExpr(t types.Type) (string, error) {
+	switch t := types.Unalias(t).(type) {
+	case *types.Named:
+		obj := t.Obj()
+		if obj.Pkg() != ct.p.pkg {
+			return ct.p.typeName(t), nil
+		}
+		if t.TypeParams().Len() > 0 || t.TypeArgs().Len() > 0 {
+			return "", &ParseError{Err: fmt.Errorf("%s: generic types are not supported by the client", obj.Name()), Pos: obj.Pos()}
+		}
+		if ct.names[obj.Name()] {
+			return "", &ParseError{Err: fmt.Errorf("%s type conflicts with the declaration of the client code", obj.Name()), Pos: obj.Pos()}
+		}
+		if _, ok := ct.decls[obj.Name()]; !ok {
+			ct.decls[obj.Name()] = ""
+			ct.queue = append(ct.queue, t)
+		}
+		return obj.Name(), nil
+	case *types.Pointer:
+		if isFileHeader(t) {
+			ct.files = true
+			return "*File", nil
+		}
+		elem, err := ct.typeExpr(t.Elem())
+		return "*" + elem, err
+	case *types.Slice:
+		elem, err := ct.typeExpr(t.Elem())
+		return "[]" + elem, err
+	case *types.Array:
+		elem, err := ct.typeExpr(t.Elem())
+		return fmt.Sprintf("[%d]%s", t.Len(), elem), err
+	case *types.Map:
+		key, err := ct.typeExpr(t.Key())
+		if err != nil {
+			return "", err
+		}
+		elem, err := ct.typeExpr(t.Elem())
+		return "map[" + key + "]" + elem, err
+	case *types.Struct:
+		if t.NumFields() == 0 {
+			return "struct{}", nil
+		}
+		var b strings.Builder
+		b.WriteString("struct {\n")
+		for i := 0; i < t.NumFields(); i++ {
+			// the unexported fields are not encoded, the embedded type of
+			// the server package is declared by the client too
+			f := t.Field(i)
+			if !f.Exported() && !(f.Embedded() && ct.isLocal(f.Type())) {
+				continue
+			}
+			typ, err := ct.typeExpr(f.Type())
+			if err != nil {
+				return "", err
+			}
+			if !f.Embedded() {
+				b.WriteString(f.Name() + " ")
+			}
+			b.WriteString(typ)
+			if tag := t.Tag(i); tag != "" {
+				b.WriteString(" " + quoteTag(tag))
+			}
+			b.WriteString("\n")
+		}
+		b.WriteString("}")
+		return b.String(), nil
+	case *types.Interface:
+		if t.Empty() {
+			return "any", nil
+		}
+	}
+	return ct.p.typeName(t), nil
+}
+
+// reports whether the type or its pointer is the named type of the server package.
+func (ct *clientTypes) isLocal(t types.Type) bool {
+	if ptr, ok := types.Unalias(t).(*types.Pointer); ok {
+		t = ptr.Elem()
+	}
+	named, ok := types.Unalias(t).(*types.Named)
+	return ok && named.Obj().Pkg() == ct.p.pkg
+}
+
+// declares the queued types and the types they use.
+func (ct *clientTypes) declare() error {
+	for len(ct.queue) > 0 {
+		t := ct.queue[0]
+		ct.queue = ct.queue[1:]
+		name := t.Obj().Name()
+
+		switch {
+		case getUnmarshaler(t) == textUnmarshaler || hasMethod(t, "MarshalText", "() []byte, error"):
+			ct.text[t] = true
+			ct.decls[name] = fmt.Sprintf("// %s is the text of %s.%s.\ntype %s string", name, ct.p.pkg.Name(), name, name)
+		case getUnmarshaler(t) == jsonUnmarshaler || hasMethod(t, "MarshalJSON", "() []byte, error"):
+			ct.decls[name] = fmt.Sprintf("// %s is the json of %s.%s.\ntype %s = %s.RawMessage", name, ct.p.pkg.Name(), name, name, ct.p.use("encoding/json"))
+		default:
+			typ, err := ct.typeExpr(t.Underlying())
+			if err != nil {
+				return err
+			}
+			ct.decls[name] = fmt.Sprintf("// %s mirrors %s.%s.\ntype %s %s", name, ct.p.pkg.Name(), name, name, typ)
+		}
+	}
+	return nil
+}
+
+// prints the declarations sorted by name.
+func (ct *clientTypes) print() {
+	for _, name := range sortedKeys(ct.decls) {
+		ct.p.printf(``)
+		ct.p.printf(`%s`, ct.decls[name])
+	}
+}
+
+// returns the struct tag in the raw string literal if it can be.
+func quoteTag(tag string) string {
+	if strings.Contains(tag, q) {
+		return strconv.Quote(tag)
+	}
+	return q + tag + q
+}
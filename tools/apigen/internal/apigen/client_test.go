@@ -0,0 +1,117 @@
+package apigen
+
+import (
+	"bytes"
+	"io"
+	"log"
+	"strings"
+	"testing"
+)
+
+const clientSource = `package api
+
+import (
+	"context"
+	"mime/multipart"
+
+	"example.com/m/codes"
+)
+
+type Api struct{}
+
+// Nick is decoded from the text, the client sends the text
+type Nick struct{ s string }
+
+func (n *Nick) UnmarshalText(b []byte) error {
+	n.s = string(b)
+	return nil
+}
+
+type SetParams struct {
+	Code codes.Code
+	Nick Nick
+	Logo *multipart.FileHeader
+	note string
+}
+
+type Result struct {
+	Nicks []Nick          ` + "`json:\"nicks\"`" + `
+	Items map[string]Item ` + "`json:\"items\"`" + `
+}
+
+type Item struct{}
+
+// apigen:api {"url": "/set", "method": "POST"}
+func (a *Api) Set(ctx context.Context, in SetParams) (Result, error) {
+	return Result{}, nil
+}
+`
+
+const codesSource = `package codes
+
+import "strings"
+
+// Code is decoded from the text, but can't be encoded to it
+type Code struct{ s string }
+
+func (c *Code) UnmarshalText(b []byte) error {
+	c.s = strings.ToLower(string(b))
+	return nil
+}
+`
+
+func TestGenClient(t *testing.T) {
+	log.SetOutput(io.Discard)
+
+	gen := func(src string, opts ClientOptions) (string, error) {
+		t.Helper()
+		dir := writeModule(t, map[string]string{"api/api.go": src, "codes/codes.go": codesSource})
+		pkg, err := LoadPackage(dir+"/api", nil)
+		if err != nil {
+			t.Fatalf("LoadPackage: %v", err)
+		}
+		cfg, err := Parse(pkg, ParseOptions{})
+		if err != nil {
+			t.Fatalf("Parse: %v", err)
+		}
+		var buf bytes.Buffer
+		err = GenClient(&buf, cfg, opts)
+		return buf.String(), err
+	}
+
+	// the field of other package can't be encoded by the client
+	_, err := gen(clientSource, ClientOptions{})
+	if err == nil || !strings.Contains(err.Error(), "SetParams.Code: codes.Code type implements encoding.TextUnmarshaler, but not encoding.TextMarshaler") {
+		t.Errorf("got error %v of not encoded field", err)
+	}
+
+	// the types of the package are declared by the client
+	src := strings.Replace(clientSource, "\tCode codes.Code\n", "", 1)
+	code, err := gen(src, ClientOptions{Package: "setclient"})
+	if err != nil {
+		t.Fatalf("GenClient: %v", err)
+	}
+	for _, s := range []string{
+		"package setclient\n",
+		"type Nick string\n",
+		"type SetParams struct {\n\tNick Nick\n\tLogo *File\n}\n",
+		"Nicks []Nick          `json:\"nicks\"`",
+		"type Item struct{}\n",
+		"form.file(\"logo\", in.Logo)",
+		"form.field(\"nick\", queryValue(in.Nick))",
+	} {
+		if !strings.Contains(code, s) {
+			t.Errorf("code has no %q", s)
+		}
+	}
+
+	if _, err := gen(src, ClientOptions{Package: "set-client"}); err == nil || !strings.Contains(err.Error(), `"set-client" is not the package name`) {
+		t.Errorf("got error %v of package name", err)
+	}
+
+	src = strings.Replace(src, "type Item struct{}", "type ApiError struct{}", 1)
+	src = strings.Replace(src, "map[string]Item", "map[string]ApiError", 1)
+	if _, err := gen(src, ClientOptions{}); err == nil || !strings.Contains(err.Error(), "ApiError type conflicts with the declaration of the client code") {
+		t.Errorf("got error %v of conflicting type", err)
+	}
+}
@@ -0,0 +1,405 @@
+package apigen
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"go/token"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// names of the config file in the module root, one of them may exist
+var configFiles = []string{"apigen.yaml", "apigen.yml", "apigen.json"}
+
+// Config is read from the apigen.yaml or apigen.json file in the module root.
+// It sets the defaults of the generation of the module packages, the command
+// line flags and the apigen:api marks take precedence.
+type Config struct {
+	Generate      []string           `json:"generate,omitempty" yaml:"generate,omitempty"`           // kinds of the output if no -client and -openapi flags: server, client, openapi
+	Output        OutputNames        `json:"output,omitempty" yaml:"output,omitempty"`               // names of the output files
+	OpenAPI       string             `json:"openapi,omitempty" yaml:"openapi,omitempty"`             // format of OpenAPI document: json or yaml
+	Encoders      []string           `json:"encoders,omitempty" yaml:"encoders,omitempty"`           // response encodings besides json: xml, msgpack, cbor
+	ContentTypes  []string           `json:"contentTypes,omitempty" yaml:"contentTypes,omitempty"`   // accepted media types of the request body, all by default
+	Templates     string             `json:"templates,omitempty" yaml:"templates,omitempty"`         // directory of *.tmpl files relative to the config file
+	Names         map[string]string  `json:"names,omitempty" yaml:"names,omitempty"`                 // names of the generated helpers by default name, e.g. writeApiError
+	Authenticator string             `json:"authenticator,omitempty" yaml:"authenticator,omitempty"` // package level func used as authenticator as if it is marked
+	Envelope      string             `json:"envelope,omitempty" yaml:"envelope,omitempty"`           // envelope of the results: default, none or the type name
+	AllErrors     *bool              `json:"allErrors,omitempty" yaml:"allErrors,omitempty"`         // report all param errors instead of the first one
+	Strict        *bool              `json:"strict,omitempty" yaml:"strict,omitempty"`               // reject unknown fields and trailing data of json body
+	Packages      map[string]*Config `json:"packages,omitempty" yaml:"packages,omitempty"`           // overrides by the package dir relative to the module root
+
+	File string `json:"-" yaml:"-"` // path of the config file, empty if there is no file
+	root string // module root dir
+}
+
+// OutputNames are the names of the output files in the package dir, {pkg} is
+// replaced by the package name and {format} by the format of OpenAPI document.
+// The client is the path relative to the package dir, the client package is
+// named by its dir, e.g. ../../pkg/userclient/client_apigen.go.
+type OutputNames struct {
+	Server  string `json:"server,omitempty" yaml:"server,omitempty"`
+	Client  string `json:"client,omitempty" yaml:"client,omitempty"`
+	OpenAPI string `json:"openapi,omitempty" yaml:"openapi,omitempty"`
+}
+
+// kinds of the output
+const (
+	ServerOutput  = "server"
+	ClientOutput  = "client"
+	OpenAPIOutput = "openapi"
+)
+
+var defaultOutput = OutputNames{
+	Server:  "{pkg}_apigen.go",
+	Client:  "{pkg}client/client_apigen.go",
+	OpenAPI: "{pkg}_openapi.{format}",
+}
+
+// suffix of the generated code files, they are skipped by LoadPackage
+const generatedSuffix = "_apigen.go"
+
+// FindConfig returns path of the config file in the root of the module of the
+// dir or empty string if there is no config file.
+func FindConfig(dir string) (string, error) {
+	const op = "FindConfig"
+
+	dir, err := filepath.Abs(dir)
+	if err != nil {
+		return "", fmt.Errorf("%s: %w", op, err)
+	}
+	for {
+		if _, err := os.Stat(filepath.Join(dir, "go.mod")); err == nil {
+			break
+		}
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			return "", nil // not in a module
+		}
+		dir = parent
+	}
+
+	var found []string
+	for _, name := range configFiles {
+		if _, err := os.Stat(filepath.Join(dir, name)); err == nil {
+			found = append(found, filepath.Join(dir, name))
+		}
+	}
+	if len(found) > 1 {
+		return "", fmt.Errorf("%s: config must be one, found %s", op, strings.Join(found, ", "))
+	}
+	if len(found) == 0 {
+		return "", nil
+	}
+	return found[0], nil
+}
+
+// LoadConfig reads and validates the config file. The unknown fields are errors.
+func LoadConfig(file string) (*Config, error) {
+	const op = "LoadConfig"
+
+	src, err := os.ReadFile(file)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	c := Config{File: file, root: filepath.Dir(file)}
+	if filepath.Ext(file) == ".json" {
+		dec := json.NewDecoder(bytes.NewReader(src))
+		dec.DisallowUnknownFields()
+		err = dec.Decode(&c)
+	} else {
+		dec := yaml.NewDecoder(bytes.NewReader(src))
+		dec.KnownFields(true)
+		err = dec.Decode(&c)
+	}
+	if err != nil && !errors.Is(err, io.EOF) {
+		return nil, fmt.Errorf("%s: %s: %w", op, file, err)
+	}
+
+	if err := c.validate(true); err != nil {
+		return nil, fmt.Errorf("%s: %s: %w", op, file, err)
+	}
+	for _, dir := range sortedKeys(c.Packages) {
+		if err := c.Packages[dir].validate(false); err != nil {
+			return nil, fmt.Errorf("%s: %s: packages: %s: %w", op, file, dir, err)
+		}
+	}
+	return &c, nil
+}
+
+// checks the settings, the package overrides are checked if root is true.
+func (c *Config) validate(root bool) error {
+	for _, kind := range c.Generate {
+		if kind != ServerOutput && kind != ClientOutput && kind != OpenAPIOutput {
+			return fmt.Errorf("generate: unknown kind %s, want server, client or openapi", kind)
+		}
+	}
+	for _, out := range []struct{ kind, name string }{
+		{ServerOutput, c.Output.Server},
+		{ClientOutput, c.Output.Client},
+		{OpenAPIOutput, c.Output.OpenAPI},
+	} {
+		kind, name := out.kind, out.name
+		if name == "" {
+			continue
+		}
+		switch {
+		case kind == ClientOutput:
+			if strings.Contains(name, `\`) || name != path.Clean(name) || path.IsAbs(name) || path.Dir(name) == "." {
+				return fmt.Errorf("output: %s: %s must be the clean path of the file in the client package dir relative to the package dir", kind, name)
+			}
+		case strings.ContainsAny(name, `/\`):
+			return fmt.Errorf("output: %s: %s must be the file name in the package dir", kind, name)
+		}
+		if kind != OpenAPIOutput && !strings.HasSuffix(name, generatedSuffix) {
+			return fmt.Errorf("output: %s: %s must end with %s to skip the generated code when loading the package", kind, name, generatedSuffix)
+		}
+	}
+	if c.OpenAPI != "" && c.OpenAPI != "json" && c.OpenAPI != "yaml" {
+		return fmt.Errorf("openapi: unknown format %s, want json or yaml", c.OpenAPI)
+	}
+	for _, name := range c.Encoders {
+		if _, ok := encoderTypes[name]; !ok {
+			return fmt.Errorf("encoders: unknown encoder %s. available: %s", name, strings.Join(sortedKeys(encoderTypes), ", "))
+		}
+	}
+	if err := checkContentTypes(c.ContentTypes); err != nil {
+		return fmt.Errorf("contentTypes: %w", err)
+	}
+	if c.Templates != "" {
+		if !filepath.IsAbs(c.Templates) {
+			c.Templates = filepath.Join(c.root, c.Templates)
+		}
+		if info, err := os.Stat(c.Templates); err != nil || !info.IsDir() {
+			return fmt.Errorf("templates: %s directory not found", c.Templates)
+		}
+	}
+	if err := checkHelperNames(c.Names); err != nil {
+		return fmt.Errorf("names: %w", err)
+	}
+	if c.Authenticator != "" && !token.IsIdentifier(c.Authenticator) {
+		return fmt.Errorf("authenticator: %q is not the func name", c.Authenticator)
+	}
+	if e := c.Envelope; e != "" && e != defaultEnvelope && e != noEnvelope && !token.IsIdentifier(e) {
+		return fmt.Errorf("envelope: %q, want default, none or the type name", e)
+	}
+
+	if !root && len(c.Packages) > 0 {
+		return errors.New("packages: overrides can't be nested")
+	}
+	for _, dir := range sortedKeys(c.Packages) {
+		if c.Packages[dir] == nil {
+			c.Packages[dir] = &Config{}
+		}
+		c.Packages[dir].root = c.root
+		if dir != path.Clean(dir) || path.IsAbs(dir) || dir == ".." || strings.HasPrefix(dir, "../") {
+			return fmt.Errorf("packages: %s must be the clean dir relative to the module root", dir)
+		}
+		if info, err := os.Stat(filepath.Join(c.root, filepath.FromSlash(dir))); err != nil || !info.IsDir() {
+			return fmt.Errorf("packages: %s directory not found", dir)
+		}
+	}
+	return nil
+}
+
+// Package returns the settings of the package in the dir: the defaults of the
+// config with the package overrides.
+func (c *Config) Package(dir string) (Config, error) {
+	const op = "Config.Package"
+
+	res := *c
+	res.Packages = nil
+	if c.root == "" {
+		return res, nil
+	}
+	abs, err := filepath.Abs(dir)
+	if err != nil {
+		return res, fmt.Errorf("%s: %w", op, err)
+	}
+	rel, err := filepath.Rel(c.root, abs)
+	if err != nil {
+		return res, fmt.Errorf("%s: %w", op, err)
+	}
+	over, ok := c.Packages[filepath.ToSlash(rel)]
+	if !ok {
+		return res, nil
+	}
+
+	if over.Generate != nil {
+		res.Generate = over.Generate
+	}
+	res.Output = res.Output.override(over.Output)
+	if over.OpenAPI != "" {
+		res.OpenAPI = over.OpenAPI
+	}
+	if over.Encoders != nil {
+		res.Encoders = over.Encoders
+	}
+	if over.ContentTypes != nil {
+		res.ContentTypes = over.ContentTypes
+	}
+	if over.Templates != "" {
+		res.Templates = over.Templates
+	}
+	if len(over.Names) > 0 {
+		res.Names = map[string]string{}
+		for _, names := range []map[string]string{c.Names, over.Names} {
+			for k, v := range names {
+				res.Names[k] = v
+			}
+		}
+		if err := checkHelperNames(res.Names); err != nil {
+			return res, fmt.Errorf("%s: %s: names: %w", op, dir, err)
+		}
+	}
+	if over.Authenticator != "" {
+		res.Authenticator = over.Authenticator
+	}
+	if over.Envelope != "" {
+		res.Envelope = over.Envelope
+	}
+	if over.AllErrors != nil {
+		res.AllErrors = over.AllErrors
+	}
+	if over.Strict != nil {
+		res.Strict = over.Strict
+	}
+	return res, nil
+}
+
+// OutputFile returns the name of the output file of the kind in the package dir,
+// the client file is the slash separated path relative to the package dir.
+func (c *Config) OutputFile(kind, pkgName string) string {
+	names := defaultOutput.override(c.Output)
+	var name string
+	switch kind {
+	case ClientOutput:
+		name = names.Client
+	case OpenAPIOutput:
+		name = names.OpenAPI
+	default:
+		name = names.Server
+	}
+	format := c.OpenAPI
+	if format == "" {
+		format = "json"
+	}
+	return strings.NewReplacer("{pkg}", pkgName, "{format}", format).Replace(name)
+}
+
+// ParseOptions returns the options of Parse set by the config.
+func (c *Config) ParseOptions() ParseOptions {
+	return ParseOptions{
+		Authenticator: c.Authenticator,
+		Envelope:      c.Envelope,
+		AllErrors:     c.AllErrors != nil && *c.AllErrors,
+		Strict:        c.Strict != nil && *c.Strict,
+	}
+}
+
+// returns the names with the non-empty names of other.
+func (o OutputNames) override(other OutputNames) OutputNames {
+	if other.Server != "" {
+		o.Server = other.Server
+	}
+	if other.Client != "" {
+		o.Client = other.Client
+	}
+	if other.OpenAPI != "" {
+		o.OpenAPI = other.OpenAPI
+	}
+	return o
+}
+
+// media types of the request body the generated code accepts
+var requestContentTypes = []string{jsonContentType, formContentType, multipartType}
+
+// checks the media types of the request body are supported. The params are
+// passed in the body of json or form, so one of them is required.
+func checkContentTypes(types []string) error {
+	if len(types) == 0 {
+		return nil
+	}
+	for _, ct := range types {
+		if !contains(requestContentTypes, ct) {
+			return fmt.Errorf("unsupported content type %s. available: %s", ct, strings.Join(requestContentTypes, ", "))
+		}
+	}
+	if !contains(types, jsonContentType) && !contains(types, formContentType) {
+		return fmt.Errorf("%s or %s is required", jsonContentType, formContentType)
+	}
+	return nil
+}
+
+// returns the accepted media types of the request body, all by default.
+func acceptedContentTypes(types []string) map[string]bool {
+	if len(types) == 0 {
+		types = requestContentTypes
+	}
+	accepted := map[string]bool{}
+	for _, ct := range types {
+		accepted[ct] = true
+	}
+	return accepted
+}
+
+// checks the methods uploading files accept multipart form.
+func checkFileUploads(cfg GenConfig, accepted map[string]bool) error {
+	if accepted[multipartType] {
+		return nil
+	}
+	for _, servName := range sortedKeys(cfg.servs.items) {
+		for _, m := range cfg.servs.items[servName] {
+			if m.hasFiles {
+				return &ParseError{
+					Err: fmt.Errorf("%s.%s: files are uploaded in %s, but it is not accepted", m.recv.name, m.name, multipartType),
+					Pos: m.pos,
+				}
+			}
+		}
+	}
+	return nil
+}
+
+// helpers of the generated code the package code may refer to. They may be
+// renamed to avoid conflicts with the declarations of the package.
+var helperNames = []string{"writeApiError", "IdentityFromContext", "RegisterEncoder"}
+
+// checks the new names of the helpers by the default names.
+func checkHelperNames(names map[string]string) error {
+	used := map[string]string{}
+	for _, name := range helperNames {
+		used[name] = name
+	}
+	for _, name := range sortedKeys(names) {
+		if !contains(helperNames, name) {
+			return fmt.Errorf("unknown helper %s. available: %s", name, strings.Join(helperNames, ", "))
+		}
+		if !token.IsIdentifier(names[name]) {
+			return fmt.Errorf("%s: %q is not the Go name", name, names[name])
+		}
+		delete(used, name)
+	}
+	for _, name := range sortedKeys(names) {
+		if prev, ok := used[names[name]]; ok {
+			return fmt.Errorf("%s: name %s is used by %s", name, names[name], prev)
+		}
+		used[names[name]] = name
+	}
+	return nil
+}
+
+// returns the name of the helper in the generated code.
+func helperName(names map[string]string, name string) string {
+	if n := names[name]; n != "" {
+		return n
+	}
+	return name
+}
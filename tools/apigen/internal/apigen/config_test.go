@@ -0,0 +1,230 @@
+package apigen
+
+import (
+	"bytes"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// writes the files of the module to the temp dir and returns the dir.
+func writeModule(t *testing.T, files map[string]string) string {
+	t.Helper()
+	dir := t.TempDir()
+	files["go.mod"] = "module example.com/m\n"
+	for name, text := range files {
+		fp := filepath.Join(dir, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(fp), 0777); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(fp, []byte(text), 0666); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return dir
+}
+
+func TestLoadConfig(t *testing.T) {
+	dir := writeModule(t, map[string]string{
+		"apigen.yaml": `
+generate: [server, client]
+output:
+  server: "{pkg}_gen_apigen.go"
+encoders: [xml]
+templates: tmpl
+names:
+  writeApiError: writeError
+envelope: none
+allErrors: true
+packages:
+  internal/api:
+    generate: [openapi]
+    openapi: yaml
+    names:
+      IdentityFromContext: Identity
+    allErrors: false
+`,
+		"tmpl/code.tmpl":      "",
+		"internal/api/api.go": "package api\n",
+	})
+
+	file, err := FindConfig(filepath.Join(dir, "internal", "api"))
+	if err != nil {
+		t.Fatalf("FindConfig: %v", err)
+	}
+	if file != filepath.Join(dir, "apigen.yaml") {
+		t.Fatalf("got config file %q", file)
+	}
+	c, err := LoadConfig(file)
+	if err != nil {
+		t.Fatalf("LoadConfig: %v", err)
+	}
+	if c.Templates != filepath.Join(dir, "tmpl") {
+		t.Errorf("got templates %q", c.Templates)
+	}
+
+	root, err := c.Package(dir)
+	if err != nil {
+		t.Fatalf("Package: %v", err)
+	}
+	if got := root.OutputFile(ServerOutput, "m"); got != "m_gen_apigen.go" {
+		t.Errorf("got server output %q", got)
+	}
+	if got := root.OutputFile(ClientOutput, "m"); got != "mclient/client_apigen.go" {
+		t.Errorf("got client output %q", got)
+	}
+	if want := (ParseOptions{Envelope: "none", AllErrors: true}); root.ParseOptions() != want {
+		t.Errorf("got parse options %+v, want %+v", root.ParseOptions(), want)
+	}
+
+	api, err := c.Package(filepath.Join(dir, "internal", "api"))
+	if err != nil {
+		t.Fatalf("Package: %v", err)
+	}
+	if !reflect.DeepEqual(api.Generate, []string{OpenAPIOutput}) || !reflect.DeepEqual(api.Encoders, []string{"xml"}) {
+		t.Errorf("got generate %v, encoders %v", api.Generate, api.Encoders)
+	}
+	if got := api.OutputFile(OpenAPIOutput, "api"); got != "api_openapi.yaml" {
+		t.Errorf("got openapi output %q", got)
+	}
+	if want := map[string]string{"writeApiError": "writeError", "IdentityFromContext": "Identity"}; !reflect.DeepEqual(api.Names, want) {
+		t.Errorf("got names %v, want %v", api.Names, want)
+	}
+	if want := (ParseOptions{Envelope: "none"}); api.ParseOptions() != want {
+		t.Errorf("got parse options %+v, want %+v", api.ParseOptions(), want)
+	}
+
+	// the module without config
+	if file, err := FindConfig(writeModule(t, map[string]string{})); err != nil || file != "" {
+		t.Errorf("got config %q, %v", file, err)
+	}
+}
+
+func TestLoadConfigErrors(t *testing.T) {
+	for _, tc := range []struct {
+		name string
+		file string
+		text string
+		err  string
+	}{
+		{"unknown field", "apigen.yaml", "envelop: none", "field envelop not found"},
+		{"unknown json field", "apigen.json", `{"envelop": "none"}`, `unknown field "envelop"`},
+		{"generate", "apigen.yaml", "generate: [server, docs]", "generate: unknown kind docs"},
+		{"output dir", "apigen.yaml", "output: {server: gen/server_apigen.go}", "must be the file name"},
+		{"client dir", "apigen.yaml", "output: {client: client_apigen.go}", "must be the clean path of the file in the client package dir"},
+		{"client path", "apigen.yaml", "output: {client: ./client/client_apigen.go}", "must be the clean path"},
+		{"output suffix", "apigen.yaml", "output: {server: api.go}", "must end with _apigen.go"},
+		{"openapi", "apigen.yaml", "openapi: toml", "openapi: unknown format toml"},
+		{"encoder", "apigen.yaml", "encoders: [protobuf]", "encoders: unknown encoder protobuf"},
+		{"content type", "apigen.yaml", "contentTypes: [text/plain]", "unsupported content type text/plain"},
+		{"only multipart", "apigen.yaml", "contentTypes: [multipart/form-data]", "application/json or application/x-www-form-urlencoded is required"},
+		{"templates", "apigen.yaml", "templates: tmpl", "templates: " + "%dir%" + "/tmpl directory not found"},
+		{"helper", "apigen.yaml", "names: {negotiate: accept}", "names: unknown helper negotiate"},
+		{"helper name", "apigen.yaml", "names: {writeApiError: write-error}", `"write-error" is not the Go name`},
+		{"helper conflict", "apigen.yaml", "names: {writeApiError: RegisterEncoder}", "name RegisterEncoder is used by RegisterEncoder"},
+		{"envelope", "apigen.yaml", "envelope: data.Envelope", "want default, none or the type name"},
+		{"package dir", "apigen.yaml", "packages: {api: {envelope: none}}", "packages: api directory not found"},
+		{"package path", "apigen.yaml", "packages: {./sub: {}}", "must be the clean dir"},
+		{"package override", "apigen.yaml", "packages: {sub: {encoders: [protobuf]}}", "packages: sub: encoders: unknown encoder"},
+		{"nested packages", "apigen.yaml", "packages: {sub: {packages: {sub: {}}}}", "overrides can't be nested"},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			dir := writeModule(t, map[string]string{tc.file: tc.text, "sub/sub.go": "package sub\n"})
+			_, err := LoadConfig(filepath.Join(dir, tc.file))
+			want := strings.ReplaceAll(tc.err, "%dir%", dir)
+			if err == nil || !strings.Contains(err.Error(), want) {
+				t.Errorf("got error %v, want %q", err, want)
+			}
+		})
+	}
+
+	dir := writeModule(t, map[string]string{"apigen.yaml": "", "apigen.json": "{}"})
+	if _, err := FindConfig(dir); err == nil || !strings.Contains(err.Error(), "config must be one") {
+		t.Errorf("got error %v of two configs", err)
+	}
+}
+
+func TestParseOptions(t *testing.T) {
+	log.SetOutput(io.Discard)
+
+	pkg, err := LoadPackage("../../test", nil)
+	if err != nil {
+		t.Fatalf("can't load test package: %v", err)
+	}
+	cfg, err := Parse(pkg, ParseOptions{Envelope: noEnvelope, AllErrors: true, Strict: true})
+	if err != nil {
+		t.Fatalf("Parse: %v", err)
+	}
+	methods := map[string]*serviceMethod{}
+	for _, ms := range cfg.servs.items {
+		for _, m := range ms {
+			methods[m.recv.name+"."+m.name] = m
+		}
+	}
+	// the defaults apply to the settings not set by the service and method marks
+	for _, tc := range []struct {
+		method    string
+		envelope  string
+		allErrors bool
+		strict    bool
+	}{
+		{"MyApi.Profile", noEnvelope, true, true},
+		{"TeamApi.Create", "DataEnvelope", true, true},
+		{"TeamApi.List", "TeamListEnvelope", true, true},
+	} {
+		m := methods[tc.method]
+		if m.Envelope != tc.envelope || m.allErrors() != tc.allErrors || m.strict() != tc.strict {
+			t.Errorf("%s: got envelope %s, allErrors %v, strict %v", tc.method, m.Envelope, m.allErrors(), m.strict())
+		}
+	}
+	if cfg.hash == pkg.Hash {
+		t.Errorf("hash does not depend on options")
+	}
+
+	// the configured func is checked as the marked authenticator
+	if _, err := Parse(pkg, ParseOptions{Authenticator: "recoverPanic"}); err == nil || !strings.Contains(err.Error(), "recoverPanic: authenticator must be") {
+		t.Errorf("got error %v of configured authenticator", err)
+	}
+}
+
+func TestGenCodeConfig(t *testing.T) {
+	cfg := parseTestPackage(t)
+
+	var buf bytes.Buffer
+	opts := CodeOptions{
+		ContentTypes: []string{jsonContentType, multipartType},
+		Names:        map[string]string{"writeApiError": "writeError", "IdentityFromContext": "Identity"},
+	}
+	if err := GenCode(&buf, cfg, opts); err != nil {
+		t.Fatalf("GenCode: %v", err)
+	}
+	code := buf.String()
+	for _, s := range []string{"func writeError(", `const op = "writeError"`, "func Identity(", "func RegisterEncoder(", `case "":`} {
+		if !strings.Contains(code, s) {
+			t.Errorf("code has no %q", s)
+		}
+	}
+	for _, s := range []string{"writeApiError", "IdentityFromContext", formContentType} {
+		if strings.Contains(code, s) {
+			t.Errorf("code has %q", s)
+		}
+	}
+
+	// TeamApi.Logo uploads the file
+	opts.ContentTypes = []string{jsonContentType}
+	if err := GenCode(&buf, cfg, opts); err == nil || !strings.Contains(err.Error(), "TeamApi.Logo: files are uploaded in multipart/form-data") {
+		t.Errorf("got error %v of not accepted multipart", err)
+	}
+
+	buf.Reset()
+	if err := GenOpenAPI(&buf, cfg, OpenAPIOptions{Format: "json", Services: []string{"TeamApi"}, ContentTypes: []string{jsonContentType, multipartType}}); err != nil {
+		t.Fatalf("GenOpenAPI: %v", err)
+	}
+	if doc := buf.String(); strings.Contains(doc, formContentType) || !strings.Contains(doc, multipartType) {
+		t.Errorf("document request body types are not the accepted ones")
+	}
+}
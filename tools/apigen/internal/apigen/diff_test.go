@@ -0,0 +1,57 @@
+package apigen
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestUnifiedDiff(t *testing.T) {
+	lines := func(s string) []byte { return []byte(strings.ReplaceAll(s, " ", "\n") + "\n") }
+
+	cases := []struct {
+		old, new string
+		want     string
+	}{
+		{"a b c", "a b c", ""},
+		{"a b c d e f g h i j", "a b c d E f g h i j k", `--- old
++++ new
+@@ -2,9 +2,10 @@
+ b
+ c
+ d
+-e
++E
+ f
+ g
+ h
+ i
+ j
++k
+`},
+		{"a b c d e f g h i j k l m", "b c d e f g h i j k l m n", `--- old
++++ new
+@@ -1,4 +1,3 @@
+-a
+ b
+ c
+ d
+@@ -11,3 +10,4 @@
+ k
+ l
+ m
++n
+`},
+		{"a", "b c", `--- old
++++ new
+@@ -1 +1,2 @@
+-a
++b
++c
+`},
+	}
+	for _, c := range cases {
+		if got := UnifiedDiff("old", "new", lines(c.old), lines(c.new)); got != c.want {
+			t.Errorf("%q -> %q: got diff\n%s\nwant\n%s", c.old, c.new, got, c.want)
+		}
+	}
+}
@@ -1,21 +1,23 @@
 package apigen
 
 import (
+	"bytes"
 	"errors"
 	"fmt"
 	"io"
 	"log"
+	"net/http"
 	"sort"
 	"strings"
 )
 
 const (
 	anyHTTPMethod = "*"
-	authKey       = "100500"
 	q             = "`"
 )
 
-var imports = []string{"encoding/json", "errors", "io", "fmt", "log", "net/http", "strconv", "strings"}
+// packages the server code refers to by name, imported if the code uses them
+var imports = []string{"context", "encoding/json", "errors", "io", "log", "net/http", "strconv", "strings"}
 
 func sortedKeys[T any](m map[string]T) []string {
 	keys := make([]string, 0, len(m))
@@ -26,426 +28,864 @@ func sortedKeys[T any](m map[string]T) []string {
 	return keys
 }
 
-func GenCode(w io.Writer, cfg GenConfig) error {
+// CodeOptions are options of the generated server code.
+type CodeOptions struct {
+	Encoders     []string          // response encodings besides json: xml, msgpack, cbor
+	Templates    string            // directory of *.tmpl files redefining the default templates, see templates/code.tmpl
+	ContentTypes []string          // accepted media types of the request body, all by default
+	Names        map[string]string // names of the generated helpers by default name, e.g. writeApiError
+}
+
+// GenCode writes the server code of every service: ServeHTTP, wrappers of the
+// methods and getting the params from requests. The code is written by "code"
+// template of the default templates redefined by CodeOptions.Templates.
+func GenCode(w io.Writer, cfg GenConfig, opts CodeOptions) error {
 	const op = "GenCode"
 
-	p := newPrinter(w)
-	p.printf(`// !!! Do not change this code !!!`)
-	p.printf(`// The code is generated automatically by apigen tool`)
-	p.printf(`package %s`, cfg.packageName)
-	p.printf(`import ("%s")`, strings.Join(imports, "\";\""))
+	var body bytes.Buffer
+	p := newPrinter(&body)
+	p.pkg = cfg.pkg
 
-	if err := genWriteApiError(p); err != nil {
+	if err := checkContentTypes(opts.ContentTypes); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	if err := checkHelperNames(opts.Names); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	accepted := acceptedContentTypes(opts.ContentTypes)
+	if err := checkFileUploads(cfg, accepted); err != nil {
 		return err
 	}
 
-	order := sortedKeys(cfg.servs.items)
-	log.Printf("%s: generate methods for services: %v", op, strings.Join(order, ", "))
+	tmpl, tmplHash, err := loadTemplates(opts.Templates, codeFuncs(p, opts.Names))
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
 
-	for _, servName := range order {
-		methods := cfg.servs.items[servName]
+	log.Printf("%s: generate methods for services: %v", op, strings.Join(sortedKeys(cfg.servs.items), ", "))
+	log.Printf("%s: generate methods for param struct: %v", op, strings.Join(sortedKeys(cfg.params.items), ", "))
 
-		if err := genServeHTTP(p, servName, methods); err != nil {
-			return err
-		}
-		for _, method := range methods {
-			if err := genMethodWrapper(p, method); err != nil {
-				return err
-			}
+	data, err := newCodeData(p, cfg, opts)
+	if err != nil {
+		return err
+	}
+	data.ContentTypes = accepted
+	if err := tmpl.ExecuteTemplate(&body, "code", data); err != nil {
+		// errors of the funcs generating the field code are reported as is
+		var pe *ParseError
+		if errors.As(err, &pe) {
+			return pe
 		}
+		return fmt.Errorf("%s: %w", op, err)
 	}
 
-	order = sortedKeys(cfg.params.items)
-	log.Printf("%s: generate methods for param struct: %v", op, strings.Join(order, ", "))
+	return newPrinter(w).printFile(cfg.packageName, codeHash(cfg, opts, tmplHash), imports, p, &body)
+}
 
-	for _, structName := range order {
-		params := cfg.params.items[structName]
+// CodeHash returns the inputs hash GenCode writes to the header of the code,
+// so the code is checked by GeneratedHash without generating it.
+func CodeHash(cfg GenConfig, opts CodeOptions) (string, error) {
+	const op = "CodeHash"
+
+	_, tmplHash, err := loadTemplates(opts.Templates, codeFuncs(newPrinter(io.Discard), opts.Names))
+	if err != nil {
+		return "", fmt.Errorf("%s: %w", op, err)
+	}
+	return codeHash(cfg, opts, tmplHash), nil
+}
+
+func codeHash(cfg GenConfig, opts CodeOptions, tmplHash string) string {
+	var names []string
+	for _, name := range sortedKeys(opts.Names) {
+		names = append(names, name+"="+opts.Names[name])
+	}
+	accepted := acceptedContentTypes(opts.ContentTypes)
+	return inputsHash(cfg, "code", strings.Join(opts.Encoders, ","), tmplHash,
+		strings.Join(sortedKeys(accepted), ","), strings.Join(names, ","))
+}
+
+// returns name of the package level var of the field pattern regexp.
+func patternVar(structName string, field *paramStructField) string {
+	return "pattern" + strings.ToUpper(structName[:1]) + structName[1:] + strings.ReplaceAll(field.name, ".", "")
+}
+
+// encoders of the response body by name, the code of the encoder type is
+// generated if the encoding is enabled.
+var encoderTypes = map[string]struct {
+	typeName    string
+	contentType string
+	gen         func(p *printer)
+}{
+	"json": {"jsonEncoder", "application/json", func(p *printer) {
+		p.printf(`return json.NewEncoder(w).Encode(v)`)
+	}},
+	"xml": {"xmlEncoder", "application/xml", func(p *printer) {
+		p.printf(`root := %s.StartElement{Name: %[1]s.Name{Local: "result"}}`, p.use("encoding/xml"))
+		p.printf(`return xml.NewEncoder(w).EncodeElement(v, root)`)
+	}},
+	"msgpack": {"msgpackEncoder", "application/msgpack", func(p *printer) {
+		p.printf(`enc := %s.NewEncoder(w)`, p.use("github.com/vmihailenco/msgpack/v5"))
+		p.printf(`enc.SetCustomStructTag("json")`)
+		p.printf(`return enc.Encode(v)`)
+	}},
+	"cbor": {"cborEncoder", "application/cbor", func(p *printer) {
+		p.printf(`return %s.NewEncoder(w).Encode(v)`, p.use("github.com/fxamacker/cbor/v2"))
+	}},
+}
+
+// returns Go expression creating ParamError of the violated rule. The name may
+// have " items" suffix of the slice items, params are key-value pairs.
+func paramError(name, rule, msg string, params ...string) string {
+	args := []string{strings.TrimSuffix(name, " items"), rule, msg}
+	args = append(args, params...)
+	for i, a := range args {
+		args[i] = fmt.Sprintf("%q", a)
+	}
+	return "newParamError(" + strings.Join(args, ", ") + ")"
+}
+
+func enumError(field *paramStructField, name string) string {
+	return paramError(name, "enum", fmt.Sprintf("%s must be one of [%s]", name, strings.Join(field.enum, ", ")), "enum", strings.Join(field.enum, "|"))
+}
+
+// returns Go expression wrapping the err expression to ParamError of the violated rule.
+func wrapParamError(name, rule, msg, err string) string {
+	return fmt.Sprintf("wrapParamError(%q, %q, %q, %s)", strings.TrimSuffix(name, " items"), rule, msg, err)
+}
+
+// returns Go statement reporting the ParamError expression to errs, which
+// stops getting of the params if the errors are not accumulated.
+func fail(paramErr string) string {
+	return fmt.Sprintf("if err := errs.add(%s); err != nil { return err }", paramErr)
+}
+
+// names of the net/http constants of the success statuses
+var statusNames = map[int]string{
+	http.StatusOK:        "http.StatusOK",
+	http.StatusCreated:   "http.StatusCreated",
+	http.StatusAccepted:  "http.StatusAccepted",
+	http.StatusNoContent: "http.StatusNoContent",
+}
+
+// returns Go expression of the HTTP status code.
+func statusExpr(status int) string {
+	if name, ok := statusNames[status]; ok {
+		return name
+	}
+	return fmt.Sprint(status)
+}
 
-		if err := genGetFromRequest(p, structName, params); err != nil {
+func genGetFromPath(p *printer, structName string, fields []*paramStructField) error {
+	if len(fields) == 0 {
+		return nil
+	}
+	p.printf(`// get from path`)
+	for _, field := range fields {
+		if err := genGetFromString(p, structName, field, fmt.Sprintf(`pathValue(r, %q)`, field.apiParamName())); err != nil {
 			return err
 		}
-		if err := genValidate(p, structName, params); err != nil {
+	}
+	return p.err
+}
+
+func genGetFromHeaders(p *printer, structName string, fields []*paramStructField) error {
+	if len(fields) == 0 {
+		return nil
+	}
+	p.printf(`// get from headers and cookies`)
+	for _, field := range fields {
+		expr := fmt.Sprintf(`r.Header.Get(%q)`, field.apiParamName())
+		if field.source == cookieSource {
+			expr = fmt.Sprintf(`cookieValue(r, %q)`, field.apiParamName())
+		}
+		if err := genGetFromString(p, structName, field, expr); err != nil {
 			return err
 		}
 	}
-
-	return nil
+	return p.err
 }
 
-func genWriteApiError(p *printer) error {
-	p.printf(``)
-	p.printf(`func writeApiError(w http.ResponseWriter, ae ApiError) {`)
-	p.printf(`const op = "writeApiError"`)
-
-	p.printf(`w.Header().Add("content-type", "application/json")`)
-	p.printf(`w.WriteHeader(ae.HTTPStatus)`)
-
-	p.printf(`if _, err := fmt.Fprintf(w, "{\"error\":%%q}", ae.Err.Error()); err != nil {`)
-	p.printf(`	log.Printf("%%s: can't write response body: %%v", op, err)`)
+func genGetFromJsonBody(p *printer, structName string, fields []*paramStructField) error {
+	p.printf(`// get from json body`)
+	p.printf(`defer io.Copy(io.Discard, r.Body)`)
+	p.printf(`var req struct{`)
+	genJsonStructFields(p, fields)
 	p.printf(`}`)
 
+	p.printf(`dec := json.NewDecoder(r.Body)`)
+	p.printf(`if strict {`)
+	p.printf(`	dec.DisallowUnknownFields()`)
+	p.printf(`}`)
+	p.printf(`if err := dec.Decode(&req); err != nil { return /*bad json*/ err }`)
+	p.printf(`if strict {`)
+	p.printf(`	if _, err := dec.Token(); err != io.EOF {`)
+	p.printf(`		if err == nil {`)
+	p.printf(`			err = errors.New("unexpected data after json body")`)
+	p.printf(`		}`)
+	p.printf(`		return err`)
+	p.printf(`	}`)
 	p.printf(`}`)
-	return p.err
-}
 
-func genServeHTTP(p *printer, recvType string, methods []*serviceMethod) error {
+	if err := genJsonAssign(p, structName, fields, "req", "p", "", ""); err != nil {
+		return err
+	}
 
-	// func (h *SomeStructName ) ServeHTTP(w http.ResponseWriter, r *http.Request) {
-	// 	switch r.URL.Path {
-	// 	case "...":
-	// 		h.wrapperDoSomeJob(w, r)
-	// 	default:
-	// 		// 404
-	// 	}
-	// }
+	return p.err
+}
 
-	byPath := make(map[string]map[string]*serviceMethod)
-	for _, m := range methods {
-		byMethod, ok := byPath[m.URL]
-		if !ok {
-			byMethod = map[string]*serviceMethod{}
-			byPath[m.URL] = byMethod
+// generates fields of anonymous struct to decode json body. All fields are
+// pointers to distinguish absent ones.
+func genJsonStructFields(p *printer, fields []*paramStructField) {
+	for _, field := range fields {
+		if field.kind == File {
+			// files are uploaded in multipart form only
+			continue
 		}
-		if _, ok := byMethod[m.HTTPMethod]; ok {
-			return &ParseError{
-				Err: errors.New("dublicate HTTP method"),
-				Pos: m.pos,
+		if field.isStruct() {
+			p.printf(`%s *struct {`, field.varName())
+			genJsonStructFields(p, field.fields)
+			p.printf(`} `+q+`json:"%s"`+q, field.apiParamName())
+		} else if field.jsonText() {
+			// decoded by UnmarshalText or ParseDuration after the text rules are checked
+			if field.isSlice {
+				p.printf(`%s *[]string `+q+`json:"%s"`+q, field.varName(), field.apiParamName())
+			} else {
+				p.printf(`%s *string `+q+`json:"%s"`+q, field.varName(), field.apiParamName())
 			}
+		} else {
+			p.printf(`%s *%s `+q+`json:"%s"`+q, field.varName(), p.fieldType(field), field.apiParamName())
 		}
-		byMethod[m.HTTPMethod] = m
 	}
+}
 
-	p.printf(``)
-	p.printf(`func (h *%s) ServeHTTP(w http.ResponseWriter, r *http.Request) {`, recvType)
-	p.printf(`switch r.URL.Path {`)
+// generates assignment of the decoded json fields, applying required and default rules.
+// Fields of nested structs are guarded by the checks their parents are not nil.
+func genJsonAssign(p *printer, structName string, fields []*paramStructField, req, dst, guard, prefix string) error {
+	for _, field := range fields {
+		src := req + "." + field.varName()
+		dst := dst + "." + field.name
+		name := prefix + field.apiParamName()
 
-	for _, path := range sortedKeys(byPath) {
-		byMethod := byPath[path]
-		p.printf(`case "%s":`, path)
+		if field.isStruct() {
+			if err := genJsonAssign(p, structName, field.fields, src, dst, guard+src+" != nil && ", name+"."); err != nil {
+				return err
+			}
+			continue
+		}
 
-		p.printf(`switch /*r.Method*/ {`)
-		for _, httpMethod := range sortedKeys(byPath[path]) {
-			if httpMethod == anyHTTPMethod {
-				continue
+		if field.kind == File {
+			if field.rules&requiredRule != 0 {
+				p.printf(`%s`, fail(paramError(name, "required", name+" must be not empty")))
 			}
-			method := byMethod[httpMethod]
-			p.printf(`case strings.EqualFold(r.Method, "%s"):`, httpMethod)
-			if method.Auth {
-				if err := genAuth(p, method); err != nil {
-					return err
-				}
+			continue
+		}
+
+		if field.jsonText() {
+			if err := genJsonTextAssign(p, structName, field, src, dst, guard, name); err != nil {
+				return err
 			}
-			p.printf(`h.wrapper%s(w, r)`, method.name)
-		}
-		p.printf(`default:`)
-		if method, ok := byMethod[anyHTTPMethod]; ok {
-			if method.Auth {
-				if err := genAuth(p, method); err != nil {
-					return err
-				}
+			continue
+		}
+
+		if field.trim && !field.isSlice {
+			// the blank string is empty after trim like the form value
+			p.printf(`if %s%s != nil {`, guard, src)
+			p.printf(`%s = *%s`, dst, src)
+			genNormalize(p, field, dst, true)
+			p.printf(`}`)
+			switch {
+			case field.rules&defaultRule != 0:
+				p.printf(`if %s == "" { %s = %q }`, dst, dst, field.defaultVal)
+			case field.rules&requiredRule != 0:
+				p.printf(`if %s == "" { %s }`, dst, fail(paramError(name, "required", name+" must be not empty")))
 			}
-			p.printf(`h.wrapper%s(w, r)`, method.name)
+			continue
+		}
+
+		p.printf(`if %s%s != nil {`, guard, src)
+		p.printf(`%s = *%s`, dst, src)
+		if field.isSlice && (field.trim || field.lowercase) {
+			p.printf(`for i := range %s {`, dst)
+			genNormalize(p, field, dst+"[i]", true)
+			p.printf(`}`)
 		} else {
-			p.printf(`writeApiError(w, ApiError{HTTPStatus: http.StatusNotAcceptable, Err: errors.New("bad method")})`)
-			p.printf(`return`)
+			genNormalize(p, field, dst, true)
+		}
+		switch {
+		case field.rules&defaultRule != 0:
+			p.printf(`} else {`)
+			if field.kind == String {
+				p.printf(`%s = %q`, dst, field.defaultVal)
+			} else {
+				p.printf(`%s = %s`, dst, field.number(field.defaultVal))
+			}
+		case field.rules&requiredRule != 0:
+			p.printf(`} else {`)
+			p.printf(`%s`, fail(paramError(name, "required", name+" must be not empty")))
 		}
 		p.printf(`}`)
 	}
+	return p.err
+}
 
-	p.printf(`default:`)
-	p.printf(`	writeApiError(w, ApiError{HTTPStatus: http.StatusNotFound, Err: errors.New("unknown method")})`)
-	p.printf(`}`)
+// reports whether the field is decoded from the json string like from the form
+// value, as encoding.TextUnmarshaler or time.Duration
+func (p paramStructField) jsonText() bool {
+	return p.unmarshaler == textUnmarshaler || isDuration(p.typ)
+}
+
+// generates decoding of the encoding.TextUnmarshaler or time.Duration field
+// from the json string
+func genJsonTextAssign(p *printer, structName string, field *paramStructField, src, dst, guard, name string) error {
+	if field.isSlice {
+		p.printf(`if %s%s != nil {`, guard, src)
+		p.printf(`for _, s := range *%s {`, src)
+		if err := genParseString(p, structName, field, name+" items", dst+` = append(`+dst+`, %s)`); err != nil {
+			return err
+		}
+		p.printf(`}`)
+		if field.rules&requiredRule != 0 {
+			p.printf(`} else {`)
+			p.printf(`%s`, fail(paramError(name, "required", name+" must be not empty")))
+		}
+		p.printf(`}`)
+		return p.err
+	}
+
+	p.printf(`{`)
+	p.printf(`var s string`)
+	p.printf(`if %s%s != nil { s = *%s }`, guard, src, src)
+	if err := genGetCustomFromText(p, structName, field, name, dst+` = %s`); err != nil {
+		return err
+	}
 	p.printf(`}`)
+	return p.err
+}
 
+func genGetFromFormOrQuery(p *printer, structName string, fields []*paramStructField) error {
+	p.printf(`// get from form or query`)
+	for _, field := range fields {
+		var err error
+		switch {
+		case field.kind == File:
+			err = genGetFilesFromForm(p, field)
+		case field.isSlice:
+			err = genGetSliceFromForm(p, structName, field)
+		default:
+			err = genGetFromString(p, structName, field, formValueExpr(field))
+		}
+		if err != nil {
+			return err
+		}
+	}
 	return p.err
 }
 
-// XXX now generates dummy code
-func genAuth(p *printer, _ *serviceMethod) error {
-	p.printf(`if key := r.Header.Get("X-Auth"); key != "%s" { // XXX`, authKey)
-	p.printf(`	writeApiError(w, ApiError{HTTPStatus: http.StatusForbidden, Err: errors.New("unauthorized")})`)
-	p.printf(`	return`)
+// generates getting of the uploaded file or files of the slice field
+func genGetFilesFromForm(p *printer, field *paramStructField) error {
+	p.printf(`{`)
+	p.printf(`fhs := formFiles(r, %s)`, quoteAll(field.formKeys()))
+	if field.rules&requiredRule != 0 {
+		p.printf(`if len(fhs) == 0 { %s }`, fail(paramError(field.apiParamName(), "required", field.apiParamName()+" must be not empty")))
+	}
+	if field.isSlice {
+		p.printf(`p.%s = fhs`, field.name)
+	} else {
+		p.printf(`if len(fhs) > 0 { p.%s = fhs[0] }`, field.name)
+	}
 	p.printf(`}`)
-	return nil
+	return p.err
 }
 
-func genMethodWrapper(p *printer, m *serviceMethod) error {
+// generates normalization of the string variable by trim and lowercase rules,
+// the variable of the named string type is converted if typed is true
+func genNormalize(p *printer, field *paramStructField, v string, typed bool) {
+	for _, fn := range []struct {
+		on   bool
+		name string
+	}{
+		{field.trim, "strings.TrimSpace"},
+		{field.lowercase, "strings.ToLower"},
+	} {
+		if !fn.on {
+			continue
+		}
+		if t := p.typeName(field.typ); typed && t != "string" {
+			p.printf(`%s = %s(%s(string(%s)))`, v, t, fn.name, v)
+		} else {
+			p.printf(`%s = %s(%s)`, v, fn.name, v)
+		}
+	}
+}
 
-	// func (h *SomeStructName ) wrapperDoSomeJob() {
-	// 	// заполнение структуры params
-	// 	// валидирование параметров
-	// 	res, err := h.DoSomeJob(ctx, params)
-	// 	// прочие обработки
-	// }
+func formValueExpr(field *paramStructField) string {
+	keys := field.formKeys()
+	if len(keys) == 1 {
+		return fmt.Sprintf(`r.FormValue(%q)`, keys[0])
+	}
+	return fmt.Sprintf(`formValue(r, %s)`, quoteAll(keys))
+}
 
-	p.printf(``)
-	if m.recv.isPointer {
-		p.printf(`func (h *%s) wrapper%s(w http.ResponseWriter, r *http.Request) {`, m.recv.name, m.name)
-	} else {
-		p.printf(`func (h %s) wrapper%s(w http.ResponseWriter, r *http.Request) {`, m.recv.name, m.name)
+func quoteAll(ss []string) string {
+	qs := make([]string, len(ss))
+	for i, s := range ss {
+		qs[i] = fmt.Sprintf("%q", s)
 	}
-	p.printf(`const op = "%s.wrapper%s"`, m.recv.name, m.name)
-	p.printf(`var params %s`, m.params.name)
+	return strings.Join(qs, ", ")
+}
 
-	p.printf(`if err := params.getFromRequest(r); err != nil {`)
-	p.printf(`	writeApiError(w, ApiError{HTTPStatus: http.StatusBadRequest, Err: err})`)
-	p.printf(`	return`)
-	p.printf(`}`)
+// generates getting of the field value from the string expression
+func genGetFromString(p *printer, structName string, field *paramStructField, expr string) error {
+	p.printf(`{`)
+	p.printf(`s := %s`, expr)
+	genNormalize(p, field, "s", false)
 
-	p.printf(`if err := params.validate(); err != nil {`)
-	p.printf(`	writeApiError(w, ApiError{HTTPStatus: http.StatusBadRequest, Err: err})`)
-	p.printf(`	return`)
-	p.printf(`}`)
+	if field.kind == Custom {
+		if err := genGetCustomFromText(p, structName, field, field.apiParamName(), `p.`+field.name+` = %s`); err != nil {
+			return err
+		}
+		p.printf(`}`)
+		return p.err
+	}
 
-	p.printf(`ctx := r.Context()`)
-	if m.params.isPointer {
-		p.printf(`res, err := h.%s(ctx, &params)`, m.name)
-	} else {
-		p.printf(`res, err := h.%s(ctx, params)`, m.name)
-	}
-	p.printf(`if err != nil {`)
-	p.printf(`	switch err := err.(type) {`)
-	p.printf(`	case *ApiError:`)
-	p.printf(`		writeApiError(w, *err)`)
-	p.printf(`	case ApiError:`)
-	p.printf(`		writeApiError(w, err)`)
-	p.printf(`	default:`)
-	p.printf(`		writeApiError(w, ApiError{HTTPStatus: http.StatusInternalServerError, Err: err})`)
-	p.printf(`	}`)
-	p.printf(`	return`)
-	p.printf(`}`)
+	if field.rules&requiredRule != 0 && field.rules&defaultRule == 0 {
+		p.printf(`if s == "" { %s }`, fail(paramError(field.apiParamName(), "required", field.apiParamName()+" must be not empty")))
+	}
 
-	p.printf(`resp := struct {`)
-	if m.result.isPointer {
-		p.printf(`	Response *%s `+q+`json:"response"`+q, m.result.name)
-	} else {
-		p.printf(`	Response  %s `+q+`json:"response"`+q, m.result.name)
+	if field.rules&defaultRule != 0 {
+		p.printf(`if s == "" {`)
+		if field.kind == String {
+			p.printf(`p.%s = %q`, field.name, field.defaultVal)
+		} else {
+			p.printf(`p.%s = %s`, field.name, field.number(field.defaultVal))
+		}
+		p.printf(`} else {`)
 	}
-	p.printf(`	Error string ` + q + `json:"error"` + q)
-	p.printf(`}{`)
-	p.printf(`	Response: res,`)
-	p.printf(`}`)
 
-	p.printf(`w.Header().Add("content-type", "application/json")`)
-	p.printf(`w.WriteHeader(http.StatusOK)`)
+	if err := genParseString(p, structName, field, field.apiParamName(), `p.`+field.name+` = %s`); err != nil {
+		return err
+	}
 
-	p.printf(`if err := json.NewEncoder(w).Encode(&resp); err != nil {`)
-	p.printf(`	log.Printf("%%s: can't write response body: %%v", op, err)`)
-	p.printf(`}`)
+	if field.rules&defaultRule != 0 {
+		p.printf(`}`)
+	}
 
 	p.printf(`}`)
-
 	return p.err
 }
 
-func genGetFromRequest(p *printer, structName string, fields []*paramStructField) error {
-	p.printf(``)
-	p.printf(`func (p *%s) getFromRequest(r *http.Request) error {`, structName)
-	p.printf(`if r.Header.Get("content-type") == "application/json" {`)
-	genGetFromJsonBody(p, structName, fields)
-	p.printf(`} else {`)
-	genGetFromFormOrQuery(p, structName, fields)
-	p.printf(`}`)
-	p.printf(`return nil`)
+// generates getting of the custom type field value from the text s. The required
+// and default rules are applied to the text, the empty text is not decoded.
+func genGetCustomFromText(p *printer, structName string, field *paramStructField, name, assign string) error {
+	if field.rules&requiredRule != 0 && field.rules&defaultRule == 0 {
+		p.printf(`if s == "" { %s }`, fail(paramError(name, "required", name+" must be not empty")))
+	}
+	if field.rules&defaultRule != 0 {
+		p.printf(`if s == "" { s = %q }`, field.defaultVal)
+	}
+	p.printf(`if s != "" {`)
+	if err := genParseString(p, structName, field, name, assign); err != nil {
+		return err
+	}
 	p.printf(`}`)
-
 	return p.err
 }
 
-func genGetFromJsonBody(p *printer, structName string, fields []*paramStructField) error {
-	const op = "genGetFromJsonBody"
+// generates getting of the slice field items from the repeated form keys
+func genGetSliceFromForm(p *printer, structName string, field *paramStructField) error {
+	p.printf(`{`)
+	p.printf(`ss := formValues(r, %v, %s)`, field.csv, quoteAll(field.formKeys()))
 
-	p.printf(`// get from json body`)
-	p.printf(`defer io.Copy(io.Discard, r.Body)`)
-	p.printf(`req := struct{`)
-	for _, field := range fields {
-		if field.rules&requiredRule != 0 && field.rules&defaultRule == 0 {
-			p.printf(`%s *%s `+q+`json:"%s"`+q, field.name, field.kind, field.apiParamName())
-		} else {
-			p.printf(`%s %s `+q+`json:"%s"`+q, field.name, field.kind, field.apiParamName())
-		}
+	if field.rules&requiredRule != 0 {
+		p.printf(`if len(ss) == 0 { %s }`, fail(paramError(field.apiParamName(), "required", field.apiParamName()+" must be not empty")))
 	}
-	p.printf(`}{`)
-	for _, field := range fields {
-		if field.rules&defaultRule != 0 {
-			if field.kind == String {
-				p.printf(`%s: %q,`, field.name, field.defaultVal)
-			} else {
-				p.printf(`%s: %s,`, field.name, field.defaultVal)
-			}
-		}
+
+	p.printf(`for _, s := range ss {`)
+	genNormalize(p, field, "s", false)
+	if err := genParseString(p, structName, field, field.apiParamName()+" items", `p.`+field.name+` = append(p.`+field.name+`, %s)`); err != nil {
+		return err
 	}
 	p.printf(`}`)
 
-	p.printf(`if err := json.NewDecoder(r.Body).Decode(&req); err != nil { return /*bad json*/ err }`)
+	p.printf(`}`)
+	return p.err
+}
 
-	for _, field := range fields {
-		if field.rules&requiredRule != 0 && field.rules&defaultRule == 0 {
-			p.printf(`if req.%s == nil { return errors.New("%s must be not empty") }`, field.name, field.apiParamName())
+// generates parsing of the string s to the field kind value and its
+// assignment by the assign format, e.g. "p.Field = %s"
+func genParseString(p *printer, structName string, field *paramStructField, name string, assign string) error {
+	const op = "genParseString"
+
+	// converts the parsed value of the basic type to the field type
+	conv := func(v, basic string) string {
+		if t := p.typeName(field.typ); t != basic {
+			return t + "(" + v + ")"
 		}
+		return v
 	}
 
-	for _, field := range fields {
-		if field.rules&requiredRule != 0 && field.rules&defaultRule == 0 {
-			p.printf(`p.%s = *req.%s`, field.name, field.name)
+	switch {
+	case field.kind == Custom:
+		if field.rules&enumRule != 0 {
+			// enum of the custom type is checked on the text
+			p.printf(`{`)
+			p.printf(`valid := false`)
+			for _, e := range field.enum {
+				p.printf(`valid = valid || s == %q`, e)
+			}
+			p.printf(`if !valid { %s }`, fail(enumError(field, name)))
+			p.printf(`}`)
+		}
+		p.printf(`var v %s`, p.typeName(field.typ))
+		if field.unmarshaler == textUnmarshaler {
+			p.printf(`if err := v.UnmarshalText([]byte(s)); err != nil { %s }`, fail(wrapParamError(name, "format", name+" is invalid: ", "err")))
 		} else {
-			p.printf(`p.%s = req.%s`, field.name, field.name)
+			p.printf(`b := []byte(s)`)
+			p.printf(`if !json.Valid(b) { b, _ = json.Marshal(s) } // plain text is decoded as json string`)
+			p.printf(`if err := json.Unmarshal(b, &v); err != nil { %s }`, fail(wrapParamError(name, "format", name+" is invalid: ", "err")))
+		}
+		p.printf(assign, `v`)
+	case isDuration(field.typ):
+		p.printf(`v, err := %s.ParseDuration(s)`, p.use("time"))
+		p.printf(`if err != nil { %s }`, fail(paramError(name, "type", name+" must be duration", "type", "duration")))
+		p.printf(assign, conv(`v`, p.typeName(field.typ)))
+	case field.kind == String:
+		p.printf(assign, conv(`s`, "string"))
+	case field.kind == Bool:
+		p.printf(`v, err := strconv.ParseBool(s)`)
+		p.printf(`if err != nil { %s }`, fail(paramError(name, "type", name+" must be bool", "type", "bool")))
+		p.printf(assign, conv(`v`, "bool"))
+	case field.kind == Int:
+		p.printf(`v, err := strconv.Atoi(s)`)
+		genParseNumberError(p, name, field.kind)
+		p.printf(assign, conv(`v`, "int"))
+	case isInt(field.kind):
+		p.printf(`v, err := strconv.ParseInt(s, 10, %d)`, bitSize(field.kind))
+		genParseNumberError(p, name, field.kind)
+		p.printf(assign, conv(`v`, ""))
+	case isUint(field.kind):
+		p.printf(`v, err := strconv.ParseUint(s, 10, %d)`, bitSize(field.kind))
+		genParseNumberError(p, name, field.kind)
+		p.printf(assign, conv(`v`, ""))
+	case field.kind == Float32:
+		p.printf(`v, err := strconv.ParseFloat(s, 32)`)
+		genParseNumberError(p, name, field.kind)
+		p.printf(assign, conv(`v`, ""))
+	case field.kind == Float64:
+		p.printf(`v, err := strconv.ParseFloat(s, 64)`)
+		genParseNumberError(p, name, field.kind)
+		p.printf(assign, conv(`v`, "float64"))
+	default:
+		return &ParseError{
+			Err: fmt.Errorf(`%s: %s.%s: invalid param type: %v`, op, structName, field.name, field.kind),
+			Pos: field.pos,
 		}
 	}
 
-	return nil
+	return p.err
 }
 
-func genGetFromFormOrQuery(p *printer, structName string, fields []*paramStructField) error {
-	const op = "genGetFromFormOrQuery"
+func genParseNumberError(p *printer, name string, k kind) {
+	p.printf(`if errors.Is(err, strconv.ErrRange) { %s }`, fail(paramError(name, "type", fmt.Sprintf("%s is out of %v range", name, k), "type", k.String())))
+	p.printf(`if err != nil { %s }`, fail(paramError(name, "type", fmt.Sprintf("%s must be %v", name, k), "type", k.String())))
+}
 
-	p.printf(`// get from form or query`)
-	for _, field := range fields {
-		p.printf(`{`)
-		p.printf(`s := r.FormValue(%q)`, field.apiParamName())
+// generates validation of the leaf field of the param struct, leaves are
+// all of them to find the fields of the cross field rules.
+func genValidateField(p *printer, structName string, field *paramStructField, leaves []*paramStructField) error {
+	if field.rules == 0 && !field.hasValidate && len(field.cross) == 0 {
+		return nil
+	}
+
+	// moved to GetFrom*
+	// if field.rules&requiredRule != 0 {...}
 
-		if field.rules&requiredRule != 0 && field.rules&defaultRule == 0 {
-			p.printf(`if s == "" { return errors.New("%s must be not empty") }`, field.apiParamName())
+	// moved to GetFrom*
+	// if field.rules&defaultRule != 0 {...}
+
+	if !field.isSlice {
+		if err := genValidateValue(p, structName, field, `p.`+field.name, field.apiParamName()); err != nil {
+			return err
 		}
+		if field.hasValidate {
+			p.printf(`if err := p.%s.Validate(); err != nil { %s }`, field.name, fail(wrapParamError(field.apiParamName(), "validate", field.apiParamName()+": ", "err")))
+		}
+		return genValidateCross(p, field, leaves)
+	}
 
-		if field.rules&defaultRule != 0 {
-			p.printf(`if s == "" {`)
-			if field.kind == String {
-				p.printf(`p.%s = %q`, field.name, field.defaultVal)
-			} else {
-				p.printf(`p.%s = %s`, field.name, field.defaultVal)
-			}
-			p.printf(`} else {`)
+	if err := genValidateItems(p, field); err != nil {
+		return err
+	}
+	if field.rules&(valueRules|fileRules) != 0 {
+		p.printf(`for _, v := range p.%s {`, field.name)
+		if err := genValidateValue(p, structName, field, `v`, field.apiParamName()+" items"); err != nil {
+			return err
 		}
+		p.printf(`}`)
+	}
+	if field.hasValidate {
+		p.printf(`for i := range p.%s {`, field.name)
+		p.printf(`	if err := p.%s[i].Validate(); err != nil { %s }`, field.name, fail(wrapParamError(field.apiParamName(), "validate", field.apiParamName()+" items: ", "err")))
+		p.printf(`}`)
+	}
+	return genValidateCross(p, field, leaves)
+}
 
-		switch field.kind {
-		case String:
-			p.printf(`p.%s = s`, field.name)
-		case Int:
-			p.printf(`v, err := strconv.Atoi(s)`)
-			p.printf(`if err != nil { return errors.New("%s must be int") }`, field.apiParamName())
-			p.printf(`p.%s = v`, field.name)
-		case Float32:
-			p.printf(`v, err := strconv.ParseFloat(s, 32)`)
-			p.printf(`if err != nil { return errors.New("%s must be float32") }`, field.apiParamName())
-			p.printf(`p.%s = v`, field.name)
-		case Float64:
-			p.printf(`v, err := strconv.ParseFloat(s, 64)`)
-			p.printf(`if err != nil { return errors.New("%s must be float64") }`, field.apiParamName())
-			p.printf(`p.%s = v`, field.name)
-		default:
-			return &ParseError{
-				Err: fmt.Errorf(`%s: %s.%s: invalid param type: %v`, op, structName, field.name, field.kind),
-				Pos: field.pos,
+// generates the cross field rules comparing the field with the other field
+// of the same struct or requiring it depending on the other field.
+func genValidateCross(p *printer, field *paramStructField, leaves []*paramStructField) error {
+	for _, c := range field.cross {
+		refName := strings.TrimSuffix(field.name, c.selfName) + c.refName
+		var ref *paramStructField
+		for _, leaf := range leaves {
+			if leaf.name == refName {
+				ref = leaf
 			}
 		}
+		if ref == nil {
+			return fmt.Errorf("genValidateCross: %s: field %s not found", field.name, refName)
+		}
 
-		if field.rules&defaultRule != 0 {
-			p.printf(`}`)
+		name, refParam := field.apiParamName(), ref.apiParamName()
+		switch c.rule {
+		case "required_with":
+			p.printf(`if %s && !(%s) { %s }`, zeroExpr(p, field, "p."+field.name), zeroExpr(p, ref, "p."+ref.name),
+				fail(paramError(name, c.rule, fmt.Sprintf("%s must be not empty if %s is not empty", name, refParam), "field", refParam)))
+		case "required_without":
+			p.printf(`if %s && %s { %s }`, zeroExpr(p, field, "p."+field.name), zeroExpr(p, ref, "p."+ref.name),
+				fail(paramError(name, c.rule, fmt.Sprintf("%s must be not empty if %s is empty", name, refParam), "field", refParam)))
+		default:
+			op := crossOps[c.rule]
+			p.printf(`if !(p.%s %s p.%s) { %s }`, field.name, op, ref.name,
+				fail(paramError(name, c.rule, fmt.Sprintf("%s must be %s %s", name, op, refParam), "field", refParam)))
 		}
+	}
+	return p.err
+}
 
-		p.printf(`}`)
+// returns Go expression reporting whether the field value is zero
+func zeroExpr(p *printer, field *paramStructField, value string) string {
+	switch {
+	case field.isSlice:
+		return "len(" + value + ") == 0"
+	case field.kind == String:
+		return value + ` == ""`
+	case field.kind == Bool:
+		return "!" + value
+	case field.kind == File:
+		return value + " == nil"
+	case isNumber(field.kind):
+		return value + " == 0"
 	}
-	return nil
+	return p.use("reflect") + ".ValueOf(" + value + ").IsZero()"
 }
 
-func genValidate(p *printer, structName string, fields []*paramStructField) error {
-	const op = `genValidate`
+// generates validation of the slice field items count and uniqueness
+func genValidateItems(p *printer, field *paramStructField) error {
+	if field.rules&minItemsRule != 0 {
+		p.printf(`if !(len(p.%s) >= %s) { %s }`, field.name, field.minItems,
+			fail(paramError(field.apiParamName(), "minitems", fmt.Sprintf("%s must have >= %s items", field.apiParamName(), field.minItems), "minitems", field.minItems)))
+	}
 
-	p.printf(``)
-	p.printf(`func (p *%s) validate() error {`, structName)
+	if field.rules&maxItemsRule != 0 {
+		p.printf(`if !(len(p.%s) <= %s) { %s }`, field.name, field.maxItems,
+			fail(paramError(field.apiParamName(), "maxitems", fmt.Sprintf("%s must have <= %s items", field.apiParamName(), field.maxItems), "maxitems", field.maxItems)))
+	}
 
-	for _, field := range fields {
-		if field.rules == 0 {
+	if field.rules&uniqueRule != 0 {
+		p.printf(`{`)
+		p.printf(`seen := make(map[%s]bool, len(p.%s))`, p.typeName(field.typ), field.name)
+		p.printf(`for _, v := range p.%s {`, field.name)
+		p.printf(`	if seen[v] { %s }`, fail(paramError(field.apiParamName(), "unique", field.apiParamName()+" items must be unique")))
+		p.printf(`	seen[v] = true`)
+		p.printf(`}`)
+		p.printf(`}`)
+	}
+
+	return p.err
+}
+
+// generates validation of the string lengths and format
+func genValidateString(p *printer, structName string, field *paramStructField, value, name string) error {
+	if p.typeName(field.typ) != "string" {
+		value = "string(" + value + ")"
+	}
+
+	lengths := []struct {
+		flag  ruleSet
+		rule  string
+		expr  string
+		op    string
+		limit string
+		msg   string
+	}{
+		{lenRule, "len", "len(%s)", "==", field.length, "%s len must be %s"},
+		{runeMinRule, "runemin", "%s.RuneCountInString(%s)", ">=", field.runeMin, "%s must have >= %s characters"},
+		{runeMaxRule, "runemax", "%s.RuneCountInString(%s)", "<=", field.runeMax, "%s must have <= %s characters"},
+		{runeLenRule, "runelen", "%s.RuneCountInString(%s)", "==", field.runeLen, "%s must have %s characters"},
+	}
+	for _, l := range lengths {
+		if field.rules&l.flag == 0 {
 			continue
 		}
+		expr := fmt.Sprintf(l.expr, value)
+		if l.flag != lenRule {
+			expr = fmt.Sprintf(l.expr, p.use("unicode/utf8"), value)
+		}
+		p.printf(`if !(%s %s %s) { %s }`, expr, l.op, l.limit,
+			fail(paramError(name, l.rule, fmt.Sprintf(l.msg, name, l.limit), l.rule, l.limit)))
+	}
 
-		// moved to GetFrom*
-		// if field.rules&requiredRule != 0 {...}
+	if field.rules&formatRules == 0 {
+		return p.err
+	}
+	p.printf(`if %s != "" {`, value)
+	if field.rules&patternRule != 0 {
+		p.printf(`if !%s.MatchString(%s) { %s }`, patternVar(structName, field), value,
+			fail(paramError(name, "pattern", fmt.Sprintf("%s must match pattern %s", name, field.pattern), "pattern", field.pattern)))
+	}
+	if field.rules&emailRule != 0 {
+		p.printf(`if a, err := %s.ParseAddress(%s); err != nil || a.Address != %s { %s }`, p.use("net/mail"), value, value,
+			fail(paramError(name, "email", name+" must be valid email")))
+	}
+	if field.rules&urlRule != 0 {
+		p.printf(`if u, err := %s.ParseRequestURI(%s); err != nil || u.Scheme == "" || u.Host == "" { %s }`, p.use("net/url"), value,
+			fail(paramError(name, "url", name+" must be valid URL")))
+	}
+	if field.rules&uuidRule != 0 {
+		p.printf(`if !uuidPattern.MatchString(%s) { %s }`, value, fail(paramError(name, "uuid", name+" must be valid UUID")))
+	}
+	if field.rules&alphanumRule != 0 {
+		p.printf(`if !alphanumPattern.MatchString(%s) { %s }`, value, fail(paramError(name, "alphanum", name+" must contain letters and digits only")))
+	}
+	p.printf(`}`)
 
-		// moved to GetFrom*
-		// if field.rules&defaultRule != 0 {...}
+	return p.err
+}
 
-		if field.rules&enumRule != 0 {
-			p.printf(`valid := false`)
-			switch field.kind {
-			case String:
-				for _, s := range field.enum {
-					p.printf(`valid = valid || p.%s == %q`, field.name, s)
-				}
-				p.printf(`if !valid { return errors.New("%s must be one of [%s]") }`, field.apiParamName(), strings.Join(field.enum, `, `))
-			case Int, Float32, Float64:
-				for _, s := range field.enum {
-					p.printf(`valid |= p.%s == %s`, field.name, s)
-				}
-				p.printf(`if !valid { return errors.New("%s must be one of [%s]") }`, field.apiParamName(), strings.Join(field.enum, `, `))
-			default:
-				return &ParseError{
-					Err: fmt.Errorf(`%s: %s.%s: enum rule not applicable for %v type`, op, structName, field.name, field.kind),
-					Pos: field.pos,
-				}
+// generates validation of the uploaded file size and media type, the absent file is not checked
+func genValidateFile(p *printer, field *paramStructField, value, name string) error {
+	p.printf(`if %s != nil {`, value)
+	if field.rules&maxSizeRule != 0 {
+		p.printf(`if !(%s.Size <= %s) { %s }`, value, field.maxSize,
+			fail(paramError(name, "maxsize", fmt.Sprintf("%s size must be <= %s bytes", name, field.maxSize), "maxsize", field.maxSize)))
+	}
+	if field.rules&mimeRule != 0 {
+		p.printf(`{`)
+		p.printf(`mt, _, _ := %s.ParseMediaType(%s.Header.Get("Content-Type"))`, p.use("mime"), value)
+		p.printf(`valid := false`)
+		for _, m := range field.mime {
+			p.printf(`valid = valid || mt == %q`, m)
+		}
+		p.printf(`if !valid { %s }`, fail(paramError(name, "mime",
+			fmt.Sprintf("%s type must be one of [%s]", name, strings.Join(field.mime, ", ")), "mime", strings.Join(field.mime, "|"))))
+		p.printf(`}`)
+	}
+	p.printf(`}`)
+	return p.err
+}
+
+// generates validation of the value expression by the field rules, name is used in error messages
+func genValidateValue(p *printer, structName string, field *paramStructField, value, name string) error {
+	const op = `genValidateValue`
+
+	if field.kind == File {
+		return genValidateFile(p, field, value, name)
+	}
+
+	// enum of the custom type is checked on the text by getFromRequest
+	if field.rules&enumRule != 0 && field.kind != Custom {
+		p.printf(`{`)
+		p.printf(`valid := false`)
+		switch {
+		case field.kind == String:
+			for _, s := range field.enum {
+				p.printf(`valid = valid || %s == %q`, value, s)
+			}
+			p.printf(`if !valid { %s }`, fail(enumError(field, name)))
+		case isNumber(field.kind):
+			for _, s := range field.enum {
+				p.printf(`valid = valid || %s == %s`, value, field.number(s))
+			}
+			p.printf(`if !valid { %s }`, fail(enumError(field, name)))
+		default:
+			return &ParseError{
+				Err: fmt.Errorf(`%s: %s.%s: enum rule not applicable for %v type`, op, structName, field.name, field.kind),
+				Pos: field.pos,
 			}
 		}
+		p.printf(`}`)
+	}
 
-		if field.rules&minRule != 0 {
-			switch field.kind {
-			case String:
-				p.printf(`if !(len(p.%s) >= %s) { return errors.New("%s len must be >= %s") }`, field.name, field.min, field.apiParamName(), field.min)
-			case Int, Float32, Float64:
-				p.printf(`if !(p.%s >= %s) { return errors.New("%s must be >= %s") }`, field.name, field.min, field.apiParamName(), field.min)
-			default:
-				return &ParseError{
-					Err: fmt.Errorf(`%s: %s.%s: min rule not applicable for %v type`, op, structName, field.name, field.kind),
-					Pos: field.pos,
-				}
+	if field.rules&minRule != 0 {
+		switch {
+		case field.kind == String:
+			p.printf(`if !(len(%s) >= %s) { %s }`, value, field.min,
+				fail(paramError(name, "min", fmt.Sprintf("%s len must be >= %s", name, field.min), "min", field.min)))
+		case isNumber(field.kind):
+			p.printf(`if !(%s >= %s) { %s }`, value, field.number(field.min),
+				fail(paramError(name, "min", fmt.Sprintf("%s must be >= %s", name, field.min), "min", field.min)))
+		default:
+			return &ParseError{
+				Err: fmt.Errorf(`%s: %s.%s: min rule not applicable for %v type`, op, structName, field.name, field.kind),
+				Pos: field.pos,
 			}
 		}
+	}
 
-		if field.rules&maxRule != 0 {
-			switch field.kind {
-			case String:
-				p.printf(`if !(len(p.%s) <= %s) { return errors.New("%s len must be <= %s") }`, field.name, field.min, field.apiParamName(), field.min)
-			case Int, Float32, Float64:
-				p.printf(`if !(p.%s <= %s) { return errors.New("%s must be <= %s") }`, field.name, field.max, field.apiParamName(), field.max)
-			default:
-				return &ParseError{
-					Err: fmt.Errorf(`%s: %s.%s: max rule not applicable for %v type`, op, structName, field.name, field.kind),
-					Pos: field.pos,
-				}
+	if field.rules&maxRule != 0 {
+		switch {
+		case field.kind == String:
+			p.printf(`if !(len(%s) <= %s) { %s }`, value, field.max,
+				fail(paramError(name, "max", fmt.Sprintf("%s len must be <= %s", name, field.max), "max", field.max)))
+		case isNumber(field.kind):
+			p.printf(`if !(%s <= %s) { %s }`, value, field.number(field.max),
+				fail(paramError(name, "max", fmt.Sprintf("%s must be <= %s", name, field.max), "max", field.max)))
+		default:
+			return &ParseError{
+				Err: fmt.Errorf(`%s: %s.%s: max rule not applicable for %v type`, op, structName, field.name, field.kind),
+				Pos: field.pos,
 			}
 		}
+	}
 
-		if field.rules&greaterRule != 0 {
-			switch field.kind {
-			case String:
-				p.printf(`if !(len(p.%s) > %s) { return errors.New("%s len must be > %s") }`, field.name, field.greater, field.apiParamName(), field.greater)
-			case Int, Float32, Float64:
-				p.printf(`if !(p.%s > %s) { return errors.New("%s must be > %s") }`, field.name, field.greater, field.apiParamName(), field.greater)
-			default:
-				return &ParseError{
-					Err: fmt.Errorf(`%s: %s.%s: greate rule not applicable for %v type`, op, structName, field.name, field.kind),
-					Pos: field.pos,
-				}
+	if field.rules&greaterRule != 0 {
+		switch {
+		case field.kind == String:
+			p.printf(`if !(len(%s) > %s) { %s }`, value, field.greater,
+				fail(paramError(name, "greater", fmt.Sprintf("%s len must be > %s", name, field.greater), "greater", field.greater)))
+		case isNumber(field.kind):
+			p.printf(`if !(%s > %s) { %s }`, value, field.number(field.greater),
+				fail(paramError(name, "greater", fmt.Sprintf("%s must be > %s", name, field.greater), "greater", field.greater)))
+		default:
+			return &ParseError{
+				Err: fmt.Errorf(`%s: %s.%s: greater rule not applicable for %v type`, op, structName, field.name, field.kind),
+				Pos: field.pos,
 			}
 		}
+	}
 
-		if field.rules&lessRule != 0 {
-			switch field.kind {
-			case String:
-				p.printf(`if !(len(p.%s) < %s) { return errors.New("%s len must be < %s") }`, field.name, field.less, field.apiParamName(), field.less)
-			case Int, Float32, Float64:
-				p.printf(`if !(p.%s < %s) { return errors.New("%s must be < %s") }`, field.name, field.less, field.apiParamName(), field.less)
-			default:
-				return &ParseError{
-					Err: fmt.Errorf(`%s: %s.%s: greate rule not applicable for %v type`, op, structName, field.name, field.kind),
-					Pos: field.pos,
-				}
+	if field.rules&lessRule != 0 {
+		switch {
+		case field.kind == String:
+			p.printf(`if !(len(%s) < %s) { %s }`, value, field.less,
+				fail(paramError(name, "less", fmt.Sprintf("%s len must be < %s", name, field.less), "less", field.less)))
+		case isNumber(field.kind):
+			p.printf(`if !(%s < %s) { %s }`, value, field.number(field.less),
+				fail(paramError(name, "less", fmt.Sprintf("%s must be < %s", name, field.less), "less", field.less)))
+		default:
+			return &ParseError{
+				Err: fmt.Errorf(`%s: %s.%s: less rule not applicable for %v type`, op, structName, field.name, field.kind),
+				Pos: field.pos,
 			}
 		}
 	}
 
-	p.printf(`return nil`)
-	p.printf(`}`)
+	if field.kind == String {
+		if err := genValidateString(p, structName, field, value, name); err != nil {
+			return err
+		}
+	}
 
 	return p.err
 }
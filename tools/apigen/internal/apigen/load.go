@@ -0,0 +1,223 @@
+package apigen
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"go/types"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"golang.org/x/tools/go/packages"
+)
+
+// Package is the type checked Go package to generate code for.
+type Package struct {
+	Name  string
+	Dir   string
+	Fset  *token.FileSet
+	Files []*ast.File // files to look for apigen marks
+	Types *types.Package
+	Info  *types.Info
+	Hash  string // sha256 of the sources of the package and the imported packages of its module
+
+	typeErrors []types.Error
+}
+
+// LoadPackage loads the package in the dir. Imported packages are loaded by
+// go/packages, the package itself is parsed and type checked without the
+// generated *_apigen.go files, so the stale or not yet generated code does not
+// break the loading. If files are given, only them are searched for apigen marks.
+func LoadPackage(dir string, files []string) (*Package, error) {
+	const op = "LoadPackage"
+
+	fset := token.NewFileSet()
+	pkgs, err := packages.Load(&packages.Config{
+		Mode: packages.NeedName | packages.NeedFiles | packages.NeedImports | packages.NeedDeps | packages.NeedTypes | packages.NeedModule,
+		Dir:  dir,
+		Fset: fset,
+	}, ".")
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	if len(pkgs) != 1 {
+		return nil, fmt.Errorf("%s: %s: want one package, got %d", op, dir, len(pkgs))
+	}
+	lp := pkgs[0]
+	if len(lp.GoFiles) == 0 {
+		for _, e := range lp.Errors {
+			return nil, fmt.Errorf("%s: %v", op, e)
+		}
+		return nil, fmt.Errorf("%s: %s: no Go files", op, dir)
+	}
+
+	pkg := Package{
+		Name: lp.Name,
+		Dir:  dir,
+		Fset: fset,
+		Info: &types.Info{
+			Types: map[ast.Expr]types.TypeAndValue{},
+			Defs:  map[*ast.Ident]types.Object{},
+			Uses:  map[*ast.Ident]types.Object{},
+		},
+	}
+
+	hash := sha256.New()
+	var all []*ast.File
+	for _, fp := range lp.GoFiles {
+		if strings.HasSuffix(fp, "_apigen.go") {
+			continue
+		}
+		src, err := os.ReadFile(fp)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", op, err)
+		}
+		fmt.Fprintf(hash, "%s %d\n", filepath.Base(fp), len(src))
+		hash.Write(src)
+
+		f, err := parser.ParseFile(fset, fp, src, parser.ParseComments)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", op, err)
+		}
+		all = append(all, f)
+		if len(files) == 0 || contains(files, filepath.Base(fp)) {
+			pkg.Files = append(pkg.Files, f)
+		}
+	}
+	if len(pkg.Files) == 0 {
+		return nil, fmt.Errorf("%s: files %s not found in %s package", op, strings.Join(files, ", "), pkg.Name)
+	}
+
+	// param structs may be declared in other packages of the module, their
+	// fields may be of the types of the packages imported by them
+	deps := moduleImports(lp)
+	for _, path := range sortedKeys(deps) {
+		for _, fp := range deps[path].GoFiles {
+			src, err := os.ReadFile(fp)
+			if err != nil {
+				return nil, fmt.Errorf("%s: %w", op, err)
+			}
+			fmt.Fprintf(hash, "%s/%s %d\n", path, filepath.Base(fp), len(src))
+			hash.Write(src)
+		}
+	}
+	pkg.Hash = hex.EncodeToString(hash.Sum(nil))
+
+	conf := types.Config{
+		Importer: importerFunc(func(path string) (*types.Package, error) {
+			if imp, ok := lp.Imports[path]; ok && imp.Types != nil {
+				return imp.Types, nil
+			}
+			return nil, fmt.Errorf("%s package not loaded", path)
+		}),
+		// the errors are expected, e.g. the types declared in the generated
+		// code are unknown. They are reported only if the type is required.
+		Error: func(err error) {
+			if e, ok := err.(types.Error); ok {
+				pkg.typeErrors = append(pkg.typeErrors, e)
+			}
+		},
+	}
+	pkg.Types, _ = conf.Check(lp.PkgPath, fset, all, pkg.Info)
+
+	return &pkg, nil
+}
+
+// returns the packages of the module imported by the package directly or
+// indirectly by path.
+func moduleImports(lp *packages.Package) map[string]*packages.Package {
+	deps := map[string]*packages.Package{}
+	if lp.Module == nil {
+		return deps
+	}
+	var walk func(p *packages.Package)
+	walk = func(p *packages.Package) {
+		for path, imp := range p.Imports {
+			if _, ok := deps[path]; ok || imp.Module == nil || imp.Module.Path != lp.Module.Path {
+				continue
+			}
+			deps[path] = imp
+			walk(imp)
+		}
+	}
+	walk(lp)
+	return deps
+}
+
+// ListPackages returns the directories of the packages matched by the patterns
+// like ./..., relative to the current directory if they are in it.
+func ListPackages(patterns ...string) ([]string, error) {
+	const op = "ListPackages"
+
+	pkgs, err := packages.Load(&packages.Config{Mode: packages.NeedName | packages.NeedFiles}, patterns...)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	wd, err := os.Getwd()
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	var dirs []string
+	for _, p := range pkgs {
+		for _, e := range p.Errors {
+			return nil, fmt.Errorf("%s: %v", op, e)
+		}
+		// the packages of the generated code only, e.g. the clients, have no marks
+		if !hasSource(p.GoFiles) {
+			continue
+		}
+		dir := filepath.Dir(p.GoFiles[0])
+		// the dirs inside the working dir are relative, e.g. "." and "./api"
+		rel, err := filepath.Rel(wd, dir)
+		switch {
+		case err != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)):
+		case rel == ".":
+			dir = rel
+		default:
+			dir = "." + string(filepath.Separator) + rel
+		}
+		dirs = append(dirs, dir)
+	}
+	return dirs, nil
+}
+
+// returns type checking error at the position or nil.
+func (pkg *Package) typeError(pos token.Pos) error {
+	at := pkg.Fset.Position(pos)
+	for _, e := range pkg.typeErrors {
+		if ep := pkg.Fset.Position(e.Pos); ep.Filename == at.Filename && ep.Line == at.Line {
+			return fmt.Errorf("%s", e.Msg)
+		}
+	}
+	return nil
+}
+
+type importerFunc func(path string) (*types.Package, error)
+
+func (f importerFunc) Import(path string) (*types.Package, error) {
+	return f(path)
+}
+
+// reports whether the files are not only the generated code.
+func hasSource(files []string) bool {
+	for _, fp := range files {
+		if !strings.HasSuffix(fp, generatedSuffix) {
+			return true
+		}
+	}
+	return false
+}
+
+func contains(ss []string, s string) bool {
+	for _, v := range ss {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
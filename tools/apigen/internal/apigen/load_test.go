@@ -0,0 +1,70 @@
+package apigen
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestListPackages(t *testing.T) {
+	dirs, err := ListPackages("../../test/...")
+	if err != nil {
+		t.Fatalf("ListPackages: %v", err)
+	}
+	var got []string
+	for _, dir := range dirs {
+		got = append(got, filepath.Base(filepath.Dir(dir))+"/"+filepath.Base(dir))
+	}
+	// the generated client package test/mainclient has no marks
+	if len(got) != 2 || got[0] != "apigen/test" || got[1] != "test/model" {
+		t.Errorf("got packages %v", got)
+	}
+
+	// the working dir is "." and not "./."
+	if dirs, err := ListPackages("."); err != nil || len(dirs) != 1 || dirs[0] != "." {
+		t.Errorf("got packages %v, %v of the working dir", dirs, err)
+	}
+
+	if _, err := ListPackages("../../nothing/..."); err == nil {
+		t.Errorf("expected error of not existing directory")
+	}
+
+	// the dir "..sub" is inside the working dir
+	wd, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chdir(writeModule(t, map[string]string{"..sub/sub.go": "package sub\n"})); err != nil {
+		t.Fatal(err)
+	}
+	defer os.Chdir(wd)
+	if dirs, err := ListPackages("./..sub"); err != nil || len(dirs) != 1 || dirs[0] != "."+string(filepath.Separator)+"..sub" {
+		t.Errorf("got packages %v, %v of ..sub dir", dirs, err)
+	}
+}
+
+func TestLoadPackageHash(t *testing.T) {
+	files := map[string]string{
+		"a/a.go": "package a\n\nimport \"example.com/m/b\"\n\ntype Params struct{ B b.B }\n",
+		"b/b.go": "package b\n\nimport \"example.com/m/c\"\n\ntype B struct{ C c.C }\n",
+		"c/c.go": "package c\n\ntype C struct{ N int }\n",
+	}
+	dir := writeModule(t, files)
+	hash := func() string {
+		t.Helper()
+		pkg, err := LoadPackage(filepath.Join(dir, "a"), nil)
+		if err != nil {
+			t.Fatalf("LoadPackage: %v", err)
+		}
+		return pkg.Hash
+	}
+
+	before := hash()
+	// the package imported indirectly changes the type of the params
+	if err := os.WriteFile(filepath.Join(dir, "c", "c.go"), []byte("package c\n\ntype C struct{ N string }\n"), 0666); err != nil {
+		t.Fatal(err)
+	}
+	if hash() == before {
+		t.Errorf("hash does not depend on the package imported indirectly")
+	}
+}
@@ -0,0 +1,812 @@
+package apigen
+
+import (
+	"encoding/json"
+	"fmt"
+	"go/types"
+	"io"
+	"log"
+	"net/http"
+	"reflect"
+	"strconv"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+const (
+	openAPIVersion     = "3.1.0"
+	schemasRef         = "#/components/schemas/"
+	apiErrorSchema     = "ApiError"
+	problemSchema      = "Problem"
+	paramErrorSchema   = "ParamError"
+	problemContentType = "application/problem+json"
+	authSchemeName     = "apiAuth"
+	jsonContentType    = "application/json"
+	formContentType    = "application/x-www-form-urlencoded"
+	multipartType      = "multipart/form-data"
+)
+
+type openAPIDoc struct {
+	OpenAPI    string                           `json:"openapi" yaml:"openapi"`
+	Info       openAPIInfo                      `json:"info" yaml:"info"`
+	Paths      map[string]map[string]*operation `json:"paths" yaml:"paths"`
+	Components components                       `json:"components" yaml:"components"`
+}
+
+type openAPIInfo struct {
+	Title   string `json:"title" yaml:"title"`
+	Version string `json:"version" yaml:"version"`
+}
+
+type components struct {
+	Schemas         map[string]*schema         `json:"schemas,omitempty" yaml:"schemas,omitempty"`
+	SecuritySchemes map[string]*securityScheme `json:"securitySchemes,omitempty" yaml:"securitySchemes,omitempty"`
+}
+
+type securityScheme struct {
+	Type        string `json:"type" yaml:"type"`
+	Scheme      string `json:"scheme,omitempty" yaml:"scheme,omitempty"`
+	Description string `json:"description,omitempty" yaml:"description,omitempty"`
+}
+
+type operation struct {
+	OperationID string                `json:"operationId" yaml:"operationId"`
+	Summary     string                `json:"summary,omitempty" yaml:"summary,omitempty"`
+	Description string                `json:"description,omitempty" yaml:"description,omitempty"`
+	Tags        []string              `json:"tags,omitempty" yaml:"tags,omitempty"`
+	Security    []map[string][]string `json:"security,omitempty" yaml:"security,omitempty"`
+	Parameters  []*parameter          `json:"parameters,omitempty" yaml:"parameters,omitempty"`
+	RequestBody *requestBody          `json:"requestBody,omitempty" yaml:"requestBody,omitempty"`
+	Responses   map[string]*response  `json:"responses" yaml:"responses"`
+}
+
+type parameter struct {
+	Name     string  `json:"name" yaml:"name"`
+	In       string  `json:"in" yaml:"in"`
+	Required bool    `json:"required,omitempty" yaml:"required,omitempty"`
+	Schema   *schema `json:"schema" yaml:"schema"`
+}
+
+type requestBody struct {
+	Required bool                 `json:"required,omitempty" yaml:"required,omitempty"`
+	Content  map[string]mediaType `json:"content" yaml:"content"`
+}
+
+type response struct {
+	Description string               `json:"description" yaml:"description"`
+	Content     map[string]mediaType `json:"content,omitempty" yaml:"content,omitempty"`
+}
+
+type mediaType struct {
+	Schema   *schema              `json:"schema" yaml:"schema"`
+	Encoding map[string]*encoding `json:"encoding,omitempty" yaml:"encoding,omitempty"`
+}
+
+// encoding of the multipart form part
+type encoding struct {
+	ContentType string `json:"contentType,omitempty" yaml:"contentType,omitempty"`
+}
+
+type schema struct {
+	Ref                  string             `json:"$ref,omitempty" yaml:"$ref,omitempty"`
+	Type                 string             `json:"type,omitempty" yaml:"type,omitempty"`
+	Format               string             `json:"format,omitempty" yaml:"format,omitempty"`
+	Description          string             `json:"description,omitempty" yaml:"description,omitempty"`
+	Properties           map[string]*schema `json:"properties,omitempty" yaml:"properties,omitempty"`
+	Required             []string           `json:"required,omitempty" yaml:"required,omitempty"`
+	Items                *schema            `json:"items,omitempty" yaml:"items,omitempty"`
+	AdditionalProperties *schema            `json:"additionalProperties,omitempty" yaml:"additionalProperties,omitempty"`
+	Enum                 []any              `json:"enum,omitempty" yaml:"enum,omitempty"`
+	Default              any                `json:"default,omitempty" yaml:"default,omitempty"`
+	Minimum              any                `json:"minimum,omitempty" yaml:"minimum,omitempty"`
+	Maximum              any                `json:"maximum,omitempty" yaml:"maximum,omitempty"`
+	ExclusiveMinimum     any                `json:"exclusiveMinimum,omitempty" yaml:"exclusiveMinimum,omitempty"`
+	ExclusiveMaximum     any                `json:"exclusiveMaximum,omitempty" yaml:"exclusiveMaximum,omitempty"`
+	MinLength            *int               `json:"minLength,omitempty" yaml:"minLength,omitempty"`
+	MaxLength            *int               `json:"maxLength,omitempty" yaml:"maxLength,omitempty"`
+	Pattern              string             `json:"pattern,omitempty" yaml:"pattern,omitempty"`
+	Example              any                `json:"example,omitempty" yaml:"example,omitempty"`
+	MinItems             *int               `json:"minItems,omitempty" yaml:"minItems,omitempty"`
+	MaxItems             *int               `json:"maxItems,omitempty" yaml:"maxItems,omitempty"`
+	UniqueItems          bool               `json:"uniqueItems,omitempty" yaml:"uniqueItems,omitempty"`
+}
+
+type OpenAPIOptions struct {
+	Format       string   // json or yaml
+	Services     []string // services to describe, all by default
+	ContentTypes []string // accepted media types of the request body, all by default
+}
+
+// GenOpenAPI writes OpenAPI 3.1 document describing the service methods.
+func GenOpenAPI(w io.Writer, cfg GenConfig, opts OpenAPIOptions) error {
+	const op = "GenOpenAPI"
+
+	if err := checkContentTypes(opts.ContentTypes); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	accepted := acceptedContentTypes(opts.ContentTypes)
+	if err := checkFileUploads(cfg, accepted); err != nil {
+		return err
+	}
+
+	g := openAPIGen{
+		cfg:      cfg,
+		accepted: accepted,
+		doc: openAPIDoc{
+			OpenAPI: openAPIVersion,
+			Info: openAPIInfo{
+				Title:   cfg.packageName,
+				Version: "1.0.0",
+			},
+			Paths: map[string]map[string]*operation{},
+			Components: components{
+				Schemas: map[string]*schema{
+					apiErrorSchema: {
+						Type: "object",
+						Properties: map[string]*schema{
+							"error":  {Type: "string"},
+							"errors": paramErrorsSchema(),
+						},
+						Required: []string{"error"},
+					},
+					problemSchema: {
+						Type:        "object",
+						Description: "RFC 9457 problem details, returned if the request accepts " + problemContentType,
+						Properties: map[string]*schema{
+							"type":   {Type: "string"},
+							"title":  {Type: "string"},
+							"status": {Type: "integer"},
+							"detail": {Type: "string"},
+							"code":   {Type: "string", Description: "stable error code, e.g. param_max or not_found"},
+							"field":  {Type: "string", Description: "invalid param"},
+							"rule":   {Type: "string", Description: "violated rule"},
+							"params": {Type: "object", AdditionalProperties: &schema{Type: "string"}},
+							"errors": paramErrorsSchema(),
+						},
+						Required: []string{"type", "title", "status", "detail", "code"},
+					},
+					paramErrorSchema: {
+						Type: "object",
+						Properties: map[string]*schema{
+							"code":    {Type: "string"},
+							"field":   {Type: "string"},
+							"rule":    {Type: "string"},
+							"params":  {Type: "object", AdditionalProperties: &schema{Type: "string"}},
+							"message": {Type: "string"},
+						},
+						Required: []string{"code", "field", "rule", "message"},
+					},
+				},
+			},
+		},
+	}
+
+	order := sortedKeys(cfg.servs.items)
+	if len(opts.Services) > 0 {
+		for _, servName := range opts.Services {
+			if _, ok := cfg.servs.items[servName]; !ok {
+				return fmt.Errorf("%s: %s service not found. available: %s", op, servName, strings.Join(order, ", "))
+			}
+		}
+		order = opts.Services
+	}
+	log.Printf("%s: describe methods for services: %v", op, strings.Join(order, ", "))
+
+	for _, servName := range order {
+		for _, m := range cfg.servs.items[servName] {
+			if err := g.addMethod(m); err != nil {
+				return err
+			}
+		}
+	}
+
+	switch opts.Format {
+	case "json":
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "  ")
+		return enc.Encode(g.doc)
+	case "yaml":
+		enc := yaml.NewEncoder(w)
+		enc.SetIndent(2)
+		if err := enc.Encode(g.doc); err != nil {
+			return err
+		}
+		return enc.Close()
+	}
+
+	return fmt.Errorf("%s: unknown format %q, want json or yaml", op, opts.Format)
+}
+
+type openAPIGen struct {
+	cfg      GenConfig
+	accepted map[string]bool // accepted media types of the request body
+	doc      openAPIDoc
+}
+
+// adds the operations of the method. The method of any HTTP method is described
+// by GET with the params in the query and POST with the params in the body.
+func (g *openAPIGen) addMethod(m *serviceMethod) error {
+	httpMethods := []string{m.HTTPMethod}
+	if m.HTTPMethod == anyHTTPMethod {
+		httpMethods = []string{http.MethodGet, http.MethodPost}
+	}
+
+	byMethod, ok := g.doc.Paths[m.URL]
+	if !ok {
+		byMethod = map[string]*operation{}
+		g.doc.Paths[m.URL] = byMethod
+	}
+
+	for _, httpMethod := range httpMethods {
+		if prev, ok := byMethod[strings.ToLower(httpMethod)]; ok {
+			return &ParseError{
+				Err: fmt.Errorf("%s.%s: %s %s already described by %s, select services to describe",
+					m.recv.name, m.name, httpMethod, m.URL, prev.OperationID),
+				Pos: m.pos,
+			}
+		}
+		op, err := g.operation(m, httpMethod)
+		if err != nil {
+			return err
+		}
+		byMethod[strings.ToLower(httpMethod)] = op
+	}
+
+	return nil
+}
+
+func (g *openAPIGen) operation(m *serviceMethod, httpMethod string) (*operation, error) {
+	op := operation{
+		OperationID: m.recv.name + "." + m.name,
+		Tags:        []string{m.recv.name},
+		Responses:   map[string]*response{},
+	}
+	if m.HTTPMethod == anyHTTPMethod {
+		op.OperationID += "." + strings.ToLower(httpMethod)
+	}
+	op.Summary, op.Description, _ = strings.Cut(m.doc, "\n")
+	op.Description = strings.TrimSpace(op.Description)
+	if m.HTTPMethod == anyHTTPMethod {
+		note := "Accepts any HTTP method: the params are in the body of POST, PUT and PATCH and in the query of the others."
+		op.Description = strings.TrimSpace(op.Description + "\n\n" + note)
+	}
+
+	var (
+		bodyFields []*paramStructField
+		body       = schema{Type: "object", Properties: map[string]*schema{}}
+	)
+
+	fields := g.cfg.params.items[m.params.name]
+	if !hasRequestBody(httpMethod) {
+		// nested params are passed in query by dotted names
+		fields = flatten(fields)
+	}
+	for _, field := range fields {
+		s, err := paramSchema(field)
+		if err != nil {
+			return nil, err
+		}
+		required := field.rules&requiredRule != 0 && field.rules&defaultRule == 0
+
+		switch {
+		case field.source == pathSource:
+			op.Parameters = append(op.Parameters, &parameter{
+				Name: field.apiParamName(), In: "path", Required: true, Schema: s,
+			})
+		case field.source == headerSource || field.source == cookieSource:
+			op.Parameters = append(op.Parameters, &parameter{
+				Name: field.apiParamName(), In: field.source.String(), Required: required, Schema: s,
+			})
+		case !hasRequestBody(httpMethod):
+			op.Parameters = append(op.Parameters, &parameter{
+				Name: field.apiParamName(), In: "query", Required: required, Schema: s,
+			})
+		default:
+			bodyFields = append(bodyFields, field)
+			body.Properties[field.apiParamName()] = s
+			if required {
+				body.Required = append(body.Required, field.apiParamName())
+			}
+		}
+	}
+
+	if len(bodyFields) > 0 {
+		op.RequestBody = &requestBody{
+			Required: len(body.Required) > 0,
+			Content:  map[string]mediaType{},
+		}
+		for _, ct := range []string{jsonContentType, formContentType} {
+			if g.accepted[ct] {
+				op.RequestBody.Content[ct] = mediaType{Schema: &body}
+			}
+		}
+	}
+	if m.hasFiles {
+		// files are uploaded in multipart form only
+		mt := mediaType{Schema: &body}
+		for _, field := range bodyFields {
+			if field.kind == File && field.rules&mimeRule != 0 {
+				if mt.Encoding == nil {
+					mt.Encoding = map[string]*encoding{}
+				}
+				mt.Encoding[field.apiParamName()] = &encoding{ContentType: strings.Join(field.mime, ", ")}
+			}
+		}
+		op.RequestBody.Content = map[string]mediaType{multipartType: mt}
+	}
+
+	if m.Auth {
+		g.addAuthScheme()
+		// OpenAPI 3.1 allows the role names required by not OAuth2 schemes
+		op.Security = []map[string][]string{{authSchemeName: append(append([]string{}, m.Roles...), m.Scopes...)}}
+		op.Responses["401"] = errorResponse("Unauthorized")
+		op.Responses["403"] = errorResponse("Forbidden")
+	}
+
+	result, err := g.typeSchema(m.result.typ)
+	if err != nil {
+		return nil, &ParseError{Err: fmt.Errorf("%s.%s: %w", m.recv.name, m.name, err), Pos: m.pos}
+	}
+	status := strconv.Itoa(m.Status)
+	if m.Status == http.StatusNoContent {
+		op.Responses[status] = &response{Description: http.StatusText(m.Status)}
+	} else {
+		body, err := g.resultBodySchema(m, result)
+		if err != nil {
+			return nil, &ParseError{Err: fmt.Errorf("%s.%s: %w", m.recv.name, m.name, err), Pos: m.pos}
+		}
+		op.Responses[status] = &response{
+			Description: http.StatusText(m.Status),
+			Content:     map[string]mediaType{jsonContentType: {Schema: body}},
+		}
+	}
+	if op.RequestBody != nil {
+		op.Responses["415"] = errorResponse("Unsupported content type")
+	}
+	if m.MaxBody > 0 {
+		op.Responses["413"] = errorResponse(fmt.Sprintf("Request body larger than %d bytes", m.MaxBody))
+	}
+	op.Responses["400"] = errorResponse("Invalid params")
+	op.Responses["406"] = errorResponse("No acceptable response content type")
+	op.Responses["500"] = errorResponse("Internal error")
+
+	return &op, nil
+}
+
+// returns schema of the success response body, the result wrapped in the method envelope.
+func (g *openAPIGen) resultBodySchema(m *serviceMethod, result *schema) (*schema, error) {
+	switch {
+	case m.envelope != nil:
+		// the envelope struct is described inline as its response field is the result of the method
+		st := m.envelope.typ.Underlying().(*types.Struct)
+		s, err := g.structSchema(st)
+		if err != nil {
+			return nil, fmt.Errorf("envelope %s: %w", m.Envelope, err)
+		}
+		for i := 0; i < st.NumFields(); i++ {
+			if st.Field(i) != m.envelope.field {
+				continue
+			}
+			name, _, _ := strings.Cut(reflect.StructTag(st.Tag(i)).Get("json"), ",")
+			if name == "" {
+				name = st.Field(i).Name()
+			}
+			s.Properties[name] = result
+		}
+		return s, nil
+
+	case m.Envelope == noEnvelope:
+		return result, nil
+	}
+
+	return &schema{
+		Type: "object",
+		Properties: map[string]*schema{
+			"response": result,
+			"error":    {Type: "string"},
+		},
+		Required: []string{"response", "error"},
+	}, nil
+}
+
+func (g *openAPIGen) addAuthScheme() {
+	if g.doc.Components.SecuritySchemes == nil {
+		g.doc.Components.SecuritySchemes = map[string]*securityScheme{
+			authSchemeName: {
+				Type:        "http",
+				Scheme:      "bearer",
+				Description: "Checked by the service Authenticator",
+			},
+		}
+	}
+}
+
+func hasRequestBody(httpMethod string) bool {
+	switch httpMethod {
+	case http.MethodPost, http.MethodPut, http.MethodPatch:
+		return true
+	}
+	return false
+}
+
+// returns schema of all invalid params returned by the methods marked with "allErrors": true.
+func paramErrorsSchema() *schema {
+	return &schema{
+		Type:        "array",
+		Description: "invalid params, if the method reports all of them",
+		Items:       &schema{Ref: schemasRef + paramErrorSchema},
+	}
+}
+
+func errorResponse(description string) *response {
+	return &response{
+		Description: description,
+		Content: map[string]mediaType{
+			jsonContentType:    {Schema: &schema{Ref: schemasRef + apiErrorSchema}},
+			problemContentType: {Schema: &schema{Ref: schemasRef + problemSchema}},
+		},
+	}
+}
+
+// returns schema of the param struct field with constraints of its validator.
+func paramSchema(field *paramStructField) (*schema, error) {
+	var s schema
+	switch {
+	case field.isStruct():
+		s.Type, s.Properties = "object", map[string]*schema{}
+		for _, f := range field.fields {
+			fs, err := paramSchema(f)
+			if err != nil {
+				return nil, err
+			}
+			s.Properties[f.apiParamName()] = fs
+			if f.rules&requiredRule != 0 && f.rules&defaultRule == 0 {
+				s.Required = append(s.Required, f.apiParamName())
+			}
+		}
+		return &s, nil
+	case field.kind == Custom && field.unmarshaler == jsonUnmarshaler:
+		// any
+	case field.kind == Custom:
+		s.Type = "string"
+		if isTime(field.typ) {
+			s.Format = "date-time"
+		}
+	case isDuration(field.typ):
+		// durations are the text parsed by time.ParseDuration in json too
+		s.Type, s.Example, s.Description = "string", "1m30s", "Go duration, e.g. 1m30s"
+	case field.kind == String:
+		s.Type = "string"
+	case field.kind == Bool:
+		s.Type = "boolean"
+	case isInt(field.kind) || isUint(field.kind):
+		s.Type = "integer"
+		if bits := bitSize(field.kind); bits != 0 {
+			s.Format = fmt.Sprintf("int%d", max(bits, 32))
+		}
+		if isUint(field.kind) {
+			s.Minimum = 0
+		}
+	case field.kind == Float32:
+		s.Type, s.Format = "number", "float"
+	case field.kind == Float64:
+		s.Type, s.Format = "number", "double"
+	case field.kind == File:
+		s.Type, s.Format = "string", "binary"
+		if field.rules&maxSizeRule != 0 {
+			s.Description = "max size " + field.maxSize + " bytes"
+		}
+	default:
+		return nil, &ParseError{
+			Err: fmt.Errorf("%s: invalid param type: %v", field.name, field.kind),
+			Pos: field.pos,
+		}
+	}
+
+	value := func(v string) (any, error) {
+		if isDuration(field.typ) {
+			return v, nil
+		}
+		switch field.kind {
+		case String, Custom:
+			return v, nil
+		case Bool:
+			return strconv.ParseBool(v)
+		}
+		return parseNumber(field.kind, v)
+	}
+	length := func(v string, delta int) (*int, error) {
+		n, err := strconv.Atoi(v)
+		if err != nil {
+			return nil, err
+		}
+		n += delta
+		return &n, nil
+	}
+
+	var err error
+	if field.rules&defaultRule != 0 {
+		s.Default, err = value(field.defaultVal)
+	}
+	if err == nil && field.rules&enumRule != 0 {
+		for _, v := range field.enum {
+			var ev any
+			if ev, err = value(v); err != nil {
+				break
+			}
+			s.Enum = append(s.Enum, ev)
+		}
+	}
+
+	if field.kind == String {
+		if err == nil && field.rules&minRule != 0 {
+			s.MinLength, err = length(field.min, 0)
+		}
+		if err == nil && field.rules&maxRule != 0 {
+			s.MaxLength, err = length(field.max, 0)
+		}
+		if err == nil && field.rules&greaterRule != 0 {
+			s.MinLength, err = length(field.greater, 1)
+		}
+		if err == nil && field.rules&lessRule != 0 {
+			s.MaxLength, err = length(field.less, -1)
+		}
+		if err == nil && field.rules&lenRule != 0 {
+			s.MinLength, err = length(field.length, 0)
+			s.MaxLength = s.MinLength
+		}
+		// JSON Schema lengths are counted in characters
+		if err == nil && field.rules&runeMinRule != 0 {
+			s.MinLength, err = length(field.runeMin, 0)
+		}
+		if err == nil && field.rules&runeMaxRule != 0 {
+			s.MaxLength, err = length(field.runeMax, 0)
+		}
+		if err == nil && field.rules&runeLenRule != 0 {
+			s.MinLength, err = length(field.runeLen, 0)
+			s.MaxLength = s.MinLength
+		}
+		switch {
+		case field.rules&patternRule != 0:
+			s.Pattern = field.pattern
+		case field.rules&alphanumRule != 0:
+			s.Pattern = "^[a-zA-Z0-9]+$"
+		}
+		switch {
+		case field.rules&emailRule != 0:
+			s.Format = "email"
+		case field.rules&urlRule != 0:
+			s.Format = "uri"
+		case field.rules&uuidRule != 0:
+			s.Format = "uuid"
+		}
+	} else if isDuration(field.typ) {
+		// JSON Schema has no bounds of strings, so they are described
+		for _, b := range []struct {
+			flag  ruleSet
+			op, v string
+		}{
+			{minRule, ">=", field.min},
+			{greaterRule, ">", field.greater},
+			{maxRule, "<=", field.max},
+			{lessRule, "<", field.less},
+		} {
+			if field.rules&b.flag != 0 {
+				s.Description += ", must be " + b.op + " " + b.v
+			}
+		}
+	} else if isNumber(field.kind) {
+		if err == nil && field.rules&minRule != 0 {
+			s.Minimum, err = parseNumber(field.kind, field.min)
+		}
+		if err == nil && field.rules&maxRule != 0 {
+			s.Maximum, err = parseNumber(field.kind, field.max)
+		}
+		if err == nil && field.rules&greaterRule != 0 {
+			s.ExclusiveMinimum, err = parseNumber(field.kind, field.greater)
+		}
+		if err == nil && field.rules&lessRule != 0 {
+			s.ExclusiveMaximum, err = parseNumber(field.kind, field.less)
+		}
+	}
+
+	if err == nil && field.isSlice {
+		items := s
+		s = schema{Type: "array", Items: &items, UniqueItems: field.rules&uniqueRule != 0}
+		if field.rules&minItemsRule != 0 {
+			s.MinItems, err = length(field.minItems, 0)
+		}
+		if err == nil && field.rules&maxItemsRule != 0 {
+			s.MaxItems, err = length(field.maxItems, 0)
+		}
+	}
+
+	// JSON Schema has no cross field rules, so they are described
+	var cross []string
+	for _, c := range field.cross {
+		ref := c.refField.apiParamName()
+		switch c.rule {
+		case "required_with":
+			cross = append(cross, "required if "+ref+" is set")
+		case "required_without":
+			cross = append(cross, "required if "+ref+" is not set")
+		default:
+			cross = append(cross, "must be "+crossOps[c.rule]+" "+ref)
+		}
+	}
+	if len(cross) > 0 {
+		if s.Description != "" {
+			cross = append([]string{s.Description}, cross...)
+		}
+		s.Description = strings.Join(cross, ", ")
+	}
+
+	if err != nil {
+		return nil, &ParseError{
+			Err: fmt.Errorf("%s: invalid rule value: %w", field.name, err),
+			Pos: field.pos,
+		}
+	}
+
+	return &s, nil
+}
+
+func parseNumber(k kind, v string) (any, error) {
+	switch {
+	case isInt(k):
+		return strconv.ParseInt(v, 10, 64)
+	case isUint(k):
+		return strconv.ParseUint(v, 10, 64)
+	}
+	return strconv.ParseFloat(v, 64)
+}
+
+// returns schema of the Go type. Named struct types are added to components.
+func (g *openAPIGen) typeSchema(t types.Type) (*schema, error) {
+	switch t := t.(type) {
+	case *types.Basic:
+		if s := basicTypeSchema(t.Kind()); s != nil {
+			return s, nil
+		}
+
+	case *types.Named:
+		if isTime(t) {
+			return &schema{Type: "string", Format: "date-time"}, nil
+		}
+		if _, ok := t.Underlying().(*types.Struct); ok {
+			return g.namedTypeSchema(t)
+		}
+		return g.typeSchema(t.Underlying())
+
+	case *types.Alias:
+		return g.typeSchema(types.Unalias(t))
+
+	case *types.Pointer:
+		return g.typeSchema(t.Elem())
+
+	case *types.Slice:
+		if b, ok := t.Elem().(*types.Basic); ok && b.Kind() == types.Byte {
+			return &schema{Type: "string", Format: "byte"}, nil
+		}
+		items, err := g.typeSchema(t.Elem())
+		if err != nil {
+			return nil, err
+		}
+		return &schema{Type: "array", Items: items}, nil
+
+	case *types.Array:
+		items, err := g.typeSchema(t.Elem())
+		if err != nil {
+			return nil, err
+		}
+		return &schema{Type: "array", Items: items}, nil
+
+	case *types.Map:
+		values, err := g.typeSchema(t.Elem())
+		if err != nil {
+			return nil, err
+		}
+		return &schema{Type: "object", AdditionalProperties: values}, nil
+
+	case *types.Struct:
+		return g.structSchema(t)
+
+	case *types.Interface:
+		return &schema{}, nil // any
+	}
+
+	return nil, fmt.Errorf("can't describe %s type", t)
+}
+
+// returns reference to the component schema of the named type. Types of other
+// packages are named with the package name, e.g. model.User.
+func (g *openAPIGen) namedTypeSchema(t *types.Named) (*schema, error) {
+	name := t.Obj().Name()
+	if pkg := t.Obj().Pkg(); pkg != nil && pkg != g.cfg.pkg {
+		name = pkg.Name() + "." + name
+	}
+
+	ref := &schema{Ref: schemasRef + name}
+	if _, ok := g.doc.Components.Schemas[name]; ok {
+		return ref, nil
+	}
+
+	// placeholder for recursive types
+	g.doc.Components.Schemas[name] = &schema{}
+
+	s, err := g.typeSchema(t.Underlying())
+	if err != nil {
+		return nil, err
+	}
+	g.doc.Components.Schemas[name] = s
+
+	return ref, nil
+}
+
+func (g *openAPIGen) structSchema(t *types.Struct) (*schema, error) {
+	s := schema{Type: "object", Properties: map[string]*schema{}}
+
+	for i := 0; i < t.NumFields(); i++ {
+		field := t.Field(i)
+		tag := reflect.StructTag(t.Tag(i))
+		jsonName, jsonOpts, _ := strings.Cut(tag.Get("json"), ",")
+		if jsonName == "-" {
+			continue
+		}
+
+		// embedded struct, its fields are promoted
+		if field.Embedded() && jsonName == "" {
+			fs, err := g.typeSchema(field.Type())
+			if err != nil {
+				return nil, err
+			}
+			if fs.Ref != "" {
+				fs = g.doc.Components.Schemas[strings.TrimPrefix(fs.Ref, schemasRef)]
+			}
+			for k, v := range fs.Properties {
+				s.Properties[k] = v
+			}
+			s.Required = append(s.Required, fs.Required...)
+			continue
+		}
+
+		if !field.Exported() {
+			continue
+		}
+
+		fs, err := g.typeSchema(field.Type())
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", field.Name(), err)
+		}
+
+		propName := field.Name()
+		if jsonName != "" {
+			propName = jsonName
+		}
+		s.Properties[propName] = fs
+		if !strings.Contains(jsonOpts, "omitempty") {
+			s.Required = append(s.Required, propName)
+		}
+	}
+
+	return &s, nil
+}
+
+func basicTypeSchema(k types.BasicKind) *schema {
+	switch k {
+	case types.String:
+		return &schema{Type: "string"}
+	case types.Bool:
+		return &schema{Type: "boolean"}
+	case types.Int, types.Int64, types.Uint, types.Uint64:
+		return &schema{Type: "integer", Format: "int64"}
+	case types.Int8, types.Int16, types.Int32, types.Uint8, types.Uint16, types.Uint32:
+		return &schema{Type: "integer", Format: "int32"}
+	case types.Float32:
+		return &schema{Type: "number", Format: "float"}
+	case types.Float64:
+		return &schema{Type: "number", Format: "double"}
+	}
+	return nil
+}
@@ -0,0 +1,323 @@
+package apigen
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"log"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func parseTestPackage(t *testing.T) GenConfig {
+	t.Helper()
+	log.SetOutput(io.Discard)
+
+	pkg, err := LoadPackage("../../test", nil)
+	if err != nil {
+		t.Fatalf("can't load test package: %v", err)
+	}
+	cfg, err := Parse(pkg, ParseOptions{})
+	if err != nil {
+		t.Fatalf("Parse: %v", err)
+	}
+	return cfg
+}
+
+func TestGenOpenAPI(t *testing.T) {
+	cfg := parseTestPackage(t)
+
+	var buf bytes.Buffer
+	if err := GenOpenAPI(&buf, cfg, OpenAPIOptions{Format: "yaml"}); err == nil {
+		t.Errorf("expected error for /user/create described by two services")
+	}
+
+	buf.Reset()
+	if err := GenOpenAPI(&buf, cfg, OpenAPIOptions{Format: "json", Services: []string{"MyApi"}}); err != nil {
+		t.Fatalf("GenOpenAPI: %v", err)
+	}
+
+	var doc map[string]any
+	if err := json.Unmarshal(buf.Bytes(), &doc); err != nil {
+		t.Fatalf("can't unmarshal document: %v", err)
+	}
+
+	get := func(path ...string) any {
+		var v any = doc
+		for _, k := range path {
+			m, ok := v.(map[string]any)
+			if !ok {
+				t.Fatalf("%s: not an object", strings.Join(path, "."))
+			}
+			v = m[k]
+		}
+		return v
+	}
+
+	cases := []struct {
+		path []string
+		want any
+	}{
+		{[]string{"openapi"}, "3.1.0"},
+		{[]string{"paths", "/user/create", "post", "operationId"}, "MyApi.Create"},
+		{[]string{"paths", "/user/create", "post", "security"}, []any{map[string]any{"apiAuth": []any{"admin", "moderator"}}}},
+		{[]string{"paths", "/user/create", "post", "requestBody", "content", "application/json", "schema", "required"}, []any{"login"}},
+		{[]string{"paths", "/user/create", "post", "requestBody", "content", "application/json", "schema", "properties", "login", "minLength"}, 10.0},
+		{[]string{"paths", "/user/create", "post", "requestBody", "content", "application/json", "schema", "properties", "status", "enum"}, []any{"user", "moderator", "admin"}},
+		{[]string{"paths", "/user/create", "post", "requestBody", "content", "application/json", "schema", "properties", "status", "default"}, "user"},
+		{[]string{"paths", "/user/create", "post", "requestBody", "content", "application/json", "schema", "properties", "age", "maximum"}, 128.0},
+		{[]string{"paths", "/user/create", "post", "responses", "403", "content", "application/json", "schema", "$ref"}, "#/components/schemas/ApiError"},
+		{[]string{"paths", "/user/profile", "get", "parameters"}, []any{map[string]any{"name": "login", "in": "query", "required": true, "schema": map[string]any{"type": "string"}}}},
+		{[]string{"paths", "/user/{login}/profile", "get", "parameters"}, []any{map[string]any{"name": "login", "in": "path", "required": true, "schema": map[string]any{"type": "string"}}}},
+		{[]string{"paths", "/user/profile", "get", "responses", "200", "content", "application/json", "schema", "properties", "response", "$ref"}, "#/components/schemas/User"},
+		{[]string{"components", "schemas", "User", "properties", "full_name", "type"}, "string"},
+	}
+
+	for _, c := range cases {
+		if got := get(c.path...); !reflect.DeepEqual(got, c.want) {
+			t.Errorf("%s: got %#v, want %#v", strings.Join(c.path, "."), got, c.want)
+		}
+	}
+}
+
+func TestGenOpenAPINestedParams(t *testing.T) {
+	cfg := parseTestPackage(t)
+
+	var buf bytes.Buffer
+	if err := GenOpenAPI(&buf, cfg, OpenAPIOptions{Format: "json", Services: []string{"OtherApi"}}); err != nil {
+		t.Fatalf("GenOpenAPI: %v", err)
+	}
+
+	var doc struct {
+		Paths map[string]map[string]struct {
+			RequestBody struct {
+				Content map[string]struct {
+					Schema schema `json:"schema"`
+				} `json:"content"`
+			} `json:"requestBody"`
+		} `json:"paths"`
+	}
+	if err := json.Unmarshal(buf.Bytes(), &doc); err != nil {
+		t.Fatalf("can't unmarshal document: %v", err)
+	}
+
+	body := doc.Paths["/guild/create"]["post"].RequestBody.Content[jsonContentType].Schema
+	if _, ok := body.Properties["page"]; !ok {
+		t.Errorf("embedded field page not found in %v", body.Properties)
+	}
+	settings := body.Properties["settings"]
+	if settings == nil || settings.Type != "object" {
+		t.Fatalf("settings: got %+v, want object", settings)
+	}
+	if !reflect.DeepEqual(settings.Required, []string{"region"}) {
+		t.Errorf("settings.required: got %v, want [region]", settings.Required)
+	}
+	if got := settings.Properties["region"]; got == nil || len(got.Enum) != 3 {
+		t.Errorf("settings.region: got %+v, want enum of 3 values", got)
+	}
+}
+
+func TestGenOpenAPIDuration(t *testing.T) {
+	cfg := parseTestPackage(t)
+
+	var buf bytes.Buffer
+	if err := GenOpenAPI(&buf, cfg, OpenAPIOptions{Format: "json", Services: []string{"OtherApi"}}); err != nil {
+		t.Fatalf("GenOpenAPI: %v", err)
+	}
+
+	var doc struct {
+		Paths map[string]map[string]struct {
+			RequestBody struct {
+				Content map[string]struct {
+					Schema schema `json:"schema"`
+				} `json:"content"`
+			} `json:"requestBody"`
+		} `json:"paths"`
+	}
+	if err := json.Unmarshal(buf.Bytes(), &doc); err != nil {
+		t.Fatalf("can't unmarshal document: %v", err)
+	}
+
+	// the json body and the form have the text of time.ParseDuration
+	for _, ct := range []string{jsonContentType, formContentType} {
+		got := doc.Paths["/user/rate"]["post"].RequestBody.Content[ct].Schema.Properties["wait"]
+		if got == nil || got.Type != "string" || got.Format != "" || got.Maximum != nil || got.Example != "1m30s" {
+			t.Fatalf("%s wait: got %+v, want string", ct, got)
+		}
+		if want := "Go duration, e.g. 1m30s, must be >= 0, must be <= 1h"; got.Description != want {
+			t.Errorf("%s wait: got description %q, want %q", ct, got.Description, want)
+		}
+	}
+}
+
+func TestGenOpenAPIAnyMethod(t *testing.T) {
+	cfg := parseTestPackage(t)
+
+	var buf bytes.Buffer
+	if err := GenOpenAPI(&buf, cfg, OpenAPIOptions{Format: "json", Services: []string{"MyApi"}}); err != nil {
+		t.Fatalf("GenOpenAPI: %v", err)
+	}
+
+	var doc struct {
+		Paths map[string]map[string]operation `json:"paths"`
+	}
+	if err := json.Unmarshal(buf.Bytes(), &doc); err != nil {
+		t.Fatalf("can't unmarshal document: %v", err)
+	}
+	// MyApi.Profile accepts any HTTP method, described by GET and POST
+	for _, method := range []string{"get", "post"} {
+		if op := doc.Paths["/user/profile"][method]; !strings.Contains(op.Description, "Accepts any HTTP method") {
+			t.Errorf("%s /user/profile: got description %q", method, op.Description)
+		}
+	}
+	if op := doc.Paths["/user/create"]["post"]; strings.Contains(op.Description, "any HTTP method") {
+		t.Errorf("POST /user/create: got description %q", op.Description)
+	}
+}
+
+func TestGenOpenAPIResponses(t *testing.T) {
+	cfg := parseTestPackage(t)
+
+	var buf bytes.Buffer
+	if err := GenOpenAPI(&buf, cfg, OpenAPIOptions{Format: "json", Services: []string{"TeamApi"}}); err != nil {
+		t.Fatalf("GenOpenAPI: %v", err)
+	}
+
+	var doc struct {
+		Paths map[string]map[string]struct {
+			Responses map[string]struct {
+				Content map[string]struct {
+					Schema schema `json:"schema"`
+				} `json:"content"`
+			} `json:"responses"`
+		} `json:"paths"`
+	}
+	if err := json.Unmarshal(buf.Bytes(), &doc); err != nil {
+		t.Fatalf("can't unmarshal document: %v", err)
+	}
+
+	create := doc.Paths["/team/create"]["post"].Responses
+	if _, ok := create["200"]; ok {
+		t.Errorf("/team/create: unexpected 200 response")
+	}
+	for _, status := range []string{"413", "415"} {
+		if _, ok := create[status]; !ok {
+			t.Errorf("/team/create: %s response not found", status)
+		}
+	}
+	if _, ok := doc.Paths["/team/get"]["get"].Responses["415"]; ok {
+		t.Errorf("/team/get: unexpected 415 response of request without body")
+	}
+	body := create["201"].Content[jsonContentType].Schema
+	if got := body.Properties["data"]; got == nil || got.Ref != schemasRef+"Team" {
+		t.Errorf("/team/create: got data %+v, want Team ref", got)
+	}
+	if _, ok := body.Properties["ver"]; !ok {
+		t.Errorf("/team/create: ver not found in %v", body.Properties)
+	}
+
+	if got := doc.Paths["/team/get"]["get"].Responses["200"].Content[jsonContentType].Schema; got.Ref != schemasRef+"Team" {
+		t.Errorf("/team/get: got %+v, want Team ref", got)
+	}
+
+	del, ok := doc.Paths["/team/delete"]["post"].Responses["204"]
+	if !ok || len(del.Content) != 0 {
+		t.Errorf("/team/delete: got 204 %+v, want response without content", del)
+	}
+}
+
+func TestGenOpenAPIHeaderParams(t *testing.T) {
+	cfg := parseTestPackage(t)
+
+	var buf bytes.Buffer
+	if err := GenOpenAPI(&buf, cfg, OpenAPIOptions{Format: "json", Services: []string{"OtherApi"}}); err != nil {
+		t.Fatalf("GenOpenAPI: %v", err)
+	}
+
+	var doc struct {
+		Paths map[string]map[string]struct {
+			Parameters []parameter `json:"parameters"`
+		} `json:"paths"`
+	}
+	if err := json.Unmarshal(buf.Bytes(), &doc); err != nil {
+		t.Fatalf("can't unmarshal document: %v", err)
+	}
+
+	in := map[string]string{}
+	for _, p := range doc.Paths["/user/search"]["get"].Parameters {
+		in[p.Name] = p.In
+	}
+	for name, want := range map[string]string{"min_level": "query", "X-Request-Region": "header", "locale": "cookie"} {
+		if in[name] != want {
+			t.Errorf("%s: got in %q, want %q", name, in[name], want)
+		}
+	}
+}
+
+func TestGenOpenAPIUpload(t *testing.T) {
+	cfg := parseTestPackage(t)
+
+	var buf bytes.Buffer
+	if err := GenOpenAPI(&buf, cfg, OpenAPIOptions{Format: "json", Services: []string{"TeamApi"}}); err != nil {
+		t.Fatalf("GenOpenAPI: %v", err)
+	}
+
+	var doc struct {
+		Paths map[string]map[string]struct {
+			RequestBody requestBody `json:"requestBody"`
+		} `json:"paths"`
+	}
+	if err := json.Unmarshal(buf.Bytes(), &doc); err != nil {
+		t.Fatalf("can't unmarshal document: %v", err)
+	}
+
+	content := doc.Paths["/team/logo"]["post"].RequestBody.Content
+	if len(content) != 1 {
+		t.Fatalf("got content types %v, want multipart/form-data only", content)
+	}
+	mt := content[multipartType]
+	if logo := mt.Schema.Properties["logo"]; logo == nil || logo.Type != "string" || logo.Format != "binary" {
+		t.Errorf("logo: got %+v, want binary string", logo)
+	}
+	if extra := mt.Schema.Properties["extra"]; extra == nil || extra.Type != "array" || extra.Items.Format != "binary" {
+		t.Errorf("extra: got %+v, want array of binary strings", extra)
+	}
+	if enc := mt.Encoding["logo"]; enc == nil || enc.ContentType != "image/png, image/gif" {
+		t.Errorf("logo encoding: got %+v, want image/png, image/gif", enc)
+	}
+}
+
+func TestGenOpenAPIStringRules(t *testing.T) {
+	cfg := parseTestPackage(t)
+
+	var buf bytes.Buffer
+	if err := GenOpenAPI(&buf, cfg, OpenAPIOptions{Format: "json", Services: []string{"TeamApi"}}); err != nil {
+		t.Fatalf("GenOpenAPI: %v", err)
+	}
+
+	var doc struct {
+		Paths map[string]map[string]struct {
+			RequestBody requestBody `json:"requestBody"`
+		} `json:"paths"`
+	}
+	if err := json.Unmarshal(buf.Bytes(), &doc); err != nil {
+		t.Fatalf("can't unmarshal document: %v", err)
+	}
+
+	props := doc.Paths["/team/invite"]["post"].RequestBody.Content[jsonContentType].Schema.Properties
+	for name, want := range map[string]string{"email": "email", "site": "uri", "token": "uuid"} {
+		if got := props[name]; got == nil || got.Format != want {
+			t.Errorf("%s: got %+v, want format %s", name, got, want)
+		}
+	}
+	if got := props["team"]; got == nil || got.Pattern != "^[a-zA-Z0-9]+$" {
+		t.Errorf("team: got %+v, want alphanum pattern", got)
+	}
+	if got := props["tags"]; got == nil || got.Items == nil || got.Items.Pattern != "^[a-z]{2,}(-[a-z]+)*$" {
+		t.Errorf("tags: got %+v, want items pattern", got)
+	}
+	if got := props["code"]; got == nil || got.MinLength == nil || *got.MinLength != 6 || got.MaxLength == nil || *got.MaxLength != 6 {
+		t.Errorf("code: got %+v, want length 6", got)
+	}
+}
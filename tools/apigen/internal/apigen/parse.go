@@ -5,29 +5,90 @@ import (
 	"fmt"
 	"go/ast"
 	"go/token"
+	"go/types"
 	"log"
+	"net/http"
 	"reflect"
 	"strings"
 )
 
 type methodAPI struct {
-	URL        string `json:"url,omitempty"`
-	HTTPMethod string `json:"method,omitempty"`
-	Auth       bool   `json:"auth,omitempty"`
+	URL        string   `json:"url,omitempty"`
+	HTTPMethod string   `json:"method,omitempty"`
+	Auth       bool     `json:"auth,omitempty"`
+	Roles      []string `json:"roles,omitempty"`      // the caller must have one of the roles
+	Scopes     []string `json:"scopes,omitempty"`     // the caller must have all the scopes
+	AllErrors  *bool    `json:"allErrors,omitempty"`  // the service setting by default
+	Middleware []string `json:"middleware,omitempty"` // appended to the service middleware
+	Envelope   string   `json:"envelope,omitempty"`   // the service setting by default
+	Status     int      `json:"status,omitempty"`     // success HTTP status, 200 by default
+	MaxMemory  int64    `json:"maxMemory,omitempty"`  // memory limit of multipart form, the rest of files is stored on disk
+	MaxBody    int64    `json:"maxBody,omitempty"`    // max size of the request body in bytes, not limited by default
+	Strict     *bool    `json:"strict,omitempty"`     // the service setting by default
+}
+
+// memory limit of multipart form by default, as of http.Request.FormFile
+const defaultMaxMemory = 32 << 20
+
+// serviceAPI is set by the apigen:api mark of the service type.
+type serviceAPI struct {
+	AllErrors  *bool    `json:"allErrors,omitempty"`  // report all param errors instead of the first one
+	Middleware []string `json:"middleware,omitempty"` // middleware of every method, the first is outermost
+	Envelope   string   `json:"envelope,omitempty"`   // envelope of the results: default, none or the type name
+	Strict     *bool    `json:"strict,omitempty"`     // reject unknown fields and trailing data of json body
+}
+
+// envelopes of the method results
+const (
+	defaultEnvelope = "default" // {"response": ..., "error": ""}
+	noEnvelope      = "none"    // the bare result
+)
+
+// envelope is the struct type declared in the package with the field marked
+// with apigen:"response" tag to set the method result.
+type envelope struct {
+	typ   *types.Named
+	field *types.Var
 }
 
 type argType struct {
-	name      string
+	name      string // name of the named type, local name for params of other packages
 	isPointer bool
+	typ       types.Type // type without pointer
 }
 
 type serviceMethod struct {
 	name   string
+	doc    string // method doc comment without apigen marks
 	recv   argType
 	params argType
 	result argType
 	*methodAPI
-	pos token.Pos
+	middleware []*middleware // resolved service and method middleware
+	hasFiles   bool          // params have files uploaded in multipart form
+	validateFn bool          // params have Validate(ctx) error method called after the rules
+	envelope   *envelope     // custom envelope of the result if any
+	pos        token.Pos
+}
+
+// reports whether the type is declared in other package than the generated code.
+func (a argType) isForeign(local *types.Package) bool {
+	named, ok := a.typ.(*types.Named)
+	return ok && named.Obj().Pkg() != nil && named.Obj().Pkg() != local
+}
+
+// reports whether the generated code accumulates all param errors of the request.
+func (m *serviceMethod) allErrors() bool {
+	return m.AllErrors != nil && *m.AllErrors
+}
+
+func (m *serviceMethod) strict() bool {
+	return m.Strict != nil && *m.Strict
+}
+
+// returns name of the generated ApiMethod var describing the method.
+func (m *serviceMethod) apiMethodVar() string {
+	return "apiMethod" + m.recv.name + m.name
 }
 
 type serviceMethodCollection struct {
@@ -48,26 +109,214 @@ func (h *serviceMethodCollection) add(m *serviceMethod) {
 type kind = reflect.Kind
 
 const (
+	Bool    = reflect.Bool
 	Int     = reflect.Int
-	String  = reflect.String
-	Float64 = reflect.Float64
+	Int8    = reflect.Int8
+	Int16   = reflect.Int16
+	Int32   = reflect.Int32
+	Int64   = reflect.Int64
+	Uint    = reflect.Uint
+	Uint8   = reflect.Uint8
+	Uint16  = reflect.Uint16
+	Uint32  = reflect.Uint32
+	Uint64  = reflect.Uint64
 	Float32 = reflect.Float32
+	Float64 = reflect.Float64
+	String  = reflect.String
+
+	// Custom is kind of the types decoded by their UnmarshalText or
+	// UnmarshalJSON methods, e.g. time.Time.
+	Custom = reflect.Interface
+
+	// File is kind of the *multipart.FileHeader files uploaded in multipart form.
+	File = reflect.Pointer
+)
+
+type unmarshaler int
+
+const (
+	noUnmarshaler   unmarshaler = iota
+	textUnmarshaler             // encoding.TextUnmarshaler, decoded from the text
+	jsonUnmarshaler             // json.Unmarshaler only, decoded from JSON
 )
 
+// returns unmarshaler implemented by the type or its pointer.
+func getUnmarshaler(t types.Type) unmarshaler {
+	switch {
+	case hasMethod(t, "UnmarshalText", "([]byte) error"):
+		return textUnmarshaler
+	case hasMethod(t, "UnmarshalJSON", "([]byte) error"):
+		return jsonUnmarshaler
+	}
+	return noUnmarshaler
+}
+
+// reports whether the type or its pointer has the method with the signature
+// written without names, e.g. "([]byte) error".
+func hasMethod(t types.Type, name, sig string) bool {
+	obj, _, _ := types.LookupFieldOrMethod(types.NewPointer(t), false, nil, name)
+	fn, ok := obj.(*types.Func)
+	if !ok {
+		return false
+	}
+	typeStrings := func(vars *types.Tuple) string {
+		ss := make([]string, vars.Len())
+		for i := range ss {
+			ss[i] = types.TypeString(vars.At(i).Type(), nil)
+		}
+		return strings.Join(ss, ", ")
+	}
+	fnSig := fn.Type().(*types.Signature)
+	return "("+typeStrings(fnSig.Params())+") "+typeStrings(fnSig.Results()) == sig
+}
+
+func isDuration(t types.Type) bool {
+	return isNamed(t, "time", "Duration")
+}
+
+func isTime(t types.Type) bool {
+	return isNamed(t, "time", "Time")
+}
+
+func isFileHeader(t types.Type) bool {
+	ptr, ok := types.Unalias(t).(*types.Pointer)
+	return ok && isNamed(ptr.Elem(), "mime/multipart", "FileHeader")
+}
+
+// reports whether the type is the named type of the package.
+func isNamed(t types.Type, pkgPath, name string) bool {
+	named, ok := types.Unalias(t).(*types.Named)
+	return ok && named.Obj().Pkg() != nil && named.Obj().Pkg().Path() == pkgPath && named.Obj().Name() == name
+}
+
+// param field kinds by underlying basic types
+var kindByBasicKind = map[types.BasicKind]kind{
+	types.Bool:    Bool,
+	types.Int:     Int,
+	types.Int8:    Int8,
+	types.Int16:   Int16,
+	types.Int32:   Int32,
+	types.Int64:   Int64,
+	types.Uint:    Uint,
+	types.Uint8:   Uint8,
+	types.Uint16:  Uint16,
+	types.Uint32:  Uint32,
+	types.Uint64:  Uint64,
+	types.Float32: Float32,
+	types.Float64: Float64,
+	types.String:  String,
+}
+
+func isInt(k kind) bool {
+	return k >= Int && k <= Int64
+}
+
+func isUint(k kind) bool {
+	return k >= Uint && k <= Uint64
+}
+
+func isFloat(k kind) bool {
+	return k == Float32 || k == Float64
+}
+
+func isNumber(k kind) bool {
+	return isInt(k) || isUint(k) || isFloat(k)
+}
+
+// returns bit size of the number kind, 0 for int and uint.
+func bitSize(k kind) int {
+	switch k {
+	case Int8, Uint8:
+		return 8
+	case Int16, Uint16:
+		return 16
+	case Int32, Uint32, Float32:
+		return 32
+	case Int64, Uint64, Float64:
+		return 64
+	}
+	return 0
+}
+
+// returns Go literal of zero value of the kind.
+func zeroValue(k kind) string {
+	switch {
+	case k == String:
+		return `""`
+	case k == Bool:
+		return "false"
+	}
+	return "0"
+}
+
 type paramStructField struct {
-	name string
-	kind kind
+	name    string     // Go name of the field, dotted for fields promoted from embedded structs
+	kind    kind       // underlying kind of the field or of the slice items
+	typ     types.Type // type of the field or of the slice items
+	isSlice bool
+	unmarshaler
+	hasValidate bool                // the type has Validate() error method
+	fields      []*paramStructField // fields of the nested struct
+	apiPath     []string            // api names of the nested field and its parents, see flatten
 	*validator
 	pos token.Pos
 }
 
 func (p paramStructField) apiParamName() string {
 	if p.validator == nil || p.validator.paramName == "" {
-		return strings.ToLower(p.name)
+		// promoted field of embedded struct is named as its own
+		return strings.ToLower(p.name[strings.LastIndex(p.name, ".")+1:])
 	}
 	return p.validator.paramName
 }
 
+func (p paramStructField) isStruct() bool {
+	return p.kind == reflect.Struct
+}
+
+// returns name of the field in generated anonymous structs.
+func (p paramStructField) varName() string {
+	return strings.ReplaceAll(p.name, ".", "_")
+}
+
+// returns form or query keys of the field: dotted settings.region and
+// bracket settings[region] forms for the nested fields.
+func (p paramStructField) formKeys() []string {
+	if len(p.apiPath) < 2 {
+		return []string{p.apiParamName()}
+	}
+	return []string{
+		strings.Join(p.apiPath, "."),
+		p.apiPath[0] + "[" + strings.Join(p.apiPath[1:], "][") + "]",
+	}
+}
+
+// returns leaf fields of the nested structs with the Go and api names
+// relative to the top-level param struct, e.g. Settings.Region and settings.region.
+func flatten(fields []*paramStructField) []*paramStructField {
+	var leaves []*paramStructField
+	for _, field := range fields {
+		if !field.isStruct() {
+			leaves = append(leaves, field)
+			continue
+		}
+		apiPath := field.apiPath
+		if len(apiPath) == 0 {
+			apiPath = []string{field.apiParamName()}
+		}
+		for _, sub := range field.fields {
+			leaf := *sub
+			v := *sub.validator
+			leaf.validator = &v
+			leaf.name = field.name + "." + sub.name
+			leaf.apiPath = append(append([]string(nil), apiPath...), sub.apiParamName())
+			leaf.paramName = strings.Join(leaf.apiPath, ".")
+			leaves = append(leaves, flatten([]*paramStructField{&leaf})...)
+		}
+	}
+	return leaves
+}
+
 type paramStructFieldCollection struct {
 	items      map[string][]*paramStructField
 	fieldCount int
@@ -87,11 +336,85 @@ func (ps *paramStructFieldCollection) add(structName string, p *paramStructField
 	}
 }
 
+// marks a param struct as found, even if it has no fields.
+func (ps *paramStructFieldCollection) found(structName string) {
+	if ps.items[structName] == nil {
+		ps.items[structName] = []*paramStructField{}
+	}
+}
+
 func (ps *paramStructFieldCollection) contains(structName string) bool {
 	_, ok := ps.items[structName]
 	return ok
 }
 
+type authenticator struct {
+	name string // func or method name
+	recv string // receiver type name, empty for a package level func
+	pos  token.Pos
+}
+
+type authenticatorCollection struct {
+	items map[string]*authenticator
+}
+
+// adds an authenticator for the receiver type. Package level authenticator
+// is stored with empty receiver name.
+func (as *authenticatorCollection) add(a *authenticator) error {
+	if as.items == nil {
+		as.items = map[string]*authenticator{}
+	}
+	if prev, ok := as.items[a.recv]; ok {
+		return &ParseError{
+			Err: fmt.Errorf("%s: authenticator already defined by %s", a.name, prev.name),
+			Pos: a.pos,
+		}
+	}
+	as.items[a.recv] = a
+	return nil
+}
+
+// returns authenticator for the receiver type or package level one.
+func (as *authenticatorCollection) get(recv string) *authenticator {
+	if a, ok := as.items[recv]; ok {
+		return a
+	}
+	return as.items[""]
+}
+
+type middleware struct {
+	name string // func or method name
+	recv string // receiver type name, empty for a package level func
+	pos  token.Pos
+}
+
+type middlewareCollection struct {
+	items map[string]*middleware
+}
+
+func (ms *middlewareCollection) add(m *middleware) error {
+	if ms.items == nil {
+		ms.items = map[string]*middleware{}
+	}
+	key := m.recv + "." + m.name
+	if _, ok := ms.items[key]; ok {
+		return &ParseError{
+			Err: fmt.Errorf("%s: middleware already defined", m.name),
+			Pos: m.pos,
+		}
+	}
+	ms.items[key] = m
+	return nil
+}
+
+// returns the method of the receiver type or package level func by name.
+func (ms *middlewareCollection) get(recv, name string) *middleware {
+	if m, ok := ms.items[recv+"."+name]; ok {
+		return m
+	}
+	return ms.items["."+name]
+}
+
 type ParseError struct {
 	Pos token.Pos
 	Err error
@@ -103,67 +426,322 @@ func (e *ParseError) Error() string {
 
 type GenConfig struct {
 	packageName string
+	hash        string // hash of the package sources
+	pkg         *types.Package
 	servs       serviceMethodCollection
 	params      paramStructFieldCollection
+	auths       authenticatorCollection
+	mws         middlewareCollection
+}
+
+// Services returns the sorted names of the services having apigen:api marks.
+func (cfg GenConfig) Services() []string {
+	return sortedKeys(cfg.servs.items)
+}
+
+// ParseOptions are the package defaults of the settings of the service methods,
+// the apigen:api marks take precedence.
+type ParseOptions struct {
+	Authenticator string // package level func used as authenticator as if it is marked
+	Envelope      string // envelope of the results: default, none or the type name
+	AllErrors     bool   // report all param errors instead of the first one
+	Strict        bool   // reject unknown fields and trailing data of json body
 }
 
-func ParseFiles(files map[string]*ast.File) (cfg GenConfig, err error) {
-	const op = "ParseFiles"
+// Parse finds the service methods marked with apigen:api, their param structs,
+// authenticators and middleware in the package.
+func Parse(pkg *Package, opts ParseOptions) (cfg GenConfig, err error) {
+	const op = "Parse"
 
-	// XXX
-	defer func() {
-		if p := recover(); p != nil {
-			if p, ok := p.(*ParseError); ok {
-				err = p
+	cfg.packageName = pkg.Name
+	cfg.hash = pkg.Hash
+	cfg.pkg = pkg.Types
+	if opts != (ParseOptions{}) {
+		// the options change the code like the sources
+		cfg.hash = inputsHash(cfg, fmt.Sprintf("%+v", opts))
+	}
+
+	for _, f := range pkg.Files {
+		err := findServiceMethods(f, pkg, &cfg.servs)
+		if err != nil {
+			return cfg, err
+		}
+	}
+
+	log.Printf("%s: FOUND %d/%d service/methods", op, len(cfg.servs.items), cfg.servs.methodCount)
+
+	servAPIs := map[string]*serviceAPI{}
+	for _, f := range pkg.Files {
+		if err := findServiceAPIs(f, servAPIs); err != nil {
+			return cfg, err
+		}
+	}
+	for servName, api := range servAPIs {
+		for _, m := range cfg.servs.items[servName] {
+			if m.AllErrors == nil {
+				m.AllErrors = api.AllErrors
+			}
+			m.Middleware = append(append([]string{}, api.Middleware...), m.Middleware...)
+			if m.Envelope == "" {
+				m.Envelope = api.Envelope
+			}
+			if m.Strict == nil {
+				m.Strict = api.Strict
 			}
-			panic(p)
 		}
-	}()
+	}
+	for _, methods := range cfg.servs.items {
+		for _, m := range methods {
+			if m.AllErrors == nil {
+				m.AllErrors = &opts.AllErrors
+			}
+			if m.Envelope == "" {
+				m.Envelope = opts.Envelope
+			}
+			if m.Strict == nil {
+				m.Strict = &opts.Strict
+			}
+		}
+	}
 
-	for _, f := range files {
-		if cfg.packageName == "" {
-			cfg.packageName = f.Name.Name
-		} else if f.Name.Name != cfg.packageName {
-			return cfg, &ParseError{
-				Err: fmt.Errorf("different package name %s, want %s", f.Name.Name, cfg.packageName),
-				Pos: f.Name.Pos(),
+	for _, methods := range cfg.servs.items {
+		for _, m := range methods {
+			if err := checkResult(m, pkg.Types); err != nil {
+				return cfg, err
+			}
+			if err := checkBodyLimits(m); err != nil {
+				return cfg, err
 			}
 		}
 	}
 
-	for _, f := range files {
-		err := findServiceMethods(f, &cfg.servs)
-		if err != nil {
+	for _, f := range pkg.Files {
+		if err := findAuthenticators(f, pkg, opts.Authenticator, &cfg.servs, &cfg.auths); err != nil {
 			return cfg, err
 		}
 	}
 
-	log.Printf("%s: FOUND %d/%d service/methods", op, len(cfg.servs.items), cfg.servs.methodCount)
-
 	for _, methods := range cfg.servs.items {
 		for _, m := range methods {
-			cfg.params.add(m.params.name, nil)
+			if m.Auth && cfg.auths.get(m.recv.name) == nil {
+				return cfg, &ParseError{
+					Err: fmt.Errorf("%s.%s: auth required, but authenticator not found", m.recv.name, m.name),
+					Pos: m.pos,
+				}
+			}
 		}
 	}
 
-	for _, f := range files {
-		if err := findParamStructFields(f, &cfg.params); err != nil {
+	for _, f := range pkg.Files {
+		if err := findMiddleware(f, pkg, &cfg.mws); err != nil {
 			return cfg, err
 		}
 	}
 
-	for t, p := range cfg.params.items {
-		if p == nil {
-			return cfg, fmt.Errorf("%s: NOT FOUND %s param struct", op, t)
+	for _, methods := range cfg.servs.items {
+		for _, m := range methods {
+			for _, name := range m.Middleware {
+				mw := cfg.mws.get(m.recv.name, name)
+				if mw == nil {
+					return cfg, &ParseError{
+						Err: fmt.Errorf("%s.%s: middleware %s not found", m.recv.name, m.name, name),
+						Pos: m.pos,
+					}
+				}
+				m.middleware = append(m.middleware, mw)
+			}
+		}
+	}
+
+	for _, servName := range sortedKeys(cfg.servs.items) {
+		for _, m := range cfg.servs.items[servName] {
+			if err := findParamStructFields(m, cfg.pkg, &cfg.params); err != nil {
+				return cfg, err
+			}
 		}
 	}
 
 	log.Printf("%s: FOUND %d/%d param struct/fields", op, len(cfg.params.items), cfg.params.fieldCount)
 
+	if err := checkPathParams(&cfg); err != nil {
+		return cfg, err
+	}
+	if err := checkFileParams(&cfg); err != nil {
+		return cfg, err
+	}
+
 	return cfg, nil
 }
 
-func findServiceMethods(f *ast.File, servs *serviceMethodCollection) error {
+// checks the success status of the method and resolves the custom envelope of its result.
+func checkResult(m *serviceMethod, pkg *types.Package) error {
+	if m.Status == 0 {
+		m.Status = http.StatusOK
+	}
+	if m.Status < 200 || m.Status > 299 {
+		return &ParseError{
+			Err: fmt.Errorf("%s.%s: status %d: success status must be 2xx", m.recv.name, m.name, m.Status),
+			Pos: m.pos,
+		}
+	}
+
+	switch m.Envelope {
+	case "":
+		m.Envelope = defaultEnvelope
+		return nil
+	case defaultEnvelope, noEnvelope:
+		return nil
+	}
+
+	env, err := findEnvelope(m.Envelope, pkg)
+	if err != nil {
+		return &ParseError{Err: fmt.Errorf("%s.%s: %w", m.recv.name, m.name, err), Pos: m.pos}
+	}
+	res := m.result.typ
+	if m.result.isPointer {
+		res = types.NewPointer(res)
+	}
+	// the client decodes the result to the field of interface type or takes the field of the result type
+	ft := env.field.Type()
+	if types.IsInterface(ft) && !types.AssignableTo(res, ft) || !types.IsInterface(ft) && !types.Identical(res, ft) {
+		return &ParseError{
+			Err: fmt.Errorf("%s.%s: result %s does not fit %s.%s field of %s type",
+				m.recv.name, m.name, types.TypeString(res, types.RelativeTo(pkg)), m.Envelope, env.field.Name(), env.field.Type()),
+			Pos: m.pos,
+		}
+	}
+	m.envelope = env
+	return nil
+}
+
+// checks the limits of the request body and sets the default ones.
+func checkBodyLimits(m *serviceMethod) error {
+	if m.MaxMemory < 0 || m.MaxBody < 0 {
+		return &ParseError{
+			Err: fmt.Errorf("%s.%s: maxMemory and maxBody must be positive", m.recv.name, m.name),
+			Pos: m.pos,
+		}
+	}
+	if m.MaxMemory == 0 {
+		m.MaxMemory = defaultMaxMemory
+	}
+	return nil
+}
+
+// returns the envelope struct type declared in the package by name.
+func findEnvelope(name string, pkg *types.Package) (*envelope, error) {
+	obj, ok := pkg.Scope().Lookup(name).(*types.TypeName)
+	if !ok {
+		return nil, fmt.Errorf("envelope %s: type not found, want default, none or the struct type name", name)
+	}
+	named, ok := obj.Type().(*types.Named)
+	if !ok || named.TypeParams().Len() > 0 {
+		return nil, fmt.Errorf("envelope %s: must be not generic named struct type", name)
+	}
+	st, ok := named.Underlying().(*types.Struct)
+	if !ok {
+		return nil, fmt.Errorf("envelope %s: must be struct type", name)
+	}
+	for i := 0; i < st.NumFields(); i++ {
+		if reflect.StructTag(st.Tag(i)).Get("apigen") != "response" {
+			continue
+		}
+		if !st.Field(i).Exported() {
+			return nil, fmt.Errorf("envelope %s: %s field must be exported", name, st.Field(i).Name())
+		}
+		return &envelope{typ: named, field: st.Field(i)}, nil
+	}
+	return nil, fmt.Errorf(`envelope %s: field with apigen:"response" tag not found`, name)
+}
+
+// checks that every path param of the method params is present in the method URL template
+// and params of path, headers and cookies are not nested.
+func checkPathParams(cfg *GenConfig) error {
+	for _, methods := range cfg.servs.items {
+		for _, m := range methods {
+			names := map[string]bool{}
+			for _, name := range urlTemplateParams(m.URL) {
+				names[name] = true
+			}
+			for _, field := range flatten(cfg.params.items[m.params.name]) {
+				if field.source != bodySource && len(field.apiPath) > 1 {
+					return &ParseError{
+						Err: fmt.Errorf("%s.%s: %v param must not be nested", m.params.name, field.name, field.source),
+						Pos: field.pos,
+					}
+				}
+				if field.source == pathSource && !names[field.apiParamName()] {
+					return &ParseError{
+						Err: fmt.Errorf("%s.%s: URL %s has no {%s} param required by %s.%s",
+							m.recv.name, m.name, m.URL, field.apiParamName(), m.params.name, field.name),
+						Pos: m.pos,
+					}
+				}
+			}
+		}
+	}
+	return nil
+}
+
+// marks the methods with file params and checks that the files can be sent in the request body.
+func checkFileParams(cfg *GenConfig) error {
+	for _, methods := range cfg.servs.items {
+		for _, m := range methods {
+			for _, field := range flatten(cfg.params.items[m.params.name]) {
+				if field.kind == File {
+					m.hasFiles = true
+				}
+			}
+			if !m.hasFiles {
+				continue
+			}
+			if !hasRequestBody(m.HTTPMethod) {
+				return &ParseError{
+					Err: fmt.Errorf("%s.%s: files of %s are uploaded by POST, PUT or PATCH only", m.recv.name, m.name, m.params.name),
+					Pos: m.pos,
+				}
+			}
+		}
+	}
+	return nil
+}
+
+func isURLTemplate(url string) bool {
+	return strings.Contains(url, "{")
+}
+
+// returns names of params of the URL template like /users/{id}.
+func urlTemplateParams(url string) []string {
+	var names []string
+	for _, seg := range strings.Split(url, "/") {
+		if strings.HasPrefix(seg, "{") && strings.HasSuffix(seg, "}") {
+			names = append(names, seg[1:len(seg)-1])
+		}
+	}
+	return names
+}
+
+// checks that the URL template params take whole path segments and are not duplicated.
+func checkURLTemplate(url string) error {
+	names := map[string]bool{}
+	for _, seg := range strings.Split(url, "/") {
+		if !strings.ContainsAny(seg, "{}") {
+			continue
+		}
+		if !strings.HasPrefix(seg, "{") || !strings.HasSuffix(seg, "}") || len(seg) == 2 ||
+			strings.ContainsAny(seg[1:len(seg)-1], "{}") {
+			return fmt.Errorf("%s: invalid URL template segment %q", url, seg)
+		}
+		name := seg[1 : len(seg)-1]
+		if names[name] {
+			return fmt.Errorf("%s: duplicate URL template param {%s}", url, name)
+		}
+		names[name] = true
+	}
+	return nil
+}
+
+func findServiceMethods(f *ast.File, pkg *Package, servs *serviceMethodCollection) error {
 	const op = "findServiceMethods"
 
 	for _, decl := range f.Decls {
@@ -182,38 +760,54 @@ func findServiceMethods(f *ast.File, servs *serviceMethodCollection) error {
 			continue
 		}
 
-		recv := funcDecl.Recv
-		if recv == nil {
+		sig := funcSignature(funcDecl, pkg)
+		if sig.Recv() == nil {
 			return &ParseError{
 				Err: fmt.Errorf("%s: method must have receiver", funcName),
 				Pos: funcDecl.Pos(),
 			}
 		}
 
-		params := funcDecl.Type.Params
-		if params == nil || len(params.List) != 2 { // (ctx, params)
+		if sig.Params().Len() != 2 { // (ctx, params)
 			return &ParseError{
 				Err: fmt.Errorf("%s: method must have two parameters (ctx, params)", funcName),
-				Pos: params.Pos(),
+				Pos: funcDecl.Type.Params.Pos(),
 			}
 		}
 
-		results := funcDecl.Type.Results
-		if results == nil || len(results.List) != 2 { // (result, err)
+		if sig.Results().Len() != 2 { // (result, err)
 			return &ParseError{
 				Err: fmt.Errorf("%s: method must have two results (result, err)", funcName),
-				Pos: results.Pos(),
+				Pos: funcDecl.Type.Pos(),
 			}
 		}
 
 		m := serviceMethod{
 			name:      funcName,
-			recv:      getArgType(funcDecl.Recv.List[0].Type),
-			params:    getArgType(params.List[1].Type),
-			result:    getArgType(results.List[0].Type),
+			doc:       getMethodDoc(funcDecl),
 			methodAPI: api,
 			pos:       funcDecl.Pos(),
 		}
+		args := []struct {
+			dst  *argType
+			v    *types.Var
+			expr ast.Expr
+		}{
+			{&m.recv, sig.Recv(), funcDecl.Recv.List[0].Type},
+			{&m.params, sig.Params().At(1), funcDecl.Type.Params.List[len(funcDecl.Type.Params.List)-1].Type},
+			{&m.result, sig.Results().At(0), funcDecl.Type.Results.List[0].Type},
+		}
+		for _, arg := range args {
+			if *arg.dst, err = getArgType(arg.v.Type(), pkg.Types); err != nil {
+				if terr := pkg.typeError(arg.expr.Pos()); terr != nil {
+					err = terr
+				}
+				return &ParseError{
+					Err: fmt.Errorf("%s: %w", funcName, err),
+					Pos: arg.expr.Pos(),
+				}
+			}
+		}
 
 		log.Printf("%s: FOUND %s.%s method", op, m.recv.name, m.name)
 		servs.add(&m)
@@ -222,6 +816,14 @@ func findServiceMethods(f *ast.File, servs *serviceMethodCollection) error {
 	return nil
 }
 
+// returns type checked signature of the func declaration.
+func funcSignature(funcDecl *ast.FuncDecl, pkg *Package) *types.Signature {
+	if fn, ok := pkg.Info.Defs[funcDecl.Name].(*types.Func); ok {
+		return fn.Type().(*types.Signature)
+	}
+	return new(types.Signature)
+}
+
 // returns nil if not marked with comment `// apigen:api`
 func getMethodApi(funcDecl *ast.FuncDecl) (*methodAPI, error) {
 	if funcDecl.Doc == nil {
@@ -239,6 +841,15 @@ func getMethodApi(funcDecl *ast.FuncDecl) (*methodAPI, error) {
 				}
 			}
 			api.HTTPMethod = strings.ToUpper(api.HTTPMethod)
+			if len(api.Roles) > 0 || len(api.Scopes) > 0 {
+				api.Auth = true // roles and scopes are checked for the authenticated caller
+			}
+			if err := checkURLTemplate(api.URL); err != nil {
+				return nil, &ParseError{
+					Err: fmt.Errorf("apigen:api: %w", err),
+					Pos: comment.Pos(),
+				}
+			}
 			return &api, nil
 		}
 	}
@@ -246,95 +857,488 @@ func getMethodApi(funcDecl *ast.FuncDecl) (*methodAPI, error) {
 	return nil, nil
 }
 
-func getArgType(t ast.Expr) argType {
-	switch t := t.(type) {
-	case *ast.StarExpr:
-		return argType{name: t.X.(*ast.Ident).Name, isPointer: true}
-	case *ast.Ident:
-		return argType{name: t.Name}
+// finds the service types marked with comment `// apigen:api {...}` setting
+// the defaults of the service methods.
+func findServiceAPIs(f *ast.File, apis map[string]*serviceAPI) error {
+	const op = "findServiceAPIs"
+
+	for _, decl := range f.Decls {
+		genDecl, ok := decl.(*ast.GenDecl)
+		if !ok || genDecl.Tok != token.TYPE {
+			continue
+		}
+		for _, spec := range genDecl.Specs {
+			typeSpec := spec.(*ast.TypeSpec)
+			doc := typeSpec.Doc
+			if doc == nil && len(genDecl.Specs) == 1 {
+				doc = genDecl.Doc
+			}
+			if doc == nil {
+				continue
+			}
+			for _, comment := range doc.List {
+				if !strings.HasPrefix(comment.Text, "// apigen:api") {
+					continue
+				}
+				var api serviceAPI
+				if err := json.Unmarshal([]byte(strings.TrimPrefix(comment.Text, "// apigen:api")), &api); err != nil {
+					return &ParseError{
+						Err: fmt.Errorf("apigen:api: %w", err),
+						Pos: comment.Pos(),
+					}
+				}
+				log.Printf("%s: FOUND %s service settings", op, typeSpec.Name.Name)
+				apis[typeSpec.Name.Name] = &api
+			}
+		}
 	}
-	panic(&ParseError{
-		Err: fmt.Errorf("type must be *ats.Ident or *ast.StarExpr, got %T", t),
-		Pos: t.Pos(),
-	})
+
+	return nil
 }
 
-func findParamStructFields(f *ast.File, params *paramStructFieldCollection) error {
-	const op = "findParamStructFields"
+// finds the Authenticate methods of the services and funcs marked with comment
+// `// apigen:authenticator`. The package level func named by the config is found
+// as if it is marked. The unmarked Authenticate methods of other signature are skipped.
+func findAuthenticators(f *ast.File, pkg *Package, configured string, servs *serviceMethodCollection, auths *authenticatorCollection) error {
+	const op = "findAuthenticators"
 
 	for _, decl := range f.Decls {
-		g, ok := decl.(*ast.GenDecl)
+		funcDecl, ok := decl.(*ast.FuncDecl)
 		if !ok {
 			continue
 		}
-		for _, spec := range g.Specs {
-			typeSpec, ok := spec.(*ast.TypeSpec)
-			if !ok {
+		funcName := funcDecl.Name.Name
+
+		marked := hasMark(funcDecl, "// apigen:authenticator") || funcDecl.Recv == nil && funcName == configured
+		if !marked && (funcName != "Authenticate" || funcDecl.Recv == nil) {
+			continue
+		}
+
+		a := authenticator{
+			name: funcName,
+			pos:  funcDecl.Pos(),
+		}
+		sig := funcSignature(funcDecl, pkg)
+		if recv := sig.Recv(); recv != nil {
+			recvType, err := getArgType(recv.Type(), pkg.Types)
+			if err != nil {
+				return &ParseError{Err: fmt.Errorf("%s: %w", funcName, err), Pos: funcDecl.Pos()}
+			}
+			a.recv = recvType.name
+		}
+		if !marked {
+			if _, ok := servs.items[a.recv]; !ok {
+				log.Printf("%s: SKIP %s.%s method of not service type", op, a.recv, funcName)
 				continue
 			}
+		}
 
-			typeName := typeSpec.Name.Name
-			if !params.contains(typeName) {
-				log.Printf("%s: SKIP %s is unknown type", op, typeName)
+		if !isAuthenticatorFunc(funcDecl, sig, pkg.Types) {
+			if !marked {
+				log.Printf("%s: SKIP %s.%s method of other signature", op, a.recv, funcName)
 				continue
 			}
+			return &ParseError{
+				Err: fmt.Errorf("%s: authenticator must be func(*http.Request, ApiMethod) (any, error)", funcName),
+				Pos: funcDecl.Pos(),
+			}
+		}
 
-			structType, ok := typeSpec.Type.(*ast.StructType)
-			if !ok {
-				return &ParseError{
-					Err: fmt.Errorf("%s: params must be struct", typeName),
-					Pos: typeSpec.Type.Pos(),
-				}
+		log.Printf("%s: FOUND %s authenticator for %q", op, a.name, a.recv)
+		if err := auths.add(&a); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// reports whether the func is func(*http.Request, ApiMethod) (any, error).
+func isAuthenticatorFunc(funcDecl *ast.FuncDecl, sig *types.Signature, local *types.Package) bool {
+	params, results := sig.Params(), sig.Results()
+	if params.Len() != 2 || results.Len() != 2 {
+		return false
+	}
+	req, ok := types.Unalias(params.At(0).Type()).(*types.Pointer)
+	if !ok || !isNamed(req.Elem(), "net/http", "Request") {
+		return false
+	}
+	if !isApiMethodParam(funcDecl, sig, 1, local) {
+		return false
+	}
+	id, ok := types.Unalias(results.At(0).Type()).(*types.Interface)
+	if !ok || !id.Empty() {
+		return false
+	}
+	return types.Identical(results.At(1).Type(), types.Universe.Lookup("error").Type())
+}
+
+// reports whether the func is func(http.Handler, ApiMethod) http.Handler.
+func isMiddlewareFunc(funcDecl *ast.FuncDecl, sig *types.Signature, local *types.Package) bool {
+	params, results := sig.Params(), sig.Results()
+	if params.Len() != 2 || results.Len() != 1 {
+		return false
+	}
+	return isNamed(params.At(0).Type(), "net/http", "Handler") &&
+		isApiMethodParam(funcDecl, sig, 1, local) &&
+		isNamed(results.At(0).Type(), "net/http", "Handler")
+}
+
+// reports whether the i-th param of the func is ApiMethod of the local package.
+// ApiMethod is declared by the generated code, so it is not resolved if the
+// code is not generated yet, then its name is checked.
+func isApiMethodParam(funcDecl *ast.FuncDecl, sig *types.Signature, i int, local *types.Package) bool {
+	switch t := types.Unalias(sig.Params().At(i).Type()).(type) {
+	case *types.Named:
+		return t.Obj().Pkg() == local && t.Obj().Name() == "ApiMethod"
+	case *types.Basic:
+		return t.Kind() == types.Invalid && isIdent(paramTypeExpr(funcDecl, i), "ApiMethod")
+	}
+	return false
+}
+
+// returns the type expression of the i-th param of the func.
+func paramTypeExpr(funcDecl *ast.FuncDecl, i int) ast.Expr {
+	for _, field := range funcDecl.Type.Params.List {
+		n := max(len(field.Names), 1)
+		if i < n {
+			return field.Type
+		}
+		i -= n
+	}
+	return nil
+}
+
+func isIdent(expr ast.Expr, name string) bool {
+	id, ok := expr.(*ast.Ident)
+	return ok && id.Name == name
+}
+
+// finds the funcs and methods marked with comment `// apigen:middleware`.
+func findMiddleware(f *ast.File, pkg *Package, mws *middlewareCollection) error {
+	const op = "findMiddleware"
+
+	for _, decl := range f.Decls {
+		funcDecl, ok := decl.(*ast.FuncDecl)
+		if !ok || !hasMark(funcDecl, "// apigen:middleware") {
+			continue
+		}
+		funcName := funcDecl.Name.Name
+
+		mw := middleware{
+			name: funcName,
+			pos:  funcDecl.Pos(),
+		}
+		sig := funcSignature(funcDecl, pkg)
+		if recv := sig.Recv(); recv != nil {
+			recvType, err := getArgType(recv.Type(), pkg.Types)
+			if err != nil {
+				return &ParseError{Err: fmt.Errorf("%s: %w", funcName, err), Pos: funcDecl.Pos()}
 			}
+			mw.recv = recvType.name
+		}
 
-			log.Printf("%s: FOUND %s struct", op, typeName)
+		if !isMiddlewareFunc(funcDecl, sig, pkg.Types) {
+			return &ParseError{
+				Err: fmt.Errorf("%s: middleware must be func(http.Handler, ApiMethod) http.Handler", funcName),
+				Pos: funcDecl.Pos(),
+			}
+		}
 
-			for _, field := range structType.Fields.List {
-				validator, err := getApiValidator(field)
-				if err != nil {
-					return &ParseError{Err: err, Pos: field.Pos()}
-				}
-				if validator == nil {
-					continue
+		log.Printf("%s: FOUND %s middleware for %q", op, mw.name, mw.recv)
+		if err := mws.add(&mw); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func hasMark(funcDecl *ast.FuncDecl, mark string) bool {
+	if funcDecl.Doc == nil {
+		return false
+	}
+	for _, comment := range funcDecl.Doc.List {
+		if strings.TrimSpace(comment.Text) == mark {
+			return true
+		}
+	}
+	return false
+}
+
+func getMethodDoc(funcDecl *ast.FuncDecl) string {
+	if funcDecl.Doc == nil {
+		return ""
+	}
+	var lines []string
+	for _, line := range strings.Split(funcDecl.Doc.Text(), "\n") {
+		if !strings.HasPrefix(line, "apigen:") {
+			lines = append(lines, line)
+		}
+	}
+	return strings.TrimSpace(strings.Join(lines, "\n"))
+}
+
+// returns the named type of the method receiver, params or result. The result
+// type may be not named, e.g. slice.
+func getArgType(t types.Type, local *types.Package) (argType, error) {
+	var arg argType
+	if ptr, ok := types.Unalias(t).(*types.Pointer); ok {
+		t = ptr.Elem()
+		arg.isPointer = true
+	}
+	t = types.Unalias(t)
+	arg.typ = t
+
+	switch t := t.(type) {
+	case *types.Named:
+		obj := t.Obj()
+		arg.name = obj.Name()
+		if obj.Pkg() != nil && obj.Pkg() != local {
+			arg.name = obj.Pkg().Name() + obj.Name()
+		}
+	case *types.Basic:
+		if t.Kind() == types.Invalid {
+			return arg, fmt.Errorf("invalid type")
+		}
+	}
+	return arg, nil
+}
+
+// finds fields of the method param struct. The struct may be declared in
+// other package, then it is added with the local name, see getArgType.
+func findParamStructFields(m *serviceMethod, local *types.Package, params *paramStructFieldCollection) error {
+	const op = "findParamStructFields"
+
+	m.validateFn = hasMethod(m.params.typ, "Validate", "(context.Context) error")
+
+	typeName := m.params.name
+	if params.contains(typeName) {
+		return nil
+	}
+
+	named, ok := m.params.typ.(*types.Named)
+	if !ok {
+		return &ParseError{
+			Err: fmt.Errorf("%s.%s: params must be named struct, got %s", m.recv.name, m.name, m.params.typ),
+			Pos: m.pos,
+		}
+	}
+	structType, ok := named.Underlying().(*types.Struct)
+	if !ok {
+		return &ParseError{
+			Err: fmt.Errorf("%s: params must be struct", typeName),
+			Pos: named.Obj().Pos(),
+		}
+	}
+
+	log.Printf("%s: FOUND %s struct", op, typeName)
+	params.add(typeName, nil)
+	params.found(typeName)
+
+	fields, err := parseParamFields(structType, local, map[*types.Named]bool{named: true})
+	if err != nil {
+		return err
+	}
+	for _, field := range fields {
+		params.add(typeName, field)
+	}
+
+	return nil
+}
+
+// parses fields of the param struct. Fields of embedded structs are promoted,
+// nested structs are parsed recursively. The seen contains the structs being
+// parsed to detect recursive types.
+func parseParamFields(structType *types.Struct, local *types.Package, seen map[*types.Named]bool) ([]*paramStructField, error) {
+	var fields []*paramStructField
+
+	for i := 0; i < structType.NumFields(); i++ {
+		field := structType.Field(i)
+		if !field.Exported() && field.Pkg() != local {
+			// can't be set by the generated code
+			continue
+		}
+
+		validator, err := getApiValidator(structType.Tag(i))
+		if err != nil {
+			return nil, &ParseError{Err: err, Pos: field.Pos()}
+		}
+		if validator == nil {
+			continue
+		}
+
+		// embedded struct
+		if field.Embedded() {
+			st, named := structOf(field.Type())
+			if st == nil {
+				return nil, &ParseError{
+					Err: fmt.Errorf("%s: embedded field must be struct, not pointer", field.Name()),
+					Pos: field.Pos(),
 				}
+			}
+			embedded, err := parseNestedStruct(st, named, local, seen, field.Pos())
+			if err != nil {
+				return nil, err
+			}
+			for _, f := range embedded {
+				f.name = field.Name() + "." + f.name
+				fields = append(fields, f)
+			}
+			continue
+		}
 
-				fieldName := field.Names[0].Name
-				fieldType := field.Type.(*ast.Ident).Name
-
-				var fieldKind kind
-				switch fieldType {
-				case "string":
-					fieldKind = String
-				case "int":
-					fieldKind = Int
-				case "float64":
-					fieldKind = Float64
-				default:
-					return &ParseError{
-						Err: fmt.Errorf("%s: field type must be int, string or float64", fieldName),
-						Pos: field.Type.Pos(),
-					}
+		var (
+			fieldType = field.Type()
+			fieldKind kind
+			isSlice   bool
+			nested    []*paramStructField
+			unm       = getUnmarshaler(fieldType)
+		)
+		if t, ok := types.Unalias(fieldType).(*types.Slice); ok && unm == noUnmarshaler {
+			fieldType = t.Elem()
+			isSlice = true
+			unm = getUnmarshaler(fieldType)
+		}
+		switch t := fieldType.Underlying().(type) {
+		case *types.Basic:
+			fieldKind = kindByBasicKind[t.Kind()]
+		case *types.Pointer:
+			if isFileHeader(fieldType) {
+				fieldKind = File
+			}
+		case *types.Struct:
+			if !isSlice && unm == noUnmarshaler {
+				_, named := structOf(fieldType)
+				if nested, err = parseNestedStruct(t, named, local, seen, field.Pos()); err != nil {
+					return nil, err
 				}
+				fieldKind = reflect.Struct
+			}
+		}
+		if unm != noUnmarshaler {
+			fieldKind = Custom
+		}
+
+		if fieldKind == reflect.Invalid {
+			return nil, &ParseError{
+				Err: fmt.Errorf("%s: field type must be bool, string, int, uint or float of any size, *multipart.FileHeader, slice of them or struct, got %s", field.Name(), fieldType),
+				Pos: field.Pos(),
+			}
+		}
+
+		if err := validator.checkSlice(isSlice); err != nil {
+			return nil, &ParseError{
+				Err: fmt.Errorf("%s: %w", field.Name(), err),
+				Pos: field.Pos(),
+			}
+		}
+		validator.duration = isDuration(fieldType)
+		if err := validator.checkKind(fieldKind); err != nil {
+			return nil, &ParseError{
+				Err: fmt.Errorf("%s: %w", field.Name(), err),
+				Pos: field.Pos(),
+			}
+		}
+		if err := validator.checkUnmarshaler(unm, isSlice && types.Comparable(fieldType)); err != nil {
+			return nil, &ParseError{
+				Err: fmt.Errorf("%s: %w", field.Name(), err),
+				Pos: field.Pos(),
+			}
+		}
 
-				params.add(typeName, &paramStructField{
-					name:      fieldName,
-					kind:      fieldKind,
-					validator: validator,
-					pos:       field.Pos(),
-				})
+		fields = append(fields, &paramStructField{
+			name:        field.Name(),
+			kind:        fieldKind,
+			typ:         fieldType,
+			isSlice:     isSlice,
+			unmarshaler: unm,
+			hasValidate: hasMethod(fieldType, "Validate", "() error"),
+			fields:      nested,
+			validator:   validator,
+			pos:         field.Pos(),
+		})
+	}
+
+	if err := resolveCrossRules(fields); err != nil {
+		return nil, err
+	}
+
+	return fields, nil
+}
+
+// resolves the other fields of the cross field rules among the fields of the
+// struct. Rules of the promoted fields are resolved in their embedded struct.
+func resolveCrossRules(fields []*paramStructField) error {
+	for _, field := range fields {
+		if field.validator == nil {
+			continue
+		}
+		for _, c := range field.cross {
+			if c.refField != nil {
+				continue
+			}
+			for _, f := range fields {
+				if f.name == c.ref || strings.HasSuffix(f.name, "."+c.ref) {
+					c.refField, c.refName, c.selfName = f, f.name, field.name
+					break
+				}
+			}
+			if err := checkCrossRule(field, c); err != nil {
+				return &ParseError{Err: fmt.Errorf("%s: %s=%s: %w", field.name, c.rule, c.ref, err), Pos: field.pos}
 			}
 		}
 	}
+	return nil
+}
 
+func checkCrossRule(field *paramStructField, c *crossRule) error {
+	ref := c.refField
+	switch {
+	case ref == nil:
+		return fmt.Errorf("field not found")
+	case ref == field:
+		return fmt.Errorf("field must be other one")
+	case field.isStruct() || ref.isStruct():
+		return fmt.Errorf("rule not applicable for nested struct")
+	}
+	if _, ok := crossOps[c.rule]; !ok {
+		// required_with and required_without
+		return nil
+	}
+	if field.isSlice || ref.isSlice || !types.Identical(field.typ, ref.typ) {
+		return fmt.Errorf("fields must be of the same not slice type")
+	}
+	if !isNumber(field.kind) && field.kind != String {
+		return fmt.Errorf("rule applicable for numbers and strings only")
+	}
 	return nil
 }
 
-func getApiValidator(field *ast.Field) (*validator, error) {
-	if field.Tag == nil {
-		return &validator{}, nil
+// returns the struct type and its name if the type is named struct.
+func structOf(t types.Type) (*types.Struct, *types.Named) {
+	named, _ := types.Unalias(t).(*types.Named)
+	st, _ := t.Underlying().(*types.Struct)
+	return st, named
+}
+
+func parseNestedStruct(st *types.Struct, named *types.Named, local *types.Package, seen map[*types.Named]bool, pos token.Pos) ([]*paramStructField, error) {
+	if named != nil {
+		if seen[named] {
+			return nil, &ParseError{
+				Err: fmt.Errorf("%s: recursive param struct", named.Obj().Name()),
+				Pos: pos,
+			}
+		}
+		seen[named] = true
+		defer delete(seen, named)
 	}
-	tag := reflect.StructTag(field.Tag.Value[1 : len(field.Tag.Value)-1])
-	tagVal, ok := tag.Lookup("apivalidator")
+
+	return parseParamFields(st, local, seen)
+}
+
+func getApiValidator(tag string) (*validator, error) {
+	tagVal, ok := reflect.StructTag(tag).Lookup("apivalidator")
 	if !ok || tagVal == "" {
 		return &validator{}, nil
 	}
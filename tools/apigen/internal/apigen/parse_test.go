@@ -0,0 +1,134 @@
+package apigen
+
+import (
+	"io"
+	"log"
+	"strings"
+	"testing"
+)
+
+const authSource = `package api
+
+import (
+	"context"
+	"net/http"
+)
+
+type Api struct{}
+
+type GetParams struct {
+	ID int ` + "`apigen:\"required\"`" + `
+}
+
+// apigen:api {"url": "/get", "auth": true}
+func (a *Api) Get(ctx context.Context, in GetParams) (int, error) {
+	return in.ID, nil
+}
+
+func (a *Api) Authenticate(r *http.Request, m ApiMethod) (any, error) {
+	return nil, nil
+}
+
+// LDAP is not the service, its Authenticate is not the authenticator
+type LDAP struct{}
+
+func (l *LDAP) Authenticate(ctx context.Context, user, pass string) (bool, error) {
+	return false, nil
+}
+
+type Basic struct{}
+
+func (b Basic) Authenticate(user, pass string) (bool, error) {
+	return false, nil
+}
+`
+
+func TestFindAuthenticators(t *testing.T) {
+	log.SetOutput(io.Discard)
+
+	parse := func(src string) (GenConfig, error) {
+		t.Helper()
+		dir := writeModule(t, map[string]string{"api.go": src})
+		pkg, err := LoadPackage(dir, nil)
+		if err != nil {
+			t.Fatalf("LoadPackage: %v", err)
+		}
+		return Parse(pkg, ParseOptions{})
+	}
+
+	cfg, err := parse(authSource)
+	if err != nil {
+		t.Fatalf("Parse: %v", err)
+	}
+	if len(cfg.auths.items) != 1 || cfg.auths.get("Api") == nil {
+		t.Errorf("got authenticators %v, want Api.Authenticate", sortedKeys(cfg.auths.items))
+	}
+
+	// the unmarked method of the service with the wrong types is skipped
+	src := strings.Replace(authSource, "(r *http.Request, m ApiMethod) (any, error)", "(r *http.Request, m string) (any, error)", 1)
+	if _, err := parse(src); err == nil || !strings.Contains(err.Error(), "auth required, but authenticator not found") {
+		t.Errorf("got error %v of skipped Authenticate", err)
+	}
+
+	// the marked func is checked
+	src = strings.Replace(authSource, "type Basic struct{}\n", "type Basic struct{}\n\n// apigen:authenticator", 1)
+	if _, err := parse(src); err == nil || !strings.Contains(err.Error(), "Authenticate: authenticator must be func(*http.Request, ApiMethod) (any, error)") {
+		t.Errorf("got error %v of marked authenticator", err)
+	}
+}
+
+const middlewareSource = `package api
+
+import (
+	"context"
+	"net/http"
+)
+
+type Api struct{}
+
+type GetParams struct{}
+
+// apigen:api {"url": "/get", "middleware": ["logged"]}
+func (a *Api) Get(ctx context.Context, in GetParams) (int, error) {
+	return 0, nil
+}
+
+// apigen:middleware
+func logged(next http.Handler, m ApiMethod) http.Handler {
+	return next
+}
+`
+
+func TestFindMiddleware(t *testing.T) {
+	log.SetOutput(io.Discard)
+
+	parse := func(src string) (GenConfig, error) {
+		t.Helper()
+		dir := writeModule(t, map[string]string{"api.go": src})
+		pkg, err := LoadPackage(dir, nil)
+		if err != nil {
+			t.Fatalf("LoadPackage: %v", err)
+		}
+		return Parse(pkg, ParseOptions{})
+	}
+
+	cfg, err := parse(middlewareSource)
+	if err != nil {
+		t.Fatalf("Parse: %v", err)
+	}
+	if cfg.mws.get("", "logged") == nil {
+		t.Errorf("got middleware %v, want logged", sortedKeys(cfg.mws.items))
+	}
+
+	// the types are checked, not only the number of params and results
+	for _, sig := range []string{
+		"(next http.Handler, m string) http.Handler",
+		"(next http.HandlerFunc, m ApiMethod) http.Handler",
+		"(next http.Handler, m ApiMethod) http.HandlerFunc",
+	} {
+		src := strings.Replace(middlewareSource, "(next http.Handler, m ApiMethod) http.Handler", sig, 1)
+		if _, err := parse(src); err == nil || !strings.Contains(err.Error(), "logged: middleware must be func(http.Handler, ApiMethod) http.Handler") {
+			t.Errorf("%s: got error %v of wrong middleware", sig, err)
+		}
+	}
+}
@@ -1,13 +1,28 @@
 package apigen
 
 import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
 	"fmt"
+	"go/ast"
+	"go/format"
+	"go/parser"
+	"go/scanner"
+	"go/token"
+	"go/types"
 	"io"
+	"path"
+	"strings"
 )
 
 type printer struct {
 	w   io.Writer
 	err error
+
+	pkg     *types.Package    // package of the generated code
+	imports map[string]string // packages of the types used in the code by path
 }
 
 func newPrinter(w io.Writer) *printer {
@@ -23,3 +38,182 @@ func (p *printer) printf(f string, a ...interface{}) error {
 	}
 	return p.err
 }
+
+// returns Go name of the type in the generated code and remembers its package to import.
+func (p *printer) typeName(t types.Type) string {
+	return types.TypeString(t, func(other *types.Package) string {
+		if other == p.pkg {
+			return ""
+		}
+		if p.imports == nil {
+			p.imports = map[string]string{}
+		}
+		p.imports[other.Path()] = other.Name()
+		return other.Name()
+	})
+}
+
+// returns name of the package to use in the generated code and remembers it to import.
+// The major version suffix like /v2 is not the package name.
+func (p *printer) use(pkgPath string) string {
+	if p.imports == nil {
+		p.imports = map[string]string{}
+	}
+	name := path.Base(pkgPath)
+	if dir := path.Dir(pkgPath); len(name) > 1 && name[0] == 'v' && strings.Trim(name[1:], "0123456789") == "" && dir != "." {
+		name = path.Base(dir)
+	}
+	p.imports[pkgPath] = name
+	return name
+}
+
+// returns the code printed by gen to insert into the template output. The
+// non-empty code starts with a newline like the code of the templates.
+func (p *printer) sprint(gen func(p *printer) error) (string, error) {
+	if p.imports == nil {
+		p.imports = map[string]string{}
+	}
+	var buf strings.Builder
+	if err := gen(&printer{w: &buf, pkg: p.pkg, imports: p.imports}); err != nil {
+		return "", err
+	}
+	if buf.Len() == 0 {
+		return "", nil
+	}
+	return "\n" + strings.TrimSuffix(buf.String(), "\n"), nil
+}
+
+// returns Go type of the param field.
+func (p *printer) fieldType(field *paramStructField) string {
+	if field.isSlice {
+		return "[]" + p.typeName(field.typ)
+	}
+	return p.typeName(field.typ)
+}
+
+// prints the file header, the imports used by the body and the body formatted
+// by gofmt. The imports are the packages of the types used in the body and the
+// known packages the code refers to by name, e.g. strconv, if it does.
+func (p *printer) printFile(pkgName, hash string, known []string, body *printer, buf *bytes.Buffer) error {
+	const op = "printFile"
+
+	if body.err != nil {
+		return body.err
+	}
+
+	header := "// !!! Do not change this code !!!\n// The code is generated automatically by apigen tool\n"
+	if hash != "" {
+		header += hashPrefix + hash + "\n"
+	}
+	header += "package " + pkgName + "\n"
+	src := append([]byte(header), buf.Bytes()...)
+
+	f, err := parser.ParseFile(token.NewFileSet(), "", src, 0)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, sourceError(src, err))
+	}
+	used := usedNames(f)
+
+	pkgs := map[string]string{}
+	for _, imp := range known {
+		pkgs[imp] = path.Base(imp)
+	}
+	for imp, name := range body.imports {
+		pkgs[imp] = name
+	}
+
+	// goimports groups: standard library, then others
+	var std, others []string
+	for _, imp := range sortedKeys(pkgs) {
+		name := pkgs[imp]
+		if !used[name] {
+			continue
+		}
+		spec := fmt.Sprintf("%q", imp)
+		if name != path.Base(imp) {
+			spec = name + " " + spec
+		}
+		if first, _, _ := strings.Cut(imp, "/"); strings.Contains(first, ".") {
+			others = append(others, spec)
+		} else {
+			std = append(std, spec)
+		}
+	}
+
+	var decl string
+	switch {
+	case len(std) > 0 && len(others) > 0:
+		decl = "import (\n" + strings.Join(std, "\n") + "\n\n" + strings.Join(others, "\n") + "\n)\n"
+	case len(std)+len(others) > 0:
+		decl = "import (\n" + strings.Join(append(std, others...), "\n") + "\n)\n"
+	}
+
+	src = append([]byte(header+decl), buf.Bytes()...)
+	code, err := format.Source(src)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, sourceError(src, err))
+	}
+	_, p.err = p.w.Write(code)
+	return p.err
+}
+
+const hashPrefix = "// Inputs hash: "
+
+// returns hash of the inputs of the generated code: the package sources, the
+// kind of the code and its options.
+func inputsHash(cfg GenConfig, opts ...string) string {
+	h := sha256.New()
+	io.WriteString(h, cfg.hash)
+	for _, opt := range opts {
+		io.WriteString(h, "\x00"+opt)
+	}
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// GeneratedHash returns the inputs hash written to the header of the code
+// generated by apigen or empty string if there is no hash. The code is up to
+// date if the hash is the one of the current inputs, see CodeHash and ClientHash.
+func GeneratedHash(code []byte) string {
+	for _, line := range strings.SplitN(string(code), "\n", 5) {
+		if hash, ok := strings.CutPrefix(line, hashPrefix); ok {
+			return strings.TrimSpace(hash)
+		}
+	}
+	return ""
+}
+
+// returns names the code selects from which are not declared in the file,
+// they are names of the packages if the code compiles.
+func usedNames(f *ast.File) map[string]bool {
+	used := map[string]bool{}
+	ast.Inspect(f, func(n ast.Node) bool {
+		if sel, ok := n.(*ast.SelectorExpr); ok {
+			// parser resolves the identifiers declared in the file scopes
+			if id, ok := sel.X.(*ast.Ident); ok && id.Obj == nil {
+				used[id.Name] = true
+			}
+		}
+		return true
+	})
+	return used
+}
+
+// returns error of the generated code which is not valid Go with the lines
+// around the first error, the code may be broken by the templates.
+func sourceError(src []byte, err error) error {
+	var list scanner.ErrorList
+	if !errors.As(err, &list) || len(list) == 0 {
+		return fmt.Errorf("generated code is not valid Go: %w", err)
+	}
+	pos := list[0].Pos
+	lines := strings.Split(string(src), "\n")
+	var b strings.Builder
+	for i := max(pos.Line-3, 0); i < min(pos.Line+2, len(lines)); i++ {
+		mark := " "
+		if i+1 == pos.Line {
+			mark = ">"
+		}
+		fmt.Fprintf(&b, "\n%s%5d | %s", mark, i+1, lines[i])
+	}
+	return fmt.Errorf("generated code is not valid Go: %d:%d: %s%s", pos.Line, pos.Column, list[0].Msg, b.String())
+}
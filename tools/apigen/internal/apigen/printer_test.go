@@ -0,0 +1,98 @@
+package apigen
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func TestPrintFile(t *testing.T) {
+	var body bytes.Buffer
+	p := newPrinter(&body)
+	p.printf(`func f(path string) string {`)
+	p.printf(`s := strings.TrimSpace(path)`)
+	p.printf(`return %s.QuoteToASCII(s)`, p.use("strconv"))
+	p.printf(`}`)
+	p.printf(`func g(w io.Writer, v any) error { return %s.NewEncoder(w).Encode(v) }`, p.use("github.com/vmihailenco/msgpack/v5"))
+
+	var buf bytes.Buffer
+	if err := newPrinter(&buf).printFile("main", "", []string{"io", "path", "strconv", "strings", "time"}, p, &body); err != nil {
+		t.Fatalf("printFile: %v", err)
+	}
+
+	// unused known packages are not imported, the local path var is not the package
+	want := `import (
+	"io"
+	"strconv"
+	"strings"
+
+	msgpack "github.com/vmihailenco/msgpack/v5"
+)
+
+func f(path string) string {
+	s := strings.TrimSpace(path)
+	return strconv.QuoteToASCII(s)
+}
+`
+	if got := buf.String(); !strings.Contains(got, want) {
+		t.Errorf("got code:\n%s\nwant:\n%s", got, want)
+	}
+}
+
+func TestPrintFileError(t *testing.T) {
+	var body bytes.Buffer
+	p := newPrinter(&body)
+	p.printf(`func f() int {`)
+	p.printf(`return 1 +`)
+	p.printf(`}`)
+
+	err := newPrinter(&bytes.Buffer{}).printFile("main", "", nil, p, &body)
+	if err == nil {
+		t.Fatal("expected error")
+	}
+	for _, s := range []string{"generated code is not valid Go: 6:1", ">    6 | }", "     5 | return 1 +"} {
+		if !strings.Contains(err.Error(), s) {
+			t.Errorf("error %q has no %q", err, s)
+		}
+	}
+}
+
+func TestGeneratedHash(t *testing.T) {
+	cfg := parseTestPackage(t)
+
+	hashes := map[string]bool{}
+	for _, opts := range []CodeOptions{{}, {Encoders: []string{"xml"}}} {
+		var buf bytes.Buffer
+		if err := GenCode(&buf, cfg, opts); err != nil {
+			t.Fatalf("GenCode: %v", err)
+		}
+		hash := GeneratedHash(buf.Bytes())
+		if len(hash) != 64 {
+			t.Fatalf("got hash %q", hash)
+		}
+		if got, err := CodeHash(cfg, opts); err != nil || got != hash {
+			t.Errorf("CodeHash: got %q, %v, want %q", got, err, hash)
+		}
+		hashes[hash] = true
+	}
+
+	var client bytes.Buffer
+	if err := GenClient(&client, cfg, ClientOptions{}); err != nil {
+		t.Fatalf("GenClient: %v", err)
+	}
+	if hash, err := ClientHash(cfg, ClientOptions{}); err != nil || hash != GeneratedHash(client.Bytes()) {
+		t.Errorf("ClientHash: got %q, %v, want %q", hash, err, GeneratedHash(client.Bytes()))
+	}
+	if len(hashes) != 2 {
+		t.Errorf("hash does not depend on options")
+	}
+
+	cfg.hash = "other sources"
+	var buf bytes.Buffer
+	if err := GenCode(&buf, cfg, CodeOptions{}); err != nil {
+		t.Fatalf("GenCode: %v", err)
+	}
+	if hashes[GeneratedHash(buf.Bytes())] {
+		t.Errorf("hash does not depend on sources")
+	}
+}
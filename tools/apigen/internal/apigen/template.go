@@ -0,0 +1,389 @@
+package apigen
+
+import (
+	"crypto/sha256"
+	"embed"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io/fs"
+	"net/http"
+	"os"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+	"text/template"
+)
+
+// default templates of the server code, every one is defined by name and
+// may be redefined by the templates of CodeOptions.Templates directory.
+//
+//go:embed templates/*.tmpl
+var defaultTemplates embed.FS
+
+// loads the default templates and the *.tmpl files of dir redefining them.
+// It also returns hash of the sources of the templates.
+func loadTemplates(dir string, funcs template.FuncMap) (*template.Template, string, error) {
+	const op = "loadTemplates"
+
+	type source struct {
+		fsys fs.FS
+		name string
+	}
+	var sources []source
+
+	names, err := fs.Glob(defaultTemplates, "templates/*.tmpl")
+	if err != nil {
+		return nil, "", fmt.Errorf("%s: %w", op, err)
+	}
+	for _, name := range names {
+		sources = append(sources, source{defaultTemplates, name})
+	}
+	if dir != "" {
+		dirFS := os.DirFS(dir)
+		if names, err = fs.Glob(dirFS, "*.tmpl"); err != nil {
+			return nil, "", fmt.Errorf("%s: %w", op, err)
+		}
+		if len(names) == 0 {
+			return nil, "", fmt.Errorf("%s: no *.tmpl files in %s", op, dir)
+		}
+		for _, name := range names {
+			sources = append(sources, source{dirFS, name})
+		}
+	}
+
+	// the later definitions of the templates replace the earlier ones
+	t := template.New("apigen").Funcs(funcs)
+	hash := sha256.New()
+	for _, src := range sources {
+		text, err := fs.ReadFile(src.fsys, src.name)
+		if err != nil {
+			return nil, "", fmt.Errorf("%s: %w", op, err)
+		}
+		fmt.Fprintf(hash, "%s %d\n", src.name, len(text))
+		hash.Write(text)
+		if _, err := t.New(path.Base(src.name)).Parse(string(text)); err != nil {
+			return nil, "", fmt.Errorf("%s: %w", op, err)
+		}
+	}
+	return t, hex.EncodeToString(hash.Sum(nil)), nil
+}
+
+// returns funcs of the templates. The code of the param fields is generated
+// by the funcs, the types and packages used by the templates are imported.
+// The helper func returns the name of the helper renamed by CodeOptions.Names.
+func codeFuncs(p *printer, names map[string]string) template.FuncMap {
+	return template.FuncMap{
+		"use":      p.use,
+		"quote":    strconv.Quote,
+		"quoteAll": quoteAll,
+		"helper":   func(name string) string { return helperName(names, name) },
+		"getFromPath": func(d *paramsData) (string, error) {
+			return p.sprint(func(p *printer) error { return genGetFromPath(p, d.Name, d.pathFields) })
+		},
+		"getFromHeaders": func(d *paramsData) (string, error) {
+			return p.sprint(func(p *printer) error { return genGetFromHeaders(p, d.Name, d.headerFields) })
+		},
+		"getFromJsonBody": func(d *paramsData) (string, error) {
+			return p.sprint(func(p *printer) error { return genGetFromJsonBody(p, d.Name, d.bodyFields) })
+		},
+		"getFromForm": func(d *paramsData) (string, error) {
+			return p.sprint(func(p *printer) error { return genGetFromFormOrQuery(p, d.Name, flatten(d.bodyFields)) })
+		},
+		"validateField": func(d *paramsData, field *paramStructField) (string, error) {
+			return p.sprint(func(p *printer) error { return genValidateField(p, d.Name, field, d.Fields) })
+		},
+	}
+}
+
+// codeData is the data of "code" template.
+type codeData struct {
+	Package      string
+	Encoders     []encoderData
+	ContentTypes map[string]bool // accepted media types of the request body
+	Services     []*serviceData
+	ParamTypes   []paramTypeData
+	Patterns     []patternData
+	Params       []*paramsData
+}
+
+type encoderData struct {
+	TypeName    string
+	ContentType string
+	Body        string // code of Encode method
+}
+
+type serviceData struct {
+	Name    string
+	Methods []*methodData
+	Paths   []*pathData // sorted URLs of the methods
+}
+
+// pathData is the URL of the service methods.
+type pathData struct {
+	Path     string
+	Template bool          // URL has path params like /users/{id}
+	Methods  []*methodData // methods by HTTP method
+	Any      *methodData   // method of any HTTP method if any
+}
+
+// methodData is the data of the wrapper and handler templates of the method.
+type methodData struct {
+	Service        string
+	Name           string
+	Recv           string // receiver of the wrapper, e.g. h *MyApi
+	ApiMethodVar   string
+	URL            string
+	HTTPMethod     string
+	Auth           bool
+	Roles          []string
+	Scopes         []string
+	Authenticator  string   // Authenticator expression if Auth
+	Middleware     []string // middleware funcs, the innermost first
+	Params         string   // local type of the params
+	AllErrors      bool
+	Errs           string // ParamErrors argument of getFromRequest and validate
+	MaxBody        int64
+	MaxMemory      int64
+	Strict         bool
+	ValidateParams string // params expression to call Validate(ctx) if the params have it
+	Arg            string // params argument of the method
+	NoContent      bool
+	Status         string // success status expression
+	Body           string // response body expression
+}
+
+type paramTypeData struct {
+	Name string
+	Type string
+}
+
+type patternData struct {
+	Name string
+	Expr string // regexp string literal
+}
+
+// paramsData is the data of getFromRequest and validate templates of the param struct.
+type paramsData struct {
+	Name   string
+	Fields []*paramStructField // leaf fields of the nested structs
+
+	pathFields, headerFields, bodyFields []*paramStructField
+}
+
+func newCodeData(p *printer, cfg GenConfig, opts CodeOptions) (*codeData, error) {
+	const op = "newCodeData"
+
+	data := &codeData{Package: cfg.packageName}
+
+	names := []string{"json"}
+	for _, name := range opts.Encoders {
+		if _, ok := encoderTypes[name]; !ok {
+			return nil, fmt.Errorf("%s: unknown encoder %s. available: %s", op, name, strings.Join(sortedKeys(encoderTypes), ", "))
+		}
+		if !contains(names, name) {
+			names = append(names, name)
+		}
+	}
+	for _, name := range names {
+		enc := encoderTypes[name]
+		body, err := p.sprint(func(p *printer) error {
+			enc.gen(p)
+			return p.err
+		})
+		if err != nil {
+			return nil, err
+		}
+		data.Encoders = append(data.Encoders, encoderData{enc.typeName, enc.contentType, body})
+	}
+
+	declared := map[string]bool{}
+	for _, servName := range sortedKeys(cfg.servs.items) {
+		serv, err := newServiceData(p, servName, cfg.servs.items[servName], cfg.auths.get(servName))
+		if err != nil {
+			return nil, err
+		}
+		data.Services = append(data.Services, serv)
+
+		for _, m := range cfg.servs.items[servName] {
+			if !m.params.isForeign(cfg.pkg) || declared[m.params.name] {
+				continue
+			}
+			declared[m.params.name] = true
+			data.ParamTypes = append(data.ParamTypes, paramTypeData{m.params.name, p.typeName(m.params.typ)})
+		}
+	}
+
+	order := sortedKeys(cfg.params.items)
+	data.Patterns = newPatternData(cfg, order)
+	for _, structName := range order {
+		fields := cfg.params.items[structName]
+		params := &paramsData{Name: structName, Fields: flatten(fields)}
+		for _, field := range fields {
+			switch field.source {
+			case pathSource:
+				params.pathFields = append(params.pathFields, field)
+			case headerSource, cookieSource:
+				params.headerFields = append(params.headerFields, field)
+			default:
+				params.bodyFields = append(params.bodyFields, field)
+			}
+		}
+		data.Params = append(data.Params, params)
+	}
+
+	return data, nil
+}
+
+func newServiceData(p *printer, servName string, methods []*serviceMethod, auth *authenticator) (*serviceData, error) {
+	serv := &serviceData{Name: servName}
+
+	byPath := map[string]*pathData{}
+	for _, m := range methods {
+		md, err := newMethodData(p, m, auth)
+		if err != nil {
+			return nil, err
+		}
+		serv.Methods = append(serv.Methods, md)
+
+		path, ok := byPath[m.URL]
+		if !ok {
+			path = &pathData{Path: m.URL, Template: isURLTemplate(m.URL)}
+			byPath[m.URL] = path
+		}
+		dup := path.Any != nil && m.HTTPMethod == anyHTTPMethod
+		for _, other := range path.Methods {
+			dup = dup || other.HTTPMethod == m.HTTPMethod
+		}
+		if dup {
+			return nil, &ParseError{
+				Err: errors.New("dublicate HTTP method"),
+				Pos: m.pos,
+			}
+		}
+		if m.HTTPMethod == anyHTTPMethod {
+			path.Any = md
+		} else {
+			path.Methods = append(path.Methods, md)
+		}
+	}
+
+	for _, url := range sortedKeys(byPath) {
+		path := byPath[url]
+		sort.Slice(path.Methods, func(i, j int) bool { return path.Methods[i].HTTPMethod < path.Methods[j].HTTPMethod })
+		serv.Paths = append(serv.Paths, path)
+	}
+	return serv, nil
+}
+
+func newMethodData(p *printer, m *serviceMethod, auth *authenticator) (*methodData, error) {
+	md := &methodData{
+		Service:      m.recv.name,
+		Name:         m.name,
+		Recv:         "h " + m.recv.name,
+		ApiMethodVar: m.apiMethodVar(),
+		URL:          m.URL,
+		HTTPMethod:   m.HTTPMethod,
+		Auth:         m.Auth,
+		Roles:        m.Roles,
+		Scopes:       m.Scopes,
+		Params:       m.params.name,
+		AllErrors:    m.allErrors(),
+		Errs:         "nil", // nil errs stops on the first param error
+		MaxBody:      m.MaxBody,
+		MaxMemory:    m.MaxMemory,
+		Strict:       m.strict(),
+		NoContent:    m.Status == http.StatusNoContent,
+		Status:       statusExpr(m.Status),
+	}
+	if m.recv.isPointer {
+		md.Recv = "h *" + m.recv.name
+	}
+	if md.AllErrors {
+		md.Errs = "&errs"
+	}
+
+	if m.Auth {
+		if auth == nil {
+			return nil, &ParseError{
+				Err: fmt.Errorf("%s.%s: authenticator not found", m.recv.name, m.name),
+				Pos: m.pos,
+			}
+		}
+		// the receiver implements Authenticator itself
+		md.Authenticator = "h"
+		if auth.recv == "" {
+			md.Authenticator = fmt.Sprintf("AuthenticatorFunc(%s)", auth.name)
+		}
+	}
+
+	for i := len(m.middleware) - 1; i >= 0; i-- {
+		mw := m.middleware[i]
+		if mw.recv != "" {
+			md.Middleware = append(md.Middleware, "h."+mw.name)
+		} else {
+			md.Middleware = append(md.Middleware, mw.name)
+		}
+	}
+
+	foreign := m.params.isForeign(p.pkg)
+	if m.validateFn {
+		md.ValidateParams = "params"
+		if foreign {
+			md.ValidateParams = fmt.Sprintf("(*%s)(&params)", p.typeName(m.params.typ))
+		}
+	}
+
+	switch {
+	case foreign && m.params.isPointer:
+		md.Arg = fmt.Sprintf("(*%s)(&params)", p.typeName(m.params.typ))
+	case foreign:
+		md.Arg = fmt.Sprintf("%s(params)", p.typeName(m.params.typ))
+	case m.params.isPointer:
+		md.Arg = "&params"
+	default:
+		md.Arg = "params"
+	}
+
+	switch {
+	case m.envelope != nil:
+		md.Body = fmt.Sprintf(`&%s{%s: res}`, p.typeName(m.envelope.typ), m.envelope.field.Name())
+	case m.Envelope == noEnvelope:
+		md.Body = `res`
+	default:
+		md.Body = `&apiResponse{Response: res}`
+	}
+
+	return md, nil
+}
+
+// returns package level vars of the regexps compiled once: patterns of
+// the fields and the shared ones of uuid and alphanum rules if used.
+func newPatternData(cfg GenConfig, order []string) []patternData {
+	var (
+		rules ruleSet
+		vars  []patternData
+	)
+	for _, structName := range order {
+		for _, field := range flatten(cfg.params.items[structName]) {
+			if field.rules&patternRule != 0 {
+				vars = append(vars, patternData{patternVar(structName, field), field.pattern})
+			}
+			rules |= field.rules
+		}
+	}
+	if rules&uuidRule != 0 {
+		vars = append(vars, patternData{"uuidPattern", `^[0-9a-fA-F]{8}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{12}$`})
+	}
+	if rules&alphanumRule != 0 {
+		vars = append(vars, patternData{"alphanumPattern", `^[a-zA-Z0-9]+$`})
+	}
+	for i, v := range vars {
+		vars[i].Expr = "`" + v.Expr + "`"
+		if strings.Contains(v.Expr, "`") {
+			vars[i].Expr = strconv.Quote(v.Expr)
+		}
+	}
+	return vars
+}
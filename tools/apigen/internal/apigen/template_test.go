@@ -0,0 +1,64 @@
+package apigen
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestGenCodeTemplates(t *testing.T) {
+	cfg := parseTestPackage(t)
+
+	var def bytes.Buffer
+	if err := GenCode(&def, cfg, CodeOptions{}); err != nil {
+		t.Fatalf("GenCode: %v", err)
+	}
+
+	dir := t.TempDir()
+	override := `{{define "extra"}}
+// generated services: {{range .Services}}{{.Name}} {{end}}
+{{end}}
+
+{{define "auth"}}
+// custom auth of {{.ApiMethodVar}}
+r, ok := authenticate(w, r, {{.Authenticator}}, {{.ApiMethodVar}})
+if !ok {
+	return
+}
+{{- end}}
+`
+	if err := os.WriteFile(filepath.Join(dir, "custom.tmpl"), []byte(override), 0666); err != nil {
+		t.Fatal(err)
+	}
+
+	var buf bytes.Buffer
+	if err := GenCode(&buf, cfg, CodeOptions{Templates: dir}); err != nil {
+		t.Fatalf("GenCode: %v", err)
+	}
+	code := buf.String()
+	for _, s := range []string{"// generated services: MyApi OtherApi TeamApi", "// custom auth of apiMethodMyApiCreate"} {
+		if !strings.Contains(code, s) {
+			t.Errorf("code has no %q", s)
+		}
+	}
+	if strings.Contains(def.String(), "custom auth") {
+		t.Errorf("default code has overridden template")
+	}
+	// the templates not redefined are the default ones
+	if !strings.Contains(code, "func (h *MyApi) wrapperProfile(") {
+		t.Errorf("code has no default wrapper")
+	}
+
+	bad := t.TempDir()
+	if err := os.WriteFile(filepath.Join(bad, "bad.tmpl"), []byte(`{{define "extra"}}{{.Unknown}}{{end}}`), 0666); err != nil {
+		t.Fatal(err)
+	}
+	if err := GenCode(&buf, cfg, CodeOptions{Templates: bad}); err == nil {
+		t.Errorf("expected error of unknown field")
+	}
+	if err := GenCode(&buf, cfg, CodeOptions{Templates: t.TempDir()}); err == nil {
+		t.Errorf("expected error of directory without templates")
+	}
+}
@@ -2,7 +2,12 @@ package apigen
 
 import (
 	"fmt"
+	"mime"
+	"reflect"
+	"regexp"
+	"strconv"
 	"strings"
+	"time"
 )
 
 type ruleSet int
@@ -15,10 +20,63 @@ const (
 	maxRule
 	greaterRule
 	lessRule
+	minItemsRule
+	maxItemsRule
+	uniqueRule
+	maxSizeRule
+	mimeRule
+	patternRule
+	lenRule
+	runeMinRule
+	runeMaxRule
+	runeLenRule
+	emailRule
+	urlRule
+	uuidRule
+	alphanumRule
 )
 
+// rules applicable to the strings or to every item of the string slice field
+const stringRules = patternRule | lenRule | runeMinRule | runeMaxRule | runeLenRule | emailRule | urlRule | uuidRule | alphanumRule
+
+// format rules are not checked on empty strings, they are checked by required rule
+const formatRules = patternRule | emailRule | urlRule | uuidRule | alphanumRule
+
+// rules applicable to the field value or to every item of the slice field
+const valueRules = enumRule | minRule | maxRule | greaterRule | lessRule | stringRules
+
+// rules applicable to the slice field only
+const sliceRules = minItemsRule | maxItemsRule | uniqueRule
+
+// rules applicable to the uploaded file or to every file of the slice field
+const fileRules = maxSizeRule | mimeRule
+
+// source of the param value in the request
+type paramSource int
+
+const (
+	bodySource   paramSource = iota // json body, form or query
+	pathSource                      // URL template param, e.g. {id} in /users/{id}
+	headerSource                    // request header, e.g. X-Request-Region
+	cookieSource                    // request cookie
+)
+
+// returns the name of the param location in OpenAPI document
+func (s paramSource) String() string {
+	switch s {
+	case pathSource:
+		return "path"
+	case headerSource:
+		return "header"
+	case cookieSource:
+		return "cookie"
+	}
+	return "body"
+}
+
 type validator struct {
 	rules      ruleSet
+	source     paramSource
 	paramName  string
 	defaultVal string
 	enum       []string
@@ -26,17 +84,85 @@ type validator struct {
 	max        string
 	greater    string
 	less       string
+	minItems   string
+	maxItems   string
+	maxSize    string   // max size of the uploaded file in bytes
+	mime       []string // allowed media types of the uploaded file
+	pattern    string   // regexp the string must match
+	length     string   // exact length of the string in bytes
+	runeMin    string
+	runeMax    string
+	runeLen    string
+	cross      []*crossRule
+	csv        bool // slice items also may be comma separated in form or query
+	trim       bool // leading and trailing white space of the string is removed
+	lowercase  bool // the string is converted to lower case
+	duration   bool // number rule values are durations like 1s of time.Duration field
+}
+
+// crossRule compares the field with the other field of the same struct or
+// requires the field depending on the other one.
+type crossRule struct {
+	rule     string // eqfield, nefield, gtfield, gtefield, ltfield, ltefield, required_with or required_without
+	ref      string // Go name of the other field
+	refField *paramStructField
+	refName  string // name of the other field relative to the struct of the rule, dotted if promoted
+	selfName string // name of the field relative to the struct of the rule
+}
+
+// comparison operators of the cross field rules
+var crossOps = map[string]string{
+	"eqfield":  "==",
+	"nefield":  "!=",
+	"gtfield":  ">",
+	"gtefield": ">=",
+	"ltfield":  "<",
+	"ltefield": "<=",
 }
 
 func parseValidator(s string) (*validator, error) {
 	var v validator
 	var err error
 
+	// the pattern may contain commas, so it is the last rule
+	if i := strings.Index(s, "pattern="); i == 0 || i > 0 && s[i-1] == ',' {
+		v.rules |= patternRule
+		v.pattern = s[i+len("pattern="):]
+		if _, err := regexp.Compile(v.pattern); err != nil {
+			return nil, fmt.Errorf("pattern=%s: %w", v.pattern, err)
+		}
+		s = strings.TrimSuffix(s[:i], ",")
+		if s == "" {
+			return &v, nil
+		}
+	}
+
 	for _, entry := range strings.Split(s, ",") {
 		switch {
 		case strings.HasPrefix(entry, "paramname="):
 			v.paramName = strings.TrimPrefix(entry, "paramname=")
 
+		case strings.HasPrefix(entry, "path="):
+			v.source = pathSource
+			v.paramName = strings.TrimPrefix(entry, "path=")
+			if v.paramName == "" {
+				err = fmt.Errorf("%s: path param name must be not empty", entry)
+			}
+
+		case strings.HasPrefix(entry, "header="):
+			v.source = headerSource
+			v.paramName = strings.TrimPrefix(entry, "header=")
+			if v.paramName == "" {
+				err = fmt.Errorf("%s: header name must be not empty", entry)
+			}
+
+		case strings.HasPrefix(entry, "cookie="):
+			v.source = cookieSource
+			v.paramName = strings.TrimPrefix(entry, "cookie=")
+			if v.paramName == "" {
+				err = fmt.Errorf("%s: cookie name must be not empty", entry)
+			}
+
 		case entry == "required":
 			v.rules |= requiredRule
 
@@ -56,6 +182,71 @@ func parseValidator(s string) (*validator, error) {
 			v.rules |= maxRule
 			v.max = strings.TrimPrefix(entry, "max=")
 
+		case strings.HasPrefix(entry, "minitems="):
+			v.rules |= minItemsRule
+			v.minItems = strings.TrimPrefix(entry, "minitems=")
+
+		case strings.HasPrefix(entry, "maxitems="):
+			v.rules |= maxItemsRule
+			v.maxItems = strings.TrimPrefix(entry, "maxitems=")
+
+		case entry == "unique":
+			v.rules |= uniqueRule
+
+		case strings.HasPrefix(entry, "maxsize="):
+			v.rules |= maxSizeRule
+			v.maxSize = strings.TrimPrefix(entry, "maxsize=")
+
+		case strings.HasPrefix(entry, "mime="):
+			v.rules |= mimeRule
+			v.mime = strings.Split(strings.TrimPrefix(entry, "mime="), "|")
+
+		case entry == "csv":
+			v.csv = true
+
+		case strings.HasPrefix(entry, "len="):
+			v.rules |= lenRule
+			v.length = strings.TrimPrefix(entry, "len=")
+
+		case strings.HasPrefix(entry, "runemin="):
+			v.rules |= runeMinRule
+			v.runeMin = strings.TrimPrefix(entry, "runemin=")
+
+		case strings.HasPrefix(entry, "runemax="):
+			v.rules |= runeMaxRule
+			v.runeMax = strings.TrimPrefix(entry, "runemax=")
+
+		case strings.HasPrefix(entry, "runelen="):
+			v.rules |= runeLenRule
+			v.runeLen = strings.TrimPrefix(entry, "runelen=")
+
+		case entry == "email":
+			v.rules |= emailRule
+
+		case entry == "url":
+			v.rules |= urlRule
+
+		case entry == "uuid":
+			v.rules |= uuidRule
+
+		case entry == "alphanum":
+			v.rules |= alphanumRule
+
+		case entry == "trim":
+			v.trim = true
+
+		case strings.Contains(entry, "field=") || strings.HasPrefix(entry, "required_with"):
+			rule, ref, _ := strings.Cut(entry, "=")
+			if _, ok := crossOps[rule]; !ok && rule != "required_with" && rule != "required_without" {
+				err = fmt.Errorf("%s: unknown rule", entry)
+			} else if ref == "" {
+				err = fmt.Errorf("%s: field name must be not empty", entry)
+			}
+			v.cross = append(v.cross, &crossRule{rule: rule, ref: ref})
+
+		case entry == "lowercase":
+			v.lowercase = true
+
 		case strings.HasPrefix(entry, ">="):
 			v.rules |= minRule
 			v.min = strings.TrimPrefix(entry, ">=")
@@ -83,3 +274,187 @@ func parseValidator(s string) (*validator, error) {
 
 	return &v, nil
 }
+
+// checks that the rules are applicable to the slice or not slice field.
+func (v *validator) checkSlice(isSlice bool) error {
+	if !isSlice {
+		if v.rules&sliceRules != 0 || v.csv {
+			return fmt.Errorf("minitems, maxitems, unique and csv rules applicable for slices only")
+		}
+		return nil
+	}
+
+	if v.rules&defaultRule != 0 {
+		return fmt.Errorf("default rule not applicable for slices")
+	}
+	if v.source != bodySource {
+		return fmt.Errorf("slice can't be %v param", v.source)
+	}
+	for _, n := range []struct {
+		flag  ruleSet
+		name  string
+		value string
+	}{
+		{minItemsRule, "minitems", v.minItems},
+		{maxItemsRule, "maxitems", v.maxItems},
+	} {
+		if v.rules&n.flag == 0 {
+			continue
+		}
+		if i, err := strconv.Atoi(n.value); err != nil || i < 0 {
+			return fmt.Errorf("%s=%s: items count must be non-negative int", n.name, n.value)
+		}
+	}
+	return nil
+}
+
+// checks that the rules are applicable to the field kind and the rule values
+// are valid for it, so the generated code compiles and compares values of
+// the same type.
+func (v *validator) checkKind(k kind) error {
+	type rule struct {
+		flag  ruleSet
+		name  string
+		value string
+	}
+	bounds := []rule{
+		{minRule, "min", v.min},
+		{maxRule, "max", v.max},
+		{greaterRule, ">", v.greater},
+		{lessRule, "<", v.less},
+	}
+
+	if k != File && v.rules&fileRules != 0 {
+		return fmt.Errorf("maxsize and mime rules applicable for files only")
+	}
+	if k != String && (v.rules&stringRules != 0 || v.trim || v.lowercase) {
+		return fmt.Errorf("pattern, len, runemin, runemax, runelen, email, url, uuid, alphanum, trim and lowercase rules applicable for strings only")
+	}
+
+	switch {
+	case k == String:
+		lengths := append(bounds,
+			rule{lenRule, "len", v.length},
+			rule{runeMinRule, "runemin", v.runeMin},
+			rule{runeMaxRule, "runemax", v.runeMax},
+			rule{runeLenRule, "runelen", v.runeLen},
+		)
+		for _, r := range lengths {
+			if v.rules&r.flag == 0 {
+				continue
+			}
+			if n, err := strconv.Atoi(r.value); err != nil || n < 0 {
+				return fmt.Errorf("%s=%s: string length must be non-negative int", r.name, r.value)
+			}
+		}
+
+	case k == Bool:
+		for _, r := range append(bounds, rule{enumRule, "enum", ""}) {
+			if v.rules&r.flag != 0 {
+				return fmt.Errorf("%s rule not applicable for %v type", r.name, k)
+			}
+		}
+		if v.rules&defaultRule != 0 {
+			b, err := strconv.ParseBool(v.defaultVal)
+			if err != nil {
+				return fmt.Errorf("default=%s: invalid %v value", v.defaultVal, k)
+			}
+			v.defaultVal = strconv.FormatBool(b)
+		}
+
+	case k == Custom:
+		for _, r := range bounds {
+			if v.rules&r.flag != 0 {
+				return fmt.Errorf("%s rule not applicable for custom type", r.name)
+			}
+		}
+
+	case k == File:
+		for _, r := range append(bounds, rule{enumRule, "enum", ""}, rule{defaultRule, "default", ""}, rule{uniqueRule, "unique", ""}) {
+			if v.rules&r.flag != 0 {
+				return fmt.Errorf("%s rule not applicable for files", r.name)
+			}
+		}
+		if v.source != bodySource {
+			return fmt.Errorf("file can't be %v param", v.source)
+		}
+		if v.rules&maxSizeRule != 0 {
+			if n, err := strconv.ParseInt(v.maxSize, 10, 64); err != nil || n <= 0 {
+				return fmt.Errorf("maxsize=%s: file size must be positive int", v.maxSize)
+			}
+		}
+		for _, m := range v.mime {
+			if _, _, err := mime.ParseMediaType(m); err != nil {
+				return fmt.Errorf("mime=%s: %w", m, err)
+			}
+		}
+
+	case k == reflect.Struct:
+		if v.rules != 0 || v.source != bodySource {
+			return fmt.Errorf("rules not applicable for nested struct, mark its fields")
+		}
+
+	case isNumber(k):
+		values := append(bounds, rule{defaultRule, "default", v.defaultVal})
+		for _, s := range v.enum {
+			values = append(values, rule{enumRule, "enum", s})
+		}
+		for _, r := range values {
+			if v.rules&r.flag == 0 {
+				continue
+			}
+			if err := checkNumber(k, v.number(r.value)); err != nil {
+				return fmt.Errorf("%s=%s: %w", r.name, r.value, err)
+			}
+		}
+
+	default:
+		return fmt.Errorf("%v type not supported", k)
+	}
+
+	return nil
+}
+
+// checks that the rules are applicable to the type decoded by the unmarshaler.
+// Rules of encoding.TextUnmarshaler types are checked on the text, json.Unmarshaler
+// types are decoded from JSON, so they can be required only.
+func (v *validator) checkUnmarshaler(u unmarshaler, comparableItems bool) error {
+	switch u {
+	case textUnmarshaler:
+		if v.rules&uniqueRule != 0 && !comparableItems {
+			return fmt.Errorf("unique rule not applicable for not comparable type")
+		}
+	case jsonUnmarshaler:
+		if v.rules&^(requiredRule|minItemsRule|maxItemsRule) != 0 || v.source != bodySource {
+			return fmt.Errorf("only required, minitems and maxitems rules applicable for json.Unmarshaler type")
+		}
+	}
+	return nil
+}
+
+// returns Go constant of the number rule value. The durations like 1s are
+// converted to nanoseconds, the rule text is kept for messages.
+func (v *validator) number(s string) string {
+	if v.duration {
+		if d, err := time.ParseDuration(s); err == nil {
+			return strconv.FormatInt(int64(d), 10)
+		}
+	}
+	return s
+}
+
+func checkNumber(k kind, s string) error {
+	var err error
+	switch {
+	case isInt(k):
+		_, err = strconv.ParseInt(s, 10, bitSize(k))
+	case isUint(k):
+		_, err = strconv.ParseUint(s, 10, bitSize(k))
+	case isFloat(k):
+		_, err = strconv.ParseFloat(s, bitSize(k))
+	}
+	if err != nil {
+		return fmt.Errorf("invalid %v value", k)
+	}
+	return nil
+}
@@ -0,0 +1,84 @@
+package apigen
+
+import (
+	"go/types"
+	"testing"
+)
+
+func TestParseValidatorErrors(t *testing.T) {
+	cases := []struct {
+		tag  string
+		kind kind
+	}{
+		{"pattern=^[a-z+$", String},
+		{"required,pattern=(", String},
+		{"len=-1", String},
+		{"runemin=x", String},
+		{"runelen=1.5", String},
+		{"email", Int},
+		{"trim", Bool},
+		{"pattern=^[0-9]+$", Int64},
+		{"samefield=ID", Int},
+		{"gtfield=", Int},
+	}
+	for _, c := range cases {
+		v, err := parseValidator(c.tag)
+		if err == nil {
+			err = v.checkKind(c.kind)
+		}
+		if err == nil {
+			t.Errorf("%s of %v: expected error", c.tag, c.kind)
+		}
+	}
+}
+
+func TestParseValidatorPattern(t *testing.T) {
+	v, err := parseValidator("required,trim,pattern=^[a-z]{2,4}(,[a-z]+)*$")
+	if err != nil {
+		t.Fatalf("parseValidator: %v", err)
+	}
+	if v.pattern != "^[a-z]{2,4}(,[a-z]+)*$" || v.rules&requiredRule == 0 || !v.trim {
+		t.Errorf("got pattern %q, rules %b, trim %v", v.pattern, v.rules, v.trim)
+	}
+	if err := v.checkKind(String); err != nil {
+		t.Errorf("checkKind: %v", err)
+	}
+}
+
+func TestResolveCrossRules(t *testing.T) {
+	field := func(name string, k kind, typ types.Type, tag string) *paramStructField {
+		v := &validator{}
+		if tag != "" {
+			var err error
+			if v, err = parseValidator(tag); err != nil {
+				t.Fatalf("parseValidator %q: %v", tag, err)
+			}
+		}
+		return &paramStructField{name: name, kind: k, typ: typ, validator: v}
+	}
+	intType, stringType := types.Typ[types.Int], types.Typ[types.String]
+
+	valid := []*paramStructField{
+		field("Min", Int, intType, ""),
+		field("Max", Int, intType, "gtefield=Min"),
+		field("Name", String, stringType, "required_without=Max"),
+	}
+	if err := resolveCrossRules(valid); err != nil {
+		t.Fatalf("resolveCrossRules: %v", err)
+	}
+	if c := valid[1].cross[0]; c.refField != valid[0] || c.refName != "Min" || c.selfName != "Max" {
+		t.Errorf("got ref %q of %q", c.refName, c.selfName)
+	}
+
+	invalid := [][]*paramStructField{
+		{field("Max", Int, intType, "gtfield=Min")},
+		{field("Max", Int, intType, "gtfield=Max")},
+		{field("Name", String, stringType, ""), field("Max", Int, intType, "ltfield=Name")},
+		{field("Ok", Bool, types.Typ[types.Bool], ""), field("No", Bool, types.Typ[types.Bool], "eqfield=Ok")},
+	}
+	for _, fields := range invalid {
+		if err := resolveCrossRules(fields); err == nil {
+			t.Errorf("%s: expected error", fields[len(fields)-1].name)
+		}
+	}
+}
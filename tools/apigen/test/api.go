@@ -2,9 +2,14 @@ package main
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"mime/multipart"
 	"net/http"
+	"sort"
 	"sync"
+
+	"apigen/test/model"
 )
 
 // вы можете использовать ApiError в коде, который получается в результате генерации
@@ -26,6 +31,9 @@ const (
 	statusAdmin     = 20
 )
 
+// у всех методов MyApi паника перехватывается
+//
+// apigen:api {"middleware": ["recoverPanic"]}
 type MyApi struct {
 	statuses map[string]int
 	users    map[string]*User
@@ -75,12 +83,66 @@ type NewUser struct {
 	ID uint64 `json:"id"`
 }
 
-// apigen:api {"url": "/user/profile", "auth": false}
+// authUser - личность вызывающего, у пользователя с большим статусом есть роли меньших
+type authUser struct {
+	login  string
+	status int
+}
+
+func (u authUser) HasRole(role string) bool {
+	switch role {
+	case "admin":
+		return u.status >= statusAdmin
+	case "moderator":
+		return u.status >= statusModerator
+	}
+	return role == "user"
+}
+
+// Authenticate реализует Authenticator для методов MyApi, помеченных "auth": true
+func (srv *MyApi) Authenticate(r *http.Request, m ApiMethod) (any, error) {
+	switch r.Header.Get("X-Auth") {
+	case "100500":
+		return authUser{login: "rvasily", status: statusAdmin}, nil
+	case "100501":
+		return authUser{login: "mr.user", status: statusUser}, nil
+	}
+	return nil, ApiError{http.StatusForbidden, errors.New("unauthorized")}
+}
+
+// recoverPanic отвечает 500 вместо обрыва соединения при панике в методе
+//
+// apigen:middleware
+func recoverPanic(next http.Handler, m ApiMethod) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		defer func() {
+			if err := recover(); err != nil {
+				writeApiError(w, r, ApiError{http.StatusInternalServerError, fmt.Errorf("%s.%s: panic: %v", m.Service, m.Name, err)})
+			}
+		}()
+		next.ServeHTTP(w, r)
+	})
+}
+
+// methodHeader сообщает в заголовке, какой метод вызван
+//
+// apigen:middleware
+func (srv *MyApi) methodHeader(next http.Handler, m ApiMethod) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("X-Api-Method", m.HTTPMethod+" "+m.URL)
+		next.ServeHTTP(w, r)
+	})
+}
+
+// apigen:api {"url": "/user/profile", "auth": false, "middleware": ["methodHeader"]}
 func (srv *MyApi) Profile(ctx context.Context, in ProfileParams) (*User, error) {
 
 	if in.Login == "bad_user" {
 		return nil, fmt.Errorf("bad user")
 	}
+	if in.Login == "panic_user" {
+		panic("unexpected user")
+	}
 
 	srv.mu.RLock()
 	user, exist := srv.users[in.Login]
@@ -92,7 +154,7 @@ func (srv *MyApi) Profile(ctx context.Context, in ProfileParams) (*User, error)
 	return user, nil
 }
 
-// apigen:api {"url": "/user/create", "auth": true, "method": "POST"}
+// apigen:api {"url": "/user/create", "auth": true, "method": "POST", "roles": ["admin", "moderator"]}
 func (srv *MyApi) Create(ctx context.Context, in CreateParams) (*NewUser, error) {
 	if in.Login == "bad_username" {
 		return nil, fmt.Errorf("bad user")
@@ -118,6 +180,27 @@ func (srv *MyApi) Create(ctx context.Context, in CreateParams) (*NewUser, error)
 	return &NewUser{id}, nil
 }
 
+// apigen:api {"url": "/user/whoami", "auth": true}
+func (srv *MyApi) Whoami(ctx context.Context, in WhoamiParams) (*User, error) {
+	id, ok := IdentityFromContext(ctx)
+	if !ok {
+		return nil, fmt.Errorf("identity not found")
+	}
+	return srv.Profile(ctx, ProfileParams{Login: id.(authUser).login})
+}
+
+type WhoamiParams struct {
+}
+
+type ProfileByLoginParams struct {
+	Login string `apivalidator:"path=login,required"`
+}
+
+// apigen:api {"url": "/user/{login}/profile", "method": "GET"}
+func (srv *MyApi) ProfileByLogin(ctx context.Context, in ProfileByLoginParams) (*User, error) {
+	return srv.Profile(ctx, ProfileParams{Login: in.Login})
+}
+
 // 2-я часть
 // это похожая структура, с теми же методами, но у них другие параметры!
 // код, созданный вашим кодогенератором работает с конкретной струткурой, про другие ничего не знает
@@ -130,6 +213,34 @@ func NewOtherApi() *OtherApi {
 	return &OtherApi{}
 }
 
+// authKey - ключ доступа с набором разрешений
+type authKey []string
+
+func (k authKey) HasScope(scope string) bool {
+	for _, s := range k {
+		if s == scope {
+			return true
+		}
+	}
+	return false
+}
+
+// checkAuthKey используется для всех сервисов пакета, у которых нет своего Authenticate.
+// Гостевой ключ пропускает анонимно в методы, которым не нужны разрешения
+//
+// apigen:authenticator
+func checkAuthKey(r *http.Request, m ApiMethod) (any, error) {
+	switch r.Header.Get("X-Auth") {
+	case "100500":
+		return authKey{"users:read", "users:write"}, nil
+	case "100501":
+		return authKey{"users:read"}, nil
+	case "guest":
+		return nil, nil
+	}
+	return nil, ApiError{http.StatusForbidden, errors.New("unauthorized")}
+}
+
 type OtherCreateParams struct {
 	Username string `apivalidator:"required,min=3"`
 	Name     string `apivalidator:"paramname=account_name"`
@@ -137,6 +248,114 @@ type OtherCreateParams struct {
 	Level    int    `apivalidator:"min=1,max=50"`
 }
 
+type OtherSetLevelParams struct {
+	ID    int `apivalidator:"path=id,>0"`
+	Level int `apivalidator:"min=1,max=50"`
+}
+
+// apigen:api {"url": "/user/{id}/level", "method": "POST", "scopes": ["users:write"]}
+func (srv *OtherApi) SetLevel(ctx context.Context, in OtherSetLevelParams) (*OtherUser, error) {
+	return &OtherUser{
+		ID:    uint64(in.ID),
+		Level: in.Level,
+	}, nil
+}
+
+type OtherSearchParams struct {
+	Online   bool    `apivalidator:"default=true"`
+	MinLevel uint8   `apivalidator:"paramname=min_level,default=1,max=50"`
+	Limit    int64   `apivalidator:"enum=10|20|50,default=10"`
+	Rating   float32 `apivalidator:">=0,<=5,default=0"`
+	Region   string  `apivalidator:"header=X-Request-Region,enum=eu|us|asia,default=eu"`
+	Locale   string  `apivalidator:"cookie=locale,enum=en|ru,default=en"`
+}
+
+type OtherSearchResult struct {
+	Online   bool    `json:"online"`
+	MinLevel uint8   `json:"min_level"`
+	Limit    int64   `json:"limit"`
+	Rating   float32 `json:"rating"`
+	Region   string  `json:"region"`
+	Locale   string  `json:"locale"`
+}
+
+// apigen:api {"url": "/user/search", "method": "GET"}
+func (srv *OtherApi) Search(ctx context.Context, in OtherSearchParams) (OtherSearchResult, error) {
+	return OtherSearchResult(in), nil
+}
+
+type OtherTagsParams struct {
+	IDs    []int     `apivalidator:"paramname=ids,csv,required,maxitems=3,unique,>0"`
+	Tags   []string  `apivalidator:"enum=pve|pvp|raid,minitems=1"`
+	Scores []float64 `apivalidator:">=0,<=1"`
+}
+
+type OtherTagsResult struct {
+	IDs    []int     `json:"ids"`
+	Tags   []string  `json:"tags"`
+	Scores []float64 `json:"scores"`
+}
+
+// apigen:api {"url": "/user/tags", "method": "POST"}
+func (srv *OtherApi) Tags(ctx context.Context, in OtherTagsParams) (OtherTagsResult, error) {
+	return OtherTagsResult(in), nil
+}
+
+type OtherPaging struct {
+	Page int `apivalidator:"default=1,min=1"`
+}
+
+type OtherGuildSettings struct {
+	Region string `apivalidator:"required,enum=eu|us|asia"`
+	Size   int    `apivalidator:"max=100"`
+}
+
+// вложенная структура передаётся как settings.region или settings[region],
+// поля встроенной структуры - как свои собственные
+type OtherGuildParams struct {
+	OtherPaging
+	Name     string `apivalidator:"required"`
+	Settings OtherGuildSettings
+}
+
+type OtherGuildResult struct {
+	Page   int    `json:"page"`
+	Name   string `json:"name"`
+	Region string `json:"region"`
+	Size   int    `json:"size"`
+}
+
+// apigen:api {"url": "/guild/create", "method": "POST"}
+func (srv *OtherApi) CreateGuild(ctx context.Context, in OtherGuildParams) (OtherGuildResult, error) {
+	return OtherGuildResult{
+		Page:   in.Page,
+		Name:   in.Name,
+		Region: in.Settings.Region,
+		Size:   in.Settings.Size,
+	}, nil
+}
+
+// те же параметры, но в ответе все ошибки сразу, а не только первая
+//
+// apigen:api {"url": "/guild/check", "method": "POST", "allErrors": true}
+func (srv *OtherApi) CheckGuild(ctx context.Context, in OtherGuildParams) (OtherGuildResult, error) {
+	return srv.CreateGuild(ctx, in)
+}
+
+// псевдоним типа из другого пакета
+type OtherRating = model.Rating
+
+// apigen:api {"url": "/user/rate", "method": "POST"}
+func (srv *OtherApi) Rate(ctx context.Context, in *model.RateParams) (*OtherRating, error) {
+	return &OtherRating{
+		Login:  in.Login,
+		Skill:  in.Skill,
+		Region: in.Region,
+		Wait:   in.Wait,
+		Since:  in.Since,
+	}, nil
+}
+
 type OtherUser struct {
 	ID       uint64 `json:"id"`
 	Login    string `json:"login"`
@@ -153,3 +372,182 @@ func (srv *OtherApi) Create(ctx context.Context, in OtherCreateParams) (*OtherUs
 		Level:    in.Level,
 	}, nil
 }
+
+// 3-я часть
+// ответы TeamApi обёрнуты в свой конверт, а коды успешных ответов зависят от метода
+
+// DataEnvelope - конверт ответов TeamApi
+type DataEnvelope struct {
+	Data any    `json:"data" apigen:"response"`
+	Ver  string `json:"ver"`
+}
+
+// TeamListEnvelope - конверт списка с полем конкретного типа
+type TeamListEnvelope struct {
+	List  TeamList `json:"list" apigen:"response"`
+	Total int      `json:"total"`
+}
+
+// apigen:api {"envelope": "DataEnvelope", "strict": true}
+type TeamApi struct {
+	teams map[string]*Team
+	mu    *sync.Mutex
+}
+
+func NewTeamApi() *TeamApi {
+	return &TeamApi{
+		teams: map[string]*Team{"alpha": {Name: "alpha", Size: 5}},
+		mu:    &sync.Mutex{},
+	}
+}
+
+type Team struct {
+	Name string `json:"name"`
+	Size int    `json:"size"`
+}
+
+type TeamList struct {
+	Names []string `json:"names"`
+}
+
+type TeamParams struct {
+	Name string `apivalidator:"required"`
+}
+
+type TeamCreateParams struct {
+	Name string `apivalidator:"required"`
+	Size int    `apivalidator:"min=1,default=1"`
+}
+
+type TeamNone struct{}
+
+// apigen:api {"url": "/team/create", "method": "POST", "status": 201, "maxBody": 128}
+func (srv *TeamApi) Create(ctx context.Context, in TeamCreateParams) (*Team, error) {
+	srv.mu.Lock()
+	defer srv.mu.Unlock()
+	if _, exists := srv.teams[in.Name]; exists {
+		return nil, ApiError{http.StatusConflict, errors.New("exists")}
+	}
+	t := &Team{Name: in.Name, Size: in.Size}
+	srv.teams[in.Name] = t
+	return t, nil
+}
+
+// apigen:api {"url": "/team/get", "method": "GET", "envelope": "none"}
+func (srv *TeamApi) Get(ctx context.Context, in TeamParams) (*Team, error) {
+	srv.mu.Lock()
+	defer srv.mu.Unlock()
+	t, ok := srv.teams[in.Name]
+	if !ok {
+		return nil, ApiError{http.StatusNotFound, errors.New("team not exist")}
+	}
+	return t, nil
+}
+
+// apigen:api {"url": "/team/list", "method": "GET", "envelope": "TeamListEnvelope"}
+func (srv *TeamApi) List(ctx context.Context, in TeamNone) (TeamList, error) {
+	srv.mu.Lock()
+	defer srv.mu.Unlock()
+	var list TeamList
+	for name := range srv.teams {
+		list.Names = append(list.Names, name)
+	}
+	sort.Strings(list.Names)
+	return list, nil
+}
+
+// apigen:api {"url": "/team/delete", "method": "POST", "status": 204}
+func (srv *TeamApi) Delete(ctx context.Context, in TeamParams) (TeamNone, error) {
+	srv.mu.Lock()
+	defer srv.mu.Unlock()
+	if _, ok := srv.teams[in.Name]; !ok {
+		return TeamNone{}, ApiError{http.StatusNotFound, errors.New("team not exist")}
+	}
+	delete(srv.teams, in.Name)
+	return TeamNone{}, nil
+}
+
+type TeamLogoParams struct {
+	Name  string                  `apivalidator:"required"`
+	Logo  *multipart.FileHeader   `apivalidator:"required,maxsize=1024,mime=image/png|image/gif"`
+	Extra []*multipart.FileHeader `apivalidator:"maxitems=2,maxsize=2048"`
+}
+
+type TeamLogo struct {
+	Name  string `json:"name"`
+	File  string `json:"file"`
+	Size  int64  `json:"size"`
+	Extra int    `json:"extra"`
+}
+
+// apigen:api {"url": "/team/logo", "method": "POST", "maxMemory": 4096}
+func (srv *TeamApi) Logo(ctx context.Context, in TeamLogoParams) (*TeamLogo, error) {
+	f, err := in.Logo.Open()
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return &TeamLogo{Name: in.Name, File: in.Logo.Filename, Size: in.Logo.Size, Extra: len(in.Extra)}, nil
+}
+
+type TeamNick string
+
+type TeamInviteParams struct {
+	Team  string   `apivalidator:"required,trim,lowercase,alphanum"`
+	Email string   `apivalidator:"required,trim,email"`
+	Site  string   `apivalidator:"url"`
+	Code  string   `apivalidator:"len=6"`
+	Token string   `apivalidator:"uuid"`
+	Nick  TeamNick `apivalidator:"trim,runemin=2,runemax=8"`
+	Tags  []string `apivalidator:"trim,lowercase,pattern=^[a-z]{2,}(-[a-z]+)*$"`
+}
+
+type TeamInvite struct {
+	Team  string   `json:"team"`
+	Email string   `json:"email"`
+	Site  string   `json:"site"`
+	Code  string   `json:"code"`
+	Token string   `json:"token"`
+	Nick  TeamNick `json:"nick"`
+	Tags  []string `json:"tags"`
+}
+
+// apigen:api {"url": "/team/invite", "method": "POST"}
+func (srv *TeamApi) Invite(ctx context.Context, in TeamInviteParams) (TeamInvite, error) {
+	return TeamInvite(in), nil
+}
+
+type TeamMatchParams struct {
+	ID       int    `apivalidator:"default=0,required_without=Name"`
+	Name     string `apivalidator:"required_without=ID"`
+	MinSkill int    `apivalidator:"default=0,min=0"`
+	MaxSkill int    `apivalidator:"default=100,gtefield=MinSkill"`
+	Region   string `apivalidator:"default=eu"`
+	Backup   string `apivalidator:"nefield=Region"`
+	Reason   string `apivalidator:"required_with=Backup"`
+}
+
+// Validate checks the params after the rules, e.g. with the service state.
+func (p *TeamMatchParams) Validate(ctx context.Context) error {
+	if p.Region == "mars" || p.Backup == "mars" {
+		return ApiError{http.StatusUnprocessableEntity, errors.New("region mars is not available")}
+	}
+	return nil
+}
+
+type TeamMatch struct {
+	Team     string `json:"team"`
+	MinSkill int    `json:"minSkill"`
+	MaxSkill int    `json:"maxSkill"`
+	Region   string `json:"region"`
+	Backup   string `json:"backup"`
+}
+
+// apigen:api {"url": "/team/match", "method": "POST"}
+func (srv *TeamApi) Match(ctx context.Context, in TeamMatchParams) (TeamMatch, error) {
+	team := in.Name
+	if team == "" {
+		team = fmt.Sprint(in.ID)
+	}
+	return TeamMatch{Team: team, MinSkill: in.MinSkill, MaxSkill: in.MaxSkill, Region: in.Region, Backup: in.Backup}, nil
+}
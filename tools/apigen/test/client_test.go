@@ -0,0 +1,251 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"apigen/test/mainclient"
+	"apigen/test/model"
+)
+
+func TestMyApiClient(t *testing.T) {
+	ts := httptest.NewServer(NewMyApi())
+	defer ts.Close()
+
+	ctx := context.Background()
+	c := mainclient.NewMyApiClient(ts.URL, ts.Client(), func(r *http.Request) {
+		r.Header.Set("X-Auth", "100500")
+	})
+
+	newUser, err := c.Create(ctx, mainclient.CreateParams{Login: "mr.moderator", Name: "Ivan Ivanov", Age: 32})
+	if err != nil {
+		t.Fatalf("Create: %v", err)
+	}
+	if newUser.ID != 43 {
+		t.Errorf("Create: got id %d, want 43", newUser.ID)
+	}
+
+	user, err := c.ProfileByLogin(ctx, mainclient.ProfileByLoginParams{Login: "mr.moderator"})
+	if err != nil {
+		t.Fatalf("ProfileByLogin: %v", err)
+	}
+	want := &mainclient.User{ID: 43, Login: "mr.moderator", FullName: "Ivan Ivanov", Status: statusUser}
+	if !reflect.DeepEqual(user, want) {
+		t.Errorf("ProfileByLogin: got %+v, want %+v", user, want)
+	}
+
+	user, err = c.Whoami(ctx, mainclient.WhoamiParams{})
+	if err != nil {
+		t.Fatalf("Whoami: %v", err)
+	}
+	if user.Login != "rvasily" {
+		t.Errorf("Whoami: got login %q, want rvasily", user.Login)
+	}
+
+	errorCases := []struct {
+		name   string
+		call   func() error
+		status int
+		msg    string
+	}{
+		{
+			name: "validation",
+			call: func() error {
+				_, err := c.Create(ctx, mainclient.CreateParams{Login: "short", Age: 32})
+				return err
+			},
+			status: http.StatusBadRequest,
+			msg:    "login len must be >= 10",
+		},
+		{
+			name: "not found",
+			call: func() error {
+				_, err := c.Profile(ctx, mainclient.ProfileParams{Login: "not_exist_user"})
+				return err
+			},
+			status: http.StatusNotFound,
+			msg:    "user not exist",
+		},
+		{
+			name: "unauthorized",
+			call: func() error {
+				_, err := mainclient.NewMyApiClient(ts.URL, nil, nil).Whoami(ctx, mainclient.WhoamiParams{})
+				return err
+			},
+			status: http.StatusForbidden,
+			msg:    "unauthorized",
+		},
+	}
+
+	for _, c := range errorCases {
+		var ae mainclient.ApiError
+		if err := c.call(); !errors.As(err, &ae) {
+			t.Errorf("%s: got error %v, want ApiError", c.name, err)
+			continue
+		}
+		if ae.HTTPStatus != c.status || ae.Error() != c.msg {
+			t.Errorf("%s: got %d %q, want %d %q", c.name, ae.HTTPStatus, ae.Error(), c.status, c.msg)
+		}
+	}
+}
+
+func TestOtherApiClient(t *testing.T) {
+	ts := httptest.NewServer(NewOtherApi())
+	defer ts.Close()
+
+	ctx := context.Background()
+	c := mainclient.NewOtherApiClient(ts.URL, ts.Client(), nil)
+
+	// json arrays
+	in := mainclient.OtherTagsParams{IDs: []int{3, 1}, Tags: []string{"pvp"}, Scores: []float64{0, 1}}
+	res, err := c.Tags(ctx, in)
+	if err != nil {
+		t.Fatalf("Tags: %v", err)
+	}
+	if want := mainclient.OtherTagsResult(in); !reflect.DeepEqual(res, want) {
+		t.Errorf("Tags: got %+v, want %+v", res, want)
+	}
+
+	_, err = c.Tags(ctx, mainclient.OtherTagsParams{IDs: []int{1, 2, 3, 4}, Tags: []string{"pvp"}})
+	var ae mainclient.ApiError
+	if !errors.As(err, &ae) || ae.HTTPStatus != http.StatusBadRequest || ae.Error() != "ids must have <= 3 items" {
+		t.Errorf("Tags: got error %v, want 400 ids must have <= 3 items", err)
+	}
+
+	// params of other package
+	since := time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+	rr, err := c.Rate(ctx, &model.RateParams{Login: "bob", Skill: 7.5, Wait: time.Second, Since: since})
+	if err != nil {
+		t.Fatalf("Rate: %v", err)
+	}
+	if want := (OtherRating{Login: "bob", Skill: 7.5, Region: "eu", Wait: time.Second, Since: since}); *rr != want {
+		t.Errorf("Rate: got %+v, want %+v", *rr, want)
+	}
+
+	// nested params are sent as json objects
+	gr, err := c.CreateGuild(ctx, mainclient.OtherGuildParams{Name: "alpha", Settings: mainclient.OtherGuildSettings{Region: "us", Size: 5}})
+	if err != nil {
+		t.Fatalf("CreateGuild: %v", err)
+	}
+	if want := (mainclient.OtherGuildResult{Page: 1, Name: "alpha", Region: "us", Size: 5}); gr != want {
+		t.Errorf("CreateGuild: got %+v, want %+v", gr, want)
+	}
+
+	_, err = c.CreateGuild(ctx, mainclient.OtherGuildParams{Name: "alpha", Settings: mainclient.OtherGuildSettings{Region: "mars"}})
+	if !errors.As(err, &ae) || ae.Error() != "settings.region must be one of [eu, us, asia]" {
+		t.Errorf("CreateGuild: got error %v, want settings.region must be one of [eu, us, asia]", err)
+	}
+	var pe *mainclient.ParamError
+	if !errors.As(ae.Err, &pe) || pe.Field != "settings.region" || pe.Rule != "enum" || pe.Params["enum"] != "eu|us|asia" {
+		t.Errorf("CreateGuild: got error %#v, want ParamError of settings.region enum", err)
+	}
+
+	_, err = c.CheckGuild(ctx, mainclient.OtherGuildParams{OtherPaging: mainclient.OtherPaging{Page: -1}, Settings: mainclient.OtherGuildSettings{Region: "mars"}})
+	var pes mainclient.ParamErrors
+	if !errors.As(err, &ae) || !errors.As(ae.Err, &pes) || len(pes) != 2 {
+		t.Fatalf("CheckGuild: got error %#v, want ParamErrors of page and settings.region", err)
+	}
+	for i, field := range []string{"page", "settings.region"} {
+		if pes[i].Field != field {
+			t.Errorf("CheckGuild: got error of %s, want %s", pes[i].Field, field)
+		}
+	}
+
+	// the key without users:write scope
+	c.Auth = func(r *http.Request) { r.Header.Set("X-Auth", "100501") }
+	_, err = c.SetLevel(ctx, mainclient.OtherSetLevelParams{ID: 12, Level: 7})
+	var ace *mainclient.AccessError
+	if !errors.As(err, &ae) || ae.HTTPStatus != http.StatusForbidden || !errors.As(ae.Err, &ace) || ace.Code != "missing_scope" {
+		t.Errorf("SetLevel: got error %#v, want 403 AccessError of missing_scope", err)
+	}
+
+	// query, zero values of params with defaults are not sent
+	sr, err := c.Search(ctx, mainclient.OtherSearchParams{MinLevel: 5, Rating: 2.5})
+	if err != nil {
+		t.Fatalf("Search: %v", err)
+	}
+	if want := (mainclient.OtherSearchResult{Online: true, MinLevel: 5, Limit: 10, Rating: 2.5, Region: "eu", Locale: "en"}); sr != want {
+		t.Errorf("Search: got %+v, want %+v", sr, want)
+	}
+
+	// header and cookie
+	sr, err = c.Search(ctx, mainclient.OtherSearchParams{Region: "us", Locale: "ru"})
+	if err != nil {
+		t.Fatalf("Search: %v", err)
+	}
+	if sr.Region != "us" || sr.Locale != "ru" {
+		t.Errorf("Search: got region %q, locale %q, want us, ru", sr.Region, sr.Locale)
+	}
+}
+
+func TestTeamApiClient(t *testing.T) {
+	ts := httptest.NewServer(NewTeamApi())
+	defer ts.Close()
+
+	ctx := context.Background()
+	c := mainclient.NewTeamApiClient(ts.URL, ts.Client(), nil)
+
+	team, err := c.Create(ctx, mainclient.TeamCreateParams{Name: "beta", Size: 3})
+	if err != nil {
+		t.Fatalf("Create: %v", err)
+	}
+	if want := (mainclient.Team{Name: "beta", Size: 3}); *team != want {
+		t.Errorf("Create: got %+v, want %+v", *team, want)
+	}
+
+	team, err = c.Get(ctx, mainclient.TeamParams{Name: "alpha"})
+	if err != nil {
+		t.Fatalf("Get: %v", err)
+	}
+	if want := (mainclient.Team{Name: "alpha", Size: 5}); *team != want {
+		t.Errorf("Get: got %+v, want %+v", *team, want)
+	}
+
+	list, err := c.List(ctx, mainclient.TeamNone{})
+	if err != nil {
+		t.Fatalf("List: %v", err)
+	}
+	if want := []string{"alpha", "beta"}; !reflect.DeepEqual(list.Names, want) {
+		t.Errorf("List: got %v, want %v", list.Names, want)
+	}
+
+	// json strings are normalized too
+	inv, err := c.Invite(ctx, mainclient.TeamInviteParams{Team: " Alpha ", Email: "bob@example.com", Code: "abc123", Nick: " bob ", Tags: []string{" PvP "}})
+	if err != nil {
+		t.Fatalf("Invite: %v", err)
+	}
+	if inv.Team != "alpha" || inv.Nick != "bob" || !reflect.DeepEqual(inv.Tags, []string{"pvp"}) {
+		t.Errorf("Invite: got %+v, want normalized team, nick and tags", inv)
+	}
+
+	if _, err := c.Delete(ctx, mainclient.TeamParams{Name: "beta"}); err != nil {
+		t.Fatalf("Delete: %v", err)
+	}
+	_, err = c.Delete(ctx, mainclient.TeamParams{Name: "beta"})
+	var ae mainclient.ApiError
+	if !errors.As(err, &ae) || ae.HTTPStatus != http.StatusNotFound || ae.Error() != "team not exist" {
+		t.Errorf("Delete: got error %v, want 404 team not exist", err)
+	}
+
+	// files are uploaded in multipart form
+	logo := &mainclient.File{Name: "logo.png", ContentType: "image/png", Content: strings.NewReader("png")}
+	extra := []*mainclient.File{{Name: "notes.txt", Content: strings.NewReader("notes")}}
+	tl, err := c.Logo(ctx, mainclient.TeamLogoParams{Name: "alpha", Logo: logo, Extra: extra})
+	if err != nil {
+		t.Fatalf("Logo: %v", err)
+	}
+	if want := (mainclient.TeamLogo{Name: "alpha", File: "logo.png", Size: 3, Extra: 1}); *tl != want {
+		t.Errorf("Logo: got %+v, want %+v", *tl, want)
+	}
+
+	_, err = c.Logo(ctx, mainclient.TeamLogoParams{Name: "alpha", Logo: &mainclient.File{Name: "logo.txt", Content: strings.NewReader("txt")}})
+	if !errors.As(err, &ae) || ae.HTTPStatus != http.StatusBadRequest || ae.Error() != "logo type must be one of [image/png, image/gif]" {
+		t.Errorf("Logo: got error %v, want 400 logo type must be one of [image/png, image/gif]", err)
+	}
+}
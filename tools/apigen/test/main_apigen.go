@@ -1,358 +1,3262 @@
 // !!! Do not change this code !!!
 // The code is generated automatically by apigen tool
+// Inputs hash: 32010bb27bf57532400f9391ab50b995c975c432c19f53a60f9c5a6c28e26b4a
 package main
 
 import (
+	"apigen/test/model"
+	"context"
 	"encoding/json"
+	"encoding/xml"
 	"errors"
-	"fmt"
 	"io"
 	"log"
+	"mime"
+	"mime/multipart"
 	"net/http"
+	"net/mail"
+	"net/url"
+	"regexp"
+	"sort"
 	"strconv"
 	"strings"
+	"time"
+	"unicode/utf8"
+
+	cbor "github.com/fxamacker/cbor/v2"
+	msgpack "github.com/vmihailenco/msgpack/v5"
 )
 
-func writeApiError(w http.ResponseWriter, ae ApiError) {
+// Encoder encodes the response body to the media type.
+type Encoder interface {
+	ContentType() string
+	Encode(w io.Writer, v any) error
+}
+
+type jsonEncoder struct{}
+
+func (jsonEncoder) ContentType() string { return "application/json" }
+
+func (jsonEncoder) Encode(w io.Writer, v any) error {
+	return json.NewEncoder(w).Encode(v)
+}
+
+type xmlEncoder struct{}
+
+func (xmlEncoder) ContentType() string { return "application/xml" }
+
+func (xmlEncoder) Encode(w io.Writer, v any) error {
+	root := xml.StartElement{Name: xml.Name{Local: "result"}}
+	return xml.NewEncoder(w).EncodeElement(v, root)
+}
+
+type msgpackEncoder struct{}
+
+func (msgpackEncoder) ContentType() string { return "application/msgpack" }
+
+func (msgpackEncoder) Encode(w io.Writer, v any) error {
+	enc := msgpack.NewEncoder(w)
+	enc.SetCustomStructTag("json")
+	return enc.Encode(v)
+}
+
+type cborEncoder struct{}
+
+func (cborEncoder) ContentType() string { return "application/cbor" }
+
+func (cborEncoder) Encode(w io.Writer, v any) error {
+	return cbor.NewEncoder(w).Encode(v)
+}
+
+// encoders of the responses, the first one is used if the request accepts any type.
+var encoders = []Encoder{jsonEncoder{}, xmlEncoder{}, msgpackEncoder{}, cborEncoder{}}
+
+// RegisterEncoder adds the encoder of the response media type or replaces
+// the registered one. It must be called before serving requests.
+func RegisterEncoder(e Encoder) {
+	for i, v := range encoders {
+		if v.ContentType() == e.ContentType() {
+			encoders[i] = e
+			return
+		}
+	}
+	encoders = append(encoders, e)
+}
+
+// negotiate returns the encoder of the most preferred media type accepted by
+// the request, the first encoder if the Accept header is absent, or nil.
+func negotiate(r *http.Request) Encoder {
+	accept := strings.Join(r.Header.Values("accept"), ",")
+	if accept == "" {
+		return encoders[0]
+	}
+	type mediaRange struct {
+		typ string
+		q   float64
+	}
+	var ranges []mediaRange
+	for _, s := range strings.Split(accept, ",") {
+		typ, params, _ := strings.Cut(s, ";")
+		mr := mediaRange{typ: strings.ToLower(strings.TrimSpace(typ)), q: 1}
+		for _, param := range strings.Split(params, ";") {
+			if k, v, ok := strings.Cut(strings.TrimSpace(param), "="); ok && k == "q" {
+				mr.q, _ = strconv.ParseFloat(v, 64)
+			}
+		}
+		if mr.q > 0 {
+			ranges = append(ranges, mr)
+		}
+	}
+	sort.SliceStable(ranges, func(i, j int) bool { return ranges[i].q > ranges[j].q })
+	for _, mr := range ranges {
+		for _, e := range encoders {
+			ct := e.ContentType()
+			if mr.typ == "*/*" || mr.typ == ct || strings.HasSuffix(mr.typ, "/*") && strings.HasPrefix(ct, mr.typ[:len(mr.typ)-1]) {
+				return e
+			}
+		}
+	}
+	return nil
+}
+
+// apiResponse is the response body of the service method.
+type apiResponse struct {
+	Response any    `json:"response" xml:"response"`
+	Error    string `json:"error" xml:"error"`
+}
+
+// apiErrorResponse is the response body of the failed request.
+type apiErrorResponse struct {
+	Error  string      `json:"error" xml:"error"`
+	Errors ParamErrors `json:"errors,omitempty" xml:"errors,omitempty"`
+}
+
+const problemContentType = "application/problem+json"
+
+// Problem is RFC 9457 problem details, written instead of {"error": "..."}
+// if the request accepts application/problem+json.
+type Problem struct {
+	Type   string            `json:"type"`
+	Title  string            `json:"title"`
+	Status int               `json:"status"`
+	Detail string            `json:"detail"`
+	Code   string            `json:"code"`
+	Field  string            `json:"field,omitempty"`
+	Rule   string            `json:"rule,omitempty"`
+	Params map[string]string `json:"params,omitempty"`
+	Errors ParamErrors       `json:"errors,omitempty"`
+}
+
+// errorCode returns the stable code of the error with the HTTP status, e.g. not_found.
+func errorCode(status int) string {
+	return strings.ToLower(strings.ReplaceAll(http.StatusText(status), " ", "_"))
+}
+
+func writeApiError(w http.ResponseWriter, r *http.Request, ae ApiError) {
 	const op = "writeApiError"
-	w.Header().Add("content-type", "application/json")
+	if strings.Contains(r.Header.Get("accept"), problemContentType) {
+		prob := Problem{
+			Type:   "about:blank",
+			Title:  http.StatusText(ae.HTTPStatus),
+			Status: ae.HTTPStatus,
+			Detail: ae.Err.Error(),
+			Code:   errorCode(ae.HTTPStatus),
+		}
+		var (
+			pes ParamErrors
+			pe  *ParamError
+			ace *AccessError
+		)
+		switch {
+		case errors.As(ae.Err, &pes):
+			prob.Code, prob.Errors = "param_errors", pes
+		case errors.As(ae.Err, &pe):
+			prob.Code, prob.Field, prob.Rule, prob.Params = pe.Code, pe.Field, pe.Rule, pe.Params
+		case errors.As(ae.Err, &ace):
+			prob.Code, prob.Params = ace.Code, ace.Params
+		}
+		w.Header().Add("content-type", problemContentType)
+		w.WriteHeader(ae.HTTPStatus)
+		if err := json.NewEncoder(w).Encode(&prob); err != nil {
+			log.Printf("%s: can't write response body: %v", op, err)
+		}
+		return
+	}
+	// errors are written even if the request accepts no encoder
+	enc := negotiate(r)
+	if enc == nil {
+		enc = encoders[0]
+	}
+	resp := apiErrorResponse{Error: ae.Err.Error()}
+	errors.As(ae.Err, &resp.Errors)
+	w.Header().Add("content-type", enc.ContentType())
 	w.WriteHeader(ae.HTTPStatus)
-	if _, err := fmt.Fprintf(w, "{\"error\":%q}", ae.Err.Error()); err != nil {
+	if err := enc.Encode(w, &resp); err != nil {
 		log.Printf("%s: can't write response body: %v", op, err)
 	}
 }
 
+// ParamError describes the invalid request param. The generated code returns
+// it as ApiError.Err with 400 status.
+type ParamError struct {
+	Code   string            `json:"code"`                     // stable error code, e.g. param_max
+	Field  string            `json:"field"`                    // api name of the param, e.g. settings.region
+	Rule   string            `json:"rule"`                     // violated rule, e.g. max
+	Params map[string]string `json:"params,omitempty" xml:"-"` // rule params, e.g. {"max": "128"}
+	Msg    string            `json:"message"`
+	Err    error             `json:"-"` // error of UnmarshalText or Validate method if any
+}
+
+func (e *ParamError) Error() string {
+	return e.Msg
+}
+
+func (e *ParamError) Unwrap() error {
+	return e.Err
+}
+
+// newParamError returns ParamError of the rule, params are key-value pairs.
+func newParamError(field, rule, msg string, params ...string) error {
+	pe := &ParamError{Code: "param_" + rule, Field: field, Rule: rule, Msg: msg}
+	for i := 0; i+1 < len(params); i += 2 {
+		if pe.Params == nil {
+			pe.Params = map[string]string{}
+		}
+		pe.Params[params[i]] = params[i+1]
+	}
+	return pe
+}
+
+// wrapParamError returns ParamError of the rule caused by err.
+func wrapParamError(field, rule, msg string, err error) error {
+	return &ParamError{Code: "param_" + rule, Field: field, Rule: rule, Msg: msg + err.Error(), Err: err}
+}
+
+// ParamErrors are all invalid params of the request, returned by the methods
+// marked with "allErrors": true instead of the first ParamError.
+type ParamErrors []*ParamError
+
+func (e ParamErrors) Error() string {
+	msgs := make([]string, len(e))
+	for i, pe := range e {
+		msgs[i] = pe.Msg
+	}
+	return strings.Join(msgs, "; ")
+}
+
+func (e ParamErrors) Unwrap() []error {
+	errs := make([]error, len(e))
+	for i, pe := range e {
+		errs[i] = pe
+	}
+	return errs
+}
+
+// add returns the err back if the errors are not accumulated (e is nil),
+// otherwise it keeps the first ParamError of every param and returns nil.
+func (e *ParamErrors) add(err error) error {
+	pe, ok := err.(*ParamError)
+	if e == nil || !ok {
+		return err
+	}
+	for _, v := range *e {
+		if v.Field == pe.Field {
+			return nil
+		}
+	}
+	*e = append(*e, pe)
+	return nil
+}
+
+// ApiMethod describes the service method called by the request.
+type ApiMethod struct {
+	Service    string
+	Name       string
+	URL        string
+	HTTPMethod string
+	Auth       bool
+	Roles      []string // the caller must have one of the roles
+	Scopes     []string // the caller must have all the scopes
+}
+
+// Middleware wraps the handler of the service method described by m. The
+// chain is set by "middleware" of apigen:api marks of the service type and
+// the method, the first middleware is outermost. It's called before Authenticator.
+type Middleware func(next http.Handler, m ApiMethod) http.Handler
+
+// Authenticator authenticates requests to the methods marked with "auth": true.
+// It returns the caller identity, which the service method can get by
+// IdentityFromContext, or an error. Use ApiError to set HTTP status (401 by default).
+type Authenticator interface {
+	Authenticate(r *http.Request, m ApiMethod) (any, error)
+}
+
+// AuthenticatorFunc is an adapter to use the ordinary func as Authenticator.
+type AuthenticatorFunc func(r *http.Request, m ApiMethod) (any, error)
+
+func (f AuthenticatorFunc) Authenticate(r *http.Request, m ApiMethod) (any, error) {
+	return f(r, m)
+}
+
+type identityKey struct{}
+
+// IdentityFromContext returns the caller identity returned by Authenticator.
+func IdentityFromContext(ctx context.Context) (any, bool) {
+	id := ctx.Value(identityKey{})
+	return id, id != nil
+}
+
+func authenticate(w http.ResponseWriter, r *http.Request, a Authenticator, m ApiMethod) (*http.Request, bool) {
+	id, err := a.Authenticate(r, m)
+	if err != nil {
+		switch err := err.(type) {
+		case *ApiError:
+			writeApiError(w, r, *err)
+		case ApiError:
+			writeApiError(w, r, err)
+		default:
+			writeApiError(w, r, ApiError{HTTPStatus: http.StatusUnauthorized, Err: err})
+		}
+		return r, false
+	}
+	return r.WithContext(context.WithValue(r.Context(), identityKey{}, id)), true
+}
+
+// RoleHolder is implemented by the identity returned by Authenticator to
+// call the methods marked with "roles".
+type RoleHolder interface {
+	HasRole(role string) bool
+}
+
+// ScopeHolder is implemented by the identity returned by Authenticator to
+// call the methods marked with "scopes".
+type ScopeHolder interface {
+	HasScope(scope string) bool
+}
+
+// AccessError describes the missing role or scope of the authenticated caller.
+// The generated code returns it as ApiError.Err with 403 status.
+type AccessError struct {
+	Code   string            // missing_role or missing_scope
+	Params map[string]string // required roles or scopes, e.g. {"roles": "admin|moderator"}
+	Msg    string
+}
+
+func (e *AccessError) Error() string {
+	return e.Msg
+}
+
+// authorize checks that the caller identity has one of the method roles and
+// all its scopes. It responds 401 if the caller is anonymous, 403 otherwise.
+func authorize(w http.ResponseWriter, r *http.Request, m ApiMethod) bool {
+	id, ok := IdentityFromContext(r.Context())
+	if !ok {
+		writeApiError(w, r, ApiError{HTTPStatus: http.StatusUnauthorized, Err: errors.New("authentication required")})
+		return false
+	}
+	if len(m.Roles) > 0 {
+		rh, ok := id.(RoleHolder)
+		allowed := false
+		for _, role := range m.Roles {
+			allowed = allowed || ok && rh.HasRole(role)
+		}
+		if !allowed {
+			err := &AccessError{
+				Code:   "missing_role",
+				Params: map[string]string{"roles": strings.Join(m.Roles, "|")},
+				Msg:    "one of roles required: " + strings.Join(m.Roles, ", "),
+			}
+			writeApiError(w, r, ApiError{HTTPStatus: http.StatusForbidden, Err: err})
+			return false
+		}
+	}
+	if len(m.Scopes) > 0 {
+		sh, ok := id.(ScopeHolder)
+		for _, scope := range m.Scopes {
+			if !ok || !sh.HasScope(scope) {
+				err := &AccessError{
+					Code:   "missing_scope",
+					Params: map[string]string{"scopes": strings.Join(m.Scopes, "|")},
+					Msg:    "scopes required: " + strings.Join(m.Scopes, ", "),
+				}
+				writeApiError(w, r, ApiError{HTTPStatus: http.StatusForbidden, Err: err})
+				return false
+			}
+		}
+	}
+	return true
+}
+
+type pathValuesKey struct{}
+
+// matchPath matches the path with the URL template like /users/{id}
+// and returns values of the template params.
+func matchPath(path, tmpl string) (map[string]string, bool) {
+	ps := strings.Split(strings.Trim(path, "/"), "/")
+	ts := strings.Split(strings.Trim(tmpl, "/"), "/")
+	if len(ps) != len(ts) {
+		return nil, false
+	}
+	vals := map[string]string{}
+	for i, t := range ts {
+		if strings.HasPrefix(t, "{") && strings.HasSuffix(t, "}") {
+			if ps[i] == "" {
+				return nil, false
+			}
+			vals[t[1:len(t)-1]] = ps[i]
+		} else if ps[i] != t {
+			return nil, false
+		}
+	}
+	return vals, true
+}
+
+func withPathValues(r *http.Request, vals map[string]string) *http.Request {
+	return r.WithContext(context.WithValue(r.Context(), pathValuesKey{}, vals))
+}
+
+func pathValue(r *http.Request, name string) string {
+	vals, _ := r.Context().Value(pathValuesKey{}).(map[string]string)
+	return vals[name]
+}
+
+// mediaType returns the media type of the request body without parameters.
+func mediaType(r *http.Request) string {
+	ct := r.Header.Get("content-type")
+	if ct == "" {
+		return ""
+	}
+	mt, _, err := mime.ParseMediaType(ct)
+	if err != nil {
+		return ct
+	}
+	return mt
+}
+
+// parseRequest limits the request body by maxBytes if not 0, checks its content type
+// and parses the form. The json body is decoded by getFromRequest of the params.
+func parseRequest(w http.ResponseWriter, r *http.Request, maxBytes, maxMemory int64) error {
+	if maxBytes > 0 {
+		r.Body = http.MaxBytesReader(w, r.Body, maxBytes)
+	}
+	switch mt := mediaType(r); mt {
+	case "application/json":
+		return nil
+	case "", "application/x-www-form-urlencoded":
+		return r.ParseForm()
+	case "multipart/form-data":
+		return r.ParseMultipartForm(maxMemory)
+	default:
+		return ApiError{HTTPStatus: http.StatusUnsupportedMediaType, Err: errors.New("unsupported content type " + mt)}
+	}
+}
+
+// requestError returns ApiError of reading the request: 413 if the body is too large,
+// the own status of ApiError or 400.
+func requestError(err error) ApiError {
+	var mbe *http.MaxBytesError
+	if errors.As(err, &mbe) {
+		msg := "request body must be <= " + strconv.FormatInt(mbe.Limit, 10) + " bytes"
+		return ApiError{HTTPStatus: http.StatusRequestEntityTooLarge, Err: errors.New(msg)}
+	}
+	switch ae := err.(type) {
+	case ApiError:
+		return ae
+	case *ApiError:
+		return *ae
+	}
+	return ApiError{HTTPStatus: http.StatusBadRequest, Err: err}
+}
+
+// formValue returns the first non-empty value of the form or query keys,
+// e.g. dotted settings.region and bracket settings[region] keys of the nested param.
+func formValue(r *http.Request, keys ...string) string {
+	for _, key := range keys {
+		if v := r.FormValue(key); v != "" {
+			return v
+		}
+	}
+	return ""
+}
+
+// formFiles returns the files uploaded in multipart form by the first of the keys having them.
+func formFiles(r *http.Request, keys ...string) []*multipart.FileHeader {
+	if r.MultipartForm == nil {
+		return nil
+	}
+	for _, key := range keys {
+		if fhs := r.MultipartForm.File[key]; len(fhs) > 0 {
+			return fhs
+		}
+	}
+	return nil
+}
+
+// cookieValue returns the value of the named cookie or empty string if there is no cookie.
+func cookieValue(r *http.Request, name string) string {
+	c, err := r.Cookie(name)
+	if err != nil {
+		return ""
+	}
+	return c.Value
+}
+
+// formValues returns all non-empty values of the repeated form or query keys.
+// If split is true, the values are also split by comma.
+func formValues(r *http.Request, split bool, keys ...string) []string {
+	var vals []string
+	for _, key := range keys {
+		_ = r.FormValue(key) // parses the form
+		for _, v := range r.Form[key] {
+			ss := []string{v}
+			if split {
+				ss = strings.Split(v, ",")
+			}
+			for _, s := range ss {
+				if s != "" {
+					vals = append(vals, s)
+				}
+			}
+		}
+	}
+	return vals
+}
+
+var (
+	apiMethodMyApiProfile        = ApiMethod{Service: "MyApi", Name: "Profile", URL: "/user/profile", HTTPMethod: "*", Auth: false}
+	apiMethodMyApiCreate         = ApiMethod{Service: "MyApi", Name: "Create", URL: "/user/create", HTTPMethod: "POST", Auth: true, Roles: []string{"admin", "moderator"}}
+	apiMethodMyApiWhoami         = ApiMethod{Service: "MyApi", Name: "Whoami", URL: "/user/whoami", HTTPMethod: "*", Auth: true}
+	apiMethodMyApiProfileByLogin = ApiMethod{Service: "MyApi", Name: "ProfileByLogin", URL: "/user/{login}/profile", HTTPMethod: "GET", Auth: false}
+)
+
 func (h *MyApi) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 	switch r.URL.Path {
 	case "/user/create":
 		switch /*r.Method*/ {
 		case strings.EqualFold(r.Method, "POST"):
-			if key := r.Header.Get("X-Auth"); key != "100500" { // XXX
-				writeApiError(w, ApiError{HTTPStatus: http.StatusForbidden, Err: errors.New("unauthorized")})
-				return
-			}
-			h.wrapperCreate(w, r)
+			h.handleCreate(w, r)
 		default:
-			writeApiError(w, ApiError{HTTPStatus: http.StatusNotAcceptable, Err: errors.New("bad method")})
+			writeApiError(w, r, ApiError{HTTPStatus: http.StatusNotAcceptable, Err: errors.New("bad method")})
 			return
 		}
 	case "/user/profile":
 		switch /*r.Method*/ {
 		default:
-			h.wrapperProfile(w, r)
+			h.handleProfile(w, r)
+		}
+	case "/user/whoami":
+		switch /*r.Method*/ {
+		default:
+			h.handleWhoami(w, r)
+		}
+	default:
+		if vals, ok := matchPath(r.URL.Path, "/user/{login}/profile"); ok {
+			r = withPathValues(r, vals)
+			switch /*r.Method*/ {
+			case strings.EqualFold(r.Method, "GET"):
+				h.handleProfileByLogin(w, r)
+			default:
+				writeApiError(w, r, ApiError{HTTPStatus: http.StatusNotAcceptable, Err: errors.New("bad method")})
+				return
+			}
+			return
+		}
+		writeApiError(w, r, ApiError{HTTPStatus: http.StatusNotFound, Err: errors.New("unknown method")})
+	}
+}
+
+func (h *MyApi) handleProfile(w http.ResponseWriter, r *http.Request) {
+	var next http.Handler = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		h.wrapperProfile(w, r)
+	})
+	next = h.methodHeader(next, apiMethodMyApiProfile)
+	next = recoverPanic(next, apiMethodMyApiProfile)
+	next.ServeHTTP(w, r)
+}
+
+func (h *MyApi) wrapperProfile(w http.ResponseWriter, r *http.Request) {
+	const op = "MyApi.wrapperProfile"
+	var params ProfileParams
+	if err := parseRequest(w, r, 0, 33554432); err != nil {
+		writeApiError(w, r, requestError(err))
+		return
+	}
+	if r.MultipartForm != nil {
+		defer r.MultipartForm.RemoveAll()
+	}
+	if err := params.getFromRequest(r, nil, false); err != nil {
+		writeApiError(w, r, requestError(err))
+		return
+	}
+	if err := params.validate(nil); err != nil {
+		writeApiError(w, r, ApiError{HTTPStatus: http.StatusBadRequest, Err: err})
+		return
+	}
+	enc := negotiate(r)
+	if enc == nil {
+		writeApiError(w, r, ApiError{HTTPStatus: http.StatusNotAcceptable, Err: errors.New("no acceptable response content type")})
+		return
+	}
+	ctx := r.Context()
+	res, err := h.Profile(ctx, params)
+	if err != nil {
+		switch err := err.(type) {
+		case *ApiError:
+			writeApiError(w, r, *err)
+		case ApiError:
+			writeApiError(w, r, err)
+		default:
+			writeApiError(w, r, ApiError{HTTPStatus: http.StatusInternalServerError, Err: err})
+		}
+		return
+	}
+	w.Header().Add("content-type", enc.ContentType())
+	w.WriteHeader(http.StatusOK)
+	if err := enc.Encode(w, &apiResponse{Response: res}); err != nil {
+		log.Printf("%s: can't write response body: %v", op, err)
+	}
+}
+
+func (h *MyApi) handleCreate(w http.ResponseWriter, r *http.Request) {
+	var next http.Handler = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		r, ok := authenticate(w, r, h, apiMethodMyApiCreate)
+		if !ok {
+			return
+		}
+		if !authorize(w, r, apiMethodMyApiCreate) {
+			return
+		}
+		h.wrapperCreate(w, r)
+	})
+	next = recoverPanic(next, apiMethodMyApiCreate)
+	next.ServeHTTP(w, r)
+}
+
+func (h *MyApi) wrapperCreate(w http.ResponseWriter, r *http.Request) {
+	const op = "MyApi.wrapperCreate"
+	var params CreateParams
+	if err := parseRequest(w, r, 0, 33554432); err != nil {
+		writeApiError(w, r, requestError(err))
+		return
+	}
+	if r.MultipartForm != nil {
+		defer r.MultipartForm.RemoveAll()
+	}
+	if err := params.getFromRequest(r, nil, false); err != nil {
+		writeApiError(w, r, requestError(err))
+		return
+	}
+	if err := params.validate(nil); err != nil {
+		writeApiError(w, r, ApiError{HTTPStatus: http.StatusBadRequest, Err: err})
+		return
+	}
+	enc := negotiate(r)
+	if enc == nil {
+		writeApiError(w, r, ApiError{HTTPStatus: http.StatusNotAcceptable, Err: errors.New("no acceptable response content type")})
+		return
+	}
+	ctx := r.Context()
+	res, err := h.Create(ctx, params)
+	if err != nil {
+		switch err := err.(type) {
+		case *ApiError:
+			writeApiError(w, r, *err)
+		case ApiError:
+			writeApiError(w, r, err)
+		default:
+			writeApiError(w, r, ApiError{HTTPStatus: http.StatusInternalServerError, Err: err})
+		}
+		return
+	}
+	w.Header().Add("content-type", enc.ContentType())
+	w.WriteHeader(http.StatusOK)
+	if err := enc.Encode(w, &apiResponse{Response: res}); err != nil {
+		log.Printf("%s: can't write response body: %v", op, err)
+	}
+}
+
+func (h *MyApi) handleWhoami(w http.ResponseWriter, r *http.Request) {
+	var next http.Handler = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		r, ok := authenticate(w, r, h, apiMethodMyApiWhoami)
+		if !ok {
+			return
+		}
+		h.wrapperWhoami(w, r)
+	})
+	next = recoverPanic(next, apiMethodMyApiWhoami)
+	next.ServeHTTP(w, r)
+}
+
+func (h *MyApi) wrapperWhoami(w http.ResponseWriter, r *http.Request) {
+	const op = "MyApi.wrapperWhoami"
+	var params WhoamiParams
+	if err := parseRequest(w, r, 0, 33554432); err != nil {
+		writeApiError(w, r, requestError(err))
+		return
+	}
+	if r.MultipartForm != nil {
+		defer r.MultipartForm.RemoveAll()
+	}
+	if err := params.getFromRequest(r, nil, false); err != nil {
+		writeApiError(w, r, requestError(err))
+		return
+	}
+	if err := params.validate(nil); err != nil {
+		writeApiError(w, r, ApiError{HTTPStatus: http.StatusBadRequest, Err: err})
+		return
+	}
+	enc := negotiate(r)
+	if enc == nil {
+		writeApiError(w, r, ApiError{HTTPStatus: http.StatusNotAcceptable, Err: errors.New("no acceptable response content type")})
+		return
+	}
+	ctx := r.Context()
+	res, err := h.Whoami(ctx, params)
+	if err != nil {
+		switch err := err.(type) {
+		case *ApiError:
+			writeApiError(w, r, *err)
+		case ApiError:
+			writeApiError(w, r, err)
+		default:
+			writeApiError(w, r, ApiError{HTTPStatus: http.StatusInternalServerError, Err: err})
+		}
+		return
+	}
+	w.Header().Add("content-type", enc.ContentType())
+	w.WriteHeader(http.StatusOK)
+	if err := enc.Encode(w, &apiResponse{Response: res}); err != nil {
+		log.Printf("%s: can't write response body: %v", op, err)
+	}
+}
+
+func (h *MyApi) handleProfileByLogin(w http.ResponseWriter, r *http.Request) {
+	var next http.Handler = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		h.wrapperProfileByLogin(w, r)
+	})
+	next = recoverPanic(next, apiMethodMyApiProfileByLogin)
+	next.ServeHTTP(w, r)
+}
+
+func (h *MyApi) wrapperProfileByLogin(w http.ResponseWriter, r *http.Request) {
+	const op = "MyApi.wrapperProfileByLogin"
+	var params ProfileByLoginParams
+	if err := parseRequest(w, r, 0, 33554432); err != nil {
+		writeApiError(w, r, requestError(err))
+		return
+	}
+	if r.MultipartForm != nil {
+		defer r.MultipartForm.RemoveAll()
+	}
+	if err := params.getFromRequest(r, nil, false); err != nil {
+		writeApiError(w, r, requestError(err))
+		return
+	}
+	if err := params.validate(nil); err != nil {
+		writeApiError(w, r, ApiError{HTTPStatus: http.StatusBadRequest, Err: err})
+		return
+	}
+	enc := negotiate(r)
+	if enc == nil {
+		writeApiError(w, r, ApiError{HTTPStatus: http.StatusNotAcceptable, Err: errors.New("no acceptable response content type")})
+		return
+	}
+	ctx := r.Context()
+	res, err := h.ProfileByLogin(ctx, params)
+	if err != nil {
+		switch err := err.(type) {
+		case *ApiError:
+			writeApiError(w, r, *err)
+		case ApiError:
+			writeApiError(w, r, err)
+		default:
+			writeApiError(w, r, ApiError{HTTPStatus: http.StatusInternalServerError, Err: err})
+		}
+		return
+	}
+	w.Header().Add("content-type", enc.ContentType())
+	w.WriteHeader(http.StatusOK)
+	if err := enc.Encode(w, &apiResponse{Response: res}); err != nil {
+		log.Printf("%s: can't write response body: %v", op, err)
+	}
+}
+
+var (
+	apiMethodOtherApiSetLevel    = ApiMethod{Service: "OtherApi", Name: "SetLevel", URL: "/user/{id}/level", HTTPMethod: "POST", Auth: true, Scopes: []string{"users:write"}}
+	apiMethodOtherApiSearch      = ApiMethod{Service: "OtherApi", Name: "Search", URL: "/user/search", HTTPMethod: "GET", Auth: false}
+	apiMethodOtherApiTags        = ApiMethod{Service: "OtherApi", Name: "Tags", URL: "/user/tags", HTTPMethod: "POST", Auth: false}
+	apiMethodOtherApiCreateGuild = ApiMethod{Service: "OtherApi", Name: "CreateGuild", URL: "/guild/create", HTTPMethod: "POST", Auth: false}
+	apiMethodOtherApiCheckGuild  = ApiMethod{Service: "OtherApi", Name: "CheckGuild", URL: "/guild/check", HTTPMethod: "POST", Auth: false}
+	apiMethodOtherApiRate        = ApiMethod{Service: "OtherApi", Name: "Rate", URL: "/user/rate", HTTPMethod: "POST", Auth: false}
+	apiMethodOtherApiCreate      = ApiMethod{Service: "OtherApi", Name: "Create", URL: "/user/create", HTTPMethod: "POST", Auth: true}
+)
+
+func (h *OtherApi) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	switch r.URL.Path {
+	case "/guild/check":
+		switch /*r.Method*/ {
+		case strings.EqualFold(r.Method, "POST"):
+			h.wrapperCheckGuild(w, r)
+		default:
+			writeApiError(w, r, ApiError{HTTPStatus: http.StatusNotAcceptable, Err: errors.New("bad method")})
+			return
+		}
+	case "/guild/create":
+		switch /*r.Method*/ {
+		case strings.EqualFold(r.Method, "POST"):
+			h.wrapperCreateGuild(w, r)
+		default:
+			writeApiError(w, r, ApiError{HTTPStatus: http.StatusNotAcceptable, Err: errors.New("bad method")})
+			return
+		}
+	case "/user/create":
+		switch /*r.Method*/ {
+		case strings.EqualFold(r.Method, "POST"):
+			r, ok := authenticate(w, r, AuthenticatorFunc(checkAuthKey), apiMethodOtherApiCreate)
+			if !ok {
+				return
+			}
+			h.wrapperCreate(w, r)
+		default:
+			writeApiError(w, r, ApiError{HTTPStatus: http.StatusNotAcceptable, Err: errors.New("bad method")})
+			return
+		}
+	case "/user/rate":
+		switch /*r.Method*/ {
+		case strings.EqualFold(r.Method, "POST"):
+			h.wrapperRate(w, r)
+		default:
+			writeApiError(w, r, ApiError{HTTPStatus: http.StatusNotAcceptable, Err: errors.New("bad method")})
+			return
+		}
+	case "/user/search":
+		switch /*r.Method*/ {
+		case strings.EqualFold(r.Method, "GET"):
+			h.wrapperSearch(w, r)
+		default:
+			writeApiError(w, r, ApiError{HTTPStatus: http.StatusNotAcceptable, Err: errors.New("bad method")})
+			return
+		}
+	case "/user/tags":
+		switch /*r.Method*/ {
+		case strings.EqualFold(r.Method, "POST"):
+			h.wrapperTags(w, r)
+		default:
+			writeApiError(w, r, ApiError{HTTPStatus: http.StatusNotAcceptable, Err: errors.New("bad method")})
+			return
+		}
+	default:
+		if vals, ok := matchPath(r.URL.Path, "/user/{id}/level"); ok {
+			r = withPathValues(r, vals)
+			switch /*r.Method*/ {
+			case strings.EqualFold(r.Method, "POST"):
+				r, ok := authenticate(w, r, AuthenticatorFunc(checkAuthKey), apiMethodOtherApiSetLevel)
+				if !ok {
+					return
+				}
+				if !authorize(w, r, apiMethodOtherApiSetLevel) {
+					return
+				}
+				h.wrapperSetLevel(w, r)
+			default:
+				writeApiError(w, r, ApiError{HTTPStatus: http.StatusNotAcceptable, Err: errors.New("bad method")})
+				return
+			}
+			return
+		}
+		writeApiError(w, r, ApiError{HTTPStatus: http.StatusNotFound, Err: errors.New("unknown method")})
+	}
+}
+
+func (h *OtherApi) wrapperSetLevel(w http.ResponseWriter, r *http.Request) {
+	const op = "OtherApi.wrapperSetLevel"
+	var params OtherSetLevelParams
+	if err := parseRequest(w, r, 0, 33554432); err != nil {
+		writeApiError(w, r, requestError(err))
+		return
+	}
+	if r.MultipartForm != nil {
+		defer r.MultipartForm.RemoveAll()
+	}
+	if err := params.getFromRequest(r, nil, false); err != nil {
+		writeApiError(w, r, requestError(err))
+		return
+	}
+	if err := params.validate(nil); err != nil {
+		writeApiError(w, r, ApiError{HTTPStatus: http.StatusBadRequest, Err: err})
+		return
+	}
+	enc := negotiate(r)
+	if enc == nil {
+		writeApiError(w, r, ApiError{HTTPStatus: http.StatusNotAcceptable, Err: errors.New("no acceptable response content type")})
+		return
+	}
+	ctx := r.Context()
+	res, err := h.SetLevel(ctx, params)
+	if err != nil {
+		switch err := err.(type) {
+		case *ApiError:
+			writeApiError(w, r, *err)
+		case ApiError:
+			writeApiError(w, r, err)
+		default:
+			writeApiError(w, r, ApiError{HTTPStatus: http.StatusInternalServerError, Err: err})
+		}
+		return
+	}
+	w.Header().Add("content-type", enc.ContentType())
+	w.WriteHeader(http.StatusOK)
+	if err := enc.Encode(w, &apiResponse{Response: res}); err != nil {
+		log.Printf("%s: can't write response body: %v", op, err)
+	}
+}
+
+func (h *OtherApi) wrapperSearch(w http.ResponseWriter, r *http.Request) {
+	const op = "OtherApi.wrapperSearch"
+	var params OtherSearchParams
+	if err := parseRequest(w, r, 0, 33554432); err != nil {
+		writeApiError(w, r, requestError(err))
+		return
+	}
+	if r.MultipartForm != nil {
+		defer r.MultipartForm.RemoveAll()
+	}
+	if err := params.getFromRequest(r, nil, false); err != nil {
+		writeApiError(w, r, requestError(err))
+		return
+	}
+	if err := params.validate(nil); err != nil {
+		writeApiError(w, r, ApiError{HTTPStatus: http.StatusBadRequest, Err: err})
+		return
+	}
+	enc := negotiate(r)
+	if enc == nil {
+		writeApiError(w, r, ApiError{HTTPStatus: http.StatusNotAcceptable, Err: errors.New("no acceptable response content type")})
+		return
+	}
+	ctx := r.Context()
+	res, err := h.Search(ctx, params)
+	if err != nil {
+		switch err := err.(type) {
+		case *ApiError:
+			writeApiError(w, r, *err)
+		case ApiError:
+			writeApiError(w, r, err)
+		default:
+			writeApiError(w, r, ApiError{HTTPStatus: http.StatusInternalServerError, Err: err})
+		}
+		return
+	}
+	w.Header().Add("content-type", enc.ContentType())
+	w.WriteHeader(http.StatusOK)
+	if err := enc.Encode(w, &apiResponse{Response: res}); err != nil {
+		log.Printf("%s: can't write response body: %v", op, err)
+	}
+}
+
+func (h *OtherApi) wrapperTags(w http.ResponseWriter, r *http.Request) {
+	const op = "OtherApi.wrapperTags"
+	var params OtherTagsParams
+	if err := parseRequest(w, r, 0, 33554432); err != nil {
+		writeApiError(w, r, requestError(err))
+		return
+	}
+	if r.MultipartForm != nil {
+		defer r.MultipartForm.RemoveAll()
+	}
+	if err := params.getFromRequest(r, nil, false); err != nil {
+		writeApiError(w, r, requestError(err))
+		return
+	}
+	if err := params.validate(nil); err != nil {
+		writeApiError(w, r, ApiError{HTTPStatus: http.StatusBadRequest, Err: err})
+		return
+	}
+	enc := negotiate(r)
+	if enc == nil {
+		writeApiError(w, r, ApiError{HTTPStatus: http.StatusNotAcceptable, Err: errors.New("no acceptable response content type")})
+		return
+	}
+	ctx := r.Context()
+	res, err := h.Tags(ctx, params)
+	if err != nil {
+		switch err := err.(type) {
+		case *ApiError:
+			writeApiError(w, r, *err)
+		case ApiError:
+			writeApiError(w, r, err)
+		default:
+			writeApiError(w, r, ApiError{HTTPStatus: http.StatusInternalServerError, Err: err})
+		}
+		return
+	}
+	w.Header().Add("content-type", enc.ContentType())
+	w.WriteHeader(http.StatusOK)
+	if err := enc.Encode(w, &apiResponse{Response: res}); err != nil {
+		log.Printf("%s: can't write response body: %v", op, err)
+	}
+}
+
+func (h *OtherApi) wrapperCreateGuild(w http.ResponseWriter, r *http.Request) {
+	const op = "OtherApi.wrapperCreateGuild"
+	var params OtherGuildParams
+	if err := parseRequest(w, r, 0, 33554432); err != nil {
+		writeApiError(w, r, requestError(err))
+		return
+	}
+	if r.MultipartForm != nil {
+		defer r.MultipartForm.RemoveAll()
+	}
+	if err := params.getFromRequest(r, nil, false); err != nil {
+		writeApiError(w, r, requestError(err))
+		return
+	}
+	if err := params.validate(nil); err != nil {
+		writeApiError(w, r, ApiError{HTTPStatus: http.StatusBadRequest, Err: err})
+		return
+	}
+	enc := negotiate(r)
+	if enc == nil {
+		writeApiError(w, r, ApiError{HTTPStatus: http.StatusNotAcceptable, Err: errors.New("no acceptable response content type")})
+		return
+	}
+	ctx := r.Context()
+	res, err := h.CreateGuild(ctx, params)
+	if err != nil {
+		switch err := err.(type) {
+		case *ApiError:
+			writeApiError(w, r, *err)
+		case ApiError:
+			writeApiError(w, r, err)
+		default:
+			writeApiError(w, r, ApiError{HTTPStatus: http.StatusInternalServerError, Err: err})
+		}
+		return
+	}
+	w.Header().Add("content-type", enc.ContentType())
+	w.WriteHeader(http.StatusOK)
+	if err := enc.Encode(w, &apiResponse{Response: res}); err != nil {
+		log.Printf("%s: can't write response body: %v", op, err)
+	}
+}
+
+func (h *OtherApi) wrapperCheckGuild(w http.ResponseWriter, r *http.Request) {
+	const op = "OtherApi.wrapperCheckGuild"
+	var params OtherGuildParams
+	var errs ParamErrors
+	if err := parseRequest(w, r, 0, 33554432); err != nil {
+		writeApiError(w, r, requestError(err))
+		return
+	}
+	if r.MultipartForm != nil {
+		defer r.MultipartForm.RemoveAll()
+	}
+	if err := params.getFromRequest(r, &errs, false); err != nil {
+		writeApiError(w, r, requestError(err))
+		return
+	}
+	if err := params.validate(&errs); err != nil {
+		writeApiError(w, r, ApiError{HTTPStatus: http.StatusBadRequest, Err: err})
+		return
+	}
+	if len(errs) > 0 {
+		writeApiError(w, r, ApiError{HTTPStatus: http.StatusBadRequest, Err: errs})
+		return
+	}
+	enc := negotiate(r)
+	if enc == nil {
+		writeApiError(w, r, ApiError{HTTPStatus: http.StatusNotAcceptable, Err: errors.New("no acceptable response content type")})
+		return
+	}
+	ctx := r.Context()
+	res, err := h.CheckGuild(ctx, params)
+	if err != nil {
+		switch err := err.(type) {
+		case *ApiError:
+			writeApiError(w, r, *err)
+		case ApiError:
+			writeApiError(w, r, err)
+		default:
+			writeApiError(w, r, ApiError{HTTPStatus: http.StatusInternalServerError, Err: err})
+		}
+		return
+	}
+	w.Header().Add("content-type", enc.ContentType())
+	w.WriteHeader(http.StatusOK)
+	if err := enc.Encode(w, &apiResponse{Response: res}); err != nil {
+		log.Printf("%s: can't write response body: %v", op, err)
+	}
+}
+
+func (h *OtherApi) wrapperRate(w http.ResponseWriter, r *http.Request) {
+	const op = "OtherApi.wrapperRate"
+	var params modelRateParams
+	if err := parseRequest(w, r, 0, 33554432); err != nil {
+		writeApiError(w, r, requestError(err))
+		return
+	}
+	if r.MultipartForm != nil {
+		defer r.MultipartForm.RemoveAll()
+	}
+	if err := params.getFromRequest(r, nil, false); err != nil {
+		writeApiError(w, r, requestError(err))
+		return
+	}
+	if err := params.validate(nil); err != nil {
+		writeApiError(w, r, ApiError{HTTPStatus: http.StatusBadRequest, Err: err})
+		return
+	}
+	enc := negotiate(r)
+	if enc == nil {
+		writeApiError(w, r, ApiError{HTTPStatus: http.StatusNotAcceptable, Err: errors.New("no acceptable response content type")})
+		return
+	}
+	ctx := r.Context()
+	res, err := h.Rate(ctx, (*model.RateParams)(&params))
+	if err != nil {
+		switch err := err.(type) {
+		case *ApiError:
+			writeApiError(w, r, *err)
+		case ApiError:
+			writeApiError(w, r, err)
+		default:
+			writeApiError(w, r, ApiError{HTTPStatus: http.StatusInternalServerError, Err: err})
+		}
+		return
+	}
+	w.Header().Add("content-type", enc.ContentType())
+	w.WriteHeader(http.StatusOK)
+	if err := enc.Encode(w, &apiResponse{Response: res}); err != nil {
+		log.Printf("%s: can't write response body: %v", op, err)
+	}
+}
+
+func (h *OtherApi) wrapperCreate(w http.ResponseWriter, r *http.Request) {
+	const op = "OtherApi.wrapperCreate"
+	var params OtherCreateParams
+	if err := parseRequest(w, r, 0, 33554432); err != nil {
+		writeApiError(w, r, requestError(err))
+		return
+	}
+	if r.MultipartForm != nil {
+		defer r.MultipartForm.RemoveAll()
+	}
+	if err := params.getFromRequest(r, nil, false); err != nil {
+		writeApiError(w, r, requestError(err))
+		return
+	}
+	if err := params.validate(nil); err != nil {
+		writeApiError(w, r, ApiError{HTTPStatus: http.StatusBadRequest, Err: err})
+		return
+	}
+	enc := negotiate(r)
+	if enc == nil {
+		writeApiError(w, r, ApiError{HTTPStatus: http.StatusNotAcceptable, Err: errors.New("no acceptable response content type")})
+		return
+	}
+	ctx := r.Context()
+	res, err := h.Create(ctx, params)
+	if err != nil {
+		switch err := err.(type) {
+		case *ApiError:
+			writeApiError(w, r, *err)
+		case ApiError:
+			writeApiError(w, r, err)
+		default:
+			writeApiError(w, r, ApiError{HTTPStatus: http.StatusInternalServerError, Err: err})
+		}
+		return
+	}
+	w.Header().Add("content-type", enc.ContentType())
+	w.WriteHeader(http.StatusOK)
+	if err := enc.Encode(w, &apiResponse{Response: res}); err != nil {
+		log.Printf("%s: can't write response body: %v", op, err)
+	}
+}
+
+var (
+	apiMethodTeamApiCreate = ApiMethod{Service: "TeamApi", Name: "Create", URL: "/team/create", HTTPMethod: "POST", Auth: false}
+	apiMethodTeamApiGet    = ApiMethod{Service: "TeamApi", Name: "Get", URL: "/team/get", HTTPMethod: "GET", Auth: false}
+	apiMethodTeamApiList   = ApiMethod{Service: "TeamApi", Name: "List", URL: "/team/list", HTTPMethod: "GET", Auth: false}
+	apiMethodTeamApiDelete = ApiMethod{Service: "TeamApi", Name: "Delete", URL: "/team/delete", HTTPMethod: "POST", Auth: false}
+	apiMethodTeamApiLogo   = ApiMethod{Service: "TeamApi", Name: "Logo", URL: "/team/logo", HTTPMethod: "POST", Auth: false}
+	apiMethodTeamApiInvite = ApiMethod{Service: "TeamApi", Name: "Invite", URL: "/team/invite", HTTPMethod: "POST", Auth: false}
+	apiMethodTeamApiMatch  = ApiMethod{Service: "TeamApi", Name: "Match", URL: "/team/match", HTTPMethod: "POST", Auth: false}
+)
+
+func (h *TeamApi) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	switch r.URL.Path {
+	case "/team/create":
+		switch /*r.Method*/ {
+		case strings.EqualFold(r.Method, "POST"):
+			h.wrapperCreate(w, r)
+		default:
+			writeApiError(w, r, ApiError{HTTPStatus: http.StatusNotAcceptable, Err: errors.New("bad method")})
+			return
+		}
+	case "/team/delete":
+		switch /*r.Method*/ {
+		case strings.EqualFold(r.Method, "POST"):
+			h.wrapperDelete(w, r)
+		default:
+			writeApiError(w, r, ApiError{HTTPStatus: http.StatusNotAcceptable, Err: errors.New("bad method")})
+			return
+		}
+	case "/team/get":
+		switch /*r.Method*/ {
+		case strings.EqualFold(r.Method, "GET"):
+			h.wrapperGet(w, r)
+		default:
+			writeApiError(w, r, ApiError{HTTPStatus: http.StatusNotAcceptable, Err: errors.New("bad method")})
+			return
+		}
+	case "/team/invite":
+		switch /*r.Method*/ {
+		case strings.EqualFold(r.Method, "POST"):
+			h.wrapperInvite(w, r)
+		default:
+			writeApiError(w, r, ApiError{HTTPStatus: http.StatusNotAcceptable, Err: errors.New("bad method")})
+			return
+		}
+	case "/team/list":
+		switch /*r.Method*/ {
+		case strings.EqualFold(r.Method, "GET"):
+			h.wrapperList(w, r)
+		default:
+			writeApiError(w, r, ApiError{HTTPStatus: http.StatusNotAcceptable, Err: errors.New("bad method")})
+			return
+		}
+	case "/team/logo":
+		switch /*r.Method*/ {
+		case strings.EqualFold(r.Method, "POST"):
+			h.wrapperLogo(w, r)
+		default:
+			writeApiError(w, r, ApiError{HTTPStatus: http.StatusNotAcceptable, Err: errors.New("bad method")})
+			return
+		}
+	case "/team/match":
+		switch /*r.Method*/ {
+		case strings.EqualFold(r.Method, "POST"):
+			h.wrapperMatch(w, r)
+		default:
+			writeApiError(w, r, ApiError{HTTPStatus: http.StatusNotAcceptable, Err: errors.New("bad method")})
+			return
+		}
+	default:
+		writeApiError(w, r, ApiError{HTTPStatus: http.StatusNotFound, Err: errors.New("unknown method")})
+	}
+}
+
+func (h *TeamApi) wrapperCreate(w http.ResponseWriter, r *http.Request) {
+	const op = "TeamApi.wrapperCreate"
+	var params TeamCreateParams
+	if err := parseRequest(w, r, 128, 33554432); err != nil {
+		writeApiError(w, r, requestError(err))
+		return
+	}
+	if r.MultipartForm != nil {
+		defer r.MultipartForm.RemoveAll()
+	}
+	if err := params.getFromRequest(r, nil, true); err != nil {
+		writeApiError(w, r, requestError(err))
+		return
+	}
+	if err := params.validate(nil); err != nil {
+		writeApiError(w, r, ApiError{HTTPStatus: http.StatusBadRequest, Err: err})
+		return
+	}
+	enc := negotiate(r)
+	if enc == nil {
+		writeApiError(w, r, ApiError{HTTPStatus: http.StatusNotAcceptable, Err: errors.New("no acceptable response content type")})
+		return
+	}
+	ctx := r.Context()
+	res, err := h.Create(ctx, params)
+	if err != nil {
+		switch err := err.(type) {
+		case *ApiError:
+			writeApiError(w, r, *err)
+		case ApiError:
+			writeApiError(w, r, err)
+		default:
+			writeApiError(w, r, ApiError{HTTPStatus: http.StatusInternalServerError, Err: err})
+		}
+		return
+	}
+	w.Header().Add("content-type", enc.ContentType())
+	w.WriteHeader(http.StatusCreated)
+	if err := enc.Encode(w, &DataEnvelope{Data: res}); err != nil {
+		log.Printf("%s: can't write response body: %v", op, err)
+	}
+}
+
+func (h *TeamApi) wrapperGet(w http.ResponseWriter, r *http.Request) {
+	const op = "TeamApi.wrapperGet"
+	var params TeamParams
+	if err := parseRequest(w, r, 0, 33554432); err != nil {
+		writeApiError(w, r, requestError(err))
+		return
+	}
+	if r.MultipartForm != nil {
+		defer r.MultipartForm.RemoveAll()
+	}
+	if err := params.getFromRequest(r, nil, true); err != nil {
+		writeApiError(w, r, requestError(err))
+		return
+	}
+	if err := params.validate(nil); err != nil {
+		writeApiError(w, r, ApiError{HTTPStatus: http.StatusBadRequest, Err: err})
+		return
+	}
+	enc := negotiate(r)
+	if enc == nil {
+		writeApiError(w, r, ApiError{HTTPStatus: http.StatusNotAcceptable, Err: errors.New("no acceptable response content type")})
+		return
+	}
+	ctx := r.Context()
+	res, err := h.Get(ctx, params)
+	if err != nil {
+		switch err := err.(type) {
+		case *ApiError:
+			writeApiError(w, r, *err)
+		case ApiError:
+			writeApiError(w, r, err)
+		default:
+			writeApiError(w, r, ApiError{HTTPStatus: http.StatusInternalServerError, Err: err})
+		}
+		return
+	}
+	w.Header().Add("content-type", enc.ContentType())
+	w.WriteHeader(http.StatusOK)
+	if err := enc.Encode(w, res); err != nil {
+		log.Printf("%s: can't write response body: %v", op, err)
+	}
+}
+
+func (h *TeamApi) wrapperList(w http.ResponseWriter, r *http.Request) {
+	const op = "TeamApi.wrapperList"
+	var params TeamNone
+	if err := parseRequest(w, r, 0, 33554432); err != nil {
+		writeApiError(w, r, requestError(err))
+		return
+	}
+	if r.MultipartForm != nil {
+		defer r.MultipartForm.RemoveAll()
+	}
+	if err := params.getFromRequest(r, nil, true); err != nil {
+		writeApiError(w, r, requestError(err))
+		return
+	}
+	if err := params.validate(nil); err != nil {
+		writeApiError(w, r, ApiError{HTTPStatus: http.StatusBadRequest, Err: err})
+		return
+	}
+	enc := negotiate(r)
+	if enc == nil {
+		writeApiError(w, r, ApiError{HTTPStatus: http.StatusNotAcceptable, Err: errors.New("no acceptable response content type")})
+		return
+	}
+	ctx := r.Context()
+	res, err := h.List(ctx, params)
+	if err != nil {
+		switch err := err.(type) {
+		case *ApiError:
+			writeApiError(w, r, *err)
+		case ApiError:
+			writeApiError(w, r, err)
+		default:
+			writeApiError(w, r, ApiError{HTTPStatus: http.StatusInternalServerError, Err: err})
+		}
+		return
+	}
+	w.Header().Add("content-type", enc.ContentType())
+	w.WriteHeader(http.StatusOK)
+	if err := enc.Encode(w, &TeamListEnvelope{List: res}); err != nil {
+		log.Printf("%s: can't write response body: %v", op, err)
+	}
+}
+
+func (h *TeamApi) wrapperDelete(w http.ResponseWriter, r *http.Request) {
+	const op = "TeamApi.wrapperDelete"
+	var params TeamParams
+	if err := parseRequest(w, r, 0, 33554432); err != nil {
+		writeApiError(w, r, requestError(err))
+		return
+	}
+	if r.MultipartForm != nil {
+		defer r.MultipartForm.RemoveAll()
+	}
+	if err := params.getFromRequest(r, nil, true); err != nil {
+		writeApiError(w, r, requestError(err))
+		return
+	}
+	if err := params.validate(nil); err != nil {
+		writeApiError(w, r, ApiError{HTTPStatus: http.StatusBadRequest, Err: err})
+		return
+	}
+	ctx := r.Context()
+	_, err := h.Delete(ctx, params)
+	if err != nil {
+		switch err := err.(type) {
+		case *ApiError:
+			writeApiError(w, r, *err)
+		case ApiError:
+			writeApiError(w, r, err)
+		default:
+			writeApiError(w, r, ApiError{HTTPStatus: http.StatusInternalServerError, Err: err})
+		}
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (h *TeamApi) wrapperLogo(w http.ResponseWriter, r *http.Request) {
+	const op = "TeamApi.wrapperLogo"
+	var params TeamLogoParams
+	if err := parseRequest(w, r, 0, 4096); err != nil {
+		writeApiError(w, r, requestError(err))
+		return
+	}
+	if r.MultipartForm != nil {
+		defer r.MultipartForm.RemoveAll()
+	}
+	if err := params.getFromRequest(r, nil, true); err != nil {
+		writeApiError(w, r, requestError(err))
+		return
+	}
+	if err := params.validate(nil); err != nil {
+		writeApiError(w, r, ApiError{HTTPStatus: http.StatusBadRequest, Err: err})
+		return
+	}
+	enc := negotiate(r)
+	if enc == nil {
+		writeApiError(w, r, ApiError{HTTPStatus: http.StatusNotAcceptable, Err: errors.New("no acceptable response content type")})
+		return
+	}
+	ctx := r.Context()
+	res, err := h.Logo(ctx, params)
+	if err != nil {
+		switch err := err.(type) {
+		case *ApiError:
+			writeApiError(w, r, *err)
+		case ApiError:
+			writeApiError(w, r, err)
+		default:
+			writeApiError(w, r, ApiError{HTTPStatus: http.StatusInternalServerError, Err: err})
+		}
+		return
+	}
+	w.Header().Add("content-type", enc.ContentType())
+	w.WriteHeader(http.StatusOK)
+	if err := enc.Encode(w, &DataEnvelope{Data: res}); err != nil {
+		log.Printf("%s: can't write response body: %v", op, err)
+	}
+}
+
+func (h *TeamApi) wrapperInvite(w http.ResponseWriter, r *http.Request) {
+	const op = "TeamApi.wrapperInvite"
+	var params TeamInviteParams
+	if err := parseRequest(w, r, 0, 33554432); err != nil {
+		writeApiError(w, r, requestError(err))
+		return
+	}
+	if r.MultipartForm != nil {
+		defer r.MultipartForm.RemoveAll()
+	}
+	if err := params.getFromRequest(r, nil, true); err != nil {
+		writeApiError(w, r, requestError(err))
+		return
+	}
+	if err := params.validate(nil); err != nil {
+		writeApiError(w, r, ApiError{HTTPStatus: http.StatusBadRequest, Err: err})
+		return
+	}
+	enc := negotiate(r)
+	if enc == nil {
+		writeApiError(w, r, ApiError{HTTPStatus: http.StatusNotAcceptable, Err: errors.New("no acceptable response content type")})
+		return
+	}
+	ctx := r.Context()
+	res, err := h.Invite(ctx, params)
+	if err != nil {
+		switch err := err.(type) {
+		case *ApiError:
+			writeApiError(w, r, *err)
+		case ApiError:
+			writeApiError(w, r, err)
+		default:
+			writeApiError(w, r, ApiError{HTTPStatus: http.StatusInternalServerError, Err: err})
+		}
+		return
+	}
+	w.Header().Add("content-type", enc.ContentType())
+	w.WriteHeader(http.StatusOK)
+	if err := enc.Encode(w, &DataEnvelope{Data: res}); err != nil {
+		log.Printf("%s: can't write response body: %v", op, err)
+	}
+}
+
+func (h *TeamApi) wrapperMatch(w http.ResponseWriter, r *http.Request) {
+	const op = "TeamApi.wrapperMatch"
+	var params TeamMatchParams
+	if err := parseRequest(w, r, 0, 33554432); err != nil {
+		writeApiError(w, r, requestError(err))
+		return
+	}
+	if r.MultipartForm != nil {
+		defer r.MultipartForm.RemoveAll()
+	}
+	if err := params.getFromRequest(r, nil, true); err != nil {
+		writeApiError(w, r, requestError(err))
+		return
+	}
+	if err := params.validate(nil); err != nil {
+		writeApiError(w, r, ApiError{HTTPStatus: http.StatusBadRequest, Err: err})
+		return
+	}
+	if err := params.Validate(r.Context()); err != nil {
+		writeApiError(w, r, requestError(err))
+		return
+	}
+	enc := negotiate(r)
+	if enc == nil {
+		writeApiError(w, r, ApiError{HTTPStatus: http.StatusNotAcceptable, Err: errors.New("no acceptable response content type")})
+		return
+	}
+	ctx := r.Context()
+	res, err := h.Match(ctx, params)
+	if err != nil {
+		switch err := err.(type) {
+		case *ApiError:
+			writeApiError(w, r, *err)
+		case ApiError:
+			writeApiError(w, r, err)
+		default:
+			writeApiError(w, r, ApiError{HTTPStatus: http.StatusInternalServerError, Err: err})
+		}
+		return
+	}
+	w.Header().Add("content-type", enc.ContentType())
+	w.WriteHeader(http.StatusOK)
+	if err := enc.Encode(w, &DataEnvelope{Data: res}); err != nil {
+		log.Printf("%s: can't write response body: %v", op, err)
+	}
+}
+
+type modelRateParams model.RateParams
+
+var (
+	patternTeamInviteParamsTags = regexp.MustCompile(`^[a-z]{2,}(-[a-z]+)*$`)
+	uuidPattern                 = regexp.MustCompile(`^[0-9a-fA-F]{8}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{12}$`)
+	alphanumPattern             = regexp.MustCompile(`^[a-zA-Z0-9]+$`)
+)
+
+func (p *CreateParams) getFromRequest(r *http.Request, errs *ParamErrors, strict bool) error {
+	if mediaType(r) == "application/json" {
+		// get from json body
+		defer io.Copy(io.Discard, r.Body)
+		var req struct {
+			Login  *string `json:"login"`
+			Name   *string `json:"full_name"`
+			Status *string `json:"status"`
+			Age    *int    `json:"age"`
+		}
+		dec := json.NewDecoder(r.Body)
+		if strict {
+			dec.DisallowUnknownFields()
+		}
+		if err := dec.Decode(&req); err != nil {
+			return /*bad json*/ err
+		}
+		if strict {
+			if _, err := dec.Token(); err != io.EOF {
+				if err == nil {
+					err = errors.New("unexpected data after json body")
+				}
+				return err
+			}
+		}
+		if req.Login != nil {
+			p.Login = *req.Login
+		} else {
+			if err := errs.add(newParamError("login", "required", "login must be not empty")); err != nil {
+				return err
+			}
+		}
+		if req.Name != nil {
+			p.Name = *req.Name
+		}
+		if req.Status != nil {
+			p.Status = *req.Status
+		} else {
+			p.Status = "user"
+		}
+		if req.Age != nil {
+			p.Age = *req.Age
+		}
+	} else {
+		// get from form or query
+		{
+			s := r.FormValue("login")
+			if s == "" {
+				if err := errs.add(newParamError("login", "required", "login must be not empty")); err != nil {
+					return err
+				}
+			}
+			p.Login = s
+		}
+		{
+			s := r.FormValue("full_name")
+			p.Name = s
+		}
+		{
+			s := r.FormValue("status")
+			if s == "" {
+				p.Status = "user"
+			} else {
+				p.Status = s
+			}
+		}
+		{
+			s := r.FormValue("age")
+			v, err := strconv.Atoi(s)
+			if errors.Is(err, strconv.ErrRange) {
+				if err := errs.add(newParamError("age", "type", "age is out of int range", "type", "int")); err != nil {
+					return err
+				}
+			}
+			if err != nil {
+				if err := errs.add(newParamError("age", "type", "age must be int", "type", "int")); err != nil {
+					return err
+				}
+			}
+			p.Age = v
+		}
+	}
+	return nil
+}
+
+func (p *CreateParams) validate(errs *ParamErrors) error {
+	if !(len(p.Login) >= 10) {
+		if err := errs.add(newParamError("login", "min", "login len must be >= 10", "min", "10")); err != nil {
+			return err
+		}
+	}
+	{
+		valid := false
+		valid = valid || p.Status == "user"
+		valid = valid || p.Status == "moderator"
+		valid = valid || p.Status == "admin"
+		if !valid {
+			if err := errs.add(newParamError("status", "enum", "status must be one of [user, moderator, admin]", "enum", "user|moderator|admin")); err != nil {
+				return err
+			}
+		}
+	}
+	if !(p.Age >= 0) {
+		if err := errs.add(newParamError("age", "min", "age must be >= 0", "min", "0")); err != nil {
+			return err
+		}
+	}
+	if !(p.Age <= 128) {
+		if err := errs.add(newParamError("age", "max", "age must be <= 128", "max", "128")); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (p *OtherCreateParams) getFromRequest(r *http.Request, errs *ParamErrors, strict bool) error {
+	if mediaType(r) == "application/json" {
+		// get from json body
+		defer io.Copy(io.Discard, r.Body)
+		var req struct {
+			Username *string `json:"username"`
+			Name     *string `json:"account_name"`
+			Class    *string `json:"class"`
+			Level    *int    `json:"level"`
+		}
+		dec := json.NewDecoder(r.Body)
+		if strict {
+			dec.DisallowUnknownFields()
+		}
+		if err := dec.Decode(&req); err != nil {
+			return /*bad json*/ err
+		}
+		if strict {
+			if _, err := dec.Token(); err != io.EOF {
+				if err == nil {
+					err = errors.New("unexpected data after json body")
+				}
+				return err
+			}
+		}
+		if req.Username != nil {
+			p.Username = *req.Username
+		} else {
+			if err := errs.add(newParamError("username", "required", "username must be not empty")); err != nil {
+				return err
+			}
+		}
+		if req.Name != nil {
+			p.Name = *req.Name
+		}
+		if req.Class != nil {
+			p.Class = *req.Class
+		} else {
+			p.Class = "warrior"
+		}
+		if req.Level != nil {
+			p.Level = *req.Level
+		}
+	} else {
+		// get from form or query
+		{
+			s := r.FormValue("username")
+			if s == "" {
+				if err := errs.add(newParamError("username", "required", "username must be not empty")); err != nil {
+					return err
+				}
+			}
+			p.Username = s
+		}
+		{
+			s := r.FormValue("account_name")
+			p.Name = s
+		}
+		{
+			s := r.FormValue("class")
+			if s == "" {
+				p.Class = "warrior"
+			} else {
+				p.Class = s
+			}
+		}
+		{
+			s := r.FormValue("level")
+			v, err := strconv.Atoi(s)
+			if errors.Is(err, strconv.ErrRange) {
+				if err := errs.add(newParamError("level", "type", "level is out of int range", "type", "int")); err != nil {
+					return err
+				}
+			}
+			if err != nil {
+				if err := errs.add(newParamError("level", "type", "level must be int", "type", "int")); err != nil {
+					return err
+				}
+			}
+			p.Level = v
+		}
+	}
+	return nil
+}
+
+func (p *OtherCreateParams) validate(errs *ParamErrors) error {
+	if !(len(p.Username) >= 3) {
+		if err := errs.add(newParamError("username", "min", "username len must be >= 3", "min", "3")); err != nil {
+			return err
+		}
+	}
+	{
+		valid := false
+		valid = valid || p.Class == "warrior"
+		valid = valid || p.Class == "sorcerer"
+		valid = valid || p.Class == "rouge"
+		if !valid {
+			if err := errs.add(newParamError("class", "enum", "class must be one of [warrior, sorcerer, rouge]", "enum", "warrior|sorcerer|rouge")); err != nil {
+				return err
+			}
+		}
+	}
+	if !(p.Level >= 1) {
+		if err := errs.add(newParamError("level", "min", "level must be >= 1", "min", "1")); err != nil {
+			return err
+		}
+	}
+	if !(p.Level <= 50) {
+		if err := errs.add(newParamError("level", "max", "level must be <= 50", "max", "50")); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (p *OtherGuildParams) getFromRequest(r *http.Request, errs *ParamErrors, strict bool) error {
+	if mediaType(r) == "application/json" {
+		// get from json body
+		defer io.Copy(io.Discard, r.Body)
+		var req struct {
+			OtherPaging_Page *int    `json:"page"`
+			Name             *string `json:"name"`
+			Settings         *struct {
+				Region *string `json:"region"`
+				Size   *int    `json:"size"`
+			} `json:"settings"`
+		}
+		dec := json.NewDecoder(r.Body)
+		if strict {
+			dec.DisallowUnknownFields()
+		}
+		if err := dec.Decode(&req); err != nil {
+			return /*bad json*/ err
+		}
+		if strict {
+			if _, err := dec.Token(); err != io.EOF {
+				if err == nil {
+					err = errors.New("unexpected data after json body")
+				}
+				return err
+			}
+		}
+		if req.OtherPaging_Page != nil {
+			p.OtherPaging.Page = *req.OtherPaging_Page
+		} else {
+			p.OtherPaging.Page = 1
+		}
+		if req.Name != nil {
+			p.Name = *req.Name
+		} else {
+			if err := errs.add(newParamError("name", "required", "name must be not empty")); err != nil {
+				return err
+			}
+		}
+		if req.Settings != nil && req.Settings.Region != nil {
+			p.Settings.Region = *req.Settings.Region
+		} else {
+			if err := errs.add(newParamError("settings.region", "required", "settings.region must be not empty")); err != nil {
+				return err
+			}
+		}
+		if req.Settings != nil && req.Settings.Size != nil {
+			p.Settings.Size = *req.Settings.Size
+		}
+	} else {
+		// get from form or query
+		{
+			s := r.FormValue("page")
+			if s == "" {
+				p.OtherPaging.Page = 1
+			} else {
+				v, err := strconv.Atoi(s)
+				if errors.Is(err, strconv.ErrRange) {
+					if err := errs.add(newParamError("page", "type", "page is out of int range", "type", "int")); err != nil {
+						return err
+					}
+				}
+				if err != nil {
+					if err := errs.add(newParamError("page", "type", "page must be int", "type", "int")); err != nil {
+						return err
+					}
+				}
+				p.OtherPaging.Page = v
+			}
+		}
+		{
+			s := r.FormValue("name")
+			if s == "" {
+				if err := errs.add(newParamError("name", "required", "name must be not empty")); err != nil {
+					return err
+				}
+			}
+			p.Name = s
+		}
+		{
+			s := formValue(r, "settings.region", "settings[region]")
+			if s == "" {
+				if err := errs.add(newParamError("settings.region", "required", "settings.region must be not empty")); err != nil {
+					return err
+				}
+			}
+			p.Settings.Region = s
+		}
+		{
+			s := formValue(r, "settings.size", "settings[size]")
+			v, err := strconv.Atoi(s)
+			if errors.Is(err, strconv.ErrRange) {
+				if err := errs.add(newParamError("settings.size", "type", "settings.size is out of int range", "type", "int")); err != nil {
+					return err
+				}
+			}
+			if err != nil {
+				if err := errs.add(newParamError("settings.size", "type", "settings.size must be int", "type", "int")); err != nil {
+					return err
+				}
+			}
+			p.Settings.Size = v
+		}
+	}
+	return nil
+}
+
+func (p *OtherGuildParams) validate(errs *ParamErrors) error {
+	if !(p.OtherPaging.Page >= 1) {
+		if err := errs.add(newParamError("page", "min", "page must be >= 1", "min", "1")); err != nil {
+			return err
+		}
+	}
+	{
+		valid := false
+		valid = valid || p.Settings.Region == "eu"
+		valid = valid || p.Settings.Region == "us"
+		valid = valid || p.Settings.Region == "asia"
+		if !valid {
+			if err := errs.add(newParamError("settings.region", "enum", "settings.region must be one of [eu, us, asia]", "enum", "eu|us|asia")); err != nil {
+				return err
+			}
+		}
+	}
+	if !(p.Settings.Size <= 100) {
+		if err := errs.add(newParamError("settings.size", "max", "settings.size must be <= 100", "max", "100")); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (p *OtherSearchParams) getFromRequest(r *http.Request, errs *ParamErrors, strict bool) error {
+	// get from headers and cookies
+	{
+		s := r.Header.Get("X-Request-Region")
+		if s == "" {
+			p.Region = "eu"
+		} else {
+			p.Region = s
+		}
+	}
+	{
+		s := cookieValue(r, "locale")
+		if s == "" {
+			p.Locale = "en"
+		} else {
+			p.Locale = s
+		}
+	}
+	if mediaType(r) == "application/json" {
+		// get from json body
+		defer io.Copy(io.Discard, r.Body)
+		var req struct {
+			Online   *bool    `json:"online"`
+			MinLevel *uint8   `json:"min_level"`
+			Limit    *int64   `json:"limit"`
+			Rating   *float32 `json:"rating"`
+		}
+		dec := json.NewDecoder(r.Body)
+		if strict {
+			dec.DisallowUnknownFields()
+		}
+		if err := dec.Decode(&req); err != nil {
+			return /*bad json*/ err
+		}
+		if strict {
+			if _, err := dec.Token(); err != io.EOF {
+				if err == nil {
+					err = errors.New("unexpected data after json body")
+				}
+				return err
+			}
+		}
+		if req.Online != nil {
+			p.Online = *req.Online
+		} else {
+			p.Online = true
+		}
+		if req.MinLevel != nil {
+			p.MinLevel = *req.MinLevel
+		} else {
+			p.MinLevel = 1
+		}
+		if req.Limit != nil {
+			p.Limit = *req.Limit
+		} else {
+			p.Limit = 10
+		}
+		if req.Rating != nil {
+			p.Rating = *req.Rating
+		} else {
+			p.Rating = 0
+		}
+	} else {
+		// get from form or query
+		{
+			s := r.FormValue("online")
+			if s == "" {
+				p.Online = true
+			} else {
+				v, err := strconv.ParseBool(s)
+				if err != nil {
+					if err := errs.add(newParamError("online", "type", "online must be bool", "type", "bool")); err != nil {
+						return err
+					}
+				}
+				p.Online = v
+			}
+		}
+		{
+			s := r.FormValue("min_level")
+			if s == "" {
+				p.MinLevel = 1
+			} else {
+				v, err := strconv.ParseUint(s, 10, 8)
+				if errors.Is(err, strconv.ErrRange) {
+					if err := errs.add(newParamError("min_level", "type", "min_level is out of uint8 range", "type", "uint8")); err != nil {
+						return err
+					}
+				}
+				if err != nil {
+					if err := errs.add(newParamError("min_level", "type", "min_level must be uint8", "type", "uint8")); err != nil {
+						return err
+					}
+				}
+				p.MinLevel = uint8(v)
+			}
+		}
+		{
+			s := r.FormValue("limit")
+			if s == "" {
+				p.Limit = 10
+			} else {
+				v, err := strconv.ParseInt(s, 10, 64)
+				if errors.Is(err, strconv.ErrRange) {
+					if err := errs.add(newParamError("limit", "type", "limit is out of int64 range", "type", "int64")); err != nil {
+						return err
+					}
+				}
+				if err != nil {
+					if err := errs.add(newParamError("limit", "type", "limit must be int64", "type", "int64")); err != nil {
+						return err
+					}
+				}
+				p.Limit = int64(v)
+			}
+		}
+		{
+			s := r.FormValue("rating")
+			if s == "" {
+				p.Rating = 0
+			} else {
+				v, err := strconv.ParseFloat(s, 32)
+				if errors.Is(err, strconv.ErrRange) {
+					if err := errs.add(newParamError("rating", "type", "rating is out of float32 range", "type", "float32")); err != nil {
+						return err
+					}
+				}
+				if err != nil {
+					if err := errs.add(newParamError("rating", "type", "rating must be float32", "type", "float32")); err != nil {
+						return err
+					}
+				}
+				p.Rating = float32(v)
+			}
+		}
+	}
+	return nil
+}
+
+func (p *OtherSearchParams) validate(errs *ParamErrors) error {
+	if !(p.MinLevel <= 50) {
+		if err := errs.add(newParamError("min_level", "max", "min_level must be <= 50", "max", "50")); err != nil {
+			return err
+		}
+	}
+	{
+		valid := false
+		valid = valid || p.Limit == 10
+		valid = valid || p.Limit == 20
+		valid = valid || p.Limit == 50
+		if !valid {
+			if err := errs.add(newParamError("limit", "enum", "limit must be one of [10, 20, 50]", "enum", "10|20|50")); err != nil {
+				return err
+			}
+		}
+	}
+	if !(p.Rating >= 0) {
+		if err := errs.add(newParamError("rating", "min", "rating must be >= 0", "min", "0")); err != nil {
+			return err
+		}
+	}
+	if !(p.Rating <= 5) {
+		if err := errs.add(newParamError("rating", "max", "rating must be <= 5", "max", "5")); err != nil {
+			return err
+		}
+	}
+	{
+		valid := false
+		valid = valid || p.Region == "eu"
+		valid = valid || p.Region == "us"
+		valid = valid || p.Region == "asia"
+		if !valid {
+			if err := errs.add(newParamError("X-Request-Region", "enum", "X-Request-Region must be one of [eu, us, asia]", "enum", "eu|us|asia")); err != nil {
+				return err
+			}
+		}
+	}
+	{
+		valid := false
+		valid = valid || p.Locale == "en"
+		valid = valid || p.Locale == "ru"
+		if !valid {
+			if err := errs.add(newParamError("locale", "enum", "locale must be one of [en, ru]", "enum", "en|ru")); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func (p *OtherSetLevelParams) getFromRequest(r *http.Request, errs *ParamErrors, strict bool) error {
+	// get from path
+	{
+		s := pathValue(r, "id")
+		v, err := strconv.Atoi(s)
+		if errors.Is(err, strconv.ErrRange) {
+			if err := errs.add(newParamError("id", "type", "id is out of int range", "type", "int")); err != nil {
+				return err
+			}
+		}
+		if err != nil {
+			if err := errs.add(newParamError("id", "type", "id must be int", "type", "int")); err != nil {
+				return err
+			}
+		}
+		p.ID = v
+	}
+	if mediaType(r) == "application/json" {
+		// get from json body
+		defer io.Copy(io.Discard, r.Body)
+		var req struct {
+			Level *int `json:"level"`
+		}
+		dec := json.NewDecoder(r.Body)
+		if strict {
+			dec.DisallowUnknownFields()
+		}
+		if err := dec.Decode(&req); err != nil {
+			return /*bad json*/ err
+		}
+		if strict {
+			if _, err := dec.Token(); err != io.EOF {
+				if err == nil {
+					err = errors.New("unexpected data after json body")
+				}
+				return err
+			}
+		}
+		if req.Level != nil {
+			p.Level = *req.Level
+		}
+	} else {
+		// get from form or query
+		{
+			s := r.FormValue("level")
+			v, err := strconv.Atoi(s)
+			if errors.Is(err, strconv.ErrRange) {
+				if err := errs.add(newParamError("level", "type", "level is out of int range", "type", "int")); err != nil {
+					return err
+				}
+			}
+			if err != nil {
+				if err := errs.add(newParamError("level", "type", "level must be int", "type", "int")); err != nil {
+					return err
+				}
+			}
+			p.Level = v
+		}
+	}
+	return nil
+}
+
+func (p *OtherSetLevelParams) validate(errs *ParamErrors) error {
+	if !(p.ID > 0) {
+		if err := errs.add(newParamError("id", "greater", "id must be > 0", "greater", "0")); err != nil {
+			return err
+		}
+	}
+	if !(p.Level >= 1) {
+		if err := errs.add(newParamError("level", "min", "level must be >= 1", "min", "1")); err != nil {
+			return err
+		}
+	}
+	if !(p.Level <= 50) {
+		if err := errs.add(newParamError("level", "max", "level must be <= 50", "max", "50")); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (p *OtherTagsParams) getFromRequest(r *http.Request, errs *ParamErrors, strict bool) error {
+	if mediaType(r) == "application/json" {
+		// get from json body
+		defer io.Copy(io.Discard, r.Body)
+		var req struct {
+			IDs    *[]int     `json:"ids"`
+			Tags   *[]string  `json:"tags"`
+			Scores *[]float64 `json:"scores"`
+		}
+		dec := json.NewDecoder(r.Body)
+		if strict {
+			dec.DisallowUnknownFields()
+		}
+		if err := dec.Decode(&req); err != nil {
+			return /*bad json*/ err
+		}
+		if strict {
+			if _, err := dec.Token(); err != io.EOF {
+				if err == nil {
+					err = errors.New("unexpected data after json body")
+				}
+				return err
+			}
+		}
+		if req.IDs != nil {
+			p.IDs = *req.IDs
+		} else {
+			if err := errs.add(newParamError("ids", "required", "ids must be not empty")); err != nil {
+				return err
+			}
+		}
+		if req.Tags != nil {
+			p.Tags = *req.Tags
+		}
+		if req.Scores != nil {
+			p.Scores = *req.Scores
+		}
+	} else {
+		// get from form or query
+		{
+			ss := formValues(r, true, "ids")
+			if len(ss) == 0 {
+				if err := errs.add(newParamError("ids", "required", "ids must be not empty")); err != nil {
+					return err
+				}
+			}
+			for _, s := range ss {
+				v, err := strconv.Atoi(s)
+				if errors.Is(err, strconv.ErrRange) {
+					if err := errs.add(newParamError("ids", "type", "ids items is out of int range", "type", "int")); err != nil {
+						return err
+					}
+				}
+				if err != nil {
+					if err := errs.add(newParamError("ids", "type", "ids items must be int", "type", "int")); err != nil {
+						return err
+					}
+				}
+				p.IDs = append(p.IDs, v)
+			}
+		}
+		{
+			ss := formValues(r, false, "tags")
+			for _, s := range ss {
+				p.Tags = append(p.Tags, s)
+			}
+		}
+		{
+			ss := formValues(r, false, "scores")
+			for _, s := range ss {
+				v, err := strconv.ParseFloat(s, 64)
+				if errors.Is(err, strconv.ErrRange) {
+					if err := errs.add(newParamError("scores", "type", "scores items is out of float64 range", "type", "float64")); err != nil {
+						return err
+					}
+				}
+				if err != nil {
+					if err := errs.add(newParamError("scores", "type", "scores items must be float64", "type", "float64")); err != nil {
+						return err
+					}
+				}
+				p.Scores = append(p.Scores, v)
+			}
+		}
+	}
+	return nil
+}
+
+func (p *OtherTagsParams) validate(errs *ParamErrors) error {
+	if !(len(p.IDs) <= 3) {
+		if err := errs.add(newParamError("ids", "maxitems", "ids must have <= 3 items", "maxitems", "3")); err != nil {
+			return err
+		}
+	}
+	{
+		seen := make(map[int]bool, len(p.IDs))
+		for _, v := range p.IDs {
+			if seen[v] {
+				if err := errs.add(newParamError("ids", "unique", "ids items must be unique")); err != nil {
+					return err
+				}
+			}
+			seen[v] = true
+		}
+	}
+	for _, v := range p.IDs {
+		if !(v > 0) {
+			if err := errs.add(newParamError("ids", "greater", "ids items must be > 0", "greater", "0")); err != nil {
+				return err
+			}
+		}
+	}
+	if !(len(p.Tags) >= 1) {
+		if err := errs.add(newParamError("tags", "minitems", "tags must have >= 1 items", "minitems", "1")); err != nil {
+			return err
+		}
+	}
+	for _, v := range p.Tags {
+		{
+			valid := false
+			valid = valid || v == "pve"
+			valid = valid || v == "pvp"
+			valid = valid || v == "raid"
+			if !valid {
+				if err := errs.add(newParamError("tags", "enum", "tags items must be one of [pve, pvp, raid]", "enum", "pve|pvp|raid")); err != nil {
+					return err
+				}
+			}
+		}
+	}
+	for _, v := range p.Scores {
+		if !(v >= 0) {
+			if err := errs.add(newParamError("scores", "min", "scores items must be >= 0", "min", "0")); err != nil {
+				return err
+			}
+		}
+		if !(v <= 1) {
+			if err := errs.add(newParamError("scores", "max", "scores items must be <= 1", "max", "1")); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func (p *ProfileByLoginParams) getFromRequest(r *http.Request, errs *ParamErrors, strict bool) error {
+	// get from path
+	{
+		s := pathValue(r, "login")
+		if s == "" {
+			if err := errs.add(newParamError("login", "required", "login must be not empty")); err != nil {
+				return err
+			}
+		}
+		p.Login = s
+	}
+	if mediaType(r) == "application/json" {
+		// get from json body
+		defer io.Copy(io.Discard, r.Body)
+		var req struct {
+		}
+		dec := json.NewDecoder(r.Body)
+		if strict {
+			dec.DisallowUnknownFields()
+		}
+		if err := dec.Decode(&req); err != nil {
+			return /*bad json*/ err
+		}
+		if strict {
+			if _, err := dec.Token(); err != io.EOF {
+				if err == nil {
+					err = errors.New("unexpected data after json body")
+				}
+				return err
+			}
+		}
+	} else {
+		// get from form or query
+	}
+	return nil
+}
+
+func (p *ProfileByLoginParams) validate(errs *ParamErrors) error {
+	return nil
+}
+
+func (p *ProfileParams) getFromRequest(r *http.Request, errs *ParamErrors, strict bool) error {
+	if mediaType(r) == "application/json" {
+		// get from json body
+		defer io.Copy(io.Discard, r.Body)
+		var req struct {
+			Login *string `json:"login"`
+		}
+		dec := json.NewDecoder(r.Body)
+		if strict {
+			dec.DisallowUnknownFields()
+		}
+		if err := dec.Decode(&req); err != nil {
+			return /*bad json*/ err
+		}
+		if strict {
+			if _, err := dec.Token(); err != io.EOF {
+				if err == nil {
+					err = errors.New("unexpected data after json body")
+				}
+				return err
+			}
+		}
+		if req.Login != nil {
+			p.Login = *req.Login
+		} else {
+			if err := errs.add(newParamError("login", "required", "login must be not empty")); err != nil {
+				return err
+			}
+		}
+	} else {
+		// get from form or query
+		{
+			s := r.FormValue("login")
+			if s == "" {
+				if err := errs.add(newParamError("login", "required", "login must be not empty")); err != nil {
+					return err
+				}
+			}
+			p.Login = s
+		}
+	}
+	return nil
+}
+
+func (p *ProfileParams) validate(errs *ParamErrors) error {
+	return nil
+}
+
+func (p *TeamCreateParams) getFromRequest(r *http.Request, errs *ParamErrors, strict bool) error {
+	if mediaType(r) == "application/json" {
+		// get from json body
+		defer io.Copy(io.Discard, r.Body)
+		var req struct {
+			Name *string `json:"name"`
+			Size *int    `json:"size"`
+		}
+		dec := json.NewDecoder(r.Body)
+		if strict {
+			dec.DisallowUnknownFields()
+		}
+		if err := dec.Decode(&req); err != nil {
+			return /*bad json*/ err
+		}
+		if strict {
+			if _, err := dec.Token(); err != io.EOF {
+				if err == nil {
+					err = errors.New("unexpected data after json body")
+				}
+				return err
+			}
+		}
+		if req.Name != nil {
+			p.Name = *req.Name
+		} else {
+			if err := errs.add(newParamError("name", "required", "name must be not empty")); err != nil {
+				return err
+			}
+		}
+		if req.Size != nil {
+			p.Size = *req.Size
+		} else {
+			p.Size = 1
+		}
+	} else {
+		// get from form or query
+		{
+			s := r.FormValue("name")
+			if s == "" {
+				if err := errs.add(newParamError("name", "required", "name must be not empty")); err != nil {
+					return err
+				}
+			}
+			p.Name = s
+		}
+		{
+			s := r.FormValue("size")
+			if s == "" {
+				p.Size = 1
+			} else {
+				v, err := strconv.Atoi(s)
+				if errors.Is(err, strconv.ErrRange) {
+					if err := errs.add(newParamError("size", "type", "size is out of int range", "type", "int")); err != nil {
+						return err
+					}
+				}
+				if err != nil {
+					if err := errs.add(newParamError("size", "type", "size must be int", "type", "int")); err != nil {
+						return err
+					}
+				}
+				p.Size = v
+			}
+		}
+	}
+	return nil
+}
+
+func (p *TeamCreateParams) validate(errs *ParamErrors) error {
+	if !(p.Size >= 1) {
+		if err := errs.add(newParamError("size", "min", "size must be >= 1", "min", "1")); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (p *TeamInviteParams) getFromRequest(r *http.Request, errs *ParamErrors, strict bool) error {
+	if mediaType(r) == "application/json" {
+		// get from json body
+		defer io.Copy(io.Discard, r.Body)
+		var req struct {
+			Team  *string   `json:"team"`
+			Email *string   `json:"email"`
+			Site  *string   `json:"site"`
+			Code  *string   `json:"code"`
+			Token *string   `json:"token"`
+			Nick  *TeamNick `json:"nick"`
+			Tags  *[]string `json:"tags"`
+		}
+		dec := json.NewDecoder(r.Body)
+		if strict {
+			dec.DisallowUnknownFields()
+		}
+		if err := dec.Decode(&req); err != nil {
+			return /*bad json*/ err
+		}
+		if strict {
+			if _, err := dec.Token(); err != io.EOF {
+				if err == nil {
+					err = errors.New("unexpected data after json body")
+				}
+				return err
+			}
+		}
+		if req.Team != nil {
+			p.Team = *req.Team
+			p.Team = strings.TrimSpace(p.Team)
+			p.Team = strings.ToLower(p.Team)
+		}
+		if p.Team == "" {
+			if err := errs.add(newParamError("team", "required", "team must be not empty")); err != nil {
+				return err
+			}
+		}
+		if req.Email != nil {
+			p.Email = *req.Email
+			p.Email = strings.TrimSpace(p.Email)
+		}
+		if p.Email == "" {
+			if err := errs.add(newParamError("email", "required", "email must be not empty")); err != nil {
+				return err
+			}
+		}
+		if req.Site != nil {
+			p.Site = *req.Site
+		}
+		if req.Code != nil {
+			p.Code = *req.Code
+		}
+		if req.Token != nil {
+			p.Token = *req.Token
+		}
+		if req.Nick != nil {
+			p.Nick = *req.Nick
+			p.Nick = TeamNick(strings.TrimSpace(string(p.Nick)))
+		}
+		if req.Tags != nil {
+			p.Tags = *req.Tags
+			for i := range p.Tags {
+				p.Tags[i] = strings.TrimSpace(p.Tags[i])
+				p.Tags[i] = strings.ToLower(p.Tags[i])
+			}
+		}
+	} else {
+		// get from form or query
+		{
+			s := r.FormValue("team")
+			s = strings.TrimSpace(s)
+			s = strings.ToLower(s)
+			if s == "" {
+				if err := errs.add(newParamError("team", "required", "team must be not empty")); err != nil {
+					return err
+				}
+			}
+			p.Team = s
+		}
+		{
+			s := r.FormValue("email")
+			s = strings.TrimSpace(s)
+			if s == "" {
+				if err := errs.add(newParamError("email", "required", "email must be not empty")); err != nil {
+					return err
+				}
+			}
+			p.Email = s
+		}
+		{
+			s := r.FormValue("site")
+			p.Site = s
+		}
+		{
+			s := r.FormValue("code")
+			p.Code = s
+		}
+		{
+			s := r.FormValue("token")
+			p.Token = s
+		}
+		{
+			s := r.FormValue("nick")
+			s = strings.TrimSpace(s)
+			p.Nick = TeamNick(s)
+		}
+		{
+			ss := formValues(r, false, "tags")
+			for _, s := range ss {
+				s = strings.TrimSpace(s)
+				s = strings.ToLower(s)
+				p.Tags = append(p.Tags, s)
+			}
 		}
-	default:
-		writeApiError(w, ApiError{HTTPStatus: http.StatusNotFound, Err: errors.New("unknown method")})
 	}
+	return nil
 }
 
-func (h *MyApi) wrapperProfile(w http.ResponseWriter, r *http.Request) {
-	const op = "MyApi.wrapperProfile"
-	var params ProfileParams
-	if err := params.getFromRequest(r); err != nil {
-		writeApiError(w, ApiError{HTTPStatus: http.StatusBadRequest, Err: err})
-		return
-	}
-	if err := params.validate(); err != nil {
-		writeApiError(w, ApiError{HTTPStatus: http.StatusBadRequest, Err: err})
-		return
-	}
-	ctx := r.Context()
-	res, err := h.Profile(ctx, params)
-	if err != nil {
-		switch err := err.(type) {
-		case *ApiError:
-			writeApiError(w, *err)
-		case ApiError:
-			writeApiError(w, err)
-		default:
-			writeApiError(w, ApiError{HTTPStatus: http.StatusInternalServerError, Err: err})
+func (p *TeamInviteParams) validate(errs *ParamErrors) error {
+	if p.Team != "" {
+		if !alphanumPattern.MatchString(p.Team) {
+			if err := errs.add(newParamError("team", "alphanum", "team must contain letters and digits only")); err != nil {
+				return err
+			}
 		}
-		return
 	}
-	resp := struct {
-		Response *User  `json:"response"`
-		Error    string `json:"error"`
-	}{
-		Response: res,
+	if p.Email != "" {
+		if a, err := mail.ParseAddress(p.Email); err != nil || a.Address != p.Email {
+			if err := errs.add(newParamError("email", "email", "email must be valid email")); err != nil {
+				return err
+			}
+		}
 	}
-	w.Header().Add("content-type", "application/json")
-	w.WriteHeader(http.StatusOK)
-	if err := json.NewEncoder(w).Encode(&resp); err != nil {
-		log.Printf("%s: can't write response body: %v", op, err)
+	if p.Site != "" {
+		if u, err := url.ParseRequestURI(p.Site); err != nil || u.Scheme == "" || u.Host == "" {
+			if err := errs.add(newParamError("site", "url", "site must be valid URL")); err != nil {
+				return err
+			}
+		}
 	}
-}
-
-func (h *MyApi) wrapperCreate(w http.ResponseWriter, r *http.Request) {
-	const op = "MyApi.wrapperCreate"
-	var params CreateParams
-	if err := params.getFromRequest(r); err != nil {
-		writeApiError(w, ApiError{HTTPStatus: http.StatusBadRequest, Err: err})
-		return
+	if !(len(p.Code) == 6) {
+		if err := errs.add(newParamError("code", "len", "code len must be 6", "len", "6")); err != nil {
+			return err
+		}
 	}
-	if err := params.validate(); err != nil {
-		writeApiError(w, ApiError{HTTPStatus: http.StatusBadRequest, Err: err})
-		return
+	if p.Token != "" {
+		if !uuidPattern.MatchString(p.Token) {
+			if err := errs.add(newParamError("token", "uuid", "token must be valid UUID")); err != nil {
+				return err
+			}
+		}
 	}
-	ctx := r.Context()
-	res, err := h.Create(ctx, params)
-	if err != nil {
-		switch err := err.(type) {
-		case *ApiError:
-			writeApiError(w, *err)
-		case ApiError:
-			writeApiError(w, err)
-		default:
-			writeApiError(w, ApiError{HTTPStatus: http.StatusInternalServerError, Err: err})
+	if !(utf8.RuneCountInString(string(p.Nick)) >= 2) {
+		if err := errs.add(newParamError("nick", "runemin", "nick must have >= 2 characters", "runemin", "2")); err != nil {
+			return err
 		}
-		return
 	}
-	resp := struct {
-		Response *NewUser `json:"response"`
-		Error    string   `json:"error"`
-	}{
-		Response: res,
+	if !(utf8.RuneCountInString(string(p.Nick)) <= 8) {
+		if err := errs.add(newParamError("nick", "runemax", "nick must have <= 8 characters", "runemax", "8")); err != nil {
+			return err
+		}
 	}
-	w.Header().Add("content-type", "application/json")
-	w.WriteHeader(http.StatusOK)
-	if err := json.NewEncoder(w).Encode(&resp); err != nil {
-		log.Printf("%s: can't write response body: %v", op, err)
+	for _, v := range p.Tags {
+		if v != "" {
+			if !patternTeamInviteParamsTags.MatchString(v) {
+				if err := errs.add(newParamError("tags", "pattern", "tags items must match pattern ^[a-z]{2,}(-[a-z]+)*$", "pattern", "^[a-z]{2,}(-[a-z]+)*$")); err != nil {
+					return err
+				}
+			}
+		}
 	}
+	return nil
 }
 
-func (h *OtherApi) ServeHTTP(w http.ResponseWriter, r *http.Request) {
-	switch r.URL.Path {
-	case "/user/create":
-		switch /*r.Method*/ {
-		case strings.EqualFold(r.Method, "POST"):
-			if key := r.Header.Get("X-Auth"); key != "100500" { // XXX
-				writeApiError(w, ApiError{HTTPStatus: http.StatusForbidden, Err: errors.New("unauthorized")})
-				return
+func (p *TeamLogoParams) getFromRequest(r *http.Request, errs *ParamErrors, strict bool) error {
+	if mediaType(r) == "application/json" {
+		// get from json body
+		defer io.Copy(io.Discard, r.Body)
+		var req struct {
+			Name *string `json:"name"`
+		}
+		dec := json.NewDecoder(r.Body)
+		if strict {
+			dec.DisallowUnknownFields()
+		}
+		if err := dec.Decode(&req); err != nil {
+			return /*bad json*/ err
+		}
+		if strict {
+			if _, err := dec.Token(); err != io.EOF {
+				if err == nil {
+					err = errors.New("unexpected data after json body")
+				}
+				return err
 			}
-			h.wrapperCreate(w, r)
-		default:
-			writeApiError(w, ApiError{HTTPStatus: http.StatusNotAcceptable, Err: errors.New("bad method")})
-			return
 		}
-	default:
-		writeApiError(w, ApiError{HTTPStatus: http.StatusNotFound, Err: errors.New("unknown method")})
+		if req.Name != nil {
+			p.Name = *req.Name
+		} else {
+			if err := errs.add(newParamError("name", "required", "name must be not empty")); err != nil {
+				return err
+			}
+		}
+		if err := errs.add(newParamError("logo", "required", "logo must be not empty")); err != nil {
+			return err
+		}
+	} else {
+		// get from form or query
+		{
+			s := r.FormValue("name")
+			if s == "" {
+				if err := errs.add(newParamError("name", "required", "name must be not empty")); err != nil {
+					return err
+				}
+			}
+			p.Name = s
+		}
+		{
+			fhs := formFiles(r, "logo")
+			if len(fhs) == 0 {
+				if err := errs.add(newParamError("logo", "required", "logo must be not empty")); err != nil {
+					return err
+				}
+			}
+			if len(fhs) > 0 {
+				p.Logo = fhs[0]
+			}
+		}
+		{
+			fhs := formFiles(r, "extra")
+			p.Extra = fhs
+		}
 	}
+	return nil
 }
 
-func (h *OtherApi) wrapperCreate(w http.ResponseWriter, r *http.Request) {
-	const op = "OtherApi.wrapperCreate"
-	var params OtherCreateParams
-	if err := params.getFromRequest(r); err != nil {
-		writeApiError(w, ApiError{HTTPStatus: http.StatusBadRequest, Err: err})
-		return
-	}
-	if err := params.validate(); err != nil {
-		writeApiError(w, ApiError{HTTPStatus: http.StatusBadRequest, Err: err})
-		return
-	}
-	ctx := r.Context()
-	res, err := h.Create(ctx, params)
-	if err != nil {
-		switch err := err.(type) {
-		case *ApiError:
-			writeApiError(w, *err)
-		case ApiError:
-			writeApiError(w, err)
-		default:
-			writeApiError(w, ApiError{HTTPStatus: http.StatusInternalServerError, Err: err})
+func (p *TeamLogoParams) validate(errs *ParamErrors) error {
+	if p.Logo != nil {
+		if !(p.Logo.Size <= 1024) {
+			if err := errs.add(newParamError("logo", "maxsize", "logo size must be <= 1024 bytes", "maxsize", "1024")); err != nil {
+				return err
+			}
+		}
+		{
+			mt, _, _ := mime.ParseMediaType(p.Logo.Header.Get("Content-Type"))
+			valid := false
+			valid = valid || mt == "image/png"
+			valid = valid || mt == "image/gif"
+			if !valid {
+				if err := errs.add(newParamError("logo", "mime", "logo type must be one of [image/png, image/gif]", "mime", "image/png|image/gif")); err != nil {
+					return err
+				}
+			}
 		}
-		return
 	}
-	resp := struct {
-		Response *OtherUser `json:"response"`
-		Error    string     `json:"error"`
-	}{
-		Response: res,
+	if !(len(p.Extra) <= 2) {
+		if err := errs.add(newParamError("extra", "maxitems", "extra must have <= 2 items", "maxitems", "2")); err != nil {
+			return err
+		}
 	}
-	w.Header().Add("content-type", "application/json")
-	w.WriteHeader(http.StatusOK)
-	if err := json.NewEncoder(w).Encode(&resp); err != nil {
-		log.Printf("%s: can't write response body: %v", op, err)
+	for _, v := range p.Extra {
+		if v != nil {
+			if !(v.Size <= 2048) {
+				if err := errs.add(newParamError("extra", "maxsize", "extra items size must be <= 2048 bytes", "maxsize", "2048")); err != nil {
+					return err
+				}
+			}
+		}
 	}
+	return nil
 }
 
-func (p *CreateParams) getFromRequest(r *http.Request) error {
-	if r.Header.Get("content-type") == "application/json" {
+func (p *TeamMatchParams) getFromRequest(r *http.Request, errs *ParamErrors, strict bool) error {
+	if mediaType(r) == "application/json" {
 		// get from json body
 		defer io.Copy(io.Discard, r.Body)
-		req := struct {
-			Login  *string `json:"login"`
-			Name   string  `json:"full_name"`
-			Status string  `json:"status"`
-			Age    int     `json:"age"`
-		}{
-			Status: "user",
+		var req struct {
+			ID       *int    `json:"id"`
+			Name     *string `json:"name"`
+			MinSkill *int    `json:"minskill"`
+			MaxSkill *int    `json:"maxskill"`
+			Region   *string `json:"region"`
+			Backup   *string `json:"backup"`
+			Reason   *string `json:"reason"`
 		}
-		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		dec := json.NewDecoder(r.Body)
+		if strict {
+			dec.DisallowUnknownFields()
+		}
+		if err := dec.Decode(&req); err != nil {
 			return /*bad json*/ err
 		}
-		if req.Login == nil {
-			return errors.New("login must be not empty")
+		if strict {
+			if _, err := dec.Token(); err != io.EOF {
+				if err == nil {
+					err = errors.New("unexpected data after json body")
+				}
+				return err
+			}
+		}
+		if req.ID != nil {
+			p.ID = *req.ID
+		} else {
+			p.ID = 0
+		}
+		if req.Name != nil {
+			p.Name = *req.Name
+		}
+		if req.MinSkill != nil {
+			p.MinSkill = *req.MinSkill
+		} else {
+			p.MinSkill = 0
+		}
+		if req.MaxSkill != nil {
+			p.MaxSkill = *req.MaxSkill
+		} else {
+			p.MaxSkill = 100
+		}
+		if req.Region != nil {
+			p.Region = *req.Region
+		} else {
+			p.Region = "eu"
+		}
+		if req.Backup != nil {
+			p.Backup = *req.Backup
+		}
+		if req.Reason != nil {
+			p.Reason = *req.Reason
 		}
-		p.Login = *req.Login
-		p.Name = req.Name
-		p.Status = req.Status
-		p.Age = req.Age
 	} else {
 		// get from form or query
 		{
-			s := r.FormValue("login")
+			s := r.FormValue("id")
 			if s == "" {
-				return errors.New("login must be not empty")
+				p.ID = 0
+			} else {
+				v, err := strconv.Atoi(s)
+				if errors.Is(err, strconv.ErrRange) {
+					if err := errs.add(newParamError("id", "type", "id is out of int range", "type", "int")); err != nil {
+						return err
+					}
+				}
+				if err != nil {
+					if err := errs.add(newParamError("id", "type", "id must be int", "type", "int")); err != nil {
+						return err
+					}
+				}
+				p.ID = v
 			}
-			p.Login = s
 		}
 		{
-			s := r.FormValue("full_name")
+			s := r.FormValue("name")
 			p.Name = s
 		}
 		{
-			s := r.FormValue("status")
+			s := r.FormValue("minskill")
 			if s == "" {
-				p.Status = "user"
+				p.MinSkill = 0
 			} else {
-				p.Status = s
+				v, err := strconv.Atoi(s)
+				if errors.Is(err, strconv.ErrRange) {
+					if err := errs.add(newParamError("minskill", "type", "minskill is out of int range", "type", "int")); err != nil {
+						return err
+					}
+				}
+				if err != nil {
+					if err := errs.add(newParamError("minskill", "type", "minskill must be int", "type", "int")); err != nil {
+						return err
+					}
+				}
+				p.MinSkill = v
 			}
 		}
 		{
-			s := r.FormValue("age")
-			v, err := strconv.Atoi(s)
-			if err != nil {
-				return errors.New("age must be int")
+			s := r.FormValue("maxskill")
+			if s == "" {
+				p.MaxSkill = 100
+			} else {
+				v, err := strconv.Atoi(s)
+				if errors.Is(err, strconv.ErrRange) {
+					if err := errs.add(newParamError("maxskill", "type", "maxskill is out of int range", "type", "int")); err != nil {
+						return err
+					}
+				}
+				if err != nil {
+					if err := errs.add(newParamError("maxskill", "type", "maxskill must be int", "type", "int")); err != nil {
+						return err
+					}
+				}
+				p.MaxSkill = v
 			}
-			p.Age = v
+		}
+		{
+			s := r.FormValue("region")
+			if s == "" {
+				p.Region = "eu"
+			} else {
+				p.Region = s
+			}
+		}
+		{
+			s := r.FormValue("backup")
+			p.Backup = s
+		}
+		{
+			s := r.FormValue("reason")
+			p.Reason = s
 		}
 	}
 	return nil
 }
 
-func (p *CreateParams) validate() error {
-	if !(len(p.Login) >= 10) {
-		return errors.New("login len must be >= 10")
+func (p *TeamMatchParams) validate(errs *ParamErrors) error {
+	if p.ID == 0 && p.Name == "" {
+		if err := errs.add(newParamError("id", "required_without", "id must be not empty if name is empty", "field", "name")); err != nil {
+			return err
+		}
 	}
-	valid := false
-	valid = valid || p.Status == "user"
-	valid = valid || p.Status == "moderator"
-	valid = valid || p.Status == "admin"
-	if !valid {
-		return errors.New("status must be one of [user, moderator, admin]")
+	if p.Name == "" && p.ID == 0 {
+		if err := errs.add(newParamError("name", "required_without", "name must be not empty if id is empty", "field", "id")); err != nil {
+			return err
+		}
 	}
-	if !(p.Age >= 0) {
-		return errors.New("age must be >= 0")
+	if !(p.MinSkill >= 0) {
+		if err := errs.add(newParamError("minskill", "min", "minskill must be >= 0", "min", "0")); err != nil {
+			return err
+		}
 	}
-	if !(p.Age <= 128) {
-		return errors.New("age must be <= 128")
+	if !(p.MaxSkill >= p.MinSkill) {
+		if err := errs.add(newParamError("maxskill", "gtefield", "maxskill must be >= minskill", "field", "minskill")); err != nil {
+			return err
+		}
+	}
+	if !(p.Backup != p.Region) {
+		if err := errs.add(newParamError("backup", "nefield", "backup must be != region", "field", "region")); err != nil {
+			return err
+		}
+	}
+	if p.Reason == "" && !(p.Backup == "") {
+		if err := errs.add(newParamError("reason", "required_with", "reason must be not empty if backup is not empty", "field", "backup")); err != nil {
+			return err
+		}
 	}
 	return nil
 }
 
-func (p *OtherCreateParams) getFromRequest(r *http.Request) error {
-	if r.Header.Get("content-type") == "application/json" {
+func (p *TeamNone) getFromRequest(r *http.Request, errs *ParamErrors, strict bool) error {
+	if mediaType(r) == "application/json" {
 		// get from json body
 		defer io.Copy(io.Discard, r.Body)
-		req := struct {
-			Username *string `json:"username"`
-			Name     string  `json:"account_name"`
-			Class    string  `json:"class"`
-			Level    int     `json:"level"`
-		}{
-			Class: "warrior",
+		var req struct {
+		}
+		dec := json.NewDecoder(r.Body)
+		if strict {
+			dec.DisallowUnknownFields()
 		}
-		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		if err := dec.Decode(&req); err != nil {
 			return /*bad json*/ err
 		}
-		if req.Username == nil {
-			return errors.New("username must be not empty")
+		if strict {
+			if _, err := dec.Token(); err != io.EOF {
+				if err == nil {
+					err = errors.New("unexpected data after json body")
+				}
+				return err
+			}
 		}
-		p.Username = *req.Username
-		p.Name = req.Name
-		p.Class = req.Class
-		p.Level = req.Level
 	} else {
 		// get from form or query
-		{
-			s := r.FormValue("username")
-			if s == "" {
-				return errors.New("username must be not empty")
+	}
+	return nil
+}
+
+func (p *TeamNone) validate(errs *ParamErrors) error {
+	return nil
+}
+
+func (p *TeamParams) getFromRequest(r *http.Request, errs *ParamErrors, strict bool) error {
+	if mediaType(r) == "application/json" {
+		// get from json body
+		defer io.Copy(io.Discard, r.Body)
+		var req struct {
+			Name *string `json:"name"`
+		}
+		dec := json.NewDecoder(r.Body)
+		if strict {
+			dec.DisallowUnknownFields()
+		}
+		if err := dec.Decode(&req); err != nil {
+			return /*bad json*/ err
+		}
+		if strict {
+			if _, err := dec.Token(); err != io.EOF {
+				if err == nil {
+					err = errors.New("unexpected data after json body")
+				}
+				return err
 			}
-			p.Username = s
 		}
-		{
-			s := r.FormValue("account_name")
-			p.Name = s
+		if req.Name != nil {
+			p.Name = *req.Name
+		} else {
+			if err := errs.add(newParamError("name", "required", "name must be not empty")); err != nil {
+				return err
+			}
 		}
+	} else {
+		// get from form or query
 		{
-			s := r.FormValue("class")
+			s := r.FormValue("name")
 			if s == "" {
-				p.Class = "warrior"
-			} else {
-				p.Class = s
+				if err := errs.add(newParamError("name", "required", "name must be not empty")); err != nil {
+					return err
+				}
 			}
+			p.Name = s
 		}
-		{
-			s := r.FormValue("level")
-			v, err := strconv.Atoi(s)
-			if err != nil {
-				return errors.New("level must be int")
+	}
+	return nil
+}
+
+func (p *TeamParams) validate(errs *ParamErrors) error {
+	return nil
+}
+
+func (p *WhoamiParams) getFromRequest(r *http.Request, errs *ParamErrors, strict bool) error {
+	if mediaType(r) == "application/json" {
+		// get from json body
+		defer io.Copy(io.Discard, r.Body)
+		var req struct {
+		}
+		dec := json.NewDecoder(r.Body)
+		if strict {
+			dec.DisallowUnknownFields()
+		}
+		if err := dec.Decode(&req); err != nil {
+			return /*bad json*/ err
+		}
+		if strict {
+			if _, err := dec.Token(); err != io.EOF {
+				if err == nil {
+					err = errors.New("unexpected data after json body")
+				}
+				return err
 			}
-			p.Level = v
 		}
+	} else {
+		// get from form or query
 	}
 	return nil
 }
 
-func (p *OtherCreateParams) validate() error {
-	if !(len(p.Username) >= 3) {
-		return errors.New("username len must be >= 3")
-	}
-	valid := false
-	valid = valid || p.Class == "warrior"
-	valid = valid || p.Class == "sorcerer"
-	valid = valid || p.Class == "rouge"
-	if !valid {
-		return errors.New("class must be one of [warrior, sorcerer, rouge]")
-	}
-	if !(p.Level >= 1) {
-		return errors.New("level must be >= 1")
-	}
-	if !(p.Level <= 50) {
-		return errors.New("level must be <= 50")
-	}
+func (p *WhoamiParams) validate(errs *ParamErrors) error {
 	return nil
 }
 
-func (p *ProfileParams) getFromRequest(r *http.Request) error {
-	if r.Header.Get("content-type") == "application/json" {
+func (p *modelRateParams) getFromRequest(r *http.Request, errs *ParamErrors, strict bool) error {
+	if mediaType(r) == "application/json" {
 		// get from json body
 		defer io.Copy(io.Discard, r.Body)
-		req := struct {
-			Login *string `json:"login"`
-		}{}
-		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		var req struct {
+			Login  *string      `json:"login"`
+			Skill  *model.Skill `json:"skill"`
+			Region *string      `json:"region"`
+			Wait   *string      `json:"wait"`
+			Since  *string      `json:"since"`
+		}
+		dec := json.NewDecoder(r.Body)
+		if strict {
+			dec.DisallowUnknownFields()
+		}
+		if err := dec.Decode(&req); err != nil {
 			return /*bad json*/ err
 		}
-		if req.Login == nil {
-			return errors.New("login must be not empty")
+		if strict {
+			if _, err := dec.Token(); err != io.EOF {
+				if err == nil {
+					err = errors.New("unexpected data after json body")
+				}
+				return err
+			}
+		}
+		if req.Login != nil {
+			p.Login = *req.Login
+		} else {
+			if err := errs.add(newParamError("login", "required", "login must be not empty")); err != nil {
+				return err
+			}
+		}
+		if req.Skill != nil {
+			p.Skill = *req.Skill
+		} else {
+			p.Skill = 5
+		}
+		{
+			var s string
+			if req.Region != nil {
+				s = *req.Region
+			}
+			if s == "" {
+				s = "eu"
+			}
+			if s != "" {
+				{
+					valid := false
+					valid = valid || s == "eu"
+					valid = valid || s == "EU"
+					valid = valid || s == "us"
+					if !valid {
+						if err := errs.add(newParamError("region", "enum", "region must be one of [eu, EU, us]", "enum", "eu|EU|us")); err != nil {
+							return err
+						}
+					}
+				}
+				var v model.Region
+				if err := v.UnmarshalText([]byte(s)); err != nil {
+					if err := errs.add(wrapParamError("region", "format", "region is invalid: ", err)); err != nil {
+						return err
+					}
+				}
+				p.Region = v
+			}
+		}
+		{
+			var s string
+			if req.Wait != nil {
+				s = *req.Wait
+			}
+			if s != "" {
+				v, err := time.ParseDuration(s)
+				if err != nil {
+					if err := errs.add(newParamError("wait", "type", "wait must be duration", "type", "duration")); err != nil {
+						return err
+					}
+				}
+				p.Wait = v
+			}
+		}
+		{
+			var s string
+			if req.Since != nil {
+				s = *req.Since
+			}
+			if s != "" {
+				var v time.Time
+				if err := v.UnmarshalText([]byte(s)); err != nil {
+					if err := errs.add(wrapParamError("since", "format", "since is invalid: ", err)); err != nil {
+						return err
+					}
+				}
+				p.Since = v
+			}
 		}
-		p.Login = *req.Login
 	} else {
 		// get from form or query
 		{
 			s := r.FormValue("login")
 			if s == "" {
-				return errors.New("login must be not empty")
+				if err := errs.add(newParamError("login", "required", "login must be not empty")); err != nil {
+					return err
+				}
 			}
 			p.Login = s
 		}
+		{
+			s := r.FormValue("skill")
+			if s == "" {
+				p.Skill = 5
+			} else {
+				v, err := strconv.ParseFloat(s, 64)
+				if errors.Is(err, strconv.ErrRange) {
+					if err := errs.add(newParamError("skill", "type", "skill is out of float64 range", "type", "float64")); err != nil {
+						return err
+					}
+				}
+				if err != nil {
+					if err := errs.add(newParamError("skill", "type", "skill must be float64", "type", "float64")); err != nil {
+						return err
+					}
+				}
+				p.Skill = model.Skill(v)
+			}
+		}
+		{
+			s := r.FormValue("region")
+			if s == "" {
+				s = "eu"
+			}
+			if s != "" {
+				{
+					valid := false
+					valid = valid || s == "eu"
+					valid = valid || s == "EU"
+					valid = valid || s == "us"
+					if !valid {
+						if err := errs.add(newParamError("region", "enum", "region must be one of [eu, EU, us]", "enum", "eu|EU|us")); err != nil {
+							return err
+						}
+					}
+				}
+				var v model.Region
+				if err := v.UnmarshalText([]byte(s)); err != nil {
+					if err := errs.add(wrapParamError("region", "format", "region is invalid: ", err)); err != nil {
+						return err
+					}
+				}
+				p.Region = v
+			}
+		}
+		{
+			s := r.FormValue("wait")
+			v, err := time.ParseDuration(s)
+			if err != nil {
+				if err := errs.add(newParamError("wait", "type", "wait must be duration", "type", "duration")); err != nil {
+					return err
+				}
+			}
+			p.Wait = v
+		}
+		{
+			s := r.FormValue("since")
+			if s != "" {
+				var v time.Time
+				if err := v.UnmarshalText([]byte(s)); err != nil {
+					if err := errs.add(wrapParamError("since", "format", "since is invalid: ", err)); err != nil {
+						return err
+					}
+				}
+				p.Since = v
+			}
+		}
 	}
 	return nil
 }
 
-func (p *ProfileParams) validate() error {
+func (p *modelRateParams) validate(errs *ParamErrors) error {
+	if !(p.Skill >= 0) {
+		if err := errs.add(newParamError("skill", "min", "skill must be >= 0", "min", "0")); err != nil {
+			return err
+		}
+	}
+	if !(p.Skill <= 10) {
+		if err := errs.add(newParamError("skill", "max", "skill must be <= 10", "max", "10")); err != nil {
+			return err
+		}
+	}
+	if err := p.Skill.Validate(); err != nil {
+		if err := errs.add(wrapParamError("skill", "validate", "skill: ", err)); err != nil {
+			return err
+		}
+	}
+	if !(p.Wait >= 0) {
+		if err := errs.add(newParamError("wait", "min", "wait must be >= 0", "min", "0")); err != nil {
+			return err
+		}
+	}
+	if !(p.Wait <= 3600000000000) {
+		if err := errs.add(newParamError("wait", "max", "wait must be <= 1h", "max", "1h")); err != nil {
+			return err
+		}
+	}
 	return nil
 }
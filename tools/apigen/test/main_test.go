@@ -1,15 +1,22 @@
 package main
 
 import (
+	"bytes"
 	"encoding/json"
+	"encoding/xml"
 	"fmt"
 	"io/ioutil"
+	"mime/multipart"
 	"net/http"
 	"net/http/httptest"
+	"net/textproto"
 	"reflect"
 	"strings"
 	"testing"
 	"time"
+
+	"github.com/fxamacker/cbor/v2"
+	"github.com/vmihailenco/msgpack/v5"
 )
 
 func CheckoutDummy(w http.ResponseWriter, r *http.Request) {
@@ -25,6 +32,9 @@ type Case struct {
 	Path   string
 	Query  string
 	Auth   bool
+	Token  string // X-Auth вместо 100500
+	Accept string
+	Header map[string]string // заголовки и куки
 	Status int
 	Result interface{}
 }
@@ -32,6 +42,7 @@ type Case struct {
 const (
 	ApiUserCreate  = "/user/create"
 	ApiUserProfile = "/user/profile"
+	ApiUserWhoami  = "/user/whoami"
 )
 
 // CaseResponse
@@ -104,6 +115,57 @@ func TestMyApi(t *testing.T) {
 			},
 		},
 		// ------
+		Case{ // identity из Authenticate доступна в методе сервиса
+			Path:   ApiUserWhoami,
+			Status: http.StatusOK,
+			Auth:   true,
+			Result: CR{
+				"error": "",
+				"response": CR{
+					"id":        42,
+					"login":     "rvasily",
+					"full_name": "Vasily Romanov",
+					"status":    20,
+				},
+			},
+		},
+		Case{
+			Path:   ApiUserWhoami,
+			Status: http.StatusForbidden,
+			Result: CR{
+				"error": "unauthorized",
+			},
+		},
+		// ------
+		Case{ // параметр из пути
+			Path:   "/user/rvasily/profile",
+			Status: http.StatusOK,
+			Result: CR{
+				"error": "",
+				"response": CR{
+					"id":        42,
+					"login":     "rvasily",
+					"full_name": "Vasily Romanov",
+					"status":    20,
+				},
+			},
+		},
+		Case{
+			Path:   "/user/rvasily/profile",
+			Method: http.MethodPost,
+			Status: http.StatusNotAcceptable,
+			Result: CR{
+				"error": "bad method",
+			},
+		},
+		Case{
+			Path:   "/user//profile",
+			Status: http.StatusNotFound,
+			Result: CR{
+				"error": "unknown method",
+			},
+		},
+		// ------
 		Case{ // создаём юзера
 			Path:   ApiUserCreate,
 			Method: http.MethodPost,
@@ -280,11 +342,962 @@ func TestOtherApi(t *testing.T) {
 				},
 			},
 		},
+		Case{ // bool, uint8, int64, float32 и значения по-умолчанию
+			Path:   "/user/search",
+			Status: http.StatusOK,
+			Result: CR{
+				"error": "",
+				"response": CR{
+					"online":    true,
+					"min_level": 1,
+					"limit":     10,
+					"rating":    0,
+					"region":    "eu",
+					"locale":    "en",
+				},
+			},
+		},
+		Case{
+			Path:   "/user/search",
+			Query:  "online=false&min_level=50&limit=50&rating=4.5",
+			Status: http.StatusOK,
+			Result: CR{
+				"error": "",
+				"response": CR{
+					"online":    false,
+					"min_level": 50,
+					"limit":     50,
+					"rating":    4.5,
+					"region":    "eu",
+					"locale":    "en",
+				},
+			},
+		},
+		Case{ // регион из заголовка, язык из куки
+			Path:   "/user/search",
+			Header: map[string]string{"X-Request-Region": "asia", "Cookie": "locale=ru"},
+			Status: http.StatusOK,
+			Result: CR{
+				"error": "",
+				"response": CR{
+					"online":    true,
+					"min_level": 1,
+					"limit":     10,
+					"rating":    0,
+					"region":    "asia",
+					"locale":    "ru",
+				},
+			},
+		},
+		Case{
+			Path:   "/user/search",
+			Header: map[string]string{"X-Request-Region": "mars"},
+			Status: http.StatusBadRequest,
+			Result: CR{
+				"error": "X-Request-Region must be one of [eu, us, asia]",
+			},
+		},
+		Case{
+			Path:   "/user/search",
+			Header: map[string]string{"Cookie": "locale=de"},
+			Status: http.StatusBadRequest,
+			Result: CR{
+				"error": "locale must be one of [en, ru]",
+			},
+		},
+		Case{
+			Path:   "/user/search",
+			Query:  "online=yes",
+			Status: http.StatusBadRequest,
+			Result: CR{
+				"error": "online must be bool",
+			},
+		},
+		Case{
+			Path:   "/user/search",
+			Query:  "min_level=256",
+			Status: http.StatusBadRequest,
+			Result: CR{
+				"error": "min_level is out of uint8 range",
+			},
+		},
+		Case{
+			Path:   "/user/search",
+			Query:  "min_level=-1",
+			Status: http.StatusBadRequest,
+			Result: CR{
+				"error": "min_level must be uint8",
+			},
+		},
+		Case{
+			Path:   "/user/search",
+			Query:  "min_level=51",
+			Status: http.StatusBadRequest,
+			Result: CR{
+				"error": "min_level must be <= 50",
+			},
+		},
+		Case{
+			Path:   "/user/search",
+			Query:  "limit=15",
+			Status: http.StatusBadRequest,
+			Result: CR{
+				"error": "limit must be one of [10, 20, 50]",
+			},
+		},
+		Case{
+			Path:   "/user/search",
+			Query:  "rating=5.5",
+			Status: http.StatusBadRequest,
+			Result: CR{
+				"error": "rating must be <= 5",
+			},
+		},
+		Case{ // параметры из другого пакета, именованные типы
+			Path:   "/user/rate",
+			Method: http.MethodPost,
+			Query:  "login=bob&skill=7.5&region=EU&wait=1m&since=2024-01-02T03:04:05Z",
+			Status: http.StatusOK,
+			Result: CR{
+				"error": "",
+				"response": CR{
+					"login":  "bob",
+					"skill":  7.5,
+					"region": "eu",
+					"wait":   60000000000,
+					"since":  "2024-01-02T03:04:05Z",
+				},
+			},
+		},
+		Case{
+			Path:   "/user/rate",
+			Method: http.MethodPost,
+			Query:  "login=bob&skill=11&wait=0s",
+			Status: http.StatusBadRequest,
+			Result: CR{
+				"error": "skill must be <= 10",
+			},
+		},
+		Case{ // Validate() типа поля
+			Path:   "/user/rate",
+			Method: http.MethodPost,
+			Query:  "login=bob&skill=7.3&wait=0s",
+			Status: http.StatusBadRequest,
+			Result: CR{
+				"error": "skill: must be a multiple of 0.5",
+			},
+		},
+		Case{ // enum проверяется по тексту, до UnmarshalText
+			Path:   "/user/rate",
+			Method: http.MethodPost,
+			Query:  "login=bob&region=asia&wait=0s",
+			Status: http.StatusBadRequest,
+			Result: CR{
+				"error": "region must be one of [eu, EU, us]",
+			},
+		},
+		Case{
+			Path:   "/user/rate",
+			Method: http.MethodPost,
+			Query:  "login=bob&wait=2h",
+			Status: http.StatusBadRequest,
+			Result: CR{
+				"error": "wait must be <= 1h",
+			},
+		},
+		Case{
+			Path:   "/user/rate",
+			Method: http.MethodPost,
+			Query:  "login=bob&wait=0s&since=yesterday",
+			Status: http.StatusBadRequest,
+			Result: CR{
+				"error": `since is invalid: parsing time "yesterday" as "2006-01-02T15:04:05Z07:00": cannot parse "yesterday" as "2006"`,
+			},
+		},
+		Case{ // вложенные параметры через точку и скобки, встроенные - как свои
+			Path:   "/guild/create",
+			Method: http.MethodPost,
+			Query:  "name=alpha&page=2&settings.region=eu&settings[size]=10",
+			Status: http.StatusOK,
+			Result: CR{
+				"error": "",
+				"response": CR{
+					"page":   2,
+					"name":   "alpha",
+					"region": "eu",
+					"size":   10,
+				},
+			},
+		},
+		Case{
+			Path:   "/guild/create",
+			Method: http.MethodPost,
+			Query:  "name=alpha&settings[region]=mars&settings.size=1",
+			Status: http.StatusBadRequest,
+			Result: CR{
+				"error": "settings.region must be one of [eu, us, asia]",
+			},
+		},
+		Case{
+			Path:   "/guild/create",
+			Method: http.MethodPost,
+			Query:  "name=alpha",
+			Status: http.StatusBadRequest,
+			Result: CR{
+				"error": "settings.region must be not empty",
+			},
+		},
+		Case{ // все ошибки параметров сразу, по одной на параметр
+			Path:   "/guild/check",
+			Method: http.MethodPost,
+			Query:  "page=0&settings[region]=mars&settings.size=1000",
+			Status: http.StatusBadRequest,
+			Result: CR{
+				"error": "name must be not empty; page must be >= 1; settings.region must be one of [eu, us, asia]; settings.size must be <= 100",
+				"errors": []interface{}{
+					CR{"code": "param_required", "field": "name", "rule": "required", "message": "name must be not empty"},
+					CR{"code": "param_min", "field": "page", "rule": "min", "params": CR{"min": "1"}, "message": "page must be >= 1"},
+					CR{"code": "param_enum", "field": "settings.region", "rule": "enum", "params": CR{"enum": "eu|us|asia"}, "message": "settings.region must be one of [eu, us, asia]"},
+					CR{"code": "param_max", "field": "settings.size", "rule": "max", "params": CR{"max": "100"}, "message": "settings.size must be <= 100"},
+				},
+			},
+		},
+		Case{ // ошибка разбора не дублируется ошибками правил
+			Path:   "/guild/check",
+			Method: http.MethodPost,
+			Query:  "name=alpha&page=first&settings.region=eu&settings.size=1",
+			Accept: "application/problem+json",
+			Status: http.StatusBadRequest,
+			Result: CR{
+				"type":   "about:blank",
+				"title":  "Bad Request",
+				"status": http.StatusBadRequest,
+				"detail": "page must be int",
+				"code":   "param_errors",
+				"errors": []interface{}{
+					CR{"code": "param_type", "field": "page", "rule": "type", "params": CR{"type": "int"}, "message": "page must be int"},
+				},
+			},
+		},
+		Case{
+			Path:   "/guild/check",
+			Method: http.MethodPost,
+			Query:  "name=alpha&settings.region=eu&settings.size=1",
+			Status: http.StatusOK,
+			Result: CR{
+				"error": "",
+				"response": CR{
+					"page":   1,
+					"name":   "alpha",
+					"region": "eu",
+					"size":   1,
+				},
+			},
+		},
+		Case{ // слайсы из повторяющихся ключей и через запятую
+			Path:   "/user/tags",
+			Method: http.MethodPost,
+			Query:  "ids=1,2&ids=3&tags=pve&tags=raid&scores=0.5",
+			Status: http.StatusOK,
+			Result: CR{
+				"error": "",
+				"response": CR{
+					"ids":    []int{1, 2, 3},
+					"tags":   []string{"pve", "raid"},
+					"scores": []float64{0.5},
+				},
+			},
+		},
+		Case{
+			Path:   "/user/tags",
+			Method: http.MethodPost,
+			Query:  "ids=1,1&tags=pve",
+			Status: http.StatusBadRequest,
+			Result: CR{
+				"error": "ids items must be unique",
+			},
+		},
+		Case{
+			Path:   "/user/tags",
+			Method: http.MethodPost,
+			Query:  "ids=1,2,3,4&tags=pve",
+			Status: http.StatusBadRequest,
+			Result: CR{
+				"error": "ids must have <= 3 items",
+			},
+		},
+		Case{
+			Path:   "/user/tags",
+			Method: http.MethodPost,
+			Query:  "ids=0&tags=pve",
+			Status: http.StatusBadRequest,
+			Result: CR{
+				"error": "ids items must be > 0",
+			},
+		},
+		Case{
+			Path:   "/user/tags",
+			Method: http.MethodPost,
+			Query:  "ids=a&tags=pve",
+			Status: http.StatusBadRequest,
+			Result: CR{
+				"error": "ids items must be int",
+			},
+		},
+		Case{
+			Path:   "/user/tags",
+			Method: http.MethodPost,
+			Query:  "tags=pve",
+			Status: http.StatusBadRequest,
+			Result: CR{
+				"error": "ids must be not empty",
+			},
+		},
+		Case{
+			Path:   "/user/tags",
+			Method: http.MethodPost,
+			Query:  "ids=1",
+			Status: http.StatusBadRequest,
+			Result: CR{
+				"error": "tags must have >= 1 items",
+			},
+		},
+		Case{
+			Path:   "/user/tags",
+			Method: http.MethodPost,
+			Query:  "ids=1&tags=pvx",
+			Status: http.StatusBadRequest,
+			Result: CR{
+				"error": "tags items must be one of [pve, pvp, raid]",
+			},
+		},
+		Case{
+			Path:   "/user/tags",
+			Method: http.MethodPost,
+			Query:  "ids=1&tags=pve&scores=1.5",
+			Status: http.StatusBadRequest,
+			Result: CR{
+				"error": "scores items must be <= 1",
+			},
+		},
+		Case{ // параметр из пути с преобразованием типа
+			Path:   "/user/12/level",
+			Method: http.MethodPost,
+			Query:  "level=7",
+			Status: http.StatusOK,
+			Auth:   true,
+			Result: CR{
+				"error": "",
+				"response": CR{
+					"id":        12,
+					"login":     "",
+					"full_name": "",
+					"level":     7,
+				},
+			},
+		},
+		Case{
+			Path:   "/user/twelve/level",
+			Method: http.MethodPost,
+			Query:  "level=7",
+			Status: http.StatusBadRequest,
+			Auth:   true,
+			Result: CR{
+				"error": "id must be int",
+			},
+		},
+		Case{
+			Path:   "/user/0/level",
+			Method: http.MethodPost,
+			Query:  "level=7",
+			Status: http.StatusBadRequest,
+			Auth:   true,
+			Result: CR{
+				"error": "id must be > 0",
+			},
+		},
+		Case{ // структурированные ошибки в формате RFC 9457
+			Path:   "/user/tags",
+			Method: http.MethodPost,
+			Query:  "ids=1,2,3,4&tags=pve",
+			Accept: "application/problem+json",
+			Status: http.StatusBadRequest,
+			Result: CR{
+				"type":   "about:blank",
+				"title":  "Bad Request",
+				"status": http.StatusBadRequest,
+				"detail": "ids must have <= 3 items",
+				"code":   "param_maxitems",
+				"field":  "ids",
+				"rule":   "maxitems",
+				"params": CR{"maxitems": "3"},
+			},
+		},
+		Case{
+			Path:   "/guild/create",
+			Method: http.MethodPost,
+			Query:  "name=alpha&settings[region]=mars&settings.size=1",
+			Accept: "application/problem+json",
+			Status: http.StatusBadRequest,
+			Result: CR{
+				"type":   "about:blank",
+				"title":  "Bad Request",
+				"status": http.StatusBadRequest,
+				"detail": "settings.region must be one of [eu, us, asia]",
+				"code":   "param_enum",
+				"field":  "settings.region",
+				"rule":   "enum",
+				"params": CR{"enum": "eu|us|asia"},
+			},
+		},
+		Case{
+			Path:   "/user/rate",
+			Method: http.MethodPost,
+			Query:  "login=bob&skill=7.3&wait=0s",
+			Accept: "application/problem+json",
+			Status: http.StatusBadRequest,
+			Result: CR{
+				"type":   "about:blank",
+				"title":  "Bad Request",
+				"status": http.StatusBadRequest,
+				"detail": "skill: must be a multiple of 0.5",
+				"code":   "param_validate",
+				"field":  "skill",
+				"rule":   "validate",
+			},
+		},
+		Case{
+			Path:   "/user/unknown",
+			Accept: "application/problem+json",
+			Status: http.StatusNotFound,
+			Result: CR{
+				"type":   "about:blank",
+				"title":  "Not Found",
+				"status": http.StatusNotFound,
+				"detail": "unknown method",
+				"code":   "not_found",
+			},
+		},
 	}
 
 	runTests(t, ts, cases)
 }
 
+func TestAccess(t *testing.T) {
+	runTests(t, httptest.NewServer(NewMyApi()), []Case{
+		Case{ // у пользователя нет ни одной из ролей метода
+			Path:   ApiUserCreate,
+			Method: http.MethodPost,
+			Query:  "login=mr.moderator&age=32",
+			Token:  "100501",
+			Status: http.StatusForbidden,
+			Result: CR{
+				"error": "one of roles required: admin, moderator",
+			},
+		},
+		Case{
+			Path:   ApiUserCreate,
+			Method: http.MethodPost,
+			Query:  "login=mr.moderator&age=32",
+			Token:  "100501",
+			Accept: "application/problem+json",
+			Status: http.StatusForbidden,
+			Result: CR{
+				"type":   "about:blank",
+				"title":  "Forbidden",
+				"status": http.StatusForbidden,
+				"detail": "one of roles required: admin, moderator",
+				"code":   "missing_role",
+				"params": CR{"roles": "admin|moderator"},
+			},
+		},
+		Case{ // роли не нужны
+			Path:   ApiUserWhoami,
+			Token:  "100501",
+			Status: http.StatusNotFound,
+			Result: CR{
+				"error": "user not exist",
+			},
+		},
+	})
+
+	runTests(t, httptest.NewServer(NewOtherApi()), []Case{
+		Case{ // у ключа нет разрешения метода
+			Path:   "/user/12/level",
+			Method: http.MethodPost,
+			Query:  "level=7",
+			Token:  "100501",
+			Accept: "application/problem+json",
+			Status: http.StatusForbidden,
+			Result: CR{
+				"type":   "about:blank",
+				"title":  "Forbidden",
+				"status": http.StatusForbidden,
+				"detail": "scopes required: users:write",
+				"code":   "missing_scope",
+				"params": CR{"scopes": "users:write"},
+			},
+		},
+		Case{ // анонимному вызову разрешения не проверить
+			Path:   "/user/12/level",
+			Method: http.MethodPost,
+			Query:  "level=7",
+			Token:  "guest",
+			Status: http.StatusUnauthorized,
+			Result: CR{
+				"error": "authentication required",
+			},
+		},
+		Case{ // метод без разрешений доступен гостю
+			Path:   "/user/create",
+			Method: http.MethodPost,
+			Query:  "username=guest&level=1",
+			Token:  "guest",
+			Status: http.StatusOK,
+			Result: CR{
+				"error": "",
+				"response": CR{
+					"id":        12,
+					"login":     "guest",
+					"full_name": "",
+					"level":     1,
+				},
+			},
+		},
+	})
+}
+
+func TestEncoders(t *testing.T) {
+	ts := httptest.NewServer(NewMyApi())
+
+	get := func(path, accept string) (*http.Response, []byte) {
+		t.Helper()
+		req, _ := http.NewRequest(http.MethodGet, ts.URL+path, nil)
+		req.Header.Set("Accept", accept)
+		resp, err := client.Do(req)
+		if err != nil {
+			t.Fatalf("request error: %v", err)
+		}
+		defer resp.Body.Close()
+		body, _ := ioutil.ReadAll(resp.Body)
+		return resp, body
+	}
+
+	cases := []struct {
+		accept      string
+		contentType string
+		decode      func(body []byte, v any) error
+	}{
+		{"application/xml", "application/xml", xml.Unmarshal},
+		{"application/msgpack", "application/msgpack", func(body []byte, v any) error {
+			dec := msgpack.NewDecoder(bytes.NewReader(body))
+			dec.SetCustomStructTag("json")
+			return dec.Decode(v)
+		}},
+		{"application/xml;q=0.5, application/cbor", "application/cbor", cbor.Unmarshal},
+		{"text/html, */*;q=0.1", "application/json", json.Unmarshal},
+	}
+	for _, c := range cases {
+		resp, body := get(ApiUserProfile+"?login=rvasily", c.accept)
+		if resp.StatusCode != http.StatusOK || resp.Header.Get("content-type") != c.contentType {
+			t.Errorf("[%s] got %d %s, want 200 %s", c.accept, resp.StatusCode, resp.Header.Get("content-type"), c.contentType)
+			continue
+		}
+		var res struct {
+			Response struct {
+				Login string `json:"login" xml:"Login"`
+			} `json:"response" xml:"response"`
+		}
+		if err := c.decode(body, &res); err != nil || res.Response.Login != "rvasily" {
+			t.Errorf("[%s] got %+v, %v, want rvasily", c.accept, res, err)
+		}
+	}
+
+	// ошибки в том же конверте
+	resp, body := get(ApiUserProfile, "application/xml")
+	if want := "<result><error>login must be not empty</error></result>"; resp.StatusCode != http.StatusBadRequest || string(body) != want {
+		t.Errorf("got %d %s, want 400 %s", resp.StatusCode, body, want)
+	}
+
+	runTests(t, ts, []Case{
+		Case{ // подходящего кодировщика нет
+			Path:   ApiUserProfile,
+			Query:  "login=rvasily",
+			Accept: "text/csv",
+			Status: http.StatusNotAcceptable,
+			Result: CR{
+				"error": "no acceptable response content type",
+			},
+		},
+	})
+}
+
+func TestMiddleware(t *testing.T) {
+	ts := httptest.NewServer(NewMyApi())
+
+	runTests(t, ts, []Case{
+		Case{ // паника перехвачена middleware сервиса
+			Path:   ApiUserProfile,
+			Query:  "login=panic_user",
+			Status: http.StatusInternalServerError,
+			Result: CR{
+				"error": "MyApi.Profile: panic: unexpected user",
+			},
+		},
+		Case{ // и в методе с авторизацией
+			Path:   ApiUserCreate,
+			Method: http.MethodPost,
+			Query:  "login=mr.moderator&age=32&status=moderator&full_name=Ivan_Ivanov",
+			Status: http.StatusForbidden,
+			Result: CR{
+				"error": "unauthorized",
+			},
+		},
+	})
+
+	// middleware метода получает его описание
+	resp, err := client.Get(ts.URL + ApiUserProfile + "?login=rvasily")
+	if err != nil {
+		t.Fatalf("request error: %v", err)
+	}
+	resp.Body.Close()
+	if got := resp.Header.Get("X-Api-Method"); got != "* /user/profile" {
+		t.Errorf("X-Api-Method: got %q, want %q", got, "* /user/profile")
+	}
+
+	resp, err = client.Get(ts.URL + ApiUserWhoami)
+	if err != nil {
+		t.Fatalf("request error: %v", err)
+	}
+	resp.Body.Close()
+	if got := resp.Header.Get("X-Api-Method"); got != "" {
+		t.Errorf("X-Api-Method: got %q for method without the middleware", got)
+	}
+}
+
+func TestTeamApi(t *testing.T) {
+	runTests(t, httptest.NewServer(NewTeamApi()), []Case{
+		Case{ // конверт сервиса и код 201
+			Path:   "/team/create",
+			Method: http.MethodPost,
+			Query:  "name=beta&size=3",
+			Status: http.StatusCreated,
+			Result: CR{
+				"data": CR{"name": "beta", "size": 3},
+				"ver":  "",
+			},
+		},
+		Case{ // ошибки в прежнем конверте
+			Path:   "/team/create",
+			Method: http.MethodPost,
+			Query:  "name=beta",
+			Status: http.StatusConflict,
+			Result: CR{
+				"error": "exists",
+			},
+		},
+		Case{ // без конверта
+			Path:   "/team/get",
+			Query:  "name=alpha",
+			Status: http.StatusOK,
+			Result: CR{"name": "alpha", "size": 5},
+		},
+		Case{
+			Path:   "/team/list",
+			Status: http.StatusOK,
+			Result: CR{
+				"list":  CR{"names": []string{"alpha", "beta"}},
+				"total": 0,
+			},
+		},
+		Case{ // 204 без тела
+			Path:   "/team/delete",
+			Method: http.MethodPost,
+			Query:  "name=beta",
+			Status: http.StatusNoContent,
+		},
+		Case{
+			Path:   "/team/delete",
+			Method: http.MethodPost,
+			Query:  "name=beta",
+			Status: http.StatusNotFound,
+			Result: CR{
+				"error": "team not exist",
+			},
+		},
+	})
+}
+
+func TestRequestBody(t *testing.T) {
+	jsonType := map[string]string{"Content-Type": "application/json; charset=utf-8"}
+
+	runTests(t, httptest.NewServer(NewTeamApi()), []Case{
+		Case{ // параметры типа содержимого не мешают
+			Path:   "/team/create",
+			Method: http.MethodPost,
+			Query:  `{"name": "gamma"}`,
+			Header: jsonType,
+			Status: http.StatusCreated,
+			Result: CR{
+				"data": CR{"name": "gamma", "size": 1},
+				"ver":  "",
+			},
+		},
+		Case{ // строгий режим сервиса
+			Path:   "/team/create",
+			Method: http.MethodPost,
+			Query:  `{"name": "delta", "color": "red"}`,
+			Header: jsonType,
+			Status: http.StatusBadRequest,
+			Result: CR{
+				"error": `json: unknown field "color"`,
+			},
+		},
+		Case{
+			Path:   "/team/create",
+			Method: http.MethodPost,
+			Query:  `{"name": "delta"} {"name": "epsilon"}`,
+			Header: jsonType,
+			Status: http.StatusBadRequest,
+			Result: CR{
+				"error": "unexpected data after json body",
+			},
+		},
+		Case{
+			Path:   "/team/create",
+			Method: http.MethodPost,
+			Query:  "name=delta",
+			Header: map[string]string{"Content-Type": "text/plain"},
+			Status: http.StatusUnsupportedMediaType,
+			Result: CR{
+				"error": "unsupported content type text/plain",
+			},
+		},
+		Case{
+			Path:   "/team/create",
+			Method: http.MethodPost,
+			Query:  "name=" + strings.Repeat("x", 128),
+			Status: http.StatusRequestEntityTooLarge,
+			Result: CR{
+				"error": "request body must be <= 128 bytes",
+			},
+		},
+		Case{
+			Path:   "/team/create",
+			Method: http.MethodPost,
+			Query:  `{"name": "` + strings.Repeat("x", 128) + `"}`,
+			Header: jsonType,
+			Status: http.StatusRequestEntityTooLarge,
+			Result: CR{
+				"error": "request body must be <= 128 bytes",
+			},
+		},
+	})
+
+	runTests(t, httptest.NewServer(NewOtherApi()), []Case{
+		Case{ // без строгого режима лишнее игнорируется
+			Path:   "/guild/create",
+			Method: http.MethodPost,
+			Query:  `{"name": "alpha", "settings": {"region": "eu", "size": 5, "color": "red"}}`,
+			Header: map[string]string{"Content-Type": "Application/JSON"},
+			Status: http.StatusOK,
+			Result: CR{
+				"error":    "",
+				"response": CR{"page": 1, "name": "alpha", "region": "eu", "size": 5},
+			},
+		},
+		Case{ // длительность в json - строка как в query
+			Path:   "/user/rate",
+			Method: http.MethodPost,
+			Query:  `{"login": "bob", "wait": "1m"}`,
+			Header: jsonType,
+			Status: http.StatusOK,
+			Result: CR{
+				"error":    "",
+				"response": CR{"login": "bob", "skill": 5, "region": "eu", "wait": 60000000000, "since": "0001-01-01T00:00:00Z"},
+			},
+		},
+		Case{
+			Path:   "/user/rate",
+			Method: http.MethodPost,
+			Query:  `{"login": "bob", "wait": "2h"}`,
+			Header: jsonType,
+			Status: http.StatusBadRequest,
+			Result: CR{
+				"error": "wait must be <= 1h",
+			},
+		},
+	})
+}
+
+func TestStringRules(t *testing.T) {
+	const valid = "team=Alpha7&email=+bob@example.com+&code=abc123&nick=+Вася+&tags=PvP&tags=ranked-eu"
+
+	invalid := func(query, msg string) Case {
+		return Case{
+			Path:   "/team/invite",
+			Method: http.MethodPost,
+			Query:  query,
+			Status: http.StatusBadRequest,
+			Result: CR{"error": msg},
+		}
+	}
+
+	runTests(t, httptest.NewServer(NewTeamApi()), []Case{
+		Case{ // пробелы обрезаны, регистр понижен
+			Path:   "/team/invite",
+			Method: http.MethodPost,
+			Query:  valid + "&site=https://example.com/team&token=123e4567-e89b-12d3-a456-426614174000",
+			Status: http.StatusOK,
+			Result: CR{
+				"data": CR{
+					"team":  "alpha7",
+					"email": "bob@example.com",
+					"site":  "https://example.com/team",
+					"code":  "abc123",
+					"token": "123e4567-e89b-12d3-a456-426614174000",
+					"nick":  "Вася",
+					"tags":  []string{"pvp", "ranked-eu"},
+				},
+				"ver": "",
+			},
+		},
+		invalid("team=+&email=bob@example.com&code=abc123&nick=Вася", "team must be not empty"),
+		Case{ // в json пробелы тоже обрезаются до проверки required
+			Path:   "/team/invite",
+			Method: http.MethodPost,
+			Query:  `{"team": "   ", "email": "bob@example.com", "code": "abc123", "nick": "Вася"}`,
+			Header: map[string]string{"Content-Type": "application/json"},
+			Status: http.StatusBadRequest,
+			Result: CR{"error": "team must be not empty"},
+		},
+		invalid("team=alpha_7&email=bob@example.com&code=abc123&nick=Вася", "team must contain letters and digits only"),
+		invalid("team=alpha&email=Bob+<bob@example.com>&code=abc123&nick=Вася", "email must be valid email"),
+		invalid(valid+"&site=example.com", "site must be valid URL"),
+		invalid("team=alpha&email=bob@example.com&code=abc12&nick=Вася", "code len must be 6"),
+		invalid(valid+"&token=123e4567", "token must be valid UUID"),
+		invalid("team=alpha&email=bob@example.com&code=abc123&nick=Я", "nick must have >= 2 characters"),
+		invalid("team=alpha&email=bob@example.com&code=abc123&nick=Вася+Пупкин", "nick must have <= 8 characters"),
+		invalid(valid+"&tags=x", "tags items must match pattern ^[a-z]{2,}(-[a-z]+)*$"),
+	})
+}
+
+func TestCrossRules(t *testing.T) {
+	invalid := func(query string, status int, msg string) Case {
+		return Case{
+			Path:   "/team/match",
+			Method: http.MethodPost,
+			Query:  query,
+			Status: status,
+			Result: CR{"error": msg},
+		}
+	}
+
+	runTests(t, httptest.NewServer(NewTeamApi()), []Case{
+		Case{ // достаточно одного из id и name
+			Path:   "/team/match",
+			Method: http.MethodPost,
+			Query:  "id=7&minskill=10&maxskill=10",
+			Status: http.StatusOK,
+			Result: CR{
+				"data": CR{"team": "7", "minSkill": 10, "maxSkill": 10, "region": "eu", "backup": ""},
+				"ver":  "",
+			},
+		},
+		Case{
+			Path:   "/team/match",
+			Method: http.MethodPost,
+			Query:  "name=alpha&backup=us&reason=ping",
+			Status: http.StatusOK,
+			Result: CR{
+				"data": CR{"team": "alpha", "minSkill": 0, "maxSkill": 100, "region": "eu", "backup": "us"},
+				"ver":  "",
+			},
+		},
+		invalid("minskill=1", http.StatusBadRequest, "id must be not empty if name is empty"),
+		invalid("name=alpha&minskill=50&maxskill=40", http.StatusBadRequest, "maxskill must be >= minskill"),
+		invalid("name=alpha&backup=eu&reason=ping", http.StatusBadRequest, "backup must be != region"),
+		invalid("name=alpha&backup=us", http.StatusBadRequest, "reason must be not empty if backup is not empty"),
+		// Validate(ctx) вызывается после правил и возвращает свой статус
+		invalid("name=alpha&region=mars", http.StatusUnprocessableEntity, "region mars is not available"),
+		// но не вызывается, если правила не выполнены
+		invalid("name=alpha&region=mars&backup=mars", http.StatusBadRequest, "backup must be != region"),
+	})
+}
+
+func TestUpload(t *testing.T) {
+	ts := httptest.NewServer(NewTeamApi())
+
+	type file struct {
+		field, name, contentType string
+		size                     int
+	}
+	upload := func(name string, files ...file) (int, any) {
+		t.Helper()
+		var buf bytes.Buffer
+		mw := multipart.NewWriter(&buf)
+		if name != "" {
+			mw.WriteField("name", name)
+		}
+		for _, f := range files {
+			h := textproto.MIMEHeader{}
+			h.Set("Content-Disposition", fmt.Sprintf(`form-data; name="%s"; filename="%s"`, f.field, f.name))
+			h.Set("Content-Type", f.contentType)
+			part, _ := mw.CreatePart(h)
+			part.Write(bytes.Repeat([]byte{'x'}, f.size))
+		}
+		mw.Close()
+
+		resp, err := client.Post(ts.URL+"/team/logo", mw.FormDataContentType(), &buf)
+		if err != nil {
+			t.Fatalf("request error: %v", err)
+		}
+		defer resp.Body.Close()
+		var res any
+		json.NewDecoder(resp.Body).Decode(&res)
+		return resp.StatusCode, res
+	}
+
+	cases := []struct {
+		name   string
+		files  []file
+		status int
+		want   CR
+	}{
+		{"alpha", []file{{"logo", "logo.png", "image/png", 100}, {"extra", "a.txt", "text/plain", 10}, {"extra", "b.txt", "text/plain", 10}}, http.StatusOK,
+			CR{"data": CR{"name": "alpha", "file": "logo.png", "size": 100, "extra": 2}, "ver": ""}},
+		{"alpha", nil, http.StatusBadRequest, CR{"error": "logo must be not empty"}},
+		{"alpha", []file{{"logo", "logo.png", "image/png", 2000}}, http.StatusBadRequest, CR{"error": "logo size must be <= 1024 bytes"}},
+		{"alpha", []file{{"logo", "logo.jpg", "image/jpeg", 100}}, http.StatusBadRequest, CR{"error": "logo type must be one of [image/png, image/gif]"}},
+		// файлы сверх лимита памяти сохраняются на диск
+		{"alpha", []file{{"logo", "logo.gif", "image/gif; name=logo", 1000}, {"extra", "a.bin", "application/octet-stream", 2048}}, http.StatusOK,
+			CR{"data": CR{"name": "alpha", "file": "logo.gif", "size": 1000, "extra": 1}, "ver": ""}},
+		{"alpha", []file{{"logo", "logo.png", "image/png", 100}, {"extra", "a.bin", "application/octet-stream", 2049}}, http.StatusBadRequest,
+			CR{"error": "extra items size must be <= 2048 bytes"}},
+	}
+	for i, c := range cases {
+		status, res := upload(c.name, c.files...)
+		var want any
+		data, _ := json.Marshal(c.want)
+		json.Unmarshal(data, &want)
+		if status != c.status || !reflect.DeepEqual(res, want) {
+			t.Errorf("[%d] got %d %v, want %d %v", i, status, res, c.status, c.want)
+		}
+	}
+
+	runTests(t, ts, []Case{
+		Case{ // файл в форме не передать
+			Path:   "/team/logo",
+			Method: http.MethodPost,
+			Query:  "name=alpha&logo=logo.png",
+			Status: http.StatusBadRequest,
+			Result: CR{
+				"error": "logo must be not empty",
+			},
+		},
+	})
+}
+
 func runTests(t *testing.T, ts *httptest.Server, cases []Case) {
 	for idx, item := range cases {
 		var (
@@ -304,9 +1317,17 @@ func runTests(t *testing.T, ts *httptest.Server, cases []Case) {
 			req, err = http.NewRequest(item.Method, ts.URL+item.Path+"?"+item.Query, nil)
 		}
 
-		if item.Auth {
+		if item.Token != "" {
+			req.Header.Add("X-Auth", item.Token)
+		} else if item.Auth {
 			req.Header.Add("X-Auth", "100500")
 		}
+		if item.Accept != "" {
+			req.Header.Add("Accept", item.Accept)
+		}
+		for k, v := range item.Header {
+			req.Header.Set(k, v)
+		}
 
 		resp, err := client.Do(req)
 		if err != nil {
@@ -323,6 +1344,14 @@ func runTests(t *testing.T, ts *httptest.Server, cases []Case) {
 			continue
 		}
 
+		// ответ без тела
+		if item.Result == nil {
+			if len(body) != 0 {
+				t.Errorf("[%s] expected empty body, got %s", caseName, body)
+			}
+			continue
+		}
+
 		err = json.Unmarshal(body, &result)
 		if err != nil {
 			t.Errorf("[%s] cant unpack json: %v", caseName, err)
@@ -0,0 +1,810 @@
+// !!! Do not change this code !!!
+// The code is generated automatically by apigen tool
+// Inputs hash: 003a4ac18fedcc54d5bd24222982ad8f6fc46a9cc83268f7fc29b95e893aeacf
+package mainclient
+
+import (
+	"apigen/test/model"
+	"bytes"
+	"context"
+	"encoding"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"mime/multipart"
+	"net/http"
+	"net/textproto"
+	"net/url"
+	"reflect"
+	"strings"
+)
+
+type apiClient struct {
+	// BaseURL is prepended to the method URLs, e.g. http://localhost:8080
+	BaseURL string
+	// HTTPClient is used to send requests, http.DefaultClient if nil
+	HTTPClient *http.Client
+	// Auth sets credentials to requests to the methods marked with "auth": true
+	Auth func(r *http.Request)
+}
+
+// ApiError is the error response of the service with its HTTP status.
+type ApiError struct {
+	HTTPStatus int
+	Err        error
+}
+
+func (ae ApiError) Error() string {
+	return ae.Err.Error()
+}
+
+func (ae ApiError) Unwrap() error {
+	return ae.Err
+}
+
+// ParamError describes the invalid request param, it is ApiError.Err of 400 status.
+type ParamError struct {
+	Code   string            `json:"code"`             // stable error code, e.g. param_max
+	Field  string            `json:"field"`            // api name of the param, e.g. settings.region
+	Rule   string            `json:"rule"`             // violated rule, e.g. max
+	Params map[string]string `json:"params,omitempty"` // rule params, e.g. {"max": "128"}
+	Msg    string            `json:"message"`
+}
+
+func (e *ParamError) Error() string {
+	return e.Msg
+}
+
+// ParamErrors are all invalid params of the request to the methods marked with "allErrors": true.
+type ParamErrors []*ParamError
+
+func (e ParamErrors) Error() string {
+	msgs := make([]string, len(e))
+	for i, pe := range e {
+		msgs[i] = pe.Msg
+	}
+	return strings.Join(msgs, "; ")
+}
+
+func (e ParamErrors) Unwrap() []error {
+	errs := make([]error, len(e))
+	for i, pe := range e {
+		errs[i] = pe
+	}
+	return errs
+}
+
+// AccessError describes the missing role or scope of the caller, it is ApiError.Err of 403 status.
+type AccessError struct {
+	Code   string            // missing_role or missing_scope
+	Params map[string]string // required roles or scopes, e.g. {"roles": "admin|moderator"}
+	Msg    string
+}
+
+func (e *AccessError) Error() string {
+	return e.Msg
+}
+
+// problem is RFC 9457 problem details of the error response.
+type problem struct {
+	Detail string            `json:"detail"`
+	Code   string            `json:"code"`
+	Field  string            `json:"field"`
+	Rule   string            `json:"rule"`
+	Params map[string]string `json:"params"`
+	Errors ParamErrors       `json:"errors"`
+}
+
+// apiResponse is the response body of the service method.
+type apiResponse struct {
+	Response any    `json:"response"`
+	Error    string `json:"error"`
+}
+
+// apiErrorResponse is the response body of the failed request.
+type apiErrorResponse struct {
+	Error  string      `json:"error"`
+	Errors ParamErrors `json:"errors"`
+}
+
+// File is the file uploaded in the multipart form.
+type File struct {
+	Name        string    // file name
+	ContentType string    // media type, application/octet-stream if empty
+	Content     io.Reader // read when the request is sent
+}
+
+// multipartForm is the request body of the methods uploading files.
+// The first error of writing is kept and returned by do.
+type multipartForm struct {
+	buf bytes.Buffer
+	w   *multipart.Writer
+	err error
+}
+
+func newMultipartForm() *multipartForm {
+	f := &multipartForm{}
+	f.w = multipart.NewWriter(&f.buf)
+	return f
+}
+
+func (f *multipartForm) field(name, value string) {
+	if f.err == nil {
+		f.err = f.w.WriteField(name, value)
+	}
+}
+
+func (f *multipartForm) file(name string, file *File) {
+	if f.err != nil || file == nil {
+		return
+	}
+	ct := file.ContentType
+	if ct == "" {
+		ct = "application/octet-stream"
+	}
+	h := textproto.MIMEHeader{}
+	h.Set("Content-Disposition", mime.FormatMediaType("form-data", map[string]string{"name": name, "filename": file.Name}))
+	h.Set("Content-Type", ct)
+	part, err := f.w.CreatePart(h)
+	if err == nil && file.Content != nil {
+		_, err = io.Copy(part, file.Content)
+	}
+	f.err = err
+}
+
+// queryValue formats the query or path param value, by MarshalText if implemented.
+func queryValue(v any) string {
+	switch v := v.(type) {
+	case encoding.TextMarshaler:
+		if b, err := v.MarshalText(); err == nil {
+			return string(b)
+		}
+	case json.RawMessage:
+		return string(v)
+	}
+	return fmt.Sprint(v)
+}
+
+// do sends the request and decodes the response body of the success status to res if not nil.
+func (c *apiClient) do(ctx context.Context, method, path string, query url.Values, body any, header http.Header, auth bool, status int, res any) error {
+	u := strings.TrimSuffix(c.BaseURL, "/") + path
+	if len(query) > 0 {
+		u += "?" + query.Encode()
+	}
+	var (
+		reqBody     io.Reader
+		contentType string
+	)
+	switch body := body.(type) {
+	case nil:
+	case *multipartForm:
+		if body.err == nil {
+			body.err = body.w.Close()
+		}
+		if body.err != nil {
+			return body.err
+		}
+		reqBody, contentType = &body.buf, body.w.FormDataContentType()
+	default:
+		b, err := json.Marshal(body)
+		if err != nil {
+			return err
+		}
+		reqBody, contentType = bytes.NewReader(b), "application/json"
+	}
+	req, err := http.NewRequestWithContext(ctx, method, u, reqBody)
+	if err != nil {
+		return err
+	}
+	if contentType != "" {
+		req.Header.Set("content-type", contentType)
+	}
+	for k, vs := range header {
+		req.Header[k] = vs
+	}
+	req.Header.Set("accept", "application/json, application/problem+json")
+	if auth && c.Auth != nil {
+		c.Auth(req)
+	}
+	client := c.HTTPClient
+	if client == nil {
+		client = http.DefaultClient
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != status && resp.Header.Get("content-type") == "application/problem+json" {
+		var prob problem
+		if err := json.NewDecoder(resp.Body).Decode(&prob); err != nil {
+			return ApiError{HTTPStatus: resp.StatusCode, Err: errors.New(http.StatusText(resp.StatusCode))}
+		}
+		if len(prob.Errors) > 0 {
+			return ApiError{HTTPStatus: resp.StatusCode, Err: prob.Errors}
+		}
+		if prob.Code == "missing_role" || prob.Code == "missing_scope" {
+			return ApiError{HTTPStatus: resp.StatusCode, Err: &AccessError{Code: prob.Code, Params: prob.Params, Msg: prob.Detail}}
+		}
+		if prob.Field != "" {
+			pe := &ParamError{Code: prob.Code, Field: prob.Field, Rule: prob.Rule, Params: prob.Params, Msg: prob.Detail}
+			return ApiError{HTTPStatus: resp.StatusCode, Err: pe}
+		}
+		return ApiError{HTTPStatus: resp.StatusCode, Err: errors.New(prob.Detail)}
+	}
+	if resp.StatusCode != status {
+		var env apiErrorResponse
+		if err := json.NewDecoder(resp.Body).Decode(&env); err != nil {
+			return ApiError{HTTPStatus: resp.StatusCode, Err: errors.New(http.StatusText(resp.StatusCode))}
+		}
+		if len(env.Errors) > 0 {
+			return ApiError{HTTPStatus: resp.StatusCode, Err: env.Errors}
+		}
+		return ApiError{HTTPStatus: resp.StatusCode, Err: errors.New(env.Error)}
+	}
+	if res == nil {
+		return nil
+	}
+	if err := json.NewDecoder(resp.Body).Decode(res); err != nil {
+		return fmt.Errorf("can't decode response: %w", err)
+	}
+	return nil
+}
+
+// CreateParams mirrors main.CreateParams.
+type CreateParams struct {
+	Login  string `apivalidator:"required,min=10"`
+	Name   string `apivalidator:"paramname=full_name"`
+	Status string `apivalidator:"enum=user|moderator|admin,default=user"`
+	Age    int    `apivalidator:"min=0,max=128"`
+}
+
+// DataEnvelope mirrors main.DataEnvelope.
+type DataEnvelope struct {
+	Data any    `json:"data" apigen:"response"`
+	Ver  string `json:"ver"`
+}
+
+// NewUser mirrors main.NewUser.
+type NewUser struct {
+	ID uint64 `json:"id"`
+}
+
+// OtherCreateParams mirrors main.OtherCreateParams.
+type OtherCreateParams struct {
+	Username string `apivalidator:"required,min=3"`
+	Name     string `apivalidator:"paramname=account_name"`
+	Class    string `apivalidator:"enum=warrior|sorcerer|rouge,default=warrior"`
+	Level    int    `apivalidator:"min=1,max=50"`
+}
+
+// OtherGuildParams mirrors main.OtherGuildParams.
+type OtherGuildParams struct {
+	OtherPaging
+	Name     string `apivalidator:"required"`
+	Settings OtherGuildSettings
+}
+
+// OtherGuildResult mirrors main.OtherGuildResult.
+type OtherGuildResult struct {
+	Page   int    `json:"page"`
+	Name   string `json:"name"`
+	Region string `json:"region"`
+	Size   int    `json:"size"`
+}
+
+// OtherGuildSettings mirrors main.OtherGuildSettings.
+type OtherGuildSettings struct {
+	Region string `apivalidator:"required,enum=eu|us|asia"`
+	Size   int    `apivalidator:"max=100"`
+}
+
+// OtherPaging mirrors main.OtherPaging.
+type OtherPaging struct {
+	Page int `apivalidator:"default=1,min=1"`
+}
+
+// OtherSearchParams mirrors main.OtherSearchParams.
+type OtherSearchParams struct {
+	Online   bool    `apivalidator:"default=true"`
+	MinLevel uint8   `apivalidator:"paramname=min_level,default=1,max=50"`
+	Limit    int64   `apivalidator:"enum=10|20|50,default=10"`
+	Rating   float32 `apivalidator:">=0,<=5,default=0"`
+	Region   string  `apivalidator:"header=X-Request-Region,enum=eu|us|asia,default=eu"`
+	Locale   string  `apivalidator:"cookie=locale,enum=en|ru,default=en"`
+}
+
+// OtherSearchResult mirrors main.OtherSearchResult.
+type OtherSearchResult struct {
+	Online   bool    `json:"online"`
+	MinLevel uint8   `json:"min_level"`
+	Limit    int64   `json:"limit"`
+	Rating   float32 `json:"rating"`
+	Region   string  `json:"region"`
+	Locale   string  `json:"locale"`
+}
+
+// OtherSetLevelParams mirrors main.OtherSetLevelParams.
+type OtherSetLevelParams struct {
+	ID    int `apivalidator:"path=id,>0"`
+	Level int `apivalidator:"min=1,max=50"`
+}
+
+// OtherTagsParams mirrors main.OtherTagsParams.
+type OtherTagsParams struct {
+	IDs    []int     `apivalidator:"paramname=ids,csv,required,maxitems=3,unique,>0"`
+	Tags   []string  `apivalidator:"enum=pve|pvp|raid,minitems=1"`
+	Scores []float64 `apivalidator:">=0,<=1"`
+}
+
+// OtherTagsResult mirrors main.OtherTagsResult.
+type OtherTagsResult struct {
+	IDs    []int     `json:"ids"`
+	Tags   []string  `json:"tags"`
+	Scores []float64 `json:"scores"`
+}
+
+// OtherUser mirrors main.OtherUser.
+type OtherUser struct {
+	ID       uint64 `json:"id"`
+	Login    string `json:"login"`
+	FullName string `json:"full_name"`
+	Level    int    `json:"level"`
+}
+
+// ProfileByLoginParams mirrors main.ProfileByLoginParams.
+type ProfileByLoginParams struct {
+	Login string `apivalidator:"path=login,required"`
+}
+
+// ProfileParams mirrors main.ProfileParams.
+type ProfileParams struct {
+	Login string `apivalidator:"required"`
+}
+
+// Team mirrors main.Team.
+type Team struct {
+	Name string `json:"name"`
+	Size int    `json:"size"`
+}
+
+// TeamCreateParams mirrors main.TeamCreateParams.
+type TeamCreateParams struct {
+	Name string `apivalidator:"required"`
+	Size int    `apivalidator:"min=1,default=1"`
+}
+
+// TeamInvite mirrors main.TeamInvite.
+type TeamInvite struct {
+	Team  string   `json:"team"`
+	Email string   `json:"email"`
+	Site  string   `json:"site"`
+	Code  string   `json:"code"`
+	Token string   `json:"token"`
+	Nick  TeamNick `json:"nick"`
+	Tags  []string `json:"tags"`
+}
+
+// TeamInviteParams mirrors main.TeamInviteParams.
+type TeamInviteParams struct {
+	Team  string   `apivalidator:"required,trim,lowercase,alphanum"`
+	Email string   `apivalidator:"required,trim,email"`
+	Site  string   `apivalidator:"url"`
+	Code  string   `apivalidator:"len=6"`
+	Token string   `apivalidator:"uuid"`
+	Nick  TeamNick `apivalidator:"trim,runemin=2,runemax=8"`
+	Tags  []string `apivalidator:"trim,lowercase,pattern=^[a-z]{2,}(-[a-z]+)*$"`
+}
+
+// TeamList mirrors main.TeamList.
+type TeamList struct {
+	Names []string `json:"names"`
+}
+
+// TeamListEnvelope mirrors main.TeamListEnvelope.
+type TeamListEnvelope struct {
+	List  TeamList `json:"list" apigen:"response"`
+	Total int      `json:"total"`
+}
+
+// TeamLogo mirrors main.TeamLogo.
+type TeamLogo struct {
+	Name  string `json:"name"`
+	File  string `json:"file"`
+	Size  int64  `json:"size"`
+	Extra int    `json:"extra"`
+}
+
+// TeamLogoParams mirrors main.TeamLogoParams.
+type TeamLogoParams struct {
+	Name  string  `apivalidator:"required"`
+	Logo  *File   `apivalidator:"required,maxsize=1024,mime=image/png|image/gif"`
+	Extra []*File `apivalidator:"maxitems=2,maxsize=2048"`
+}
+
+// TeamMatch mirrors main.TeamMatch.
+type TeamMatch struct {
+	Team     string `json:"team"`
+	MinSkill int    `json:"minSkill"`
+	MaxSkill int    `json:"maxSkill"`
+	Region   string `json:"region"`
+	Backup   string `json:"backup"`
+}
+
+// TeamMatchParams mirrors main.TeamMatchParams.
+type TeamMatchParams struct {
+	ID       int    `apivalidator:"default=0,required_without=Name"`
+	Name     string `apivalidator:"required_without=ID"`
+	MinSkill int    `apivalidator:"default=0,min=0"`
+	MaxSkill int    `apivalidator:"default=100,gtefield=MinSkill"`
+	Region   string `apivalidator:"default=eu"`
+	Backup   string `apivalidator:"nefield=Region"`
+	Reason   string `apivalidator:"required_with=Backup"`
+}
+
+// TeamNick mirrors main.TeamNick.
+type TeamNick string
+
+// TeamNone mirrors main.TeamNone.
+type TeamNone struct{}
+
+// TeamParams mirrors main.TeamParams.
+type TeamParams struct {
+	Name string `apivalidator:"required"`
+}
+
+// User mirrors main.User.
+type User struct {
+	ID       uint64 `json:"id"`
+	Login    string `json:"login"`
+	FullName string `json:"full_name"`
+	Status   int    `json:"status"`
+}
+
+// WhoamiParams mirrors main.WhoamiParams.
+type WhoamiParams struct{}
+
+// MyApiClient is the HTTP client of MyApi API.
+type MyApiClient struct {
+	apiClient
+}
+
+func NewMyApiClient(baseURL string, httpClient *http.Client, auth func(r *http.Request)) *MyApiClient {
+	return &MyApiClient{apiClient{BaseURL: baseURL, HTTPClient: httpClient, Auth: auth}}
+}
+
+// Profile calls GET /user/profile
+func (c *MyApiClient) Profile(ctx context.Context, in ProfileParams) (*User, error) {
+	var res *User
+	env := apiResponse{Response: &res}
+	path := strings.Join([]string{"", "user", "profile"}, "/")
+	query := url.Values{}
+	query.Set("login", queryValue(in.Login))
+	err := c.do(ctx, "GET", path, query, nil, nil, false, http.StatusOK, &env)
+	return res, err
+}
+
+// Create calls POST /user/create
+func (c *MyApiClient) Create(ctx context.Context, in CreateParams) (*NewUser, error) {
+	var res *NewUser
+	env := apiResponse{Response: &res}
+	path := strings.Join([]string{"", "user", "create"}, "/")
+	var body struct {
+		Login  string `json:"login"`
+		Name   string `json:"full_name"`
+		Status string `json:"status,omitempty"`
+		Age    int    `json:"age"`
+	}
+	body.Login = in.Login
+	body.Name = in.Name
+	body.Status = in.Status
+	body.Age = in.Age
+	err := c.do(ctx, "POST", path, nil, &body, nil, true, http.StatusOK, &env)
+	return res, err
+}
+
+// Whoami calls GET /user/whoami
+func (c *MyApiClient) Whoami(ctx context.Context, in WhoamiParams) (*User, error) {
+	var res *User
+	env := apiResponse{Response: &res}
+	path := strings.Join([]string{"", "user", "whoami"}, "/")
+	query := url.Values{}
+	err := c.do(ctx, "GET", path, query, nil, nil, true, http.StatusOK, &env)
+	return res, err
+}
+
+// ProfileByLogin calls GET /user/{login}/profile
+func (c *MyApiClient) ProfileByLogin(ctx context.Context, in ProfileByLoginParams) (*User, error) {
+	var res *User
+	env := apiResponse{Response: &res}
+	path := strings.Join([]string{"", "user", url.PathEscape(queryValue(in.Login)), "profile"}, "/")
+	query := url.Values{}
+	err := c.do(ctx, "GET", path, query, nil, nil, false, http.StatusOK, &env)
+	return res, err
+}
+
+// OtherApiClient is the HTTP client of OtherApi API.
+type OtherApiClient struct {
+	apiClient
+}
+
+func NewOtherApiClient(baseURL string, httpClient *http.Client, auth func(r *http.Request)) *OtherApiClient {
+	return &OtherApiClient{apiClient{BaseURL: baseURL, HTTPClient: httpClient, Auth: auth}}
+}
+
+// SetLevel calls POST /user/{id}/level
+func (c *OtherApiClient) SetLevel(ctx context.Context, in OtherSetLevelParams) (*OtherUser, error) {
+	var res *OtherUser
+	env := apiResponse{Response: &res}
+	path := strings.Join([]string{"", "user", url.PathEscape(queryValue(in.ID)), "level"}, "/")
+	var body struct {
+		Level int `json:"level"`
+	}
+	body.Level = in.Level
+	err := c.do(ctx, "POST", path, nil, &body, nil, true, http.StatusOK, &env)
+	return res, err
+}
+
+// Search calls GET /user/search
+func (c *OtherApiClient) Search(ctx context.Context, in OtherSearchParams) (OtherSearchResult, error) {
+	var res OtherSearchResult
+	env := apiResponse{Response: &res}
+	path := strings.Join([]string{"", "user", "search"}, "/")
+	header := http.Header{}
+	if in.Region != "" {
+		header.Set("X-Request-Region", queryValue(in.Region))
+	}
+	if in.Locale != "" {
+		header.Add("Cookie", (&http.Cookie{Name: "locale", Value: queryValue(in.Locale)}).String())
+	}
+	query := url.Values{}
+	if in.Online != false {
+		query.Set("online", queryValue(in.Online))
+	}
+	if in.MinLevel != 0 {
+		query.Set("min_level", queryValue(in.MinLevel))
+	}
+	if in.Limit != 0 {
+		query.Set("limit", queryValue(in.Limit))
+	}
+	if in.Rating != 0 {
+		query.Set("rating", queryValue(in.Rating))
+	}
+	err := c.do(ctx, "GET", path, query, nil, header, false, http.StatusOK, &env)
+	return res, err
+}
+
+// Tags calls POST /user/tags
+func (c *OtherApiClient) Tags(ctx context.Context, in OtherTagsParams) (OtherTagsResult, error) {
+	var res OtherTagsResult
+	env := apiResponse{Response: &res}
+	path := strings.Join([]string{"", "user", "tags"}, "/")
+	var body struct {
+		IDs    []int     `json:"ids"`
+		Tags   []string  `json:"tags"`
+		Scores []float64 `json:"scores"`
+	}
+	body.IDs = in.IDs
+	body.Tags = in.Tags
+	body.Scores = in.Scores
+	err := c.do(ctx, "POST", path, nil, &body, nil, false, http.StatusOK, &env)
+	return res, err
+}
+
+// CreateGuild calls POST /guild/create
+func (c *OtherApiClient) CreateGuild(ctx context.Context, in OtherGuildParams) (OtherGuildResult, error) {
+	var res OtherGuildResult
+	env := apiResponse{Response: &res}
+	path := strings.Join([]string{"", "guild", "create"}, "/")
+	var body struct {
+		OtherPaging_Page int    `json:"page,omitempty"`
+		Name             string `json:"name"`
+		Settings         struct {
+			Region string `json:"region"`
+			Size   int    `json:"size"`
+		} `json:"settings"`
+	}
+	body.OtherPaging_Page = in.OtherPaging.Page
+	body.Name = in.Name
+	body.Settings.Region = in.Settings.Region
+	body.Settings.Size = in.Settings.Size
+	err := c.do(ctx, "POST", path, nil, &body, nil, false, http.StatusOK, &env)
+	return res, err
+}
+
+// те же параметры, но в ответе все ошибки сразу, а не только первая
+func (c *OtherApiClient) CheckGuild(ctx context.Context, in OtherGuildParams) (OtherGuildResult, error) {
+	var res OtherGuildResult
+	env := apiResponse{Response: &res}
+	path := strings.Join([]string{"", "guild", "check"}, "/")
+	var body struct {
+		OtherPaging_Page int    `json:"page,omitempty"`
+		Name             string `json:"name"`
+		Settings         struct {
+			Region string `json:"region"`
+			Size   int    `json:"size"`
+		} `json:"settings"`
+	}
+	body.OtherPaging_Page = in.OtherPaging.Page
+	body.Name = in.Name
+	body.Settings.Region = in.Settings.Region
+	body.Settings.Size = in.Settings.Size
+	err := c.do(ctx, "POST", path, nil, &body, nil, false, http.StatusOK, &env)
+	return res, err
+}
+
+// Rate calls POST /user/rate
+func (c *OtherApiClient) Rate(ctx context.Context, in *model.RateParams) (*model.Rating, error) {
+	var res *model.Rating
+	env := apiResponse{Response: &res}
+	path := strings.Join([]string{"", "user", "rate"}, "/")
+	var body struct {
+		Login  string      `json:"login"`
+		Skill  model.Skill `json:"skill,omitempty"`
+		Region string      `json:"region,omitempty"`
+		Wait   string      `json:"wait"`
+		Since  string      `json:"since"`
+	}
+	body.Login = in.Login
+	body.Skill = in.Skill
+	if !reflect.ValueOf(in.Region).IsZero() {
+		b, err := in.Region.MarshalText()
+		if err != nil {
+			return res, err
+		}
+		body.Region = string(b)
+	}
+	body.Wait = in.Wait.String()
+	{
+		b, err := in.Since.MarshalText()
+		if err != nil {
+			return res, err
+		}
+		body.Since = string(b)
+	}
+	err := c.do(ctx, "POST", path, nil, &body, nil, false, http.StatusOK, &env)
+	return res, err
+}
+
+// Create calls POST /user/create
+func (c *OtherApiClient) Create(ctx context.Context, in OtherCreateParams) (*OtherUser, error) {
+	var res *OtherUser
+	env := apiResponse{Response: &res}
+	path := strings.Join([]string{"", "user", "create"}, "/")
+	var body struct {
+		Username string `json:"username"`
+		Name     string `json:"account_name"`
+		Class    string `json:"class,omitempty"`
+		Level    int    `json:"level"`
+	}
+	body.Username = in.Username
+	body.Name = in.Name
+	body.Class = in.Class
+	body.Level = in.Level
+	err := c.do(ctx, "POST", path, nil, &body, nil, true, http.StatusOK, &env)
+	return res, err
+}
+
+// TeamApiClient is the HTTP client of TeamApi API.
+type TeamApiClient struct {
+	apiClient
+}
+
+func NewTeamApiClient(baseURL string, httpClient *http.Client, auth func(r *http.Request)) *TeamApiClient {
+	return &TeamApiClient{apiClient{BaseURL: baseURL, HTTPClient: httpClient, Auth: auth}}
+}
+
+// Create calls POST /team/create
+func (c *TeamApiClient) Create(ctx context.Context, in TeamCreateParams) (*Team, error) {
+	var res *Team
+	env := DataEnvelope{Data: &res}
+	path := strings.Join([]string{"", "team", "create"}, "/")
+	var body struct {
+		Name string `json:"name"`
+		Size int    `json:"size,omitempty"`
+	}
+	body.Name = in.Name
+	body.Size = in.Size
+	err := c.do(ctx, "POST", path, nil, &body, nil, false, http.StatusCreated, &env)
+	return res, err
+}
+
+// Get calls GET /team/get
+func (c *TeamApiClient) Get(ctx context.Context, in TeamParams) (*Team, error) {
+	var res *Team
+	path := strings.Join([]string{"", "team", "get"}, "/")
+	query := url.Values{}
+	query.Set("name", queryValue(in.Name))
+	err := c.do(ctx, "GET", path, query, nil, nil, false, http.StatusOK, &res)
+	return res, err
+}
+
+// List calls GET /team/list
+func (c *TeamApiClient) List(ctx context.Context, in TeamNone) (TeamList, error) {
+	var res TeamList
+	var env TeamListEnvelope
+	path := strings.Join([]string{"", "team", "list"}, "/")
+	query := url.Values{}
+	err := c.do(ctx, "GET", path, query, nil, nil, false, http.StatusOK, &env)
+	res = env.List
+	return res, err
+}
+
+// Delete calls POST /team/delete
+func (c *TeamApiClient) Delete(ctx context.Context, in TeamParams) (TeamNone, error) {
+	var res TeamNone
+	path := strings.Join([]string{"", "team", "delete"}, "/")
+	var body struct {
+		Name string `json:"name"`
+	}
+	body.Name = in.Name
+	err := c.do(ctx, "POST", path, nil, &body, nil, false, http.StatusNoContent, nil)
+	return res, err
+}
+
+// Logo calls POST /team/logo
+func (c *TeamApiClient) Logo(ctx context.Context, in TeamLogoParams) (*TeamLogo, error) {
+	var res *TeamLogo
+	env := DataEnvelope{Data: &res}
+	path := strings.Join([]string{"", "team", "logo"}, "/")
+	form := newMultipartForm()
+	form.field("name", queryValue(in.Name))
+	form.file("logo", in.Logo)
+	for _, f := range in.Extra {
+		form.file("extra", f)
+	}
+	err := c.do(ctx, "POST", path, nil, form, nil, false, http.StatusOK, &env)
+	return res, err
+}
+
+// Invite calls POST /team/invite
+func (c *TeamApiClient) Invite(ctx context.Context, in TeamInviteParams) (TeamInvite, error) {
+	var res TeamInvite
+	env := DataEnvelope{Data: &res}
+	path := strings.Join([]string{"", "team", "invite"}, "/")
+	var body struct {
+		Team  string   `json:"team"`
+		Email string   `json:"email"`
+		Site  string   `json:"site"`
+		Code  string   `json:"code"`
+		Token string   `json:"token"`
+		Nick  TeamNick `json:"nick"`
+		Tags  []string `json:"tags"`
+	}
+	body.Team = in.Team
+	body.Email = in.Email
+	body.Site = in.Site
+	body.Code = in.Code
+	body.Token = in.Token
+	body.Nick = in.Nick
+	body.Tags = in.Tags
+	err := c.do(ctx, "POST", path, nil, &body, nil, false, http.StatusOK, &env)
+	return res, err
+}
+
+// Match calls POST /team/match
+func (c *TeamApiClient) Match(ctx context.Context, in TeamMatchParams) (TeamMatch, error) {
+	var res TeamMatch
+	env := DataEnvelope{Data: &res}
+	path := strings.Join([]string{"", "team", "match"}, "/")
+	var body struct {
+		ID       int    `json:"id,omitempty"`
+		Name     string `json:"name"`
+		MinSkill int    `json:"minskill,omitempty"`
+		MaxSkill int    `json:"maxskill,omitempty"`
+		Region   string `json:"region,omitempty"`
+		Backup   string `json:"backup"`
+		Reason   string `json:"reason"`
+	}
+	body.ID = in.ID
+	body.Name = in.Name
+	body.MinSkill = in.MinSkill
+	body.MaxSkill = in.MaxSkill
+	body.Region = in.Region
+	body.Backup = in.Backup
+	body.Reason = in.Reason
+	err := c.do(ctx, "POST", path, nil, &body, nil, false, http.StatusOK, &env)
+	return res, err
+}
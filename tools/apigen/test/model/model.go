@@ -0,0 +1,55 @@
+package model
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"strings"
+	"time"
+)
+
+// Skill - рейтинг игрока
+type Skill float64
+
+// Validate вызывается сгенерированным кодом после остальных проверок
+func (s Skill) Validate() error {
+	if math.Mod(float64(s), 0.5) != 0 {
+		return errors.New("must be a multiple of 0.5")
+	}
+	return nil
+}
+
+// Region - код региона, разбирается из текста через UnmarshalText
+type Region string
+
+func (r *Region) UnmarshalText(b []byte) error {
+	switch s := strings.ToLower(string(b)); s {
+	case "eu", "us", "asia":
+		*r = Region(s)
+		return nil
+	}
+	return fmt.Errorf("unknown region %q", b)
+}
+
+// MarshalText нужен клиенту, чтобы передать регион текстом
+func (r Region) MarshalText() ([]byte, error) {
+	return []byte(r), nil
+}
+
+// параметры метода могут быть объявлены в другом пакете,
+// поля - иметь именованные типы, в том числе из других пакетов
+type RateParams struct {
+	Login  string        `apivalidator:"required"`
+	Skill  Skill         `apivalidator:">=0,<=10,default=5"`
+	Region Region        `apivalidator:"enum=eu|EU|us,default=eu"`
+	Wait   time.Duration `apivalidator:"min=0,max=1h"`
+	Since  time.Time
+}
+
+type Rating struct {
+	Login  string        `json:"login"`
+	Skill  Skill         `json:"skill"`
+	Region Region        `json:"region"`
+	Wait   time.Duration `json:"wait"`
+	Since  time.Time     `json:"since"`
+}